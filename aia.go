@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// maxAIAHops bounds the intermediates fetched for a chain, as a chain
+	// rarely has more than two
+	maxAIAHops = 4
+	// maxAIASize bounds the response of a CA Issuers URL
+	maxAIASize = 1 << 20
+)
+
+// aiaFetcher completes chains whose server sends too few intermediates with
+// those published at the CA Issuers URLs of the Authority Information Access
+// extension (RFC 5280 section 4.2.2.1), as browsers do. The certs fetched are
+// kept by URL for the rest of the run, since many hosts share an issuer.
+type aiaFetcher struct {
+	client *http.Client
+	mu     sync.Mutex
+	certs  map[string][]*x509.Certificate
+}
+
+func newAIAFetcher(timeout time.Duration) *aiaFetcher {
+	return &aiaFetcher{
+		client: &http.Client{Timeout: timeout},
+		certs:  make(map[string][]*x509.Certificate),
+	}
+}
+
+// complete verifies chain against roots, or the system roots when nil, and
+// returns the intermediates fetched to complete it, which are none when the
+// chain served is complete. It returns the error of the verification when
+// the chain cannot be completed.
+func (f *aiaFetcher) complete(ctx context.Context, chain []*x509.Certificate, roots *x509.CertPool, now time.Time) ([]*x509.Certificate, error) {
+	leaf := chain[0]
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   now,
+	}
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := leaf.Verify(opts)
+	var unknownAuthority x509.UnknownAuthorityError
+	if err == nil || !errors.As(err, &unknownAuthority) {
+		return nil, err
+	}
+	var fetched []*x509.Certificate
+	known := append([]*x509.Certificate(nil), chain[1:]...)
+	cur := leaf
+	for range len(chain) + maxAIAHops {
+		// the issuer may be served while one above it is missing
+		if i := findIssuer(cur, known); i != nil {
+			if isSelfSigned(i) {
+				break
+			}
+			cur = i
+			continue
+		}
+		certs, fetchErr := f.fetch(ctx, cur)
+		if fetchErr != nil {
+			log.Debug("cannot fetch issuer", "subject", cur.Subject.String(), "err", fetchErr)
+			break
+		}
+		issuer := findIssuer(cur, certs)
+		if issuer == nil {
+			break
+		}
+		fetched = append(fetched, issuer)
+		known = append(known, issuer)
+		opts.Intermediates.AddCert(issuer)
+		if _, err := leaf.Verify(opts); err == nil {
+			return fetched, nil
+		}
+		cur = issuer
+	}
+	return nil, err
+}
+
+// findIssuer returns the cert of certs that signed cert, if any.
+func findIssuer(cert *x509.Certificate, certs []*x509.Certificate) *x509.Certificate {
+	for _, c := range certs {
+		if c != cert && cert.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// fetch returns the certs published at the CA Issuers URLs of cert, in DER
+// or PEM.
+func (f *aiaFetcher) fetch(ctx context.Context, cert *x509.Certificate) ([]*x509.Certificate, error) {
+	if len(cert.IssuingCertificateURL) == 0 {
+		return nil, errors.New("no CA Issuers URL")
+	}
+	var errs []error
+	for _, u := range cert.IssuingCertificateURL {
+		certs, err := f.get(ctx, u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return certs, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (f *aiaFetcher) get(ctx context.Context, u string) ([]*x509.Certificate, error) {
+	f.mu.Lock()
+	certs, ok := f.certs[u]
+	f.mu.Unlock()
+	if ok {
+		return certs, nil
+	}
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return nil, fmt.Errorf("unsupported CA Issuers URL %q", u)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %q: %s", u, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxAIASize))
+	if err != nil {
+		return nil, err
+	}
+	certs, err = x509.ParseCertificates(b)
+	if err != nil {
+		if certs, err = parsePEMChain(b); err != nil {
+			return nil, fmt.Errorf("cannot parse the cert at %q: %w", u, err)
+		}
+	}
+	f.mu.Lock()
+	f.certs[u] = certs
+	f.mu.Unlock()
+	return certs, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_aiaFetcher_complete(t *testing.T) {
+	tc := newTestChain(t)
+	orphan := *tc.leaf
+	orphan.IssuingCertificateURL = nil
+	tests := []struct {
+		name    string
+		chain   []*x509.Certificate
+		roots   *x509.CertPool
+		want    []*x509.Certificate
+		wantErr bool
+	}{
+		{
+			name:  "complete",
+			chain: []*x509.Certificate{tc.leaf, tc.intermediate},
+			roots: tc.roots,
+			want:  nil,
+		},
+		{
+			name:  "leaf only",
+			chain: []*x509.Certificate{tc.leaf},
+			roots: tc.roots,
+			want:  []*x509.Certificate{tc.intermediate},
+		},
+		{
+			name:    "no CA Issuers URL",
+			chain:   []*x509.Certificate{&orphan},
+			roots:   tc.roots,
+			wantErr: true,
+		},
+		{
+			name:    "untrusted root",
+			chain:   []*x509.Certificate{tc.leaf},
+			roots:   x509.NewCertPool(),
+			wantErr: true,
+		},
+	}
+	f := newAIAFetcher(5 * time.Second)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.complete(context.Background(), tt.chain, tt.roots, time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	// the intermediate is fetched once for the run
+	if got := tc.fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
+
+func Test_getCertList_chaseAIA(t *testing.T) {
+	tc := newTestChain(t)
+	addr := serveTestTLS(t, tc.leafKey, tc.leaf)
+	complete := false
+	tests := []struct {
+		name         string
+		aia          *aiaFetcher
+		wantComplete *bool
+		wantFetched  []string
+		wantStatus   string
+	}{
+		{
+			name:         "leaf only",
+			aia:          nil,
+			wantComplete: nil,
+			wantFetched:  nil,
+			wantStatus:   verificationFailed,
+		},
+		{
+			name:         "chased",
+			aia:          newAIAFetcher(5 * time.Second),
+			wantComplete: &complete,
+			wantFetched:  []string{"CN=aia test intermediate"},
+			wantStatus:   verificationVerified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				location: time.UTC,
+				roots:    tc.roots,
+				aia:      tt.aia,
+			}
+			infos, err := getCertList(context.Background(), []string{addr}, opts)
+			if err != nil {
+				t.Fatalf("getCertList() error = %v", err)
+			}
+			info := infos[0]
+			if diff := cmp.Diff(tt.wantComplete, info.ChainComplete); diff != "" {
+				t.Errorf("ChainComplete mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantFetched, info.FetchedIntermediates); diff != "" {
+				t.Errorf("FetchedIntermediates mismatch (-want +got):\n%s", diff)
+			}
+			if info.VerificationStatus != tt.wantStatus {
+				t.Errorf("VerificationStatus = %s, want %s", info.VerificationStatus, tt.wantStatus)
+			}
+		})
+	}
+}
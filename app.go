@@ -1,7 +1,6 @@
 package main
 
 import (
-	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -9,6 +8,7 @@ import (
 	"os"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -21,17 +21,115 @@ const (
 	canonicalName = "TLC3"
 )
 
+var logLevels = []string{
+	log.DebugLevel.String(),
+	log.InfoLevel.String(),
+	log.WarnLevel.String(),
+	log.ErrorLevel.String(),
+}
+
 type app struct {
 	*cli.App
-	completion *cli.StringFlag
-	loglevel   *cli.StringFlag
-	domain     *cli.StringSliceFlag
-	file       *cli.PathFlag
-	output     *cli.StringFlag
-	timeout    *cli.DurationFlag
-	insecure   *cli.BoolFlag
-	noTimeInfo *cli.BoolFlag
-	timeZone   *cli.StringFlag
+	completion   *cli.StringFlag
+	loglevel     *cli.StringFlag
+	domain       *cli.StringSliceFlag
+	file         *cli.PathFlag
+	output       *cli.StringFlag
+	timeout      *cli.DurationFlag
+	insecure     *cli.BoolFlag
+	noTimeInfo   *cli.BoolFlag
+	timeZone     *cli.StringFlag
+	serverName   *cli.StringFlag
+	adaptive     *cli.BoolFlag
+	unique       *cli.BoolFlag
+	starttls     *cli.StringFlag
+	selftest     *cli.BoolFlag
+	chaosDNS     *cli.Float64Flag
+	chaosDelay   *cli.DurationFlag
+	chaosChain   *cli.Float64Flag
+	top          *cli.IntFlag
+	locale       *cli.StringFlag
+	wideAmbig    *cli.BoolFlag
+	nameserver   *cli.StringFlag
+	doh          *cli.StringFlag
+	dot          *cli.StringFlag
+	dnsNegTTL    *cli.DurationFlag
+	checkpoint   *cli.PathFlag
+	resume       *cli.BoolFlag
+	probe        *cli.BoolFlag
+	weights      *cli.StringSliceFlag
+	smime        *cli.BoolFlag
+	warnDays     *cli.IntFlag
+	critDays     *cli.IntFlag
+	preset       *cli.StringFlag
+	clientCert   *cli.PathFlag
+	clientKey    *cli.PathFlag
+	resolve      *cli.StringSliceFlag
+	allIPs       *cli.BoolFlag
+	rate         *cli.StringFlag
+	keepGoing    *cli.BoolFlag
+	roots        *cli.StringFlag
+	rootsTTL     *cli.DurationFlag
+	caFile       *cli.PathFlag
+	caPath       *cli.PathFlag
+	trustStores  *cli.StringSliceFlag
+	grpc         *cli.BoolFlag
+	grpcHealth   *cli.BoolFlag
+	grpcSvc      *cli.StringFlag
+	jitter       *cli.DurationFlag
+	spread       *cli.DurationFlag
+	redact       *cli.StringSliceFlag
+	redactMode   *cli.StringFlag
+	watch        *cli.DurationFlag
+	watchChanges *cli.BoolFlag
+	saveState    *cli.PathFlag
+	diffState    *cli.PathFlag
+	history      *cli.PathFlag
+	notifyURL    *cli.StringFlag
+	notifyFmt    *cli.StringFlag
+	notifyTmpl   *cli.PathFlag
+	checkSANs    *cli.BoolFlag
+	checkCAA     *cli.BoolFlag
+	chaseAIA     *cli.BoolFlag
+	expectCert   *cli.PathFlag
+	fallback     *cli.StringSliceFlag
+	preHook      *cli.StringFlag
+	postHook     *cli.StringFlag
+	trustMaxAge  *cli.IntFlag
+	pac          *cli.StringFlag
+	fast         *cli.BoolFlag
+	cipherSuites *cli.StringSliceFlag
+	curves       *cli.StringSliceFlag
+	dumpPEM      *cli.PathFlag
+	dumpChain    *cli.BoolFlag
+	outputFile   *cli.PathFlag
+	cmdbMapping  *cli.PathFlag
+	color        *cli.StringFlag
+	columns      *cli.StringSliceFlag
+	computed     *cli.PathFlag
+	policy       *cli.PathFlag
+	sortKey      *cli.StringFlag
+	reverse      *cli.BoolFlag
+	cache        *cli.StringFlag
+	cacheTTL     *cli.DurationFlag
+	thrRetries   *cli.IntFlag
+	thrBackoff   *cli.DurationFlag
+	byIssuer     *cli.BoolFlag
+	config       *cli.PathFlag
+	groupBySrc   *cli.BoolFlag
+	cidr         *cli.StringSliceFlag
+	port         *cli.StringFlag
+	connTimeout  *cli.DurationFlag
+	concurrency  *cli.IntFlag
+	kubernetes   *cli.BoolFlag
+	kubeContext  *cli.StringFlag
+	kubeNS       *cli.StringFlag
+	kubeSecrets  *cli.BoolFlag
+	aws          *cli.StringSliceFlag
+	awsProfile   *cli.StringFlag
+	awsRegion    *cli.StringSliceFlag
+	certFile     *cli.StringSliceFlag
+	password     *cli.StringFlag
 }
 
 func CLI(ctx context.Context) {
@@ -40,6 +138,10 @@ func CLI(ctx context.Context) {
 	app := newApp(os.Stdout)
 	if err := app.RunContext(ctx, os.Args); err != nil {
 		log.Error(err)
+		var exitErr cli.ExitCoder
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -51,10 +153,16 @@ func newApp(w io.Writer) *app {
 		Aliases: []string{"c"},
 		Usage:   fmt.Sprintf("completion scripts: %s", pipeJoin(shells)),
 	}
+	a.config = &cli.PathFlag{
+		Name:        "config",
+		Usage:       "path to a YAML file of default values keyed by the long names of the flags, and of the targets checked when no domain or file is given",
+		DefaultText: "~/.config/tlc3/config.yaml",
+		EnvVars:     []string{canonicalName + "_CONFIG"},
+	}
 	a.loglevel = &cli.StringFlag{
 		Name:    "log-level",
 		Aliases: []string{"l"},
-		Usage:   "log levels: debug|info|warn|error",
+		Usage:   fmt.Sprintf("log levels: %s", pipeJoin(logLevels)),
 		Value:   log.InfoLevel.String(),
 		EnvVars: []string{canonicalName + "_LOGLEVEL"},
 	}
@@ -62,11 +170,13 @@ func newApp(w io.Writer) *app {
 		Name:    "domain",
 		Aliases: []string{"d"},
 		Usage:   "domain:port separated by commas",
+		EnvVars: []string{canonicalName + "_DOMAIN"},
 	}
 	a.file = &cli.PathFlag{
 		Name:    "file",
 		Aliases: []string{"f"},
-		Usage:   "path to newline-delimited list of domains",
+		Usage:   "path to newline-delimited list of domains, or to a CSV/TSV with options per host",
+		EnvVars: []string{canonicalName + "_FILE"},
 	}
 	a.output = &cli.StringFlag{
 		Name:    "output",
@@ -87,12 +197,14 @@ func newApp(w io.Writer) *app {
 		Aliases: []string{"i"},
 		Usage:   "skip verification of the cert chain and host name",
 		Value:   false,
+		EnvVars: []string{canonicalName + "_INSECURE"},
 	}
 	a.noTimeInfo = &cli.BoolFlag{
 		Name:    "no-timeinfo",
 		Aliases: []string{"n"},
 		Usage:   "hide fields related to the current time in table output",
 		Value:   false,
+		EnvVars: []string{canonicalName + "_NO_TIMEINFO"},
 	}
 	a.timeZone = &cli.StringFlag{
 		Name:    "timezone",
@@ -101,6 +213,497 @@ func newApp(w io.Writer) *app {
 		Value:   "Local",
 		EnvVars: []string{canonicalName + "_TIMEZONE"},
 	}
+	a.serverName = &cli.StringFlag{
+		Name:    "servername",
+		Aliases: []string{"s"},
+		Usage:   "server name for SNI and verification instead of the target host",
+		EnvVars: []string{canonicalName + "_SERVERNAME"},
+	}
+	a.adaptive = &cli.BoolFlag{
+		Name:    "adaptive-timeout",
+		Aliases: []string{"a"},
+		Usage:   "scale handshake timeout per host from connect RTT, bounded by timeout",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_ADAPTIVE_TIMEOUT"},
+	}
+	a.unique = &cli.BoolFlag{
+		Name:    "unique-certs",
+		Aliases: []string{"u"},
+		Usage:   "collapse output to one entry per distinct cert listing all hosts serving it",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_UNIQUE_CERTS"},
+	}
+	a.starttls = &cli.StringFlag{
+		Name:    "starttls",
+		Usage:   fmt.Sprintf("upgrade to TLS via protocol negotiation: %s", pipeJoin(preambleNames())),
+		EnvVars: []string{canonicalName + "_STARTTLS"},
+	}
+	a.selftest = &cli.BoolFlag{
+		Name:  "selftest",
+		Usage: fmt.Sprintf("scan a simulated fleet of %d local hosts and report throughput", selftestHosts),
+		Value: false,
+	}
+	a.top = &cli.IntFlag{
+		Name:    "top",
+		Usage:   "limit output to the N soonest-expiring certs",
+		EnvVars: []string{canonicalName + "_TOP"},
+	}
+	a.locale = &cli.StringFlag{
+		Name:    "locale",
+		Usage:   fmt.Sprintf("locale for dates and numbers in table output: %s", pipeJoin(localeNames())),
+		EnvVars: []string{canonicalName + "_LOCALE"},
+	}
+	a.wideAmbig = &cli.BoolFlag{
+		Name:    "wide-ambiguous",
+		Usage:   "count ambiguous-width characters as two columns in table output",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_WIDE_AMBIGUOUS"},
+	}
+	a.nameserver = &cli.StringFlag{
+		Name:    "nameserver",
+		Usage:   "query this DNS server directly so record TTLs bound IP caching",
+		EnvVars: []string{canonicalName + "_NAMESERVER"},
+	}
+	a.doh = &cli.StringFlag{
+		Name:    "doh",
+		Usage:   "resolve hosts with this DNS over HTTPS URL, e.g. https://1.1.1.1/dns-query, where plain DNS is intercepted",
+		EnvVars: []string{canonicalName + "_DOH"},
+	}
+	a.dot = &cli.StringFlag{
+		Name:    "dot",
+		Usage:   "resolve hosts with this DNS over TLS server, on port 853 unless given, where plain DNS is intercepted",
+		EnvVars: []string{canonicalName + "_DOT"},
+	}
+	a.dnsNegTTL = &cli.DurationFlag{
+		Name:    "dns-negative-ttl",
+		Usage:   "how long a host that does not resolve is cached when the answer carries no TTL",
+		Value:   defaultDNSNegativeTTL,
+		EnvVars: []string{canonicalName + "_DNS_NEGATIVE_TTL"},
+	}
+	a.checkpoint = &cli.PathFlag{
+		Name:    "checkpoint",
+		Usage:   "record completed hosts to this file, removed once the run completes",
+		EnvVars: []string{canonicalName + "_CHECKPOINT"},
+	}
+	a.resume = &cli.BoolFlag{
+		Name:    "resume",
+		Usage:   "skip hosts already recorded in the checkpoint file",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_RESUME"},
+	}
+	a.probe = &cli.BoolFlag{
+		Name:    "probe-versions",
+		Usage:   "try a separate handshake at each of TLS 1.0 to 1.3 and report the accepted versions",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_PROBE_VERSIONS"},
+	}
+	a.weights = &cli.StringSliceFlag{
+		Name:    "label-weights",
+		Usage:   "scheduling weights for labels in the domain list as label=weight separated by commas",
+		EnvVars: []string{canonicalName + "_LABEL_WEIGHTS"},
+	}
+	a.smime = &cli.BoolFlag{
+		Name:    "smime",
+		Usage:   "report whether the cert is also valid for S/MIME, e.g. for mail submission endpoints",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_SMIME"},
+	}
+	a.warnDays = &cli.IntFlag{
+		Name:    "warn-days",
+		Usage:   "exit with 1 if any cert expires within N days",
+		EnvVars: []string{canonicalName + "_WARN_DAYS"},
+	}
+	a.critDays = &cli.IntFlag{
+		Name:    "crit-days",
+		Usage:   "exit with 2 if any cert expires within N days",
+		EnvVars: []string{canonicalName + "_CRIT_DAYS"},
+	}
+	a.preset = &cli.StringFlag{
+		Name:    "preset",
+		Usage:   fmt.Sprintf("default port and protocol of a well-known endpoint kind: %s", pipeJoin(presetNames())),
+		EnvVars: []string{canonicalName + "_PRESET"},
+	}
+	a.clientCert = &cli.PathFlag{
+		Name:    "client-cert",
+		Usage:   "path to PEM cert presented to servers that require client authentication",
+		EnvVars: []string{canonicalName + "_CLIENT_CERT"},
+	}
+	a.clientKey = &cli.PathFlag{
+		Name:    "client-key",
+		Usage:   "path to PEM private key of the client cert",
+		EnvVars: []string{canonicalName + "_CLIENT_KEY"},
+	}
+	a.resolve = &cli.StringSliceFlag{
+		Name:    "resolve",
+		Usage:   "connect to addr for host:port instead of the address it resolves to, keeping the name for SNI and verification, as host:port:addr separated by commas",
+		EnvVars: []string{canonicalName + "_RESOLVE"},
+	}
+	a.allIPs = &cli.BoolFlag{
+		Name:    "all-ips",
+		Usage:   "check each address a host resolves to, keeping the name for SNI and verification, with a result per address",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_ALL_IPS"},
+	}
+	a.rate = &cli.StringFlag{
+		Name:    "rate",
+		Usage:   "limit new connections to N per s, m or h across the run whatever the concurrency, e.g. 10/s, spacing them evenly",
+		EnvVars: []string{canonicalName + "_RATE"},
+	}
+	a.keepGoing = &cli.BoolFlag{
+		Name:    "keep-going",
+		Usage:   "report the hosts that cannot be checked with an Error field along with the others, exiting with 2 if any",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_KEEP_GOING"},
+	}
+	a.roots = &cli.StringFlag{
+		Name:    "roots",
+		Usage:   fmt.Sprintf("roots to verify against: %s; mozilla downloads the Mozilla CA bundle from curl.se, so that results do not depend on the roots the OS ships", pipeJoin(rootSources)),
+		Value:   systemStore,
+		EnvVars: []string{canonicalName + "_ROOTS"},
+	}
+	a.rootsTTL = &cli.DurationFlag{
+		Name:    "roots-ttl",
+		Usage:   "how long the downloaded Mozilla CA bundle is reused before it is downloaded again",
+		Value:   defaultRootsTTL,
+		EnvVars: []string{canonicalName + "_ROOTS_TTL"},
+	}
+	a.caFile = &cli.PathFlag{
+		Name:      "ca-file",
+		Usage:     "path to a PEM bundle of CA certs to verify against in addition to the roots, e.g. of an internal CA",
+		EnvVars:   []string{canonicalName + "_CA_FILE"},
+		TakesFile: true,
+	}
+	a.caPath = &cli.PathFlag{
+		Name:    "ca-path",
+		Usage:   "path to a directory of PEM files of CA certs to verify against in addition to the roots",
+		EnvVars: []string{canonicalName + "_CA_PATH"},
+	}
+	a.trustStores = &cli.StringSliceFlag{
+		Name:    "trust-store",
+		Usage:   "trust stores to verify each chain against as well, reporting the trust status against each as TrustStores, as name=path to a PEM bundle or directory separated by commas, or system for the system roots",
+		EnvVars: []string{canonicalName + "_TRUST_STORE"},
+	}
+	a.grpc = &cli.BoolFlag{
+		Name:    "grpc",
+		Usage:   "offer h2 via ALPN as gRPC clients do and report the negotiated protocol",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_GRPC"},
+	}
+	a.grpcHealth = &cli.BoolFlag{
+		Name:    "grpc-health",
+		Usage:   "call grpc.health.v1.Health/Check and report the serving status",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_GRPC_HEALTH"},
+	}
+	a.grpcSvc = &cli.StringFlag{
+		Name:    "grpc-service",
+		Usage:   "service name for the gRPC health check instead of the whole server",
+		EnvVars: []string{canonicalName + "_GRPC_SERVICE"},
+	}
+	a.jitter = &cli.DurationFlag{
+		Name:    "jitter",
+		Usage:   "wait a random time from zero up to this value before checking, e.g. when started by cron",
+		EnvVars: []string{canonicalName + "_JITTER"},
+	}
+	a.spread = &cli.DurationFlag{
+		Name:    "spread",
+		Usage:   "start the checks of the hosts evenly across this window instead of all at once",
+		EnvVars: []string{canonicalName + "_SPREAD"},
+	}
+	a.redact = &cli.StringSliceFlag{
+		Name:    "redact",
+		Usage:   fmt.Sprintf("hide fields in the output separated by commas: %s", pipeJoin(redactFields)),
+		EnvVars: []string{canonicalName + "_REDACT"},
+	}
+	a.redactMode = &cli.StringFlag{
+		Name:    "redact-mode",
+		Usage:   fmt.Sprintf("how redacted fields are hidden: %s", pipeJoin(redactModes)),
+		Value:   redactHash,
+		EnvVars: []string{canonicalName + "_REDACT_MODE"},
+	}
+	a.watch = &cli.DurationFlag{
+		Name:    "watch",
+		Usage:   "keep running and check the hosts again at this interval",
+		EnvVars: []string{canonicalName + "_WATCH"},
+	}
+	a.watchChanges = &cli.BoolFlag{
+		Name:    "watch-changes",
+		Usage:   "print only the certs that changed since the previous check in watch mode",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_WATCH_CHANGES"},
+	}
+	a.saveState = &cli.PathFlag{
+		Name:    "save-state",
+		Usage:   "path to save the results to as a snapshot in json output, for --diff-state on the next run",
+		EnvVars: []string{canonicalName + "_SAVE_STATE"},
+	}
+	a.diffState = &cli.PathFlag{
+		Name:    "diff-state",
+		Usage:   "path to the snapshot of a previous run to report the changes since then as Changes, such as a new cert, issuer or SANs or an earlier expiry",
+		EnvVars: []string{canonicalName + "_DIFF_STATE"},
+	}
+	a.history = &cli.PathFlag{
+		Name:    "history",
+		Usage:   "path to a SQLite database to append the results of every run to, for the history command; requires sqlite3",
+		EnvVars: []string{canonicalName + "_HISTORY"},
+	}
+	a.notifyURL = &cli.StringFlag{
+		Name:    "notify-webhook",
+		Usage:   "post a JSON payload to this URL when a cert falls below the warning days or is replaced with a new serial or issuer",
+		EnvVars: []string{canonicalName + "_NOTIFY_WEBHOOK"},
+	}
+	a.notifyFmt = &cli.StringFlag{
+		Name:    "notify-format",
+		Usage:   fmt.Sprintf("payload format of the webhook: %s", pipeJoin(formatterNames())),
+		Value:   formatterJSON,
+		EnvVars: []string{canonicalName + "_NOTIFY_FORMAT"},
+	}
+	a.notifyTmpl = &cli.PathFlag{
+		Name:    "notify-template",
+		Usage:   "path to a Go template that renders the webhook payload instead of the default JSON",
+		EnvVars: []string{canonicalName + "_NOTIFY_TEMPLATE"},
+	}
+	a.checkSANs = &cli.BoolFlag{
+		Name:    "check-sans",
+		Usage:   "look up every DNS name in the SANs and report those that no longer resolve as StaleSANs",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_CHECK_SANS"},
+	}
+	a.checkCAA = &cli.BoolFlag{
+		Name:    "check-caa",
+		Usage:   "look up the CAA records of each name of the cert and report whether they authorize its issuer as CAAStatus, and for each name as CAANames",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_CHECK_CAA"},
+	}
+	a.chaseAIA = &cli.BoolFlag{
+		Name:    "chase-aia",
+		Usage:   "fetch the intermediates a server does not send from the CA Issuers URLs of the certs, reporting ChainComplete and FetchedIntermediates",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_CHASE_AIA"},
+	}
+	a.expectCert = &cli.PathFlag{
+		Name:    "expect-cert",
+		Usage:   "path to the cert in PEM or DER hosts are expected to serve, compared by fingerprint and reported as Match, unless given their own in an expect column of the list",
+		EnvVars: []string{canonicalName + "_EXPECT_CERT"},
+	}
+	a.fallback = &cli.StringSliceFlag{
+		Name:    "fallback-ports",
+		Usage:   "ports tried in order when the connection to the port of a host is refused, separated by commas",
+		EnvVars: []string{canonicalName + "_FALLBACK_PORTS"},
+	}
+	a.preHook = &cli.StringFlag{
+		Name:    "pre-hook",
+		Usage:   "shell command run before checking with the hosts as a JSON array on stdin; the check is aborted if it fails",
+		EnvVars: []string{canonicalName + "_PRE_HOOK"},
+	}
+	a.postHook = &cli.StringFlag{
+		Name:    "post-hook",
+		Usage:   "shell command run after each check with the result in JSON on stdin",
+		EnvVars: []string{canonicalName + "_POST_HOOK"},
+	}
+	a.trustMaxAge = &cli.IntFlag{
+		Name:    "trust-store-max-age",
+		Usage:   "report the age of the system trust store and warn if it was updated more than N days ago",
+		EnvVars: []string{canonicalName + "_TRUST_STORE_MAX_AGE"},
+	}
+	a.pac = &cli.StringFlag{
+		Name:    "pac",
+		Usage:   "path or http(s) URL of a proxy auto-config script that chooses the proxy for each host",
+		EnvVars: []string{canonicalName + "_PAC"},
+	}
+	a.fast = &cli.BoolFlag{
+		Name:    "fast",
+		Usage:   "abort each handshake once the cert is received instead of completing it, for large scans",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_FAST"},
+	}
+	a.cipherSuites = &cli.StringSliceFlag{
+		Name:    "cipher-suites",
+		Usage:   "cipher suites offered instead of the defaults, separated by commas, e.g. to emulate a legacy client; TLS 1.3 is offered only if a TLS 1.3 suite is listed",
+		EnvVars: []string{canonicalName + "_CIPHER_SUITES"},
+	}
+	a.curves = &cli.StringSliceFlag{
+		Name:    "curves",
+		Usage:   fmt.Sprintf("curves offered instead of the defaults, separated by commas: %s", pipeJoin(curveNames())),
+		EnvVars: []string{canonicalName + "_CURVES"},
+	}
+	a.dumpPEM = &cli.PathFlag{
+		Name:    "dump-pem",
+		Usage:   "directory to write the cert of each host to as a PEM file named after the host and port",
+		EnvVars: []string{canonicalName + "_DUMP_PEM"},
+	}
+	a.dumpChain = &cli.BoolFlag{
+		Name:    "dump-chain",
+		Usage:   "write the intermediates after the leaf cert in the PEM files",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_DUMP_CHAIN"},
+	}
+	a.outputFile = &cli.PathFlag{
+		Name:    "output-file",
+		Aliases: []string{"O"},
+		Usage:   "write the output to this file instead of stdout, replacing it atomically on success; required for xlsx",
+		EnvVars: []string{canonicalName + "_OUTPUT_FILE"},
+	}
+	a.cmdbMapping = &cli.PathFlag{
+		Name:    "cmdb-mapping",
+		Usage:   "path to a file of \"column = Go template\" lines that maps the fields of a cert to the columns of cmdb output",
+		EnvVars: []string{canonicalName + "_CMDB_MAPPING"},
+	}
+	a.color = &cli.StringFlag{
+		Name:    "color",
+		Usage:   fmt.Sprintf("color the rows of table output by days left: %s", pipeJoin(colorModes)),
+		Value:   colorAuto,
+		EnvVars: []string{canonicalName + "_COLOR"},
+	}
+	a.columns = &cli.StringSliceFlag{
+		Name:    "columns",
+		Usage:   "fields to show in the output in this order, separated by commas, for json|table|markdown|backlog|ndjson|xlsx output",
+		EnvVars: []string{canonicalName + "_COLUMNS"},
+	}
+	a.computed = &cli.PathFlag{
+		Name:    "computed-fields",
+		Usage:   "path to a file of \"Name = expression\" lines that add fields computed from the others, e.g. Urgent = DaysLeft < 14",
+		EnvVars: []string{canonicalName + "_COMPUTED_FIELDS"},
+	}
+	a.policy = &cli.PathFlag{
+		Name:      "policy",
+		Usage:     "path to a policy file of expectations per host pattern, e.g. issuer, sans, min_days_left, min_tls_version and key_type, exiting with 2 on violations",
+		TakesFile: true,
+		EnvVars:   []string{canonicalName + "_POLICY"},
+	}
+	a.sortKey = &cli.StringFlag{
+		Name:    "sort",
+		Usage:   fmt.Sprintf("order the results by this key: %s", pipeJoin(sortKeys)),
+		Value:   sortDomain,
+		EnvVars: []string{canonicalName + "_SORT"},
+	}
+	a.reverse = &cli.BoolFlag{
+		Name:    "reverse",
+		Usage:   "reverse the order of the results",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_REVERSE"},
+	}
+	a.byIssuer = &cli.BoolFlag{
+		Name:    "by-issuer",
+		Usage:   "split histogram output into a section per issuer",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_BY_ISSUER"},
+	}
+	a.groupBySrc = &cli.BoolFlag{
+		Name:    "group-by-source",
+		Usage:   "group the output into a section per input source, i.e. domain or the path of the list, with subtotals, for json|table|markdown|backlog|text output",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_GROUP_BY_SOURCE"},
+	}
+	a.cidr = &cli.StringSliceFlag{
+		Name:    "cidr",
+		Usage:   "CIDR ranges to sweep for TLS endpoints separated by commas, skipping addresses that do not answer and logging those without TLS",
+		EnvVars: []string{canonicalName + "_CIDR"},
+	}
+	a.port = &cli.StringFlag{
+		Name:    "port",
+		Usage:   "port swept in the CIDR ranges",
+		Value:   defaultSweepPort,
+		EnvVars: []string{canonicalName + "_PORT"},
+	}
+	a.connTimeout = &cli.DurationFlag{
+		Name:    "connect-timeout",
+		Usage:   "how long an address in a CIDR range is given to accept the connection before it is skipped",
+		Value:   defaultSweepConnectTimeout,
+		EnvVars: []string{canonicalName + "_CONNECT_TIMEOUT"},
+	}
+	a.concurrency = &cli.IntFlag{
+		Name:        "concurrency",
+		Usage:       "maximum number of hosts checked at once",
+		DefaultText: "number of CPUs",
+		EnvVars:     []string{canonicalName + "_CONCURRENCY"},
+	}
+	a.kubernetes = &cli.BoolFlag{
+		Name:    "from-kubernetes",
+		Usage:   "check the hosts of the Ingresses and Gateways in the cluster of the current kubeconfig context, listed through kubectl",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_FROM_KUBERNETES"},
+	}
+	a.kubeContext = &cli.StringFlag{
+		Name:    "kube-context",
+		Usage:   "kubeconfig context of the cluster instead of the current one",
+		EnvVars: []string{canonicalName + "_KUBE_CONTEXT"},
+	}
+	a.kubeNS = &cli.StringFlag{
+		Name:    "kube-namespace",
+		Usage:   "namespace to discover hosts in instead of all namespaces",
+		EnvVars: []string{canonicalName + "_KUBE_NAMESPACE"},
+	}
+	a.kubeSecrets = &cli.BoolFlag{
+		Name:    "kube-secrets",
+		Usage:   "also report the certs of the TLS secrets the Ingresses and Gateways use, with the secret as Secret",
+		Value:   false,
+		EnvVars: []string{canonicalName + "_KUBE_SECRETS"},
+	}
+	a.aws = &cli.StringSliceFlag{
+		Name:    "from-aws",
+		Usage:   fmt.Sprintf("report the certs in the AWS account of the current profile from services separated by commas, listed through the AWS CLI: %s", strings.Join(awsServices, "|")),
+		EnvVars: []string{canonicalName + "_FROM_AWS"},
+	}
+	a.awsProfile = &cli.StringFlag{
+		Name:    "aws-profile",
+		Usage:   "AWS CLI profile of the account instead of the current one",
+		EnvVars: []string{canonicalName + "_AWS_PROFILE"},
+	}
+	a.awsRegion = &cli.StringSliceFlag{
+		Name:    "aws-region",
+		Usage:   "AWS regions to list the certs in separated by commas instead of the region of the profile",
+		EnvVars: []string{canonicalName + "_AWS_REGION"},
+	}
+	a.certFile = &cli.StringSliceFlag{
+		Name:      "cert-file",
+		Usage:     "paths to local cert files in PEM or DER, PKCS#12 files (.p12, .pfx) or Java keystores separated by commas, reported as if served with the path as DomainName",
+		TakesFile: true,
+		EnvVars:   []string{canonicalName + "_CERT_FILE"},
+	}
+	a.password = &cli.StringFlag{
+		Name:    "password",
+		Usage:   "password of the PKCS#12 files and Java keystores, prompted for when a PKCS#12 file needs one",
+		EnvVars: []string{canonicalName + "_PASSWORD"},
+	}
+	a.cache = &cli.StringFlag{
+		Name:    "cache",
+		Usage:   "share results and DNS answers with other instances through redis://, rediss:// or memcached://, so that each host is checked once across them",
+		EnvVars: []string{canonicalName + "_CACHE"},
+	}
+	a.cacheTTL = &cli.DurationFlag{
+		Name:    "cache-ttl",
+		Usage:   "how long a result is reused from the shared cache",
+		Value:   defaultSharedTTL,
+		EnvVars: []string{canonicalName + "_CACHE_TTL"},
+	}
+	a.thrRetries = &cli.IntFlag{
+		Name:    "throttle-retries",
+		Usage:   "times a handshake is retried when the host explicitly refuses it as throttled: a proxy 429, an SMTP 421 or too many connections",
+		Value:   defaultThrottleRetries,
+		EnvVars: []string{canonicalName + "_THROTTLE_RETRIES"},
+	}
+	a.thrBackoff = &cli.DurationFlag{
+		Name:    "throttle-backoff",
+		Usage:   "wait before the first retry of a throttled handshake, doubled for each next one",
+		Value:   defaultThrottleBackoff,
+		EnvVars: []string{canonicalName + "_THROTTLE_BACKOFF"},
+	}
+	a.chaosDNS = &cli.Float64Flag{
+		Name:   "chaos-dns-failure-rate",
+		Usage:  "rate of injected DNS failures from 0 to 1",
+		Hidden: true,
+	}
+	a.chaosDelay = &cli.DurationFlag{
+		Name:   "chaos-handshake-delay",
+		Usage:  "delay injected before every TLS handshake",
+		Hidden: true,
+	}
+	a.chaosChain = &cli.Float64Flag{
+		Name:   "chaos-malformed-chain-rate",
+		Usage:  "rate of injected malformed cert chains from 0 to 1",
+		Hidden: true,
+	}
 	a.App = &cli.App{
 		Name:                 appName,
 		Usage:                "TLS cert checker CLI",
@@ -109,17 +712,342 @@ func newApp(w io.Writer) *app {
 		Description:          "CLI application for checking TLS certificate information",
 		HideHelpCommand:      true,
 		EnableBashCompletion: true,
+		BashComplete:         a.complete,
 		Before:               a.before,
 		Action:               a.action,
-		Flags:                []cli.Flag{a.completion, a.loglevel, a.domain, a.file, a.output, a.timeout, a.insecure, a.noTimeInfo, a.timeZone},
+		ExitErrHandler:       func(*cli.Context, error) {},
+		Commands: []*cli.Command{
+			{
+				Name:  "fetch",
+				Usage: "print the cert chain served by hosts in PEM format",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "domain",
+						Aliases:  []string{"d"},
+						Usage:    "domain:port separated by commas",
+						Required: true,
+					},
+				},
+				Action: a.fetch,
+			},
+			{
+				Name:  "reconcile",
+				Usage: "reconcile the certs served by hosts against an export of issued certs from a CA",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "domain",
+						Aliases: []string{"d"},
+						Usage:   "domain:port separated by commas",
+					},
+					&cli.PathFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "path to newline-delimited list of domains, or to a CSV/TSV with options per host",
+					},
+					&cli.PathFlag{
+						Name:     "inventory",
+						Usage:    "path to the CA export in CSV with a header line, or in JSON if named *.json",
+						Required: true,
+					},
+				},
+				Action: a.reconcile,
+			},
+			{
+				Name:  "gate",
+				Usage: "fail when hosts are more expired, expiring or failing than in a baseline scan",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "domain",
+						Aliases: []string{"d"},
+						Usage:   "domain:port separated by commas",
+					},
+					&cli.PathFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "path to newline-delimited list of domains, or to a CSV/TSV with options per host",
+					},
+					&cli.PathFlag{
+						Name:     "baseline",
+						Usage:    "path to a previous scan in json output",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "max-regressions",
+						Usage: "number of hosts allowed to be worse than in the baseline",
+						Value: 0,
+					},
+				},
+				Action: a.gate,
+			},
+			{
+				Name:  "changes",
+				Usage: "print what changed since the last run as NDJSON events, keeping a snapshot of the results in a file",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "domain",
+						Aliases: []string{"d"},
+						Usage:   "domain:port separated by commas",
+					},
+					&cli.PathFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "path to newline-delimited list of domains, or to a CSV/TSV with options per host",
+					},
+					&cli.PathFlag{
+						Name:     "state",
+						Usage:    "path to the snapshot of the last run in json output, created if missing",
+						Required: true,
+					},
+				},
+				Action: a.changes,
+			},
+			{
+				Name:      "certdiff",
+				Usage:     "compare the certs served by two hosts field by field",
+				ArgsUsage: "hostA:port hostB:port",
+				Action:    a.certdiff,
+			},
+			{
+				Name:      "ct",
+				Usage:     "list the certs issued for domains as logged in Certificate Transparency, searched through crt.sh",
+				ArgsUsage: "domain...",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "subdomains",
+						Usage: "also list the certs issued for the subdomains",
+						Value: false,
+					},
+					&cli.BoolFlag{
+						Name:  "exclude-expired",
+						Usage: "leave out the expired certs",
+						Value: false,
+					},
+				},
+				Action: a.ct,
+			},
+			{
+				Name:      "history",
+				Usage:     "list the certs served by hosts across the runs kept by --history, to tell when they were rotated",
+				ArgsUsage: "[host[:port]...]",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Name:     "history",
+						Usage:    "path to the SQLite database given to --history",
+						EnvVars:  []string{canonicalName + "_HISTORY"},
+						Required: true,
+					},
+				},
+				Action: a.historyAction,
+			},
+			{
+				Name:  "report",
+				Usage: "report on the runs kept by --history",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "burndown",
+						Usage: "count the certs expired or expiring within 30, 60 and 90 days on each day of the history, to tell whether the renewal backlog shrinks",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:     "history",
+								Usage:    "path to the SQLite database given to --history",
+								EnvVars:  []string{canonicalName + "_HISTORY"},
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: fmt.Sprintf("output format: %s", pipeJoin(burndownFormats)),
+								Value: burndownCSV,
+							},
+						},
+						Action: a.burndown,
+					},
+				},
+			},
+			{
+				Name:  "self-update",
+				Usage: "replace this binary with the latest release after verifying its checksum",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "only report whether a newer release is available",
+						Value: false,
+					},
+				},
+				Action: a.selfUpdate,
+			},
+			{
+				Name:  "capabilities",
+				Usage: "list the outputs, providers, protocols and notifiers this binary supports, for feature detection by tools",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print in JSON",
+						Value: false,
+					},
+				},
+				Action: a.capabilities,
+			},
+			{
+				Name:      "timezones",
+				Usage:     "list the valid names for --timezone, or those containing any of the arguments",
+				ArgsUsage: "[pattern...]",
+				Action:    a.timezones,
+			},
+		},
+		Flags: []cli.Flag{
+			a.completion,
+			a.config,
+			a.loglevel,
+			a.domain,
+			a.file,
+			a.output,
+			a.timeout,
+			a.insecure,
+			a.noTimeInfo,
+			a.timeZone,
+			a.serverName,
+			a.adaptive,
+			a.unique,
+			a.starttls,
+			a.selftest,
+			a.top,
+			a.locale,
+			a.wideAmbig,
+			a.nameserver,
+			a.doh,
+			a.dot,
+			a.dnsNegTTL,
+			a.checkpoint,
+			a.resume,
+			a.probe,
+			a.weights,
+			a.smime,
+			a.warnDays,
+			a.critDays,
+			a.preset,
+			a.clientCert,
+			a.clientKey,
+			a.resolve,
+			a.allIPs,
+			a.rate,
+			a.keepGoing,
+			a.roots,
+			a.rootsTTL,
+			a.caFile,
+			a.caPath,
+			a.trustStores,
+			a.grpc,
+			a.grpcHealth,
+			a.grpcSvc,
+			a.jitter,
+			a.spread,
+			a.redact,
+			a.redactMode,
+			a.watch,
+			a.watchChanges,
+			a.saveState,
+			a.diffState,
+			a.history,
+			a.notifyURL,
+			a.notifyFmt,
+			a.notifyTmpl,
+			a.checkSANs,
+			a.checkCAA,
+			a.chaseAIA,
+			a.expectCert,
+			a.fallback,
+			a.preHook,
+			a.postHook,
+			a.trustMaxAge,
+			a.pac,
+			a.fast,
+			a.cipherSuites,
+			a.curves,
+			a.dumpPEM,
+			a.dumpChain,
+			a.outputFile,
+			a.cmdbMapping,
+			a.color,
+			a.columns,
+			a.computed,
+			a.policy,
+			a.sortKey,
+			a.reverse,
+			a.byIssuer,
+			a.groupBySrc,
+			a.cidr,
+			a.port,
+			a.connTimeout,
+			a.concurrency,
+			a.kubernetes,
+			a.kubeContext,
+			a.kubeNS,
+			a.kubeSecrets,
+			a.aws,
+			a.awsProfile,
+			a.awsRegion,
+			a.certFile,
+			a.password,
+			a.cache,
+			a.cacheTTL,
+			a.thrRetries,
+			a.thrBackoff,
+			a.chaosDNS,
+			a.chaosDelay,
+			a.chaosChain,
+		},
 	}
 	return &a
 }
 
+// complete prints the candidates for the value of a flag with a fixed set of
+// values, and the flag names or commands as urfave/cli does otherwise. Like
+// urfave/cli, it reads the words to complete from os.Args.
+func (a *app) complete(c *cli.Context) {
+	if len(os.Args) > 1 && completeFlagValues(c.App.Writer, os.Args[:len(os.Args)-1], a.valueCompleters()) {
+		return
+	}
+	cli.DefaultAppComplete(c)
+}
+
+// valueCompleters returns the candidates for each flag with a fixed set of
+// values, keyed by every name of the flag.
+func (a *app) valueCompleters() map[string]func() []string {
+	values := func(v []string) func() []string {
+		return func() []string { return v }
+	}
+	completers := map[cli.Flag]func() []string{
+		a.completion:   values(shells),
+		a.loglevel:     values(logLevels),
+		a.output:       values(formats),
+		a.timeZone:     timeZoneNames,
+		a.starttls:     preambleNames,
+		a.locale:       localeNames,
+		a.preset:       presetNames,
+		a.redact:       values(redactFields),
+		a.redactMode:   values(redactModes),
+		a.notifyFmt:    formatterNames,
+		a.cipherSuites: cipherSuiteNames,
+		a.curves:       curveNames,
+		a.color:        values(colorModes),
+		a.columns:      values(columnNames),
+		a.sortKey:      values(sortKeys),
+		a.roots:        values(rootSources),
+	}
+	m := make(map[string]func() []string)
+	for flag, complete := range completers {
+		for _, name := range flag.Names() {
+			m[name] = complete
+		}
+	}
+	return m
+}
+
 func (a *app) before(c *cli.Context) error {
 	var (
 		target = a.completion.Name
 		flags  = []string{
+			a.config.Name,
 			a.loglevel.Name,
 			a.domain.Name,
 			a.file.Name,
@@ -127,12 +1055,222 @@ func (a *app) before(c *cli.Context) error {
 			a.timeout.Name,
 			a.insecure.Name,
 			a.noTimeInfo.Name,
+			a.serverName.Name,
+			a.adaptive.Name,
+			a.unique.Name,
+			a.starttls.Name,
+			a.selftest.Name,
+			a.top.Name,
+			a.locale.Name,
+			a.wideAmbig.Name,
+			a.nameserver.Name,
+			a.doh.Name,
+			a.dot.Name,
+			a.dnsNegTTL.Name,
+			a.checkpoint.Name,
+			a.resume.Name,
+			a.probe.Name,
+			a.weights.Name,
+			a.smime.Name,
+			a.warnDays.Name,
+			a.critDays.Name,
+			a.preset.Name,
+			a.clientCert.Name,
+			a.clientKey.Name,
+			a.resolve.Name,
+			a.allIPs.Name,
+			a.rate.Name,
+			a.keepGoing.Name,
+			a.roots.Name,
+			a.rootsTTL.Name,
+			a.caFile.Name,
+			a.caPath.Name,
+			a.trustStores.Name,
+			a.grpc.Name,
+			a.grpcHealth.Name,
+			a.grpcSvc.Name,
+			a.jitter.Name,
+			a.spread.Name,
+			a.redact.Name,
+			a.redactMode.Name,
+			a.watch.Name,
+			a.watchChanges.Name,
+			a.saveState.Name,
+			a.diffState.Name,
+			a.history.Name,
+			a.notifyURL.Name,
+			a.notifyFmt.Name,
+			a.notifyTmpl.Name,
+			a.checkSANs.Name,
+			a.checkCAA.Name,
+			a.chaseAIA.Name,
+			a.expectCert.Name,
+			a.fallback.Name,
+			a.preHook.Name,
+			a.postHook.Name,
+			a.trustMaxAge.Name,
+			a.pac.Name,
+			a.fast.Name,
+			a.cipherSuites.Name,
+			a.curves.Name,
+			a.dumpPEM.Name,
+			a.dumpChain.Name,
+			a.outputFile.Name,
+			a.cmdbMapping.Name,
+			a.color.Name,
+			a.columns.Name,
+			a.computed.Name,
+			a.policy.Name,
+			a.sortKey.Name,
+			a.reverse.Name,
+			a.byIssuer.Name,
+			a.groupBySrc.Name,
+			a.cidr.Name,
+			a.port.Name,
+			a.connTimeout.Name,
+			a.concurrency.Name,
+			a.kubernetes.Name,
+			a.kubeContext.Name,
+			a.kubeNS.Name,
+			a.kubeSecrets.Name,
+			a.aws.Name,
+			a.awsProfile.Name,
+			a.awsRegion.Name,
+			a.certFile.Name,
+			a.password.Name,
+			a.cache.Name,
+			a.cacheTTL.Name,
+			a.thrRetries.Name,
+			a.thrBackoff.Name,
+			a.chaosDNS.Name,
+			a.chaosDelay.Name,
+			a.chaosChain.Name,
 		}
 	)
 	if err := checkSingle(c, target, flags); err != nil {
 		return err
 	}
-	if err := checkValidPair(c, a.domain.Name, a.file.Name); err != nil {
+	if !c.IsSet(target) {
+		if err := a.applyConfig(c); err != nil {
+			return err
+		}
+	}
+	if err := checkSingle(c, a.selftest.Name, []string{a.domain.Name, a.file.Name, a.cidr.Name, a.kubernetes.Name, a.aws.Name, a.certFile.Name}); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.resume.Name, a.checkpoint.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.clientCert.Name, a.clientKey.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.clientKey.Name, a.clientCert.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.notifyTmpl.Name, a.notifyURL.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.notifyFmt.Name, a.notifyURL.Name); err != nil {
+		return err
+	}
+	if err := checkValidPair(c, a.notifyFmt.Name, a.notifyTmpl.Name); err != nil {
+		return err
+	}
+	if err := checkValidPair(c, a.nameserver.Name, a.doh.Name); err != nil {
+		return err
+	}
+	if err := checkValidPair(c, a.nameserver.Name, a.dot.Name); err != nil {
+		return err
+	}
+	if err := checkValidPair(c, a.doh.Name, a.dot.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.watchChanges.Name, a.watch.Name); err != nil {
+		return err
+	}
+	if err := checkValidPair(c, a.watch.Name, a.checkpoint.Name); err != nil {
+		return err
+	}
+	if err := checkValidPair(c, a.allIPs.Name, a.checkpoint.Name); err != nil {
+		return err
+	}
+	if c.IsSet(a.policy.Name) && c.String(a.output.Name) == formatNagios.String() {
+		return fmt.Errorf("cannot be used together %s and %s output", a.policy.Name, formatNagios)
+	}
+	if c.IsSet(a.policy.Name) && c.String(a.output.Name) == formatJUnit.String() {
+		return fmt.Errorf("cannot be used together %s and %s output", a.policy.Name, formatJUnit)
+	}
+	if c.Bool(a.keepGoing.Name) && c.String(a.output.Name) == formatNagios.String() {
+		return fmt.Errorf("cannot be used together %s and %s output", a.keepGoing.Name, formatNagios)
+	}
+	if c.IsSet(a.watch.Name) && c.String(a.output.Name) == formatNagios.String() {
+		return fmt.Errorf("cannot be used together %s and %s output", a.watch.Name, formatNagios)
+	}
+	if c.IsSet(a.watch.Name) && c.String(a.output.Name) == formatJUnit.String() {
+		return fmt.Errorf("cannot be used together %s and %s output", a.watch.Name, formatJUnit)
+	}
+	if c.IsSet(a.watch.Name) && c.String(a.output.Name) == formatXLSX.String() {
+		return fmt.Errorf("cannot be used together %s and %s output", a.watch.Name, formatXLSX)
+	}
+	if c.String(a.output.Name) == formatXLSX.String() && !c.IsSet(a.outputFile.Name) {
+		return fmt.Errorf("%s output: requires %s", formatXLSX, a.outputFile.Name)
+	}
+	if c.IsSet(a.cmdbMapping.Name) && c.String(a.output.Name) != formatCMDB.String() {
+		return fmt.Errorf("%s: requires %s output", a.cmdbMapping.Name, formatCMDB)
+	}
+	if c.IsSet(a.byIssuer.Name) && c.String(a.output.Name) != formatHistogram.String() {
+		return fmt.Errorf("%s: requires %s output", a.byIssuer.Name, formatHistogram)
+	}
+	if c.IsSet(a.columns.Name) {
+		// the other formats have a layout of their own
+		switch c.String(a.output.Name) {
+		case formatJSON.String(), formatTextTable.String(), formatMarkdownTable.String(), formatBacklogTable.String(), formatNDJSON.String(), formatXLSX.String():
+		default:
+			return fmt.Errorf("cannot be used together %s and %s output", a.columns.Name, c.String(a.output.Name))
+		}
+	}
+	if c.IsSet(a.groupBySrc.Name) {
+		switch c.String(a.output.Name) {
+		case formatJSON.String(), formatTextTable.String(), formatMarkdownTable.String(), formatBacklogTable.String(), formatText.String():
+		default:
+			return fmt.Errorf("cannot be used together %s and %s output", a.groupBySrc.Name, c.String(a.output.Name))
+		}
+	}
+	if err := checkValidPair(c, a.columns.Name, a.noTimeInfo.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.port.Name, a.cidr.Name); err != nil {
+		return err
+	}
+	for _, flag := range []string{a.kubeContext.Name, a.kubeNS.Name, a.kubeSecrets.Name} {
+		if err := checkRequired(c, flag, a.kubernetes.Name); err != nil {
+			return err
+		}
+	}
+	if err := checkRequired(c, a.password.Name, a.certFile.Name); err != nil {
+		return err
+	}
+	for _, flag := range []string{a.awsProfile.Name, a.awsRegion.Name} {
+		if err := checkRequired(c, flag, a.aws.Name); err != nil {
+			return err
+		}
+	}
+	if err := checkRequired(c, a.cacheTTL.Name, a.cache.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.rootsTTL.Name, a.roots.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.redactMode.Name, a.redact.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.dumpChain.Name, a.dumpPEM.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.grpcHealth.Name, a.grpc.Name); err != nil {
+		return err
+	}
+	if err := checkRequired(c, a.grpcSvc.Name, a.grpcHealth.Name); err != nil {
 		return err
 	}
 	if c.Bool(a.insecure.Name) {
@@ -145,57 +1283,950 @@ func (a *app) before(c *cli.Context) error {
 		return err
 	}
 	log.SetLevel(level)
+	setAmbiguousWidth(c.Bool(a.wideAmbig.Name))
 	return nil
 }
 
-func (a *app) action(c *cli.Context) error {
-	if c.NumFlags() == 0 {
+// applyConfig sets the flags not given on the command line or through the
+// environment from the config file. A bare invocation still shows the help
+// unless the config has targets.
+func (a *app) applyConfig(c *cli.Context) error {
+	path, required := c.Path(a.config.Name), true
+	if path == "" {
+		path, required = defaultConfigPath(), false
+	}
+	if path == "" {
+		return nil
+	}
+	cfg, err := loadConfig(path, required)
+	if err != nil {
+		return err
+	}
+	if c.NArg() == 0 && len(c.LocalFlagNames()) == 0 && cfg.values[configTargets] == nil {
+		return nil
+	}
+	return cfg.apply(c, a.Flags, []string{a.completion.Name, a.config.Name, a.selftest.Name, a.domain.Name})
+}
+
+func (a *app) action(c *cli.Context) (err error) {
+	// flags set only through the environment count as well, as in containers
+	if len(c.LocalFlagNames()) == 0 {
 		return cli.ShowAppHelp(c)
 	}
 	if c.IsSet(a.completion.Name) {
 		return comp(a.Writer, c.String(a.completion.Name))
 	}
-	var domains []string
-	var err error
+	if c.IsSet(a.selftest.Name) {
+		return selftest(c.Context, a.Writer, selftestHosts)
+	}
+	w := a.Writer
+	if path := c.Path(a.outputFile.Name); path != "" {
+		var f *atomicFile
+		f, err = createAtomic(path)
+		if err != nil {
+			return err
+		}
+		// the output is complete when the run fails only by its exit code,
+		// e.g. with certs under the thresholds
+		defer func() {
+			var exitErr cli.ExitCoder
+			if err != nil && !errors.As(err, &exitErr) {
+				f.abort()
+				return
+			}
+			if cerr := f.commit(); cerr != nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+	// a plugin must report every outcome as a status line on stdout
+	checkFailed := false
+	if c.String(a.output.Name) == formatNagios.String() {
+		defer func() {
+			err = nagiosFailure(w, err, checkFailed)
+		}()
+	}
+	// each source is prepared on its own so that its hosts can be told apart
+	type input struct {
+		source string
+		addrs  []string
+	}
+	var inputs []input
+	var ts targets
 	if c.IsSet(a.domain.Name) {
-		domains = c.StringSlice(a.domain.Name)
+		inputs = append(inputs, input{source: sourceDomain, addrs: c.StringSlice(a.domain.Name)})
 	}
 	if c.IsSet(a.file.Name) {
-		domains, err = fromList(c.Path(a.file.Name))
+		var addrs []string
+		addrs, ts, err = fromList(c.Path(a.file.Name))
 		if err != nil {
 			return err
 		}
+		weights, err := parseLabelWeights(c.StringSlice(a.weights.Name))
+		if err != nil {
+			return err
+		}
+		addrs, err = prioritize(addrs, weights)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input{source: c.Path(a.file.Name), addrs: addrs})
 	}
-	if len(domains) == 0 {
-		return errors.New("cannot receive domain names")
-	}
-	tz := c.String(a.timeZone.Name)
-	loc, err := time.LoadLocation(tz)
+	sw, swept, err := newSweep(c.StringSlice(a.cidr.Name), c.String(a.port.Name), c.Duration(a.connTimeout.Name))
 	if err != nil {
-		return fmt.Errorf("cannot load timezone %q", tz)
+		return err
 	}
-	log.Info("getting certificate information...")
-	infos, err := getCertList(c.Context, domains, c.Duration(a.timeout.Name), c.Bool(a.insecure.Name), loc)
+	if len(swept) > 0 {
+		inputs = append(inputs, input{source: sourceCIDR, addrs: swept})
+	}
+	var (
+		kc          *kube
+		kubeSecrets []kubeSecret
+	)
+	if c.Bool(a.kubernetes.Name) {
+		kc = newKube(c.String(a.kubeContext.Name), c.String(a.kubeNS.Name))
+		var addrs []string
+		addrs, kubeSecrets, err = kc.discover(c.Context)
+		if err != nil {
+			return err
+		}
+		log.Info("discovered hosts in the cluster", "hosts", len(addrs), "secrets", len(kubeSecrets))
+		inputs = append(inputs, input{source: sourceKubernetes, addrs: addrs})
+	}
+	var aw *awsAccount
+	if c.IsSet(a.aws.Name) {
+		aw, err = newAWSAccount(c.StringSlice(a.aws.Name), c.String(a.awsProfile.Name), c.StringSlice(a.awsRegion.Name))
+		if err != nil {
+			return err
+		}
+		// the certs are listed at each check, so the source only gives its place
+		inputs = append(inputs, input{source: sourceAWS})
+	}
+	files := &certFiles{
+		paths:    c.StringSlice(a.certFile.Name),
+		password: c.String(a.password.Name),
+		prompt:   passwordPrompt,
+	}
+	if len(files.paths) > 0 {
+		// the files are read at each check, as for the certs listed in AWS
+		inputs = append(inputs, input{source: sourceCertFile})
+	}
+	var p preset
+	if c.IsSet(a.preset.Name) {
+		p, err = lookupPreset(c.String(a.preset.Name))
+		if err != nil {
+			return err
+		}
+		if p.clientAuth && !c.IsSet(a.clientCert.Name) {
+			log.Warn("the endpoint usually requires a client cert", "preset", c.String(a.preset.Name), "flag", a.clientCert.Name)
+		}
+	}
+	var domains []string
+	src := newSources()
+	for _, in := range inputs {
+		addrs := in.addrs
+		if c.IsSet(a.preset.Name) {
+			addrs = applyPort(addrs, p.port)
+		}
+		addrs, err := sw.expand(addrs)
+		if err != nil {
+			return err
+		}
+		domains = append(domains, src.add(in.source, addrs)...)
+	}
+	if len(domains) == 0 && aw == nil && len(files.paths) == 0 {
+		return errors.New("cannot receive domain names")
+	}
+	warn, crit, err := a.thresholds(c)
 	if err != nil {
 		return err
 	}
-	slices.SortFunc(infos, func(a, b *certInfo) int {
-		return cmp.Compare(a.DomainName, b.DomainName)
-	})
-	if err := out(infos, a.Writer, c.String(a.output.Name), c.Bool(a.noTimeInfo.Name)); err != nil {
+	opts, err := a.newOptions(c)
+	if err != nil {
 		return err
 	}
-	log.Info("completed")
-	return nil
-}
-
-func checkSingle(c *cli.Context, target string, flags []string) error {
-	if !c.IsSet(target) {
-		return nil
+	defer opts.shared.close()
+	opts.targets = ts
+	opts.sweep = sw
+	if c.Bool(a.groupBySrc.Name) {
+		opts.sources = src
 	}
-	for _, flag := range flags {
-		if c.IsSet(flag) {
-			return fmt.Errorf("%s: not available if other flags are set", target)
+	maxAge := -1
+	if c.IsSet(a.trustMaxAge.Name) {
+		maxAge = c.Int(a.trustMaxAge.Name)
+		if maxAge < 0 {
+			return fmt.Errorf("invalid value for %s: must not be negative", a.trustMaxAge.Name)
+		}
+	}
+	// certs are not verified against the trust store when insecure
+	if !opts.insecure {
+		checkTrustStore(time.Now(), maxAge)
+	}
+	if opts.starttls == "" {
+		opts.starttls = p.protocol
+	}
+	loc, err := lookupLocale(c.String(a.locale.Name))
+	if err != nil {
+		return err
+	}
+	red, err := newRedactor(c.StringSlice(a.redact.Name), c.String(a.redactMode.Name))
+	if err != nil {
+		return err
+	}
+	nt, err := newNotifier(c.String(a.notifyURL.Name), c.String(a.notifyFmt.Name), c.Path(a.notifyTmpl.Name), c.Duration(a.timeout.Name))
+	if err != nil {
+		return err
+	}
+	hk := newHook(c.String(a.preHook.Name), c.String(a.postHook.Name))
+	if err := hk.before(c.Context, domains); err != nil {
+		return err
+	}
+	cp, err := openCheckpoint(c.Path(a.checkpoint.Name), c.Bool(a.resume.Name))
+	if err != nil {
+		return err
+	}
+	defer cp.close()
+	domains, done := cp.pending(domains)
+	if len(done) > 0 {
+		log.Info("resuming from checkpoint", "done", len(done), "pending", len(domains))
+	}
+	opts.checkpoint = cp
+	// a report has a failed case for each host that cannot be checked
+	junit := c.String(a.output.Name) == formatJUnit.String()
+	// as does the output with a record for each host that cannot be checked
+	keepGoing := c.Bool(a.keepGoing.Name) && !junit
+	if junit || keepGoing {
+		opts.failures = &failures{}
+	}
+	var st *runState
+	if c.IsSet(a.saveState.Name) || c.IsSet(a.diffState.Name) {
+		st = &runState{diffPath: c.Path(a.diffState.Name), savePath: c.Path(a.saveState.Name)}
+	}
+	hist, err := newHistory(c.Path(a.history.Name))
+	if err != nil {
+		return err
+	}
+	top := 0
+	if c.IsSet(a.top.Name) {
+		top = c.Int(a.top.Name)
+		if top < 1 {
+			return fmt.Errorf("invalid value for %s: must be greater than 0", a.top.Name)
+		}
+	}
+	order, err := certOrder(c.String(a.sortKey.Name), c.Bool(a.reverse.Name))
+	if err != nil {
+		return err
+	}
+	computed, err := loadComputedFields(c.Path(a.computed.Name))
+	if err != nil {
+		return err
+	}
+	opts.policy, err = loadPolicy(c.Path(a.policy.Name))
+	if err != nil {
+		return err
+	}
+	// the accepted versions are known only by probing them
+	if opts.policy.probes() {
+		opts.probe = true
+	}
+	violated := 0
+	cols, err := parseColumns(c.StringSlice(a.columns.Name), computed.names())
+	if err != nil {
+		return err
+	}
+	// ndjson is written as each check completes unless the output depends on
+	// the whole result
+	streaming := c.String(a.output.Name) == formatNDJSON.String() && !c.Bool(a.unique.Name) && top == 0 && !c.Bool(a.watchChanges.Name)
+	if streaming {
+		opts.stream = newStreamer(w, red, computed, cols)
+	}
+	var mapping cmdbMapping
+	if c.String(a.output.Name) == formatCMDB.String() {
+		mapping, err = loadCMDBMapping(c.Path(a.cmdbMapping.Name))
+		if err != nil {
+			return err
+		}
+	}
+	cz, err := newColorizer(c.String(a.color.Name), w, warn, crit)
+	if err != nil {
+		return err
+	}
+	// render renders the result in the formats that take more than out does
+	render := func(infos []*certInfo, w io.Writer) error {
+		switch c.String(a.output.Name) {
+		case formatTextTable.String():
+			if cz != nil {
+				var sb strings.Builder
+				if err := toTable(infos, &sb, formatTextTable.String(), c.Bool(a.noTimeInfo.Name), loc, cols); err != nil {
+					return err
+				}
+				_, err := io.WriteString(w, cz.table(sb.String(), infos))
+				return err
+			}
+		case formatNDJSON.String():
+			if streaming {
+				return nil
+			}
+		case formatXLSX.String():
+			return toXLSX(infos, w, c.Bool(a.noTimeInfo.Name), cols, warn, crit)
+		case formatCMDB.String():
+			return toCMDB(infos, w, mapping)
+		case formatHistogram.String():
+			return toHistogram(infos, w, c.Bool(a.byIssuer.Name))
+		}
+		return out(infos, w, c.String(a.output.Name), c.Bool(a.noTimeInfo.Name), loc, cols)
+	}
+	write := func(infos []*certInfo) error {
+		if opts.sources == nil {
+			return render(infos, w)
+		}
+		groups := groupBySource(infos, opts.sources.names)
+		if c.String(a.output.Name) == formatJSON.String() {
+			return toGroupedJSON(groups, w, cols)
+		}
+		return toGroupedText(groups, w, render)
+	}
+	check := func(ctx context.Context) ([]*certInfo, error) {
+		log.Info("getting certificate information...")
+		// in watch mode, only the hosts that failed in this check are reported
+		if keepGoing {
+			opts.failures = &failures{}
+		}
+		for _, info := range done {
+			if err := opts.stream.write(ctx, info); err != nil {
+				return nil, err
+			}
+		}
+		infos, err := getCertList(ctx, domains, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.sweep.report()
+		if dir := c.Path(a.dumpPEM.Name); dir != "" {
+			if err := dumpPEM(dir, infos, c.Bool(a.dumpChain.Name)); err != nil {
+				return nil, err
+			}
+		}
+		// secrets, the certs stored in AWS and local files are not served certs, so they are not dumped
+		if c.Bool(a.kubeSecrets.Name) {
+			for _, info := range kc.secretInfos(ctx, kubeSecrets, opts.location) {
+				if opts.sources != nil {
+					info.Source = sourceKubernetes
+				}
+				infos = append(infos, info)
+			}
+		}
+		if aw != nil {
+			stored, err := aw.certInfos(ctx, opts.location)
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range stored {
+				if opts.sources != nil {
+					info.Source = sourceAWS
+				}
+			}
+			infos = append(infos, stored...)
+		}
+		if len(files.paths) > 0 {
+			local, err := files.infos(opts.location)
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range local {
+				if opts.sources != nil {
+					info.Source = sourceCertFile
+				}
+			}
+			infos = append(infos, local...)
+		}
+		infos = append(infos, done...)
+		log.Debug("cache stats", "ip", ipCache.stats(), "conn", connCache.stats())
+		slices.SortFunc(infos, order)
+		violated = violating(infos)
+		// the snapshot has every host, before the output is narrowed down
+		if err := st.apply(infos, opts.failures.list(), time.Now()); err != nil {
+			return nil, err
+		}
+		if err := hist.append(c.Context, infos, time.Now()); err != nil {
+			return nil, err
+		}
+		if c.Bool(a.unique.Name) {
+			infos = uniqueCerts(infos)
+		}
+		if top > 0 {
+			infos = topExpiring(infos, top)
+			// the soonest-expiring come first unless asked otherwise
+			if c.IsSet(a.sortKey.Name) || c.Bool(a.reverse.Name) {
+				slices.SortFunc(infos, order)
+			}
+		}
+		infos, err = computed.apply(ctx, infos)
+		if err != nil {
+			return nil, err
+		}
+		infos = red.apply(infos)
+		// a failed notification should not cost the output of the check
+		if err := nt.notify(ctx, infos, warn); err != nil {
+			log.Error(err)
+		}
+		if err := hk.after(ctx, infos); err != nil {
+			log.Error(err)
+		}
+		if keepGoing {
+			failed := opts.failures.infos(time.Now(), opts.location, opts.sources)
+			for _, info := range failed {
+				if err := opts.stream.write(ctx, info); err != nil {
+					return nil, err
+				}
+			}
+			infos = append(infos, red.apply(failed)...)
+			slices.SortFunc(infos, order)
+		}
+		return infos, nil
+	}
+	if c.IsSet(a.watch.Name) {
+		interval := c.Duration(a.watch.Name)
+		if interval <= 0 {
+			return fmt.Errorf("invalid value for %s: must be greater than 0", a.watch.Name)
+		}
+		return watch(c.Context, interval, c.Bool(a.watchChanges.Name), check, func(infos []*certInfo) error {
+			if err := write(infos); err != nil {
+				return err
+			}
+			if err := checkThresholds(infos, warn, crit); err != nil {
+				log.Warn(err)
+			}
+			if n := unverifiedCount(infos); n > 0 {
+				log.Warn(&verificationError{count: n})
+			}
+			if violated > 0 {
+				log.Warn(&policyError{count: violated})
+			}
+			return nil
+		})
+	}
+	infos, err := check(c.Context)
+	if err != nil {
+		checkFailed = true
+		return err
+	}
+	if c.String(a.output.Name) == formatNagios.String() {
+		if err := cp.remove(); err != nil {
+			return err
+		}
+		return toNagios(infos, w, warn, crit)
+	}
+	if junit {
+		if err := cp.remove(); err != nil {
+			return err
+		}
+		return toJUnit(infos, opts.failures.list(), w, warn, crit)
+	}
+	if err := write(infos); err != nil {
+		return err
+	}
+	if err := cp.remove(); err != nil {
+		return err
+	}
+	log.Info("completed")
+	// the failures, chains that do not verify and violations exit as
+	// critical, so they come first and the others are logged
+	var errs []error
+	if failed := opts.failures.list(); keepGoing && len(failed) > 0 {
+		errs = append(errs, &failedError{count: len(failed)})
+	}
+	if n := unverifiedCount(infos); n > 0 {
+		errs = append(errs, &verificationError{count: n})
+	}
+	if violated > 0 {
+		errs = append(errs, &policyError{count: violated})
+	}
+	if err := checkThresholds(infos, warn, crit); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, err := range errs[1:] {
+		log.Warn(err)
+	}
+	return errs[0]
+}
+
+// thresholds returns the warning and critical days, or -1 for those not set.
+func (a *app) thresholds(c *cli.Context) (int, int, error) {
+	warn, crit := -1, -1
+	if c.IsSet(a.warnDays.Name) {
+		warn = c.Int(a.warnDays.Name)
+		if warn < 0 {
+			return 0, 0, fmt.Errorf("invalid value for %s: must not be negative", a.warnDays.Name)
+		}
+	}
+	if c.IsSet(a.critDays.Name) {
+		crit = c.Int(a.critDays.Name)
+		if crit < 0 {
+			return 0, 0, fmt.Errorf("invalid value for %s: must not be negative", a.critDays.Name)
+		}
+	}
+	if warn >= 0 && crit >= 0 && warn < crit {
+		return 0, 0, fmt.Errorf("invalid value for %s: must not be less than %s", a.warnDays.Name, a.critDays.Name)
+	}
+	return warn, crit, nil
+}
+
+func (a *app) newOptions(c *cli.Context) (*options, error) {
+	tz := c.String(a.timeZone.Name)
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	chaos, err := newChaos(c.Float64(a.chaosDNS.Name), c.Duration(a.chaosDelay.Name), c.Float64(a.chaosChain.Name))
+	if err != nil {
+		return nil, err
+	}
+	if c.Int(a.concurrency.Name) < 0 {
+		return nil, fmt.Errorf("invalid value for %s: must not be negative", a.concurrency.Name)
+	}
+	for _, flag := range []*cli.DurationFlag{a.jitter, a.spread} {
+		if c.Duration(flag.Name) < 0 {
+			return nil, fmt.Errorf("invalid value for %s: must not be negative", flag.Name)
+		}
+	}
+	fallback := c.StringSlice(a.fallback.Name)
+	for _, port := range fallback {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid value for %s: %q is not a port number", a.fallback.Name, port)
+		}
+	}
+	clientCert, err := loadClientCert(c.Path(a.clientCert.Name), c.Path(a.clientKey.Name))
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := parseRate(c.String(a.rate.Name))
+	if err != nil {
+		return nil, err
+	}
+	source, err := loadRootSource(c.Context, c.String(a.roots.Name), c.Duration(a.rootsTTL.Name), c.Duration(a.timeout.Name))
+	if err != nil {
+		return nil, err
+	}
+	roots, err := loadRoots(source, c.Path(a.caFile.Name), c.Path(a.caPath.Name))
+	if err != nil {
+		return nil, err
+	}
+	stores, err := loadTrustStores(c.StringSlice(a.trustStores.Name))
+	if err != nil {
+		return nil, err
+	}
+	pins, err := parsePins(c.StringSlice(a.resolve.Name))
+	if err != nil {
+		return nil, err
+	}
+	hello, err := newClientHello(c.StringSlice(a.cipherSuites.Name), c.StringSlice(a.curves.Name))
+	if err != nil {
+		return nil, err
+	}
+	var base resolver = systemResolver{}
+	switch {
+	case c.String(a.nameserver.Name) != "":
+		base = newDNSResolver(c.String(a.nameserver.Name))
+	case c.String(a.dot.Name) != "":
+		base = newDoTResolver(c.String(a.dot.Name))
+	case c.String(a.doh.Name) != "":
+		base, err = newDoHResolver(c.String(a.doh.Name), c.Duration(a.timeout.Name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// CAA records are queried from the DNS server given, or from that of the
+	// system, whose resolver only looks up addresses
+	var caa *dnsResolver
+	if c.Bool(a.checkCAA.Name) {
+		if r, ok := base.(*dnsResolver); ok {
+			caa = r
+		} else {
+			server, err := systemNameserver()
+			if err != nil {
+				return nil, fmt.Errorf("cannot look up CAA records without --%s: %w", a.nameserver.Name, err)
+			}
+			caa = newDNSResolver(server)
+		}
+	}
+	var aia *aiaFetcher
+	if c.Bool(a.chaseAIA.Name) {
+		aia = newAIAFetcher(c.Duration(a.timeout.Name))
+	}
+	throttle, err := newThrottle(c.Int(a.thrRetries.Name), c.Duration(a.thrBackoff.Name))
+	if err != nil {
+		return nil, err
+	}
+	shared, err := newSharedCache(c.String(a.cache.Name), c.Duration(a.cacheTTL.Name), c.Duration(a.timeout.Name))
+	if err != nil {
+		return nil, err
+	}
+	resolver := newCachingResolver(base, c.Duration(a.dnsNegTTL.Name))
+	resolver.shared = shared
+	var script *pac
+	if location := c.String(a.pac.Name); location != "" {
+		script, err = loadPAC(c.Context, location, c.Duration(a.timeout.Name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var expected string
+	if path := c.Path(a.expectCert.Name); path != "" {
+		expected, err = loadExpectedCert(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// results differ with these, which are not kept in the options, so that
+	// instances sharing the cache with other values keep them apart
+	if shared != nil {
+		shared.scope = []any{
+			c.String(a.roots.Name), c.Path(a.caFile.Name), c.Path(a.caPath.Name),
+			c.Path(a.clientCert.Name), c.Path(a.clientKey.Name),
+		}
+	}
+	opts := &options{
+		timeout:     c.Duration(a.timeout.Name),
+		insecure:    c.Bool(a.insecure.Name),
+		location:    loc,
+		serverName:  c.String(a.serverName.Name),
+		adaptive:    c.Bool(a.adaptive.Name),
+		partial:     c.Bool(a.fast.Name),
+		probe:       c.Bool(a.probe.Name),
+		smime:       c.Bool(a.smime.Name),
+		grpc:        c.Bool(a.grpc.Name),
+		grpcHealth:  c.Bool(a.grpcHealth.Name),
+		grpcSvc:     c.String(a.grpcSvc.Name),
+		checkSANs:   c.Bool(a.checkSANs.Name),
+		caa:         caa,
+		aia:         aia,
+		fallback:    fallback,
+		jitter:      c.Duration(a.jitter.Name),
+		spread:      c.Duration(a.spread.Name),
+		starttls:    c.String(a.starttls.Name),
+		clientCert:  clientCert,
+		allIPs:      c.Bool(a.allIPs.Name),
+		limiter:     limiter,
+		roots:       roots,
+		trustStores: stores,
+		pins:        pins,
+		hello:       hello,
+		resolver:    resolver,
+		pac:         script,
+		chaos:       chaos,
+		throttle:    throttle,
+		shared:      shared,
+		expected:    expected,
+		concurrency: c.Int(a.concurrency.Name),
+	}
+	return opts, nil
+}
+
+func (a *app) fetch(c *cli.Context) error {
+	opts, err := a.newOptions(c)
+	if err != nil {
+		return err
+	}
+	defer opts.shared.close()
+	log.Info("getting certificate information...")
+	infos, err := getCertList(c.Context, c.StringSlice("domain"), opts)
+	if err != nil {
+		return err
+	}
+	if err := toPEM(infos, a.Writer); err != nil {
+		return err
+	}
+	log.Info("completed")
+	return nil
+}
+
+func (a *app) ct(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("ct: domain required: %s", c.Command.ArgsUsage)
+	}
+	tz, err := loadLocation(c.String(a.timeZone.Name))
+	if err != nil {
+		return err
+	}
+	loc, err := lookupLocale(c.String(a.locale.Name))
+	if err != nil {
+		return err
+	}
+	cols, err := parseColumns(c.StringSlice(a.columns.Name), nil)
+	if err != nil {
+		return err
+	}
+	l := newCTLog(ctTimeout)
+	now := time.Now()
+	var infos []*certInfo
+	for _, domain := range c.Args().Slice() {
+		log.Info("searching certificate transparency logs...", "domain", domain)
+		entries, err := l.search(c.Context, domain, c.Bool("subdomains"), c.Bool("exclude-expired"))
+		if err != nil {
+			return err
+		}
+		res, err := ctInfos(domain, entries, now, tz)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, res...)
+	}
+	if err := out(infos, a.Writer, c.String(a.output.Name), c.Bool(a.noTimeInfo.Name), loc, cols); err != nil {
+		return err
+	}
+	log.Info("completed")
+	return nil
+}
+
+func (a *app) historyAction(c *cli.Context) error {
+	tz, err := loadLocation(c.String(a.timeZone.Name))
+	if err != nil {
+		return err
+	}
+	// a query is not to leave an empty database behind
+	if _, err := os.Stat(c.Path("history")); err != nil {
+		return fmt.Errorf("cannot open history: %w", err)
+	}
+	h, err := newHistory(c.Path("history"))
+	if err != nil {
+		return err
+	}
+	rotations, err := h.rotations(c.Context, c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	return toHistory(rotations, a.Writer, c.String(a.output.Name), tz)
+}
+
+func (a *app) burndown(c *cli.Context) error {
+	// a report is not to leave an empty database behind
+	if _, err := os.Stat(c.Path("history")); err != nil {
+		return fmt.Errorf("cannot open history: %w", err)
+	}
+	h, err := newHistory(c.Path("history"))
+	if err != nil {
+		return err
+	}
+	days, err := h.burndown(c.Context)
+	if err != nil {
+		return err
+	}
+	return toBurndown(days, a.Writer, c.String("format"))
+}
+
+func (a *app) selfUpdate(c *cli.Context) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return newUpdater(releaseTimeout).selfUpdate(c.Context, a.Writer, exe, c.Bool("check"))
+}
+
+func (a *app) capabilities(c *cli.Context) error {
+	cmds := make([]string, 0, len(a.Commands))
+	for _, cmd := range a.Commands {
+		cmds = append(cmds, cmd.Name)
+	}
+	return toCapabilities(newCapabilities(cmds), a.Writer, c.Bool("json"))
+}
+
+func (a *app) timezones(c *cli.Context) error {
+	zones := filterZones(timeZoneNames(), c.Args().Slice())
+	if len(zones) == 0 {
+		return fmt.Errorf("no timezone matches %s", strings.Join(c.Args().Slice(), ", "))
+	}
+	for _, zone := range zones {
+		fmt.Fprintln(a.Writer, zone)
+	}
+	return nil
+}
+
+func (a *app) certdiff(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return fmt.Errorf("certdiff: exactly two targets required: %s", c.Command.ArgsUsage)
+	}
+	opts, err := a.newOptions(c)
+	if err != nil {
+		return err
+	}
+	defer opts.shared.close()
+	log.Info("getting certificate information...")
+	before, err := diffTarget(c.Context, c.Args().Get(0), opts)
+	if err != nil {
+		return err
+	}
+	after, err := diffTarget(c.Context, c.Args().Get(1), opts)
+	if err != nil {
+		return err
+	}
+	diffs := diffCerts(before, after, opts.location)
+	if err := toDiff(diffs, a.Writer, c.Args().Get(0), c.Args().Get(1)); err != nil {
+		return err
+	}
+	log.Info("completed")
+	return nil
+}
+
+func (a *app) reconcile(c *cli.Context) error {
+	if err := checkValidPair(c, "domain", "file"); err != nil {
+		return err
+	}
+	domains := c.StringSlice("domain")
+	var ts targets
+	if c.IsSet("file") {
+		var err error
+		domains, ts, err = fromList(c.Path("file"))
+		if err != nil {
+			return err
+		}
+	}
+	if len(domains) == 0 {
+		return errors.New("cannot receive domain names")
+	}
+	inventory, err := loadInventory(c.Path("inventory"))
+	if err != nil {
+		return err
+	}
+	opts, err := a.newOptions(c)
+	if err != nil {
+		return err
+	}
+	defer opts.shared.close()
+	opts.targets = ts
+	log.Info("getting certificate information...")
+	infos, err := getCertList(c.Context, domains, opts)
+	if err != nil {
+		return err
+	}
+	if err := toReconcile(reconcile(inventory, infos), a.Writer, c.String(a.output.Name)); err != nil {
+		return err
+	}
+	log.Info("completed")
+	return nil
+}
+
+func (a *app) gate(c *cli.Context) error {
+	if err := checkValidPair(c, "domain", "file"); err != nil {
+		return err
+	}
+	if c.Int("max-regressions") < 0 {
+		return errors.New("invalid value for max-regressions: must not be negative")
+	}
+	domains := c.StringSlice("domain")
+	var ts targets
+	if c.IsSet("file") {
+		var err error
+		domains, ts, err = fromList(c.Path("file"))
+		if err != nil {
+			return err
+		}
+	}
+	if len(domains) == 0 {
+		return errors.New("cannot receive domain names")
+	}
+	baseline, err := loadBaseline(c.Path("baseline"))
+	if err != nil {
+		return err
+	}
+	// a cert is expiring below the warning days, or the critical days when
+	// only they are given
+	warn, crit, err := a.thresholds(c)
+	if err != nil {
+		return err
+	}
+	days := gateExpiringDays
+	switch {
+	case warn >= 0:
+		days = warn
+	case crit >= 0:
+		days = crit
+	}
+	opts, err := a.newOptions(c)
+	if err != nil {
+		return err
+	}
+	defer opts.shared.close()
+	opts.targets = ts
+	// a host that cannot be checked is a failing host rather than the end of
+	// the gate
+	opts.failures = &failures{}
+	log.Info("getting certificate information...")
+	infos, err := getCertList(c.Context, domains, opts)
+	if err != nil {
+		return err
+	}
+	base := gatePostures(baseline, nil, days)
+	cur := gatePostures(infos, opts.failures.list(), days)
+	regressions := gate(base, cur)
+	if err := toGate(a.Writer, base, cur, regressions); err != nil {
+		return err
+	}
+	if len(regressions) > c.Int("max-regressions") {
+		return &gateError{count: len(regressions), max: c.Int("max-regressions")}
+	}
+	log.Info("completed")
+	return nil
+}
+
+func (a *app) changes(c *cli.Context) error {
+	if err := checkValidPair(c, "domain", "file"); err != nil {
+		return err
+	}
+	domains := c.StringSlice("domain")
+	var ts targets
+	if c.IsSet("file") {
+		var err error
+		domains, ts, err = fromList(c.Path("file"))
+		if err != nil {
+			return err
+		}
+	}
+	if len(domains) == 0 {
+		return errors.New("cannot receive domain names")
+	}
+	prev, err := loadSnapshot(c.Path("state"))
+	if err != nil {
+		return err
+	}
+	opts, err := a.newOptions(c)
+	if err != nil {
+		return err
+	}
+	defer opts.shared.close()
+	opts.targets = ts
+	opts.failures = &failures{}
+	log.Info("getting certificate information...")
+	infos, err := getCertList(c.Context, domains, opts)
+	if err != nil {
+		return err
+	}
+	failed := opts.failures.list()
+	for _, f := range failed {
+		log.Warn("cannot check the host, keeping it in the snapshot", "host", f.addr, "err", f.err)
+	}
+	events, next := diffSnapshot(prev, infos, failed, time.Now())
+	if err := toChanges(events, a.Writer); err != nil {
+		return err
+	}
+	if err := saveSnapshot(c.Path("state"), next); err != nil {
+		return err
+	}
+	log.Info("completed")
+	return nil
+}
+
+func checkSingle(c *cli.Context, target string, flags []string) error {
+	if !c.IsSet(target) {
+		return nil
+	}
+	for _, flag := range flags {
+		if c.IsSet(flag) {
+			return fmt.Errorf("%s: not available if other flags are set", target)
 		}
 	}
 	return nil
@@ -208,6 +2239,27 @@ func checkValidPair(c *cli.Context, a string, b string) error {
 	return nil
 }
 
+func checkRequired(c *cli.Context, target string, required string) error {
+	if c.IsSet(target) && !c.IsSet(required) {
+		return fmt.Errorf("%s: requires %s", target, required)
+	}
+	return nil
+}
+
+// passwordPrompt asks for the password of the keystore at path, or fails when
+// running non-interactively.
+func passwordPrompt(path string) (string, error) {
+	ni, _ := strconv.ParseBool(os.Getenv(canonicalName + "_NON_INTERACTIVE"))
+	if ni {
+		return "", fmt.Errorf("%w: give it by --password", errKeystorePassword)
+	}
+	prompt := promptui.Prompt{
+		Label: fmt.Sprintf("password of %s", path),
+		Mask:  '*',
+	}
+	return prompt.Run()
+}
+
 func insecureConfirm() error {
 	ni, _ := strconv.ParseBool(os.Getenv(canonicalName + "_NON_INTERACTIVE"))
 	if ni {
@@ -10,6 +10,8 @@ import (
 
 func Test_cli(t *testing.T) {
 	insecure := "-i"
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	state := filepath.Join(t.TempDir(), "state.json")
 	tests := []struct {
 		name    string
 		args    []string
@@ -65,6 +67,16 @@ func Test_cli(t *testing.T) {
 			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "7.txt")},
 			wantErr: true,
 		},
+		{
+			name:    "list+priority",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "8.txt"), "--label-weights", "stg=100"},
+			wantErr: false,
+		},
+		{
+			name:    "list+invalid label weights",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "8.txt"), "--label-weights", "stg"},
+			wantErr: true,
+		},
 		{
 			name:    "timeout",
 			args:    []string{appName, insecure, "-d", addr, "-t", "10s"},
@@ -90,6 +102,161 @@ func Test_cli(t *testing.T) {
 			args:    []string{appName, insecure, "-d", addr, "-o", "backlog"},
 			wantErr: false,
 		},
+		{
+			name:    "output nagios",
+			args:    []string{appName, insecure, "-d", addr, "-o", "nagios", "--warn-days", "0"},
+			wantErr: false,
+		},
+		{
+			name:    "output nagios critical",
+			args:    []string{appName, insecure, "-d", addr, "-o", "nagios", "--crit-days", "1000000"},
+			wantErr: true,
+		},
+		{
+			name:    "output text",
+			args:    []string{appName, insecure, "-d", addr, "-o", "text"},
+			wantErr: false,
+		},
+		{
+			name:    "output xlsx",
+			args:    []string{appName, insecure, "-d", addr, "-o", "xlsx", "--output-file", filepath.Join(t.TempDir(), "report.xlsx")},
+			wantErr: false,
+		},
+		{
+			name:    "output xlsx without output file",
+			args:    []string{appName, insecure, "-d", addr, "-o", "xlsx"},
+			wantErr: true,
+		},
+		{
+			name:    "output file alias",
+			args:    []string{appName, insecure, "-d", addr, "-O", filepath.Join(t.TempDir(), "report.json")},
+			wantErr: false,
+		},
+		{
+			name:    "output file in missing dir",
+			args:    []string{appName, insecure, "-d", addr, "-O", filepath.Join(t.TempDir(), "missing", "report.json")},
+			wantErr: true,
+		},
+		{
+			name:    "color always",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--color", "always"},
+			wantErr: false,
+		},
+		{
+			name:    "color invalid",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--color", "rainbow"},
+			wantErr: true,
+		},
+		{
+			name:    "columns",
+			args:    []string{appName, insecure, "-d", addr, "--columns", "DomainName,NotAfter,DaysLeft"},
+			wantErr: false,
+		},
+		{
+			name:    "columns table",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--columns", "daysleft,domainname"},
+			wantErr: false,
+		},
+		{
+			name:    "columns ndjson",
+			args:    []string{appName, insecure, "-d", addr, "-o", "ndjson", "--columns", "DomainName,DaysLeft"},
+			wantErr: false,
+		},
+		{
+			name:    "columns unknown",
+			args:    []string{appName, insecure, "-d", addr, "--columns", "DomainName,Expiry"},
+			wantErr: true,
+		},
+		{
+			name:    "columns with no timeinfo",
+			args:    []string{appName, insecure, "-d", addr, "--columns", "DomainName", "-n"},
+			wantErr: true,
+		},
+		{
+			name:    "columns prometheus",
+			args:    []string{appName, insecure, "-d", addr, "-o", "prometheus", "--columns", "DomainName"},
+			wantErr: true,
+		},
+		{
+			name:    "computed fields",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--computed-fields", filepath.Join("testdata", "computed.txt")},
+			wantErr: false,
+		},
+		{
+			name:    "computed fields in columns",
+			args:    []string{appName, insecure, "-d", addr, "--computed-fields", filepath.Join("testdata", "computed.txt"), "--columns", "DomainName,urgent"},
+			wantErr: false,
+		},
+		{
+			name:    "computed fields not found",
+			args:    []string{appName, insecure, "-d", addr, "--computed-fields", filepath.Join("testdata", "missing.txt")},
+			wantErr: true,
+		},
+		{
+			name:    "sort by days left",
+			args:    []string{appName, insecure, "-d", addr, "--sort", "daysleft"},
+			wantErr: false,
+		},
+		{
+			name:    "sort reverse",
+			args:    []string{appName, insecure, "-d", addr, "-d", addr, "--sort", "issuer", "--reverse"},
+			wantErr: false,
+		},
+		{
+			name:    "sort with top",
+			args:    []string{appName, insecure, "-d", addr, "--top", "1", "--sort", "domain"},
+			wantErr: false,
+		},
+		{
+			name:    "sort invalid",
+			args:    []string{appName, insecure, "-d", addr, "--sort", "expiry"},
+			wantErr: true,
+		},
+		{
+			name:    "output cmdb",
+			args:    []string{appName, insecure, "-d", addr, "-o", "cmdb"},
+			wantErr: false,
+		},
+		{
+			name:    "output cmdb with mapping",
+			args:    []string{appName, insecure, "-d", addr, "-o", "cmdb", "--cmdb-mapping", "testdata/cmdb.txt"},
+			wantErr: false,
+		},
+		{
+			name:    "cmdb mapping without cmdb output",
+			args:    []string{appName, insecure, "-d", addr, "--cmdb-mapping", "testdata/cmdb.txt"},
+			wantErr: true,
+		},
+		{
+			name:    "cmdb mapping not found",
+			args:    []string{appName, insecure, "-d", addr, "-o", "cmdb", "--cmdb-mapping", "testdata/missing.txt"},
+			wantErr: true,
+		},
+		{
+			name:    "output file",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--output-file", filepath.Join(t.TempDir(), "report.txt")},
+			wantErr: false,
+		},
+		{
+			name:    "output junit",
+			args:    []string{appName, insecure, "-d", addr, "-o", "junit", "--warn-days", "0"},
+			wantErr: false,
+		},
+		{
+			name:    "output junit failed host",
+			args:    []string{appName, insecure, "-d", addr + ",127.0.0.1:1", "-o", "junit"},
+			wantErr: true,
+		},
+		{
+			name:    "output nagios unknown",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "6.txt"), "-o", "nagios"},
+			wantErr: true,
+		},
+		{
+			name:    "output prometheus",
+			args:    []string{appName, insecure, "-d", addr, "-o", "prometheus"},
+			wantErr: false,
+		},
 		{
 			name:    "output unknown format",
 			args:    []string{appName, insecure, "-d", addr, "-o", "unknown"},
@@ -105,6 +272,446 @@ func Test_cli(t *testing.T) {
 			args:    []string{appName, insecure, "-d", addr, "-z", "UTC"},
 			wantErr: false,
 		},
+		{
+			name:    "adaptive timeout",
+			args:    []string{appName, insecure, "-d", addr, "-a"},
+			wantErr: false,
+		},
+		{
+			name:    "unique certs",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "1.txt"), "-u", "-o", "table"},
+			wantErr: false,
+		},
+		{
+			name:    "servername",
+			args:    []string{appName, insecure, "-d", addr, "-s", host},
+			wantErr: false,
+		},
+		{
+			name:    "cidr",
+			args:    []string{appName, insecure, "-d", "127.0.0.1/32:8443"},
+			wantErr: false,
+		},
+		{
+			name:    "cidr invalid",
+			args:    []string{appName, insecure, "-d", "127.0.0.1/33:8443"},
+			wantErr: true,
+		},
+		{
+			name:    "starttls unknown protocol",
+			args:    []string{appName, insecure, "-d", addr, "--starttls", "unknown"},
+			wantErr: true,
+		},
+		{
+			name:    "selftest",
+			args:    []string{appName, "--selftest"},
+			wantErr: false,
+		},
+		{
+			name:    "selftest with domain",
+			args:    []string{appName, "--selftest", "-d", addr},
+			wantErr: true,
+		},
+		{
+			name:    "chaos dns failure",
+			args:    []string{appName, insecure, "-d", addr, "--chaos-dns-failure-rate", "1"},
+			wantErr: true,
+		},
+		{
+			name:    "chaos malformed chain",
+			args:    []string{appName, insecure, "-d", addr, "--chaos-malformed-chain-rate", "1"},
+			wantErr: true,
+		},
+		{
+			name:    "chaos slow handshake",
+			args:    []string{appName, insecure, "-d", addr, "-t", "1s", "--chaos-handshake-delay", "2s"},
+			wantErr: true,
+		},
+		{
+			name:    "chaos invalid rate",
+			args:    []string{appName, insecure, "-d", addr, "--chaos-dns-failure-rate", "2"},
+			wantErr: true,
+		},
+		{
+			name:    "fetch",
+			args:    []string{appName, insecure, "fetch", "-d", addr},
+			wantErr: false,
+		},
+		{
+			name:    "fetch without domain",
+			args:    []string{appName, insecure, "fetch"},
+			wantErr: true,
+		},
+		{
+			name:    "certdiff",
+			args:    []string{appName, insecure, "certdiff", addr, "127.0.0.1:8443"},
+			wantErr: false,
+		},
+		{
+			name:    "certdiff one target",
+			args:    []string{appName, insecure, "certdiff", addr},
+			wantErr: true,
+		},
+		{
+			name:    "gate",
+			args:    []string{appName, insecure, "--warn-days", "0", "gate", "-d", addr, "--baseline", filepath.Join("testdata", "baseline.json")},
+			wantErr: false,
+		},
+		{
+			name:    "gate regression",
+			args:    []string{appName, insecure, "gate", "-d", addr, "--baseline", filepath.Join("testdata", "baseline.json")},
+			wantErr: true,
+		},
+		{
+			name:    "gate regression allowed",
+			args:    []string{appName, insecure, "gate", "-d", addr, "--baseline", filepath.Join("testdata", "baseline.json"), "--max-regressions", "1"},
+			wantErr: false,
+		},
+		{
+			name:    "gate failing host",
+			args:    []string{appName, insecure, "--warn-days", "0", "gate", "-d", addr + ",127.0.0.1:1", "--baseline", filepath.Join("testdata", "baseline.json")},
+			wantErr: true,
+		},
+		{
+			name:    "gate without baseline",
+			args:    []string{appName, insecure, "gate", "-d", addr},
+			wantErr: true,
+		},
+		{
+			name:    "gate negative max regressions",
+			args:    []string{appName, insecure, "gate", "-d", addr, "--baseline", filepath.Join("testdata", "baseline.json"), "--max-regressions", "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "changes",
+			args:    []string{appName, insecure, "changes", "-d", addr, "--state", filepath.Join(t.TempDir(), "state.json")},
+			wantErr: false,
+		},
+		{
+			name:    "changes with failing host",
+			args:    []string{appName, insecure, "changes", "-d", addr + ",127.0.0.1:1", "--state", filepath.Join(t.TempDir(), "state.json")},
+			wantErr: false,
+		},
+		{
+			name:    "changes without state",
+			args:    []string{appName, insecure, "changes", "-d", addr},
+			wantErr: true,
+		},
+		{
+			name:    "changes with invalid state",
+			args:    []string{appName, insecure, "changes", "-d", addr, "--state", filepath.Join("testdata", "inventory.csv")},
+			wantErr: true,
+		},
+		{
+			name:    "reconcile",
+			args:    []string{appName, insecure, "-o", "table", "reconcile", "-d", addr, "--inventory", filepath.Join("testdata", "inventory.csv")},
+			wantErr: false,
+		},
+		{
+			name:    "reconcile without inventory",
+			args:    []string{appName, insecure, "reconcile", "-d", addr},
+			wantErr: true,
+		},
+		{
+			name:    "reconcile nagios output",
+			args:    []string{appName, insecure, "-o", "nagios", "reconcile", "-d", addr, "--inventory", filepath.Join("testdata", "inventory.json")},
+			wantErr: true,
+		},
+		{
+			name:    "top",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "1.txt"), "--top", "1"},
+			wantErr: false,
+		},
+		{
+			name:    "top zero",
+			args:    []string{appName, insecure, "-d", addr, "--top", "0"},
+			wantErr: true,
+		},
+		{
+			name:    "locale",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--locale", "ja-JP"},
+			wantErr: false,
+		},
+		{
+			name:    "locale unknown",
+			args:    []string{appName, insecure, "-d", addr, "--locale", "xx-XX"},
+			wantErr: true,
+		},
+		{
+			name:    "wide ambiguous",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--wide-ambiguous"},
+			wantErr: false,
+		},
+		{
+			name:    "checkpoint",
+			args:    []string{appName, insecure, "-d", addr, "--checkpoint", checkpoint},
+			wantErr: false,
+		},
+		{
+			name:    "checkpoint resume",
+			args:    []string{appName, insecure, "-d", addr, "--checkpoint", checkpoint, "--resume"},
+			wantErr: false,
+		},
+		{
+			name:    "resume without checkpoint",
+			args:    []string{appName, insecure, "-d", addr, "--resume"},
+			wantErr: true,
+		},
+		{
+			name:    "probe versions",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--probe-versions"},
+			wantErr: false,
+		},
+		{
+			name:    "smime",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--smime"},
+			wantErr: false,
+		},
+		{
+			name:    "warn days not reached",
+			args:    []string{appName, insecure, "-d", addr, "--warn-days", "0", "--crit-days", "0"},
+			wantErr: false,
+		},
+		{
+			name:    "crit days reached",
+			args:    []string{appName, insecure, "-d", addr, "--warn-days", "1000000", "--crit-days", "1000000"},
+			wantErr: true,
+		},
+		{
+			name:    "warn days less than crit days",
+			args:    []string{appName, insecure, "-d", addr, "--warn-days", "7", "--crit-days", "30"},
+			wantErr: true,
+		},
+		{
+			name:    "negative warn days",
+			args:    []string{appName, insecure, "-d", addr, "--warn-days", "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "preset",
+			args:    []string{appName, insecure, "-d", addr, "--preset", "winrm"},
+			wantErr: false,
+		},
+		{
+			name:    "preset unknown",
+			args:    []string{appName, insecure, "-d", addr, "--preset", "unknown"},
+			wantErr: true,
+		},
+		{
+			name:    "preset etcd without client cert",
+			args:    []string{appName, insecure, "-d", addr, "--preset", "etcd"},
+			wantErr: false,
+		},
+		{
+			name:    "client cert without key",
+			args:    []string{appName, insecure, "-d", addr, "--client-cert", filepath.Join("testdata", "1.txt")},
+			wantErr: true,
+		},
+		{
+			name:    "client cert invalid",
+			args:    []string{appName, insecure, "-d", addr, "--client-cert", filepath.Join("testdata", "1.txt"), "--client-key", filepath.Join("testdata", "1.txt")},
+			wantErr: true,
+		},
+		{
+			name:    "grpc health",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--grpc", "--grpc-health", "--grpc-service", "grpc.test.Echo"},
+			wantErr: false,
+		},
+		{
+			name:    "grpc health without grpc",
+			args:    []string{appName, insecure, "-d", addr, "--grpc-health"},
+			wantErr: true,
+		},
+		{
+			name:    "jitter and spread",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "1.txt"), "--jitter", "10ms", "--spread", "10ms"},
+			wantErr: false,
+		},
+		{
+			name:    "negative spread",
+			args:    []string{appName, insecure, "-d", addr, "--spread", "-1s"},
+			wantErr: true,
+		},
+		{
+			name:    "redact",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--redact", "sans,ips,domains"},
+			wantErr: false,
+		},
+		{
+			name:    "redact omit",
+			args:    []string{appName, insecure, "-d", addr, "--redact", "cn", "--redact-mode", "omit"},
+			wantErr: false,
+		},
+		{
+			name:    "redact unknown field",
+			args:    []string{appName, insecure, "-d", addr, "--redact", "issuer"},
+			wantErr: true,
+		},
+		{
+			name:    "redact mode without redact",
+			args:    []string{appName, insecure, "-d", addr, "--redact-mode", "omit"},
+			wantErr: true,
+		},
+		{
+			name:    "watch non-positive interval",
+			args:    []string{appName, insecure, "-d", addr, "--watch", "0s"},
+			wantErr: true,
+		},
+		{
+			name:    "watch with checkpoint",
+			args:    []string{appName, insecure, "-d", addr, "--watch", "1h", "--checkpoint", checkpoint},
+			wantErr: true,
+		},
+		{
+			name:    "watch changes without watch",
+			args:    []string{appName, insecure, "-d", addr, "--watch-changes"},
+			wantErr: true,
+		},
+		{
+			name:    "watch with junit output",
+			args:    []string{appName, insecure, "-d", addr, "--watch", "1h", "-o", "junit"},
+			wantErr: true,
+		},
+		{
+			name:    "policy",
+			args:    []string{appName, insecure, "-d", addr, "--policy", filepath.Join("testdata", "policy.yaml")},
+			wantErr: false,
+		},
+		{
+			name:    "policy violated",
+			args:    []string{appName, insecure, "-d", addr, "--policy", filepath.Join("testdata", "policy_violated.yaml")},
+			wantErr: true,
+		},
+		{
+			name:    "policy missing",
+			args:    []string{appName, insecure, "-d", addr, "--policy", filepath.Join("testdata", "missing.yaml")},
+			wantErr: true,
+		},
+		{
+			name:    "policy with nagios output",
+			args:    []string{appName, insecure, "-d", addr, "--policy", filepath.Join("testdata", "policy.yaml"), "-o", "nagios"},
+			wantErr: true,
+		},
+		{
+			name:    "keep going with a host that cannot be checked",
+			args:    []string{appName, insecure, "-d", addr + ",localhost:1", "--keep-going"},
+			wantErr: true,
+		},
+		{
+			name:    "keep going with nagios output",
+			args:    []string{appName, insecure, "-d", addr, "--keep-going", "-o", "nagios"},
+			wantErr: true,
+		},
+		{
+			name:    "watch with nagios output",
+			args:    []string{appName, insecure, "-d", addr, "--watch", "1h", "-o", "nagios"},
+			wantErr: true,
+		},
+		{
+			name:    "notify webhook invalid url",
+			args:    []string{appName, insecure, "-d", addr, "--notify-webhook", "hooks.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "notify format with template",
+			args:    []string{appName, insecure, "-d", addr, "--notify-webhook", "https://hooks.example.com/", "--notify-format", "slack", "--notify-template", filepath.Join("testdata", "notify.tmpl")},
+			wantErr: true,
+		},
+		{
+			name:    "notify unknown format",
+			args:    []string{appName, insecure, "-d", addr, "--notify-webhook", "https://hooks.example.com/", "--notify-format", "discord"},
+			wantErr: true,
+		},
+		{
+			name:    "notify template without webhook",
+			args:    []string{appName, insecure, "-d", addr, "--notify-template", filepath.Join("testdata", "notify.tmpl")},
+			wantErr: true,
+		},
+		{
+			name:    "check sans",
+			args:    []string{appName, insecure, "-d", addr, "-o", "table", "--check-sans"},
+			wantErr: false,
+		},
+		{
+			name:    "fallback ports",
+			args:    []string{appName, insecure, "-d", addr, "--fallback-ports", "443,8443"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid fallback port",
+			args:    []string{appName, insecure, "-d", addr, "--fallback-ports", "https"},
+			wantErr: true,
+		},
+		{
+			name:    "ndjson",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "1.txt"), "-o", "ndjson"},
+			wantErr: false,
+		},
+		{
+			name:    "ndjson with top",
+			args:    []string{appName, insecure, "-f", filepath.Join("testdata", "1.txt"), "-o", "ndjson", "--top", "1"},
+			wantErr: false,
+		},
+		{
+			name:    "trust store max age",
+			args:    []string{appName, insecure, "-d", addr, "--trust-store-max-age", "365"},
+			wantErr: false,
+		},
+		{
+			name:    "negative trust store max age",
+			args:    []string{appName, insecure, "-d", addr, "--trust-store-max-age", "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "hooks",
+			args:    []string{appName, insecure, "-d", addr, "--pre-hook", "cat > /dev/null", "--post-hook", "cat > /dev/null"},
+			wantErr: false,
+		},
+		{
+			name:    "pre-hook fails",
+			args:    []string{appName, insecure, "-d", addr, "--pre-hook", "exit 1"},
+			wantErr: true,
+		},
+		{
+			name:    "pac",
+			args:    []string{appName, insecure, "-d", addr, "--pac", filepath.Join("testdata", "proxy.pac")},
+			wantErr: false,
+		},
+		{
+			name:    "pac not found",
+			args:    []string{appName, insecure, "-d", addr, "--pac", filepath.Join("testdata", "missing.pac")},
+			wantErr: true,
+		},
+		{
+			name:    "fast",
+			args:    []string{appName, insecure, "-d", addr, "--fast"},
+			wantErr: false,
+		},
+		{
+			name:    "cipher suites and curves",
+			args:    []string{appName, insecure, "-d", addr, "--cipher-suites", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "--curves", "P256,P384"},
+			wantErr: false,
+		},
+		{
+			name:    "unknown cipher suite",
+			args:    []string{appName, insecure, "-d", addr, "--cipher-suites", "TLS_FOO"},
+			wantErr: true,
+		},
+		{
+			name:    "dump pem",
+			args:    []string{appName, insecure, "-d", addr, "--dump-pem", filepath.Join(t.TempDir(), "certs"), "--dump-chain"},
+			wantErr: false,
+		},
+		{
+			name:    "dump chain without dump pem",
+			args:    []string{appName, insecure, "-d", addr, "--dump-chain"},
+			wantErr: true,
+		},
+		{
+			name:    "fallback port out of range",
+			args:    []string{appName, insecure, "-d", addr, "--fallback-ports", "65536"},
+			wantErr: true,
+		},
 		{
 			name:    "completion bash",
 			args:    []string{appName, "-c", "bash"},
@@ -130,6 +737,186 @@ func Test_cli(t *testing.T) {
 			args:    []string{appName, "-1"},
 			wantErr: true,
 		},
+		{
+			name:    "domain and list",
+			args:    []string{appName, insecure, "-d", addr, "-f", filepath.Join("testdata", "1.txt")},
+			wantErr: false,
+		},
+		{
+			name:    "group by source",
+			args:    []string{appName, insecure, "-d", addr, "-f", filepath.Join("testdata", "1.txt"), "-o", "table", "--group-by-source"},
+			wantErr: false,
+		},
+		{
+			name:    "group by source json",
+			args:    []string{appName, insecure, "-d", addr, "-f", filepath.Join("testdata", "1.txt"), "--group-by-source"},
+			wantErr: false,
+		},
+		{
+			name:    "group by source with prometheus output",
+			args:    []string{appName, insecure, "-d", addr, "-o", "prometheus", "--group-by-source"},
+			wantErr: true,
+		},
+		{
+			name:    "port without cidr",
+			args:    []string{appName, insecure, "-d", addr, "--port", "8443"},
+			wantErr: true,
+		},
+		{
+			name:    "kube secrets without from kubernetes",
+			args:    []string{appName, insecure, "-d", addr, "--kube-secrets"},
+			wantErr: true,
+		},
+		{
+			name:    "from kubernetes with selftest",
+			args:    []string{appName, "--from-kubernetes", "--selftest"},
+			wantErr: true,
+		},
+		{
+			name:    "aws region without from aws",
+			args:    []string{appName, insecure, "-d", addr, "--aws-region", "us-east-1"},
+			wantErr: true,
+		},
+		{
+			name:    "from aws with unsupported service",
+			args:    []string{appName, insecure, "-d", addr, "--from-aws", "cloudfront"},
+			wantErr: true,
+		},
+		{
+			name:    "cert file",
+			args:    []string{appName, "--cert-file", filepath.Join("testdata", "cert.pem")},
+			wantErr: false,
+		},
+		{
+			name:    "cert file with domain",
+			args:    []string{appName, insecure, "-d", addr, "--cert-file", filepath.Join("testdata", "cert.pem"), "--group-by-source"},
+			wantErr: false,
+		},
+		{
+			name:    "pkcs12 cert file",
+			args:    []string{appName, "--cert-file", filepath.Join("testdata", "keystore.p12"), "--password", "changeit"},
+			wantErr: false,
+		},
+		{
+			name:    "pkcs12 cert file without password",
+			args:    []string{appName, "--cert-file", filepath.Join("testdata", "keystore.p12")},
+			wantErr: true,
+		},
+		{
+			name:    "password without cert file",
+			args:    []string{appName, insecure, "-d", addr, "--password", "changeit"},
+			wantErr: true,
+		},
+		{
+			name:    "missing cert file",
+			args:    []string{appName, "--cert-file", filepath.Join("testdata", "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "expect cert",
+			args:    []string{appName, insecure, "-d", addr, "--expect-cert", filepath.Join("testdata", "cert.pem")},
+			wantErr: false,
+		},
+		{
+			name:    "missing expected cert",
+			args:    []string{appName, insecure, "-d", addr, "--expect-cert", filepath.Join("testdata", "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "save and diff state",
+			args:    []string{appName, insecure, "-d", addr, "--save-state", state, "--diff-state", state, "-o", "table"},
+			wantErr: false,
+		},
+		{
+			name:    "diff state of a CSV file",
+			args:    []string{appName, insecure, "-d", addr, "--diff-state", filepath.Join("testdata", "inventory.csv")},
+			wantErr: true,
+		},
+		{
+			name:    "resolve",
+			args:    []string{appName, insecure, "-d", "backend.invalid:" + port, "--resolve", "backend.invalid:" + port + ":127.0.0.1"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid resolve",
+			args:    []string{appName, insecure, "-d", addr, "--resolve", "backend.invalid:443"},
+			wantErr: true,
+		},
+		{
+			name:    "ca file",
+			args:    []string{appName, insecure, "-d", addr, "--ca-file", filepath.Join("testdata", "cert.pem")},
+			wantErr: false,
+		},
+		{
+			name:    "ca file without cert",
+			args:    []string{appName, insecure, "-d", addr, "--ca-file", filepath.Join("testdata", "1.txt")},
+			wantErr: true,
+		},
+		{
+			name:    "doh and nameserver",
+			args:    []string{appName, insecure, "-d", addr, "--doh", "https://1.1.1.1/dns-query", "--nameserver", "1.1.1.1"},
+			wantErr: true,
+		},
+		{
+			name:    "doh over http",
+			args:    []string{appName, insecure, "-d", addr, "--doh", "http://1.1.1.1/dns-query"},
+			wantErr: true,
+		},
+		{
+			name:    "all ips",
+			args:    []string{appName, insecure, "-d", addr, "--all-ips"},
+			wantErr: false,
+		},
+		{
+			name:    "all ips with checkpoint",
+			args:    []string{appName, insecure, "-d", addr, "--all-ips", "--checkpoint", checkpoint},
+			wantErr: true,
+		},
+		{
+			name:    "rate",
+			args:    []string{appName, insecure, "-d", addr, "--rate", "10/s"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid rate",
+			args:    []string{appName, insecure, "-d", addr, "--rate", "fast"},
+			wantErr: true,
+		},
+		{
+			name:    "negative concurrency",
+			args:    []string{appName, insecure, "-d", addr, "--concurrency", "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "capabilities",
+			args:    []string{appName, "capabilities", "--json"},
+			wantErr: false,
+		},
+		{
+			name:    "ct without domain",
+			args:    []string{appName, "ct"},
+			wantErr: true,
+		},
+		{
+			name:    "history without database",
+			args:    []string{appName, "history"},
+			wantErr: true,
+		},
+		{
+			name:    "history of a missing database",
+			args:    []string{appName, "history", "--history", filepath.Join("testdata", "missing.sqlite")},
+			wantErr: true,
+		},
+		{
+			name:    "timezones",
+			args:    []string{appName, "timezones"},
+			wantErr: false,
+		},
+		{
+			name:    "timezones no match",
+			args:    []string{appName, "timezones", "Nowhere"},
+			wantErr: true,
+		},
 		{
 			name:    "no flag provided",
 			args:    []string{appName},
@@ -150,3 +937,58 @@ func Test_cli(t *testing.T) {
 		})
 	}
 }
+
+func Test_cli_env(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "domain and insecure",
+			env: map[string]string{
+				"TLC3_DOMAIN":   addr,
+				"TLC3_INSECURE": "true",
+			},
+			wantErr: false,
+		},
+		{
+			name: "warn days",
+			env: map[string]string{
+				"TLC3_DOMAIN":    addr,
+				"TLC3_INSECURE":  "true",
+				"TLC3_WARN_DAYS": "30",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid fallback port",
+			env: map[string]string{
+				"TLC3_DOMAIN":         addr,
+				"TLC3_INSECURE":       "true",
+				"TLC3_FALLBACK_PORTS": "https",
+			},
+			wantErr: true,
+		},
+		{
+			name: "required flag missing",
+			env: map[string]string{
+				"TLC3_DOMAIN": addr,
+				"TLC3_RESUME": "true",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(canonicalName+"_NON_INTERACTIVE", "true")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			err := newApp(io.Discard).RunContext(context.Background(), []string{appName})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
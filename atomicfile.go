@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// atomicFile is written in a temporary file next to path and renamed over it
+// on commit, so that a reader of path sees either the previous output or the
+// complete new one, never a partial write.
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	path = filepath.Clean(path)
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path}, nil
+}
+
+// commit flushes the temporary file to disk and renames it to the path.
+func (f *atomicFile) commit() error {
+	if err := f.Sync(); err != nil {
+		f.abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	// CreateTemp creates the file with 0600, which is tighter than a file
+	// created by a redirect of the shell
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), f.path)
+}
+
+// abort discards the temporary file and leaves the path as it was.
+func (f *atomicFile) abort() {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
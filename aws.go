@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// sourceAWS is the source of the certs listed in an AWS account.
+const sourceAWS = "aws"
+
+const (
+	awsACM = "acm"
+	awsELB = "elb"
+)
+
+// awsServices are the services certs can be listed from.
+var awsServices = []string{awsACM, awsELB}
+
+// awsKeyTypes are the key types of the ACM certs listed, as only RSA 2048
+// ones are listed by default.
+var awsKeyTypes = []string{"RSA_1024", "RSA_2048", "RSA_3072", "RSA_4096", "EC_prime256v1", "EC_secp384r1", "EC_secp521r1"}
+
+// awsStuckRenewals are the statuses of an ACM managed renewal that will not
+// complete without someone acting on it.
+var awsStuckRenewals = []string{"PENDING_VALIDATION", "FAILED"}
+
+// awsAccount lists the certs of ACM and of the HTTPS and TLS listeners of the
+// Application and Network Load Balancers in an AWS account through the AWS
+// CLI, so that its profiles, SSO sessions and roles work as they do for the
+// operator. The certs are reported as stored, without connecting to anything.
+type awsAccount struct {
+	aws      string
+	profile  string
+	regions  []string
+	services []string
+}
+
+func newAWSAccount(services []string, profile string, regions []string) (*awsAccount, error) {
+	for _, s := range services {
+		if !slices.Contains(awsServices, s) {
+			return nil, fmt.Errorf("unsupported AWS service %q: must be one of %s", s, strings.Join(awsServices, ", "))
+		}
+	}
+	if len(regions) == 0 {
+		// the region of the profile or of the environment
+		regions = []string{""}
+	}
+	return &awsAccount{aws: "aws", profile: profile, regions: regions, services: services}, nil
+}
+
+type awsACMList struct {
+	CertificateSummaryList []struct {
+		CertificateArn string `json:"CertificateArn"`
+	} `json:"CertificateSummaryList"`
+}
+
+type awsACMDescription struct {
+	Certificate struct {
+		DomainName     string `json:"DomainName"`
+		Status         string `json:"Status"`
+		RenewalSummary *struct {
+			RenewalStatus       string `json:"RenewalStatus"`
+			RenewalStatusReason string `json:"RenewalStatusReason"`
+		} `json:"RenewalSummary"`
+	} `json:"Certificate"`
+}
+
+type awsACMCertificate struct {
+	Certificate      string `json:"Certificate"`
+	CertificateChain string `json:"CertificateChain"`
+}
+
+type awsIAMCertificate struct {
+	ServerCertificate struct {
+		CertificateBody  string `json:"CertificateBody"`
+		CertificateChain string `json:"CertificateChain"`
+	} `json:"ServerCertificate"`
+}
+
+type awsLoadBalancerList struct {
+	LoadBalancers []struct {
+		LoadBalancerArn string `json:"LoadBalancerArn"`
+		DNSName         string `json:"DNSName"`
+	} `json:"LoadBalancers"`
+}
+
+type awsListenerList struct {
+	Listeners []struct {
+		ListenerArn string `json:"ListenerArn"`
+		Protocol    string `json:"Protocol"`
+	} `json:"Listeners"`
+}
+
+type awsListenerCertificates struct {
+	Certificates []struct {
+		CertificateArn string `json:"CertificateArn"`
+	} `json:"Certificates"`
+}
+
+// awsCert is a cert stored in ACM or IAM, with its ACM domain name and the
+// status of a stuck renewal.
+type awsCert struct {
+	domain  string
+	renewal string
+	chain   string
+}
+
+// certInfos returns the certs of the account in each region, with the ARN of
+// the ACM cert or of the listener as Resource. An ACM cert without a cert,
+// such as one still pending validation, is left out, and one whose renewal is
+// stuck is logged and has the status as Renewal.
+func (a *awsAccount) certInfos(ctx context.Context, loc *time.Location) ([]*certInfo, error) {
+	var infos []*certInfo
+	now := time.Now()
+	for _, region := range a.regions {
+		certs := make(map[string]*awsCert)
+		for _, service := range a.services {
+			var (
+				res []*certInfo
+				err error
+			)
+			switch service {
+			case awsACM:
+				res, err = a.acmInfos(ctx, region, certs, now, loc)
+			case awsELB:
+				res, err = a.elbInfos(ctx, region, certs, now, loc)
+			}
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, res...)
+		}
+	}
+	return infos, nil
+}
+
+func (a *awsAccount) acmInfos(ctx context.Context, region string, certs map[string]*awsCert, now time.Time, loc *time.Location) ([]*certInfo, error) {
+	var list awsACMList
+	if err := a.call(ctx, region, &list, "acm", "list-certificates", "--includes", "keyTypes="+strings.Join(awsKeyTypes, ",")); err != nil {
+		return nil, err
+	}
+	var infos []*certInfo
+	for _, s := range list.CertificateSummaryList {
+		cert, err := a.cert(ctx, region, s.CertificateArn, certs)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil {
+			continue
+		}
+		info, err := cert.info(now, loc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", s.CertificateArn, err)
+		}
+		info.DomainName = cert.domain
+		info.Resource = s.CertificateArn
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (a *awsAccount) elbInfos(ctx context.Context, region string, certs map[string]*awsCert, now time.Time, loc *time.Location) ([]*certInfo, error) {
+	var lbs awsLoadBalancerList
+	if err := a.call(ctx, region, &lbs, "elbv2", "describe-load-balancers"); err != nil {
+		return nil, err
+	}
+	var infos []*certInfo
+	for _, lb := range lbs.LoadBalancers {
+		var listeners awsListenerList
+		if err := a.call(ctx, region, &listeners, "elbv2", "describe-listeners", "--load-balancer-arn", lb.LoadBalancerArn); err != nil {
+			return nil, err
+		}
+		for _, l := range listeners.Listeners {
+			if l.Protocol != "HTTPS" && l.Protocol != "TLS" {
+				continue
+			}
+			var lcs awsListenerCertificates
+			if err := a.call(ctx, region, &lcs, "elbv2", "describe-listener-certificates", "--listener-arn", l.ListenerArn); err != nil {
+				return nil, err
+			}
+			for _, lc := range lcs.Certificates {
+				cert, err := a.cert(ctx, region, lc.CertificateArn, certs)
+				if err != nil {
+					return nil, err
+				}
+				if cert == nil {
+					continue
+				}
+				info, err := cert.info(now, loc)
+				if err != nil {
+					return nil, fmt.Errorf("cannot parse %s: %w", lc.CertificateArn, err)
+				}
+				info.DomainName = lb.DNSName
+				info.Resource = l.ListenerArn
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos, nil
+}
+
+// cert returns the cert of arn, stored in ACM or IAM, once per region. It
+// returns nil for an ACM cert that has not been issued.
+func (a *awsAccount) cert(ctx context.Context, region, arn string, certs map[string]*awsCert) (*awsCert, error) {
+	if cert, ok := certs[arn]; ok {
+		return cert, nil
+	}
+	var cert *awsCert
+	if strings.Contains(arn, ":iam:") {
+		var iam awsIAMCertificate
+		// IAM is global and has no region
+		if err := a.call(ctx, "", &iam, "iam", "get-server-certificate", "--server-certificate-name", path.Base(arn)); err != nil {
+			return nil, err
+		}
+		cert = &awsCert{chain: iam.ServerCertificate.CertificateBody + "\n" + iam.ServerCertificate.CertificateChain}
+	} else {
+		var desc awsACMDescription
+		if err := a.call(ctx, region, &desc, "acm", "describe-certificate", "--certificate-arn", arn); err != nil {
+			return nil, err
+		}
+		d := desc.Certificate
+		if d.Status != "ISSUED" && d.Status != "EXPIRED" {
+			log.Debug("no cert issued", "arn", arn, "status", d.Status)
+			certs[arn] = nil
+			return nil, nil
+		}
+		var c awsACMCertificate
+		if err := a.call(ctx, region, &c, "acm", "get-certificate", "--certificate-arn", arn); err != nil {
+			return nil, err
+		}
+		cert = &awsCert{domain: d.DomainName, chain: c.Certificate + "\n" + c.CertificateChain}
+		if r := d.RenewalSummary; r != nil && slices.Contains(awsStuckRenewals, r.RenewalStatus) {
+			cert.renewal = r.RenewalStatus
+			if r.RenewalStatusReason != "" {
+				cert.renewal += ": " + r.RenewalStatusReason
+			}
+			log.Warn("ACM renewal is stuck", "arn", arn, "domain", d.DomainName, "status", cert.renewal)
+		}
+	}
+	certs[arn] = cert
+	return cert, nil
+}
+
+func (c *awsCert) info(now time.Time, loc *time.Location) (*certInfo, error) {
+	chain, err := parsePEMChain([]byte(c.chain))
+	if err != nil {
+		return nil, err
+	}
+	info := newCertInfo(chain, now, loc)
+	info.Renewal = c.renewal
+	return info, nil
+}
+
+// call runs the AWS CLI with args in region and decodes its output into v.
+func (a *awsAccount) call(ctx context.Context, region string, v any, args ...string) error {
+	args = append(args, "--output", "json")
+	if a.profile != "" {
+		args = append(args, "--profile", a.profile)
+	}
+	if region != "" {
+		args = append(args, "--region", region)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, a.aws, args...) // #nosec G204
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("aws %s: %s", strings.Join(args, " "), msg)
+		}
+		return fmt.Errorf("aws %s: %w", strings.Join(args, " "), err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return fmt.Errorf("cannot parse the output of aws %s: %w", strings.Join(args[:2], " "), err)
+	}
+	return nil
+}
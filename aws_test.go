@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testACMIssued  = "arn:aws:acm:us-east-1:123456789012:certificate/issued"
+	testACMStuck   = "arn:aws:acm:us-east-1:123456789012:certificate/stuck"
+	testACMPending = "arn:aws:acm:us-east-1:123456789012:certificate/pending"
+	testIAMCert    = "arn:aws:iam::123456789012:server-certificate/legacy"
+	testListener   = "arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/web/1/2"
+)
+
+// newTestAWS writes an AWS CLI that answers from the files in its directory
+// named after the service, the operation and the last part of the ARN or
+// name it is given, and logs its arguments.
+func newTestAWS(t *testing.T, files map[string]any) (*awsAccount, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, v := range files {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := `#!/bin/sh
+dir=$(dirname "$0")
+echo "$@" >> "$dir/args"
+name="$1.$2"
+while [ $# -gt 0 ]; do
+  case "$1" in
+  --certificate-arn|--load-balancer-arn|--listener-arn|--server-certificate-name)
+    name="$name.$(basename "$2")"
+    ;;
+  esac
+  shift
+done
+if [ -f "$dir/$name" ]; then cat "$dir/$name"; exit 0; fi
+echo "An error occurred (AccessDenied) when calling $name" >&2
+exit 254
+`
+	path := filepath.Join(dir, "aws")
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil { // #nosec G306
+		t.Fatal(err)
+	}
+	return &awsAccount{aws: path, regions: []string{""}}, filepath.Join(dir, "args")
+}
+
+func testAWSFiles(t *testing.T) map[string]any {
+	t.Helper()
+	config := newTestTLSConfig(t)
+	crt := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: config.Certificates[0].Certificate[0]}))
+	summaries := []map[string]string{
+		{"CertificateArn": testACMIssued},
+		{"CertificateArn": testACMStuck},
+		{"CertificateArn": testACMPending},
+	}
+	return map[string]any{
+		"acm.list-certificates": map[string]any{"CertificateSummaryList": summaries},
+		"acm.describe-certificate.issued": map[string]any{"Certificate": map[string]any{
+			"DomainName": "www.example.com", "Status": "ISSUED",
+			"RenewalSummary": map[string]string{"RenewalStatus": "PENDING_AUTO_RENEWAL"},
+		}},
+		"acm.describe-certificate.stuck": map[string]any{"Certificate": map[string]any{
+			"DomainName": "api.example.com", "Status": "ISSUED",
+			"RenewalSummary": map[string]string{"RenewalStatus": "FAILED", "RenewalStatusReason": "CAA_ERROR"},
+		}},
+		"acm.describe-certificate.pending": map[string]any{"Certificate": map[string]any{
+			"DomainName": "new.example.com", "Status": "PENDING_VALIDATION",
+		}},
+		"acm.get-certificate.issued": map[string]string{"Certificate": crt},
+		"acm.get-certificate.stuck":  map[string]string{"Certificate": crt, "CertificateChain": crt},
+		"elbv2.describe-load-balancers": map[string]any{"LoadBalancers": []map[string]string{
+			{"LoadBalancerArn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/web/1", "DNSName": "web-1.us-east-1.elb.amazonaws.com"},
+		}},
+		"elbv2.describe-listeners.1": map[string]any{"Listeners": []map[string]string{
+			{"ListenerArn": testListener, "Protocol": "HTTPS"},
+			{"ListenerArn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/web/1/3", "Protocol": "HTTP"},
+		}},
+		"elbv2.describe-listener-certificates.2": map[string]any{"Certificates": []map[string]string{
+			{"CertificateArn": testACMIssued},
+			{"CertificateArn": testIAMCert},
+		}},
+		"iam.get-server-certificate.legacy": map[string]any{"ServerCertificate": map[string]string{"CertificateBody": crt}},
+	}
+}
+
+func Test_newAWSAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []string
+		regions  []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "default region",
+			services: []string{awsACM, awsELB},
+			want:     []string{""},
+		},
+		{
+			name:     "regions",
+			services: []string{awsACM},
+			regions:  []string{"us-east-1", "ap-northeast-1"},
+			want:     []string{"us-east-1", "ap-northeast-1"},
+		},
+		{
+			name:     "unsupported service",
+			services: []string{"cloudfront"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newAWSAccount(tt.services, "", tt.regions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newAWSAccount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got.regions); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_awsAccount_certInfos(t *testing.T) {
+	type result struct {
+		DomainName string
+		Resource   string
+		Renewal    string
+	}
+	tests := []struct {
+		name     string
+		services []string
+		profile  string
+		region   string
+		broken   string
+		want     []result
+		wantArgs string
+		wantErr  bool
+	}{
+		{
+			name:     "acm",
+			services: []string{awsACM},
+			want: []result{
+				{DomainName: "www.example.com", Resource: testACMIssued},
+				{DomainName: "api.example.com", Resource: testACMStuck, Renewal: "FAILED: CAA_ERROR"},
+			},
+			wantArgs: "acm list-certificates --includes keyTypes=RSA_1024,RSA_2048,RSA_3072,RSA_4096,EC_prime256v1,EC_secp384r1,EC_secp521r1 --output json",
+		},
+		{
+			name:     "elb",
+			services: []string{awsELB},
+			profile:  "prod",
+			region:   "us-east-1",
+			want: []result{
+				{DomainName: "web-1.us-east-1.elb.amazonaws.com", Resource: testListener},
+				{DomainName: "web-1.us-east-1.elb.amazonaws.com", Resource: testListener},
+			},
+			wantArgs: "elbv2 describe-load-balancers --output json --profile prod --region us-east-1",
+		},
+		{
+			name:     "cli failure",
+			services: []string{awsACM},
+			broken:   "acm.list-certificates",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid cert",
+			services: []string{awsACM},
+			broken:   "acm.get-certificate.issued",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := testAWSFiles(t)
+			if tt.broken != "" {
+				if strings.Contains(tt.broken, "get-certificate") {
+					files[tt.broken] = map[string]string{"Certificate": "not a cert"}
+				} else {
+					delete(files, tt.broken)
+				}
+			}
+			a, args := newTestAWS(t, files)
+			a.services = tt.services
+			a.profile = tt.profile
+			a.regions = []string{tt.region}
+			infos, err := a.certInfos(context.Background(), time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("certInfos() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got []result
+			for _, info := range infos {
+				if info.CommonName != "starttls test" {
+					t.Errorf("CommonName = %q", info.CommonName)
+				}
+				got = append(got, result{DomainName: info.DomainName, Resource: info.Resource, Renewal: info.Renewal})
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+			b, err := os.ReadFile(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first, _, _ := strings.Cut(string(b), "\n"); first != tt.wantArgs {
+				t.Errorf("args = %q, want %q", first, tt.wantArgs)
+			}
+		})
+	}
+}
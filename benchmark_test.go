@@ -9,7 +9,25 @@ import (
 func Benchmark(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := getCertList(context.Background(), []string{"localhost:8443"}, 5*time.Second, true, time.Local)
+		_, err := getCertList(context.Background(), []string{"localhost:8443"}, &options{timeout: 5 * time.Second, insecure: true, location: time.Local})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFarm(b *testing.B) {
+	f, err := newFarm(selftestHosts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.close()
+	addrs := f.addrs()
+	opts := &options{timeout: 5 * time.Second, insecure: true, location: time.Local}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		connCache.purge()
+		_, err := getCertList(context.Background(), addrs, opts)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	burndownCSV  = "csv"
+	burndownHTML = "html"
+)
+
+var burndownFormats = []string{burndownCSV, burndownHTML}
+
+// size of the chart of the burn-down, and the margin left for the axes
+const (
+	burndownWidth  = 800
+	burndownHeight = 320
+	burndownMargin = 40
+)
+
+var burndownTemplate = template.Must(template.New("burndown").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Certificate expiry burn-down</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: right; }
+</style>
+</head>
+<body>
+<h1>Certificate expiry burn-down</h1>
+<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" font-size="12">
+<line x1="{{.Left}}" y1="{{.Bottom}}" x2="{{.Right}}" y2="{{.Bottom}}" stroke="#999"/>
+<line x1="{{.Left}}" y1="{{.Top}}" x2="{{.Left}}" y2="{{.Bottom}}" stroke="#999"/>
+<text x="{{.Left}}" y="{{.Top}}" dx="-6" text-anchor="end">{{.Max}}</text>
+<text x="{{.Left}}" y="{{.Bottom}}" dx="-6" text-anchor="end">0</text>
+<text x="{{.Left}}" y="{{.Bottom}}" dy="16">{{.First}}</text>
+<text x="{{.Right}}" y="{{.Bottom}}" dy="16" text-anchor="end">{{.Last}}</text>
+{{- range $i, $s := .Series}}
+<polyline fill="none" stroke="{{$s.Color}}" stroke-width="2" points="{{$s.Points}}"/>
+<text x="{{$.Right}}" y="{{$.Top}}" dy="{{$s.Offset}}" text-anchor="end" fill="{{$s.Color}}">{{$s.Name}}</text>
+{{- end}}
+</svg>
+<table>
+<tr><th>Day</th><th>Expired</th><th>Within 30 days</th><th>Within 60 days</th><th>Within 90 days</th><th>Total</th></tr>
+{{- range .Days}}
+<tr><td>{{.Day.Format "2006-01-02"}}</td><td>{{.Expired}}</td><td>{{.Within30}}</td><td>{{.Within60}}</td><td>{{.Within90}}</td><td>{{.Total}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+type burndownSeries struct {
+	Name   string
+	Color  string
+	Points string
+	Offset int
+}
+
+// toBurndown writes the counts of days as CSV, or as an HTML page with a line
+// chart of them over the days and a table of the counts.
+func toBurndown(days []burndownDay, w io.Writer, format string) error {
+	switch format {
+	case burndownCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"day", "expired", "within_30", "within_60", "within_90", "total"}); err != nil {
+			return err
+		}
+		for _, d := range days {
+			if err := cw.Write([]string{
+				d.Day.Format(time.DateOnly),
+				strconv.Itoa(d.Expired),
+				strconv.Itoa(d.Within30),
+				strconv.Itoa(d.Within60),
+				strconv.Itoa(d.Within90),
+				strconv.Itoa(d.Total),
+			}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case burndownHTML:
+		return burndownTemplate.Execute(w, newBurndownChart(days))
+	}
+	return fmt.Errorf("invalid format for burndown: allowed values: %s", pipeJoin(burndownFormats))
+}
+
+type burndownChart struct {
+	Width, Height            int
+	Left, Right, Top, Bottom int
+	Max                      int
+	First, Last              string
+	Series                   []burndownSeries
+	Days                     []burndownDay
+}
+
+// newBurndownChart lays out a series per count, scaled to the largest count
+// of all, with the days spaced evenly from left to right.
+func newBurndownChart(days []burndownDay) *burndownChart {
+	c := &burndownChart{
+		Width:  burndownWidth,
+		Height: burndownHeight,
+		Left:   burndownMargin,
+		Right:  burndownWidth - burndownMargin,
+		Top:    burndownMargin,
+		Bottom: burndownHeight - burndownMargin,
+		Max:    1,
+		Days:   days,
+	}
+	if len(days) > 0 {
+		c.First, c.Last = days[0].Day.Format(time.DateOnly), days[len(days)-1].Day.Format(time.DateOnly)
+	}
+	for _, d := range days {
+		c.Max = max(c.Max, d.Expired, d.Within90)
+	}
+	series := []struct {
+		name  string
+		color string
+		count func(burndownDay) int
+	}{
+		{"within 90 days", "#2a9d8f", func(d burndownDay) int { return d.Within90 }},
+		{"within 60 days", "#e9c46a", func(d burndownDay) int { return d.Within60 }},
+		{"within 30 days", "#f4a261", func(d burndownDay) int { return d.Within30 }},
+		{"expired", "#e63946", func(d burndownDay) int { return d.Expired }},
+	}
+	step := float64(c.Right - c.Left)
+	if len(days) > 1 {
+		step /= float64(len(days) - 1)
+	}
+	for i, s := range series {
+		points := make([]string, len(days))
+		for j, d := range days {
+			x := float64(c.Left) + float64(j)*step
+			y := float64(c.Bottom) - float64(s.count(d))*float64(c.Bottom-c.Top)/float64(c.Max)
+			points[j] = strconv.FormatFloat(x, 'f', 1, 64) + "," + strconv.FormatFloat(y, 'f', 1, 64)
+		}
+		c.Series = append(c.Series, burndownSeries{
+			Name:   s.name,
+			Color:  s.color,
+			Points: strings.Join(points, " "),
+			Offset: i * 16,
+		})
+	}
+	return c
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_toBurndown(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	days := []burndownDay{
+		{Day: day, Expired: 1, Within30: 2, Within60: 4, Within90: 8, Total: 10},
+		{Day: day.AddDate(0, 0, 1), Expired: 0, Within30: 1, Within60: 3, Within90: 6, Total: 10},
+	}
+	tests := []struct {
+		name    string
+		days    []burndownDay
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "csv",
+			days:   days,
+			format: burndownCSV,
+			want:   []string{"day,expired,within_30,within_60,within_90,total\n2025-03-01,1,2,4,8,10\n2025-03-02,0,1,3,6,10\n"},
+		},
+		{
+			name:   "html",
+			days:   days,
+			format: burndownHTML,
+			want: []string{
+				// the 90-day series starts at the top and ends three quarters up
+				`points="40.0,40.0 760.0,100.0"`,
+				"<tr><td>2025-03-02</td><td>0</td><td>1</td><td>3</td><td>6</td><td>10</td></tr>",
+			},
+		},
+		{
+			name:   "html of a single day",
+			days:   days[:1],
+			format: burndownHTML,
+			want:   []string{`points="40.0,40.0"`},
+		},
+		{
+			name:   "empty",
+			format: burndownHTML,
+			want:   []string{"<table>"},
+		},
+		{
+			name:    "table",
+			days:    days,
+			format:  formatTextTable.String(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := toBurndown(tt.days, w, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toBurndown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.String(), want) {
+					t.Errorf("toBurndown() = %s, want to contain %s", w.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// typeCAA is the type of CAA records (RFC 8659), which dnsmessage does not
+// know by name.
+const typeCAA dnsmessage.Type = 257
+
+// statuses of the issuer of a cert against the CAA records of its name.
+const (
+	caaAuthorized    = "authorized"
+	caaUnauthorized  = "unauthorized"
+	caaNone          = "none"
+	caaUnknownIssuer = "unknown-issuer"
+)
+
+// caaIdentities are the issuer domain names that CAs publish for CAA records,
+// keyed by the organization in the issuer of the certs they sign.
+var caaIdentities = []struct {
+	organization string
+	domains      []string
+}{
+	{organization: "Let's Encrypt", domains: []string{"letsencrypt.org"}},
+	{organization: "Google Trust Services", domains: []string{"pki.goog", "google.com"}},
+	{organization: "Amazon", domains: []string{"amazon.com", "amazontrust.com", "awstrust.com", "amazonaws.com"}},
+	{organization: "DigiCert", domains: []string{"digicert.com", "symantec.com", "geotrust.com", "rapidssl.com", "thawte.com", "digitalcertvalidation.com"}},
+	{organization: "Sectigo", domains: []string{"sectigo.com", "comodoca.com", "comodo.com", "usertrust.com", "trust-provider.com"}},
+	{organization: "ZeroSSL", domains: []string{"sectigo.com", "zerossl.com"}},
+	{organization: "GlobalSign", domains: []string{"globalsign.com"}},
+	{organization: "GoDaddy", domains: []string{"godaddy.com", "starfieldtech.com"}},
+	{organization: "Starfield", domains: []string{"starfieldtech.com", "godaddy.com"}},
+	{organization: "Entrust", domains: []string{"entrust.net", "affirmtrust.com"}},
+	{organization: "SSL Corporation", domains: []string{"ssl.com"}},
+	{organization: "Buypass", domains: []string{"buypass.com", "buypass.no"}},
+	{organization: "Certum", domains: []string{"certum.pl", "certum.eu"}},
+	{organization: "Asseco", domains: []string{"certum.pl", "certum.eu"}},
+	{organization: "HARICA", domains: []string{"harica.gr"}},
+}
+
+// caaRecord is a CAA record, such as 0 issue "letsencrypt.org".
+type caaRecord struct {
+	flags uint8
+	tag   string
+	value string
+}
+
+// parseCAA parses the data of a CAA record: flags, the length of the tag, the
+// tag and the value.
+func parseCAA(data []byte) (caaRecord, error) {
+	if len(data) < 2 || len(data) < 2+int(data[1]) {
+		return caaRecord{}, errors.New("invalid CAA record")
+	}
+	n := 2 + int(data[1])
+	return caaRecord{
+		flags: data[0],
+		tag:   strings.ToLower(string(data[2:n])),
+		value: string(data[n:]),
+	}, nil
+}
+
+// lookupCAA returns the CAA records that apply to host, which are those of
+// the closest name up the tree that has any (RFC 8659 section 3).
+func (r *dnsResolver) lookupCAA(ctx context.Context, host string) ([]caaRecord, error) {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	for i := range labels {
+		name, err := dnsmessage.NewName(strings.Join(labels[i:], ".") + ".")
+		if err != nil {
+			return nil, r.dnsError(host, err.Error(), false)
+		}
+		msg, err := r.exchange(ctx, name, typeCAA)
+		if err != nil {
+			return nil, r.dnsError(host, err.Error(), false)
+		}
+		switch msg.RCode {
+		case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+		default:
+			return nil, r.dnsError(host, msg.RCode.String(), false)
+		}
+		var records []caaRecord
+		for _, rr := range msg.Answers {
+			body, ok := rr.Body.(*dnsmessage.UnknownResource)
+			if !ok || body.Type != typeCAA {
+				continue
+			}
+			rec, err := parseCAA(body.Data)
+			if err != nil {
+				return nil, r.dnsError(host, err.Error(), false)
+			}
+			records = append(records, rec)
+		}
+		if len(records) > 0 {
+			return records, nil
+		}
+	}
+	return nil, nil
+}
+
+// caaIssuers returns the issuer domain names that records authorize for a
+// name, taken from the issuewild records for a wildcard name if there are any,
+// or from the issue records otherwise. A record of ";" authorizes none, so
+// published is false only when there is no record to apply.
+func caaIssuers(records []caaRecord, wildcard bool) (issuers []string, published bool) {
+	tag := "issue"
+	if wildcard && slices.ContainsFunc(records, func(r caaRecord) bool { return r.tag == "issuewild" }) {
+		tag = "issuewild"
+	}
+	for _, r := range records {
+		if r.tag != tag {
+			continue
+		}
+		published = true
+		domain, _, _ := strings.Cut(r.value, ";")
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" && !slices.Contains(issuers, domain) {
+			issuers = append(issuers, domain)
+		}
+	}
+	return issuers, published
+}
+
+// caaStatus tells whether the issuer of cert is among issuers authorized by
+// the CAA records, when they are published.
+func caaStatus(issuers []string, published bool, cert *x509.Certificate) string {
+	if !published {
+		return caaNone
+	}
+	var domains []string
+	for _, id := range caaIdentities {
+		if slices.ContainsFunc(cert.Issuer.Organization, func(o string) bool { return strings.Contains(o, id.organization) }) {
+			domains = append(domains, id.domains...)
+		}
+	}
+	switch {
+	case slices.ContainsFunc(domains, func(d string) bool { return slices.Contains(issuers, d) }):
+		return caaAuthorized
+	case len(domains) == 0:
+		return caaUnknownIssuer
+	default:
+		return caaUnauthorized
+	}
+}
+
+// caaSeverity orders the CAA statuses from the least to the most severe, the
+// status of a cert being the most severe of those of its names.
+var caaSeverity = []string{caaNone, caaAuthorized, caaUnknownIssuer, caaUnauthorized}
+
+// checkCAA sets the CAA status of info against the records of each DNS name
+// of its cert, or of the name it was checked by when the cert has none, and
+// lists the status of each name as name: status in CAANames. The status of
+// the cert is the most severe of them, and CAAIssuers are the issuers
+// authorized for any of them. An address has no CAA records, so a cert
+// checked by address without DNS names is left alone.
+func (c *connector) checkCAA(ctx context.Context, r *dnsResolver, info *certInfo) error {
+	cert := info.chain[0]
+	names := cert.DNSNames
+	if len(names) == 0 {
+		if net.ParseIP(c.tlsConfig.ServerName) != nil {
+			return nil
+		}
+		names = []string{c.tlsConfig.ServerName}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	// a wildcard name shares the records of its base domain
+	lookups := make(map[string][]caaRecord)
+	var errs []error
+	for _, name := range names {
+		domain, wildcard := strings.CutPrefix(strings.ToLower(name), "*.")
+		records, ok := lookups[domain]
+		if !ok {
+			var err error
+			records, err = r.lookupCAA(ctx, domain)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cannot look up CAA records of %q: %w", name, err))
+				continue
+			}
+			lookups[domain] = records
+		}
+		issuers, published := caaIssuers(records, wildcard)
+		status := caaStatus(issuers, published, cert)
+		info.CAANames = append(info.CAANames, name+": "+status)
+		if slices.Index(caaSeverity, status) > slices.Index(caaSeverity, info.CAAStatus) {
+			info.CAAStatus = status
+		}
+		for _, issuer := range issuers {
+			if !slices.Contains(info.CAAIssuers, issuer) {
+				info.CAAIssuers = append(info.CAAIssuers, issuer)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// systemNameserver returns the first nameserver of /etc/resolv.conf, to query
+// the records the resolver of the operating system does not look up. Windows
+// keeps its nameservers elsewhere, so one must be given there.
+func systemNameserver() (string, error) {
+	if runtime.GOOS == "windows" {
+		return "", fmt.Errorf("cannot find a nameserver on %s", runtime.GOOS)
+	}
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "", fmt.Errorf("cannot find a nameserver: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("cannot find a nameserver in /etc/resolv.conf")
+}
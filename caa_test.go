@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_dnsResolver_lookupCAA(t *testing.T) {
+	r := newDNSResolver(startTestDNSServer(t))
+	want := []caaRecord{
+		{flags: 0, tag: "issue", value: "letsencrypt.org; validationmethods=dns-01"},
+		{flags: 0, tag: "issuewild", value: ";"},
+	}
+	tests := []struct {
+		name    string
+		host    string
+		want    []caaRecord
+		wantErr bool
+	}{
+		{
+			name: "own records",
+			host: "caa.test",
+			want: want,
+		},
+		{
+			name: "records of parent",
+			host: "www.sub.caa.test",
+			want: want,
+		},
+		{
+			name: "none",
+			host: "a.test",
+			want: nil,
+		},
+		{
+			name:    "broken record",
+			host:    "broken.caa.test",
+			wantErr: true,
+		},
+		{
+			name:    "server failure",
+			host:    "fail.test",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.lookupCAA(context.Background(), tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupCAA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(caaRecord{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_caaStatus(t *testing.T) {
+	records := []caaRecord{
+		{tag: "issue", value: "letsencrypt.org; validationmethods=dns-01"},
+		{tag: "issue", value: "Pki.goog"},
+		{tag: "issuewild", value: ";"},
+		{tag: "iodef", value: "mailto:security@example.com"},
+	}
+	cert := func(organization string, names ...string) *x509.Certificate {
+		return &x509.Certificate{Issuer: pkix.Name{Organization: []string{organization}}, DNSNames: names}
+	}
+	tests := []struct {
+		name        string
+		records     []caaRecord
+		cert        *x509.Certificate
+		wantIssuers []string
+		wantStatus  string
+	}{
+		{
+			name:        "authorized",
+			records:     records,
+			cert:        cert("Let's Encrypt", "www.example.com"),
+			wantIssuers: []string{"letsencrypt.org", "pki.goog"},
+			wantStatus:  caaAuthorized,
+		},
+		{
+			name:        "unauthorized",
+			records:     records,
+			cert:        cert("DigiCert Inc", "www.example.com"),
+			wantIssuers: []string{"letsencrypt.org", "pki.goog"},
+			wantStatus:  caaUnauthorized,
+		},
+		{
+			name:        "wildcard unauthorized",
+			records:     records,
+			cert:        cert("Let's Encrypt", "*.example.com"),
+			wantIssuers: nil,
+			wantStatus:  caaUnauthorized,
+		},
+		{
+			name:        "unknown issuer",
+			records:     records,
+			cert:        cert("Internal CA", "www.example.com"),
+			wantIssuers: []string{"letsencrypt.org", "pki.goog"},
+			wantStatus:  caaUnknownIssuer,
+		},
+		{
+			name:        "none",
+			records:     []caaRecord{{tag: "iodef", value: "mailto:security@example.com"}},
+			cert:        cert("Let's Encrypt", "www.example.com"),
+			wantIssuers: nil,
+			wantStatus:  caaNone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuers, published := caaIssuers(tt.records, strings.HasPrefix(tt.cert.DNSNames[0], "*."))
+			if diff := cmp.Diff(tt.wantIssuers, issuers); diff != "" {
+				t.Error(diff)
+			}
+			if got := caaStatus(issuers, published, tt.cert); got != tt.wantStatus {
+				t.Errorf("caaStatus() = %s, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_connector_checkCAA(t *testing.T) {
+	r := newDNSResolver(startTestDNSServer(t))
+	tests := []struct {
+		name        string
+		serverName  string
+		sans        []string
+		wantStatus  string
+		wantIssuers []string
+		wantNames   []string
+		wantErr     bool
+	}{
+		{
+			name:        "each name",
+			serverName:  "www.caa.test",
+			sans:        []string{"www.caa.test", "*.caa.test", "a.test"},
+			wantStatus:  caaUnauthorized,
+			wantIssuers: []string{"letsencrypt.org"},
+			wantNames:   []string{"www.caa.test: authorized", "*.caa.test: unauthorized", "a.test: none"},
+		},
+		{
+			name:        "name that cannot be looked up",
+			serverName:  "www.caa.test",
+			sans:        []string{"www.caa.test", "fail.test"},
+			wantStatus:  caaAuthorized,
+			wantIssuers: []string{"letsencrypt.org"},
+			wantNames:   []string{"www.caa.test: authorized"},
+			wantErr:     true,
+		},
+		{
+			name:        "no names",
+			serverName:  "caa.test",
+			wantStatus:  caaAuthorized,
+			wantIssuers: []string{"letsencrypt.org"},
+			wantNames:   []string{"caa.test: authorized"},
+		},
+		{
+			name:       "address",
+			serverName: "192.0.2.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &connector{tlsConfig: &tls.Config{ServerName: tt.serverName}, timeout: 5 * time.Second}
+			cert := &x509.Certificate{Issuer: pkix.Name{Organization: []string{"Let's Encrypt"}}, DNSNames: tt.sans}
+			info := &certInfo{chain: []*x509.Certificate{cert}}
+			err := c.checkCAA(context.Background(), r, info)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkCAA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if info.CAAStatus != tt.wantStatus {
+				t.Errorf("checkCAA() CAAStatus = %q, want %q", info.CAAStatus, tt.wantStatus)
+			}
+			if diff := cmp.Diff(tt.wantIssuers, info.CAAIssuers); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(tt.wantNames, info.CAANames); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheSize = 4096
+	defaultCacheTTL  = 10 * time.Minute
+)
+
+// CacheStats is a snapshot of cache usage.
+type CacheStats struct {
+	Size      int
+	Capacity  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// cache is a concurrency-safe LRU cache whose entries expire after ttl.
+// onEvict, if set, is called for entries removed by capacity or expiry, and
+// for values replaced by another one stored under the same key.
+type cache[V any] struct {
+	mu        sync.Mutex
+	capacity  int
+	ttl       time.Duration
+	ll        *list.List
+	items     map[string]*list.Element
+	onEvict   func(V)
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+func newCache[V any](capacity int, ttl time.Duration, onEvict func(V)) *cache[V] {
+	return &cache[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		onEvict:  onEvict,
+	}
+}
+
+func (c *cache[V]) load(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+	entry := elem.Value.(*cacheEntry[V])
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(elem)
+		c.evictions++
+		c.misses++
+		return zero, false
+	}
+	c.ll.MoveToFront(elem)
+	c.hits++
+	return entry.value, true
+}
+
+func (c *cache[V]) store(key string, value V) {
+	c.storeTTL(key, value, c.ttl)
+}
+
+// storeTTL stores value with its own ttl instead of the cache default.
+// A ttl of zero or less keeps the entry until it is evicted by capacity.
+func (c *cache[V]) storeTTL(key string, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry[V])
+		// a value stored again is kept, such as a conn released after use
+		if c.onEvict != nil && !sameValue(entry.value, value) {
+			c.onEvict(entry.value)
+		}
+		entry.value = value
+		entry.expires = expires
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry[V]{key: key, value: value, expires: expires})
+	for c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+		c.evictions++
+	}
+}
+
+func (c *cache[V]) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *cache[V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *cache[V]) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Size:      c.ll.Len(),
+		Capacity:  c.capacity,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *cache[V]) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry[V])
+	c.ll.Remove(elem)
+	delete(c.items, entry.key)
+	if c.onEvict != nil {
+		c.onEvict(entry.value)
+	}
+}
+
+// sameValue reports whether a and b are the same value, which only values of
+// comparable types such as pointers can be told to be.
+func sameValue[V any](a, b V) bool {
+	v := reflect.ValueOf(a)
+	return v.IsValid() && v.Comparable() && any(a) == any(b)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_cache(t *testing.T) {
+	var evicted []int
+	c := newCache(2, time.Hour, func(v int) { evicted = append(evicted, v) })
+	c.store("a", 1)
+	c.store("b", 2)
+	if got, ok := c.load("a"); !ok || got != 1 {
+		t.Errorf("load(a) = %v, %v, want 1, true", got, ok)
+	}
+	c.store("c", 3)
+	if _, ok := c.load("b"); ok {
+		t.Error("least recently used entry is not evicted")
+	}
+	if got, ok := c.load("c"); !ok || got != 3 {
+		t.Errorf("load(c) = %v, %v, want 3, true", got, ok)
+	}
+	c.delete("a")
+	if _, ok := c.load("a"); ok {
+		t.Error("deleted entry still exists")
+	}
+	want := CacheStats{
+		Size:      1,
+		Capacity:  2,
+		Hits:      2,
+		Misses:    2,
+		Evictions: 1,
+	}
+	if got := c.stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(evicted, []int{2, 1}) {
+		t.Errorf("evicted = %v, want %v", evicted, []int{2, 1})
+	}
+}
+
+func Test_cache_ttl(t *testing.T) {
+	c := newCache[string](0, time.Millisecond, nil)
+	c.store("a", "x")
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.load("a"); ok {
+		t.Error("expired entry is returned")
+	}
+	want := CacheStats{
+		Size:      0,
+		Capacity:  0,
+		Hits:      0,
+		Misses:    1,
+		Evictions: 1,
+	}
+	if got := c.stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_cache_storeTTL(t *testing.T) {
+	c := newCache[string](0, time.Hour, nil)
+	c.storeTTL("short", "x", time.Millisecond)
+	c.storeTTL("forever", "y", 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.load("short"); ok {
+		t.Error("expired entry is returned")
+	}
+	if v, ok := c.load("forever"); !ok || v != "y" {
+		t.Errorf("load() = %q, %v, want %q, true", v, ok, "y")
+	}
+}
+
+func Test_cache_overwrite(t *testing.T) {
+	var evicted []string
+	c := newCache(1, time.Hour, func(v string) { evicted = append(evicted, v) })
+	c.store("a", "x")
+	c.store("a", "y")
+	if got, ok := c.load("a"); !ok || got != "y" {
+		t.Errorf("load(a) = %v, %v, want y, true", got, ok)
+	}
+	if got := c.stats().Size; got != 1 {
+		t.Errorf("Size = %v, want 1", got)
+	}
+	// the value stored again is not evicted
+	c.store("a", "y")
+	if !reflect.DeepEqual(evicted, []string{"x"}) {
+		t.Errorf("evicted = %v, want %v", evicted, []string{"x"})
+	}
+}
+
+func Test_cache_overwrite_conn(t *testing.T) {
+	var closed []*tls.Conn
+	c := newCache(0, time.Hour, func(conn *tls.Conn) { closed = append(closed, conn) })
+	first, second := &tls.Conn{}, &tls.Conn{}
+	c.store("a", first)
+	c.store("a", first)
+	c.store("a", second)
+	if len(closed) != 1 || closed[0] != first {
+		t.Errorf("closed = %v, want the first conn", closed)
+	}
+}
+
+func Test_cache_purge(t *testing.T) {
+	var evicted []int
+	c := newCache(0, time.Hour, func(v int) { evicted = append(evicted, v) })
+	c.store("a", 1)
+	c.store("b", 2)
+	c.purge()
+	if got := c.stats().Size; got != 0 {
+		t.Errorf("Size = %v, want 0", got)
+	}
+	if !reflect.DeepEqual(evicted, []int{1, 2}) {
+		t.Errorf("evicted = %v, want %v", evicted, []int{1, 2})
+	}
+}
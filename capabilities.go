@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// providers are the inputs that hosts can be given by.
+var providers = []string{sourceDomain, "file", sourceCIDR, sourceKubernetes, sourceAWS, sourceCertFile}
+
+// capabilities describes what this binary supports, so that tools driving
+// different versions of it can detect features instead of parsing the help.
+// New fields are only ever added.
+type capabilities struct {
+	Version       string
+	Commands      []string
+	Outputs       []string
+	Providers     []string
+	StartTLS      []string
+	Presets       []string
+	TLSVersions   []string
+	Notifiers     []string
+	NotifyFormats []string
+	Caches        []string
+	Proxies       []string
+	Locales       []string
+}
+
+// newCapabilities collects the capabilities of the app with cmds.
+func newCapabilities(cmds []string) capabilities {
+	versions := make([]string, 0, len(probedVersions))
+	for _, v := range probedVersions {
+		versions = append(versions, tls.VersionName(v))
+	}
+	proxies := []string{"direct"}
+	for scheme := range viaSchemes {
+		proxies = append(proxies, scheme)
+	}
+	slices.Sort(proxies)
+	return capabilities{
+		Version:       Version,
+		Commands:      cmds,
+		Outputs:       formats,
+		Providers:     providers,
+		StartTLS:      preambleNames(),
+		Presets:       presetNames(),
+		TLSVersions:   versions,
+		Notifiers:     []string{"webhook", "pre-hook", "post-hook"},
+		NotifyFormats: formatterNames(),
+		Caches:        sharedSchemes,
+		Proxies:       proxies,
+		Locales:       localeNames(),
+	}
+}
+
+// toCapabilities writes caps in JSON, or as a line per capability.
+func toCapabilities(caps capabilities, w io.Writer, asJSON bool) error {
+	if asJSON {
+		return toJSON(caps, w)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "version: %s\n", caps.Version)
+	for _, line := range []struct {
+		name   string
+		values []string
+	}{
+		{"commands", caps.Commands},
+		{"outputs", caps.Outputs},
+		{"providers", caps.Providers},
+		{"starttls", caps.StartTLS},
+		{"presets", caps.Presets},
+		{"tls versions", caps.TLSVersions},
+		{"notifiers", caps.Notifiers},
+		{"notify formats", caps.NotifyFormats},
+		{"caches", caps.Caches},
+		{"proxies", caps.Proxies},
+		{"locales", caps.Locales},
+	} {
+		fmt.Fprintf(&b, "%s: %s\n", line.name, strings.Join(line.values, ", "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newCapabilities(t *testing.T) {
+	caps := newCapabilities([]string{"fetch"})
+	if diff := cmp.Diff(formats, caps.Outputs); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{"TLS 1.0", "TLS 1.1", "TLS 1.2", "TLS 1.3"}, caps.TLSVersions); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{"direct", "http", "https", "socks5", "socks5h", "ssh"}, caps.Proxies); diff != "" {
+		t.Error(diff)
+	}
+	for _, name := range preambleNames() {
+		if _, err := lookupPreamble(name); err != nil {
+			t.Errorf("StartTLS lists %q: %v", name, err)
+		}
+	}
+}
+
+func Test_toCapabilities(t *testing.T) {
+	caps := capabilities{
+		Version:       "1.2.3",
+		Commands:      []string{"fetch", "gate"},
+		Outputs:       []string{"json", "table"},
+		Providers:     []string{"domain"},
+		StartTLS:      []string{"smtp"},
+		Presets:       []string{"etcd"},
+		TLSVersions:   []string{"TLS 1.3"},
+		Notifiers:     []string{"webhook"},
+		NotifyFormats: []string{"json", "slack"},
+		Caches:        []string{"redis"},
+		Proxies:       []string{"direct"},
+		Locales:       []string{"en-US"},
+	}
+	t.Run("text", func(t *testing.T) {
+		w := &bytes.Buffer{}
+		if err := toCapabilities(caps, w, false); err != nil {
+			t.Fatal(err)
+		}
+		want := `version: 1.2.3
+commands: fetch, gate
+outputs: json, table
+providers: domain
+starttls: smtp
+presets: etcd
+tls versions: TLS 1.3
+notifiers: webhook
+notify formats: json, slack
+caches: redis
+proxies: direct
+locales: en-US
+`
+		if diff := cmp.Diff(want, w.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		w := &bytes.Buffer{}
+		if err := toCapabilities(caps, w, true); err != nil {
+			t.Fatal(err)
+		}
+		var got capabilities
+		if err := json.Unmarshal(w.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(caps, got); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
@@ -1,25 +1,40 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"runtime"
 	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 )
 
+const (
+	// Handshake timeout scaled from the TCP connect RTT, bounded by the global timeout.
+	adaptiveFactor  = 10
+	adaptiveMinimum = 500 * time.Millisecond
+)
+
 var (
-	ipMap   sync.Map
-	connMap sync.Map
+	ipCache   = newCache[lookupResult](defaultCacheSize, defaultCacheTTL, nil)
+	connCache = newCache(defaultCacheSize, defaultCacheTTL, func(conn *tls.Conn) { conn.Close() })
 )
 
 type certInfo struct {
@@ -33,149 +48,803 @@ type certInfo struct {
 	NotAfter    time.Time
 	CurrentTime time.Time
 	DaysLeft    int
+
+	SerialNumber       string
+	SignatureAlgorithm string
+	PublicKeyAlgorithm string
+	KeySize            int
+	FingerprintSHA256  string
+	FingerprintSHA1    string
+
+	StapledOCSP    bool
+	OCSPStatus     string     `json:",omitempty"`
+	OCSPNextUpdate *time.Time `json:",omitempty"`
+
+	VerificationStatus string   `json:",omitempty"`
+	VerifyError        string   `json:",omitempty"`
+	HostnameMatch      *bool    `json:",omitempty"`
+	TrustStatus        string   `json:",omitempty"`
+	IsSelfSigned       bool     `json:",omitempty"`
+	TrustStores        []string `json:",omitempty"`
+
+	Weaknesses []string `json:",omitempty"`
+	CAAStatus  string   `json:",omitempty"`
+	CAAIssuers []string `json:",omitempty"`
+	CAANames   []string `json:",omitempty"`
+
+	ChainComplete        *bool    `json:",omitempty"`
+	FetchedIntermediates []string `json:",omitempty"`
+	ChainDaysLeft        *int     `json:",omitempty"`
+	ExpiredInChain       []string `json:",omitempty"`
+
+	TLSVersions []string    `json:",omitempty"`
+	SMIME       string      `json:",omitempty"`
+	StaleSANs   []string    `json:",omitempty"`
+	ALPN        string      `json:",omitempty"`
+	GRPCHealth  string      `json:",omitempty"`
+	Distrust    string      `json:",omitempty"`
+	Throttled   bool        `json:",omitempty"`
+	Match       string      `json:",omitempty"`
+	Violations  []string    `json:",omitempty"`
+	Changes     []string    `json:",omitempty"`
+	Error       *checkError `json:",omitempty"`
+
+	RequestedPort string   `json:",omitempty"`
+	Proxy         string   `json:",omitempty"`
+	ServerName    string   `json:",omitempty"`
+	Tags          []string `json:",omitempty"`
+	Source        string   `json:",omitempty"`
+	Secret        string   `json:",omitempty"`
+	Resource      string   `json:",omitempty"`
+	Renewal       string   `json:",omitempty"`
+	Alias         string   `json:",omitempty"`
+	Hosts         []string `json:",omitempty"`
+
+	Fields fieldValues `json:",omitempty"`
+
+	chain []*x509.Certificate
+	// insecure tells that the host was checked with --insecure, so that a
+	// failed verification is reported only
+	insecure bool
+}
+
+type options struct {
+	timeout    time.Duration
+	insecure   bool
+	location   *time.Location
+	serverName string
+	adaptive   bool
+	partial    bool
+	probe      bool
+	smime      bool
+	grpc       bool
+	grpcHealth bool
+	grpcSvc    string
+	checkSANs  bool
+	// aia completes the chains missing intermediates, nil to take them as
+	// served
+	aia *aiaFetcher
+	// caa looks up the CAA records of the names, nil not to check them
+	caa *dnsResolver
+	// trustStores are the stores to report the trust status against, as of
+	// clients trusting other roots
+	trustStores []namedRoots
+	fallback    []string
+	jitter      time.Duration
+	spread      time.Duration
+	starttls    string
+	clientCert  *tls.Certificate
+	// policy asserts expectations on the certs, nil for none
+	policy policy
+	// allIPs checks a host at each of the addresses it resolves to
+	allIPs bool
+	// limiter paces the connections of the run
+	limiter *limiter
+	// roots are the roots certs are verified against, or nil for the system
+	// roots
+	roots      *x509.CertPool
+	pins       pins
+	hello      *clientHello
+	resolver   resolver
+	pac        *pac
+	checkpoint *checkpoint
+	stream     *streamer
+	failures   *failures
+	chaos      *chaos
+	throttle   *throttle
+	shared     *sharedCache
+	targets    targets
+	sources    *sources
+	sweep      *sweep
+	// expected is the fingerprint of the cert hosts are expected to serve
+	// unless given their own
+	expected string
+	// concurrency is the number of hosts checked at once, or the number of
+	// CPUs when zero
+	concurrency int
 }
 
-func getCertList(ctx context.Context, addrs []string, timeout time.Duration, insecure bool, location *time.Location) ([]*certInfo, error) {
-	res := make([]*certInfo, len(addrs))
-	sem := semaphore.NewWeighted(int64(runtime.NumCPU()))
+// loadClientCert loads the cert presented to servers that request client
+// authentication. Empty paths mean no client cert.
+func loadClientCert(certFile, keyFile string) (*tls.Certificate, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load client cert: %w", err)
+	}
+	return &cert, nil
+}
+
+func getCertList(ctx context.Context, addrs []string, opts *options) ([]*certInfo, error) {
+	res := make([][]*certInfo, len(addrs))
+	n := opts.concurrency
+	if n < 1 {
+		n = runtime.NumCPU()
+	}
+	sem := semaphore.NewWeighted(int64(n))
 	eg, ctx := errgroup.WithContext(ctx)
+	if err := sleep(ctx, randomDelay(opts.jitter)); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	var err error
 	for i, addr := range addrs {
 		i, addr := i, addr
-		if err := sem.Acquire(ctx, 1); err != nil {
-			return nil, err
+		if err = sleep(ctx, time.Until(start.Add(spreadOffset(i, len(addrs), opts.spread)))); err != nil {
+			break
+		}
+		if err = sem.Acquire(ctx, 1); err != nil {
+			break
 		}
 		eg.Go(func() error {
 			defer sem.Release(1)
-			conn, err := newConnector(addr, timeout, insecure, location)
+			begun := time.Now()
+			infos, err := checkAddr(ctx, addr, opts)
 			if err != nil {
-				return err
+				if opts.sweep.skip(addr, err) {
+					return nil
+				}
+				return opts.failures.add(addr, err, time.Since(begun))
 			}
-			if err := conn.getTLSConn(ctx); err != nil {
-				return err
+			for _, info := range infos {
+				info.Source = opts.sources.lookup(addr)
+				info.Violations = opts.policy.violations(info)
+				if err := opts.stream.write(ctx, info); err != nil {
+					return err
+				}
 			}
-			defer conn.releaseTLSConn()
-			conn.lookupIP(ctx)
-			info, err := conn.getServerCert()
-			if err != nil {
-				return err
-			}
-			res[i] = info
-			return nil
+			res[i] = infos
+			// a host is only checked at each of its addresses without a
+			// checkpoint, which keeps one result per host
+			return opts.checkpoint.record(addr, infos[0])
 		})
 	}
+	// a failed check cancels ctx, which stops the loop too, so its error is
+	// the one to report rather than that of ctx
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
-	return res, nil
+	if err != nil {
+		return nil, err
+	}
+	// hosts whose failures were collected leave no info
+	return slices.Concat(res...), nil
+}
+
+// checkAddr returns the cert served at addr, or with allIPs, the cert served
+// at each address the host resolves to.
+func checkAddr(ctx context.Context, addr string, opts *options) ([]*certInfo, error) {
+	if opts.allIPs {
+		return getCertsByIP(ctx, addr, opts)
+	}
+	shared := opts.shared
+	// the cert of a pinned backend is not to be taken for the one served
+	// under its name, nor the other way around
+	if target := ensureDefaultPort(targetAddr(addr)); opts.pins.addr(target) != target {
+		shared = nil
+	}
+	info, err := shared.check(ctx, addr, opts, func() (*certInfo, error) {
+		return getCert(ctx, addr, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*certInfo{info}, nil
+}
+
+// getCertsByIP checks the cert served at each address the host of addr
+// resolves to, keeping the name for SNI and verification, so that a node
+// behind the name still serving an old cert is not missed. Each cert has the
+// address it was served at as IPAddresses. A host that resolves to a single
+// address, or not at all, is checked as usual.
+func getCertsByIP(ctx context.Context, addr string, opts *options) ([]*certInfo, error) {
+	conn, err := newConnector(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.lookupIP(ctx); err != nil {
+		return nil, withHint(err)
+	}
+	if len(conn.ips) < 2 {
+		info, err := getCert(ctx, addr, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []*certInfo{info}, nil
+	}
+	infos := make([]*certInfo, 0, len(conn.ips))
+	for _, ip := range conn.ips {
+		o := *opts
+		o.pins = make(pins, len(opts.pins)+1)
+		maps.Copy(o.pins, opts.pins)
+		o.pins[net.JoinHostPort(strings.ToLower(conn.host), conn.port)] = ip
+		info, err := getCert(ctx, addr, &o)
+		if err != nil {
+			return nil, fmt.Errorf("at %s: %w", ip, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func getCert(ctx context.Context, addr string, opts *options) (*certInfo, error) {
+	conn, err := newConnector(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.findRoutes(ctx); err != nil {
+		return nil, err
+	}
+	if err := conn.lookupIP(ctx); err != nil {
+		return nil, withHint(err)
+	}
+	// a swept host that hangs up is most likely not a TLS endpoint
+	th := opts.throttle
+	if opts.sweep.covers(addr) {
+		th = nil
+	}
+	throttled, err := th.do(ctx, conn.addr, func() error {
+		return conn.getTLSConn(ctx)
+	})
+	if err != nil {
+		return nil, withHint(err)
+	}
+	defer conn.releaseTLSConn()
+	info, err := conn.getServerCert(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if conn.issuer != "" && !strings.Contains(info.Issuer, conn.issuer) {
+		return nil, fmt.Errorf("cert of %q is issued by %q instead of %q", conn.addr, info.Issuer, conn.issuer)
+	}
+	info.Throttled = throttled
+	if conn.expected != "" {
+		info.Match = matchCert(info.FingerprintSHA256, conn.expected)
+		if info.Match == certMismatch {
+			log.Warn("cert differs from the expected one", "host", conn.addr, "fingerprint", info.FingerprintSHA256)
+		}
+	}
+	if opts.probe {
+		info.TLSVersions = conn.probeVersions(ctx)
+	}
+	if opts.smime {
+		info.SMIME = smimeStatus(info.chain, nil)
+	}
+	if opts.checkSANs {
+		info.StaleSANs = conn.staleSANs(ctx, info.chain[0])
+	}
+	if opts.grpcHealth {
+		info.GRPCHealth = conn.grpcHealth(ctx, opts.grpcSvc)
+	}
+	// the cert is reported even when its CAA records cannot be looked up
+	if opts.caa != nil {
+		if err := conn.checkCAA(ctx, opts.caa, info); err != nil {
+			log.Warn(err)
+		}
+	}
+	if info.Distrust != "" {
+		log.Warn("chain relies on a root facing distrust", "host", conn.addr, "status", info.Distrust)
+	}
+	if len(info.ExpiredInChain) > 0 {
+		log.Warn("chain has an expired intermediate", "host", conn.addr, "subjects", info.ExpiredInChain)
+	}
+	return info, nil
 }
 
 type connector struct {
 	addr      string
 	host      string
 	port      string
+	fallback  []string
+	reqPort   string
+	pac       *pac
+	routes    []route
+	proxy     string
+	pins      pins
 	ips       []net.IP
 	timeout   time.Duration
+	adaptive  bool
 	location  *time.Location
+	preamble  preamble
+	resolver  resolver
+	chaos     *chaos
+	limiter   *limiter
+	aia       *aiaFetcher
+	stores    []namedRoots
 	tlsConfig *tls.Config
-	tlsConn   *tls.Conn
-	mu        sync.Mutex
+	// insecure tells that the chain is not expected to verify, so that it is
+	// reported without failing the check
+	insecure bool
+	tlsConn  *tls.Conn
+	partial  bool
+	tags     []string
+	issuer   string
+	expected string
+	// cnIdentity verifies the cert against its own common name
+	cnIdentity bool
+	// connectTimeout bounds a direct connection apart from the handshake
+	connectTimeout time.Duration
+	state          *tls.ConnectionState
+	mu             sync.Mutex
 }
 
-func newConnector(addr string, timeout time.Duration, insecure bool, location *time.Location) (*connector, error) {
-	addr = ensureDefaultPort(addr)
+func newConnector(addr string, opts *options) (*connector, error) {
+	t := opts.targets.lookup(addr)
+	addr = ensureDefaultPort(targetAddr(addr))
 	host, port, err := ensureHostPort(addr)
 	if err != nil {
 		return nil, err
 	}
+	if t.serverName == "" {
+		t.serverName = opts.serverName
+	}
+	serverName := host
+	if t.serverName != "" {
+		serverName = t.serverName
+	}
+	if t.timeout == 0 {
+		t.timeout = opts.timeout
+	}
+	if t.expected == "" {
+		t.expected = opts.expected
+	}
 	conn := &connector{
+		// the chain is verified by verifyChain once received rather than by
+		// the handshake, so that a host that does not verify is still reported
 		tlsConfig: &tls.Config{
-			ServerName:         host,
+			ServerName:         serverName,
+			RootCAs:            opts.roots,
 			MinVersion:         tls.VersionTLS12,
-			InsecureSkipVerify: insecure, // #nosec G402
+			InsecureSkipVerify: true, // #nosec G402
 		},
+		insecure: opts.insecure || t.insecure,
 		addr:     addr,
 		host:     host,
 		port:     port,
-		timeout:  timeout,
-		location: location,
+		timeout:  t.timeout,
+		adaptive: opts.adaptive,
+		location: opts.location,
+		resolver: opts.resolver,
+		chaos:    opts.chaos,
+		limiter:  opts.limiter,
+		aia:      opts.aia,
+		stores:   opts.trustStores,
+		fallback: opts.fallback,
+		pac:      opts.pac,
+		partial:  opts.partial,
+		tags:     t.tags,
+		routes:   t.routes,
+		pins:     opts.pins,
+		issuer:   t.issuer,
+		expected: t.expected,
+	}
+	if opts.sweep.covers(addr) {
+		conn.connectTimeout = opts.sweep.connectTimeout
+	}
+	starttls := opts.starttls
+	if t.starttls != "" {
+		starttls = t.starttls
+	}
+	if starttls != "" {
+		conn.preamble, err = lookupPreamble(starttls)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// http/1.1 is offered as well so that the cert of a server without h2
+	// is still checked, with the negotiated protocol telling the difference
+	if opts.grpc {
+		conn.tlsConfig.NextProtos = []string{alpnH2, "http/1.1"}
+	}
+	opts.hello.apply(conn.tlsConfig)
+	if opts.clientCert != nil {
+		conn.tlsConfig.Certificates = []tls.Certificate{*opts.clientCert}
+	}
+	// An address expanded from a CIDR range has no name to verify
+	// against, so the certificate is checked against its own common name
+	// instead. An address given as such is verified as usual.
+	if opts.sweep.covers(addr) && t.serverName == "" && net.ParseIP(host) != nil {
+		conn.cnIdentity = true
 	}
 	return conn, nil
 }
 
+// findRoutes asks the PAC script, if any, how to reach the host, unless the
+// host has routes of its own.
+func (c *connector) findRoutes(ctx context.Context) error {
+	if c.pac == nil || c.routes != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.pac.findProxy(ctx, c.getResolver(), c.host, c.port)
+	if err != nil {
+		return fmt.Errorf("cannot find proxy for %q: %w", c.addr, err)
+	}
+	routes, err := parseRoutes(res)
+	if err != nil {
+		return fmt.Errorf("cannot find proxy for %q: %w", c.addr, err)
+	}
+	c.routes = routes
+	return nil
+}
+
+// proxied reports whether the host is reached through a proxy first, in which
+// case the proxy resolves the name and it need not resolve locally.
+func (c *connector) proxied() bool {
+	return len(c.routes) > 0 && c.routes[0].kind != routeDirect
+}
+
 // Since IP address lookup is not the primary responsibility of this application,
-// it does not return an error but only a zero value in case of failure.
-func (c *connector) lookupIP(ctx context.Context) {
-	if caches, ok := ipMap.Load(c.host); ok {
-		c.ips = caches.([]net.IP)
-		return
+// it leaves only a zero value in case of failure. A name that does not exist is
+// the exception: it is reported so that the host is not dialed in vain.
+func (c *connector) lookupIP(ctx context.Context) error {
+	if ip := c.pins.lookup(c.host, c.port); ip != nil {
+		c.ips = []net.IP{ip}
+		return nil
 	}
+	parent := ctx
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
-	var resolver net.Resolver
-	var err error
-	c.ips, err = resolver.LookupIP(ctx, "ip", c.host)
+	ips, _, err := c.getResolver().lookupIP(ctx, c.host)
 	if err != nil {
+		// the check is over when the caller gives up, unlike a lookup that
+		// merely times out
+		if err := parent.Err(); err != nil {
+			return err
+		}
 		c.ips = []net.IP{}
+		if isNotFound(err) && !c.proxied() {
+			return fmt.Errorf("cannot resolve %q: %w", c.host, err)
+		}
+		return nil
 	}
-	slices.SortFunc(c.ips, func(a, b net.IP) int {
-		return bytes.Compare(a, b)
-	})
-	ipMap.Store(c.host, c.ips)
+	c.ips = ips
+	return nil
+}
+
+func (c *connector) getResolver() resolver {
+	if c.resolver == nil {
+		return defaultResolver
+	}
+	return c.resolver
 }
 
 func (c *connector) getTLSConn(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if conn, ok := connMap.Load(c.host); ok {
-		c.tlsConn = conn.(*tls.Conn)
-		return nil
-	}
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
-	dialer := tls.Dialer{Config: c.tlsConfig}
-	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	// injected failures take effect even when the connection is cached
+	if err := c.chaos.dnsFailure(c.host); err != nil {
+		return fmt.Errorf("cannot connect to %q: %w", c.addr, err)
+	}
+	if err := c.chaos.delayHandshake(ctx); err != nil {
+		return fmt.Errorf("cannot connect to %q: %w", c.addr, err)
+	}
+	if conn, ok := connCache.load(c.connKey()); ok {
+		c.tlsConn = conn
+		return nil
+	}
+	start := time.Now()
+	conn, err := c.dial(ctx)
 	if err != nil {
 		return fmt.Errorf("cannot connect to %q: %w", c.addr, err)
 	}
-	var ok bool
-	c.tlsConn, ok = conn.(*tls.Conn)
-	if !ok {
+	if c.adaptive {
+		ctx, cancel = context.WithTimeout(ctx, adaptiveTimeout(time.Since(start), c.timeout))
+		defer cancel()
+	}
+	if err := c.negotiate(ctx, conn); err != nil {
+		conn.Close()
+		return err
+	}
+	if c.partial {
+		return c.partialHandshake(ctx, conn)
+	}
+	tlsConn := tls.Client(conn, c.tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
 		conn.Close()
-		return fmt.Errorf("connection is not TLS")
+		return fmt.Errorf("cannot connect to %q: %w", c.addr, err)
 	}
-	connMap.Store(c.host, c.tlsConn)
+	c.tlsConn = tlsConn
+	connCache.store(c.connKey(), c.tlsConn)
 	return nil
 }
 
+// dial connects to the address, and while the connection is refused, to the
+// fallback ports in order. The port that worked becomes the port of c.
+func (c *connector) dial(ctx context.Context) (net.Conn, error) {
+	conn, err := c.dialAddr(ctx, c.addr)
+	if err == nil || !errors.Is(err, syscall.ECONNREFUSED) {
+		return conn, err
+	}
+	for _, port := range c.fallback {
+		addr := net.JoinHostPort(c.host, port)
+		conn, fallbackErr := c.dialAddr(ctx, addr)
+		if fallbackErr == nil {
+			c.reqPort = c.port
+			c.port = port
+			c.addr = addr
+			return conn, nil
+		}
+		if !errors.Is(fallbackErr, syscall.ECONNREFUSED) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// dialAddr connects to addr, or to the address it is pinned to, directly or
+// through the first of the proxies chosen by the PAC script that works.
+func (c *connector) dialAddr(ctx context.Context, addr string) (net.Conn, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	addr = c.pins.addr(addr)
+	if c.routes == nil {
+		dialer := net.Dialer{Timeout: c.connectTimeout}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	conn, r, err := dialRoutes(ctx, c.routes, addr)
+	if err != nil {
+		return nil, err
+	}
+	if r.kind != routeDirect {
+		c.proxy = r.String()
+	}
+	return conn, nil
+}
+
+// connKey is the key of the connection in connCache, which tells apart the
+// server names a host is checked under and the addresses a pinned host is
+// dialed at.
+func (c *connector) connKey() string {
+	key := c.tlsConfig.ServerName + "@" + c.addr
+	if ip := c.pins.lookup(c.host, c.port); ip != nil {
+		return key + "@" + ip.String()
+	}
+	return key
+}
+
 func (c *connector) releaseTLSConn() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.tlsConn != nil {
-		connMap.Store(c.host, c.tlsConn)
+		connCache.store(c.connKey(), c.tlsConn)
 		c.tlsConn = nil
 	}
 }
 
-func (c *connector) getServerCert() (*certInfo, error) {
-	certs := c.tlsConn.ConnectionState().PeerCertificates
+// negotiate runs the preamble, if any, that upgrades conn to TLS.
+func (c *connector) negotiate(ctx context.Context, conn net.Conn) error {
+	if c.preamble == nil {
+		return nil
+	}
+	release := bindContext(ctx, conn)
+	defer release()
+	if err := c.preamble.negotiate(conn, c.host); err != nil {
+		return fmt.Errorf("cannot negotiate with %q: %w", c.addr, contextErr(ctx, err))
+	}
+	return nil
+}
+
+func (c *connector) getServerCert(ctx context.Context) (*certInfo, error) {
+	state := c.connectionState()
+	certs := state.PeerCertificates
 	if len(certs) == 0 {
 		return nil, fmt.Errorf("cannot find cert for %q", c.host)
 	}
-	cert := certs[0]
+	if err := c.chaos.chainFailure(); err != nil {
+		return nil, fmt.Errorf("cannot parse cert for %q: %w", c.host, err)
+	}
 	now := time.Now()
-	info := &certInfo{
-		DomainName:  c.host,
-		AccessPort:  c.port,
-		IPAddresses: c.ips,
+	ocspStatus, ocspNextUpdate := stapledOCSP(state.OCSPResponse, certs, now)
+	if ocspNextUpdate != nil {
+		t := ocspNextUpdate.In(c.location)
+		ocspNextUpdate = &t
+	}
+	info := newCertInfo(certs, now, c.location)
+	info.DomainName = c.host
+	info.AccessPort = c.port
+	info.IPAddresses = c.ips
+	info.RequestedPort = c.reqPort
+	info.Proxy = c.proxy
+	// a host checked under several names tells them apart
+	if name := c.tlsConfig.ServerName; name != c.host {
+		info.ServerName = name
+	}
+	info.Tags = c.tags
+	info.StapledOCSP = len(state.OCSPResponse) > 0
+	info.OCSPStatus = ocspStatus
+	info.OCSPNextUpdate = ocspNextUpdate
+	info.ALPN = state.NegotiatedProtocol
+	name := c.tlsConfig.ServerName
+	if c.cnIdentity {
+		name = certs[0].Subject.CommonName
+	}
+	chain := certs
+	if c.aia != nil {
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+		fetched, err := c.aia.complete(ctx, certs, c.tlsConfig.RootCAs, now)
+		// a chain that does not verify either way cannot tell
+		if err == nil {
+			complete := len(fetched) == 0
+			info.ChainComplete = &complete
+			for _, cert := range fetched {
+				info.FetchedIntermediates = append(info.FetchedIntermediates, cert.Subject.String())
+			}
+			chain = append(slices.Clone(certs), fetched...)
+		}
+	}
+	v := verifyChain(chain, c.tlsConfig.RootCAs, name, now)
+	info.VerificationStatus = v.status
+	info.VerifyError = v.err
+	info.HostnameMatch = &v.hostnameMatch
+	info.TrustStatus = v.trustStatus
+	info.insecure = c.insecure
+	info.TrustStores = storeTrust(chain, c.stores, now)
+	return info, nil
+}
+
+// newCertInfo returns the fields of the leaf of chain and of the chain itself
+// as of now, leaving those of the connection to the caller.
+func newCertInfo(chain []*x509.Certificate, now time.Time, loc *time.Location) *certInfo {
+	cert := chain[0]
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw) // #nosec G401
+	return &certInfo{
 		Issuer:      cert.Issuer.String(),
 		CommonName:  cert.Subject.CommonName,
 		SANs:        getSANs(cert),
-		NotBefore:   cert.NotBefore.In(c.location),
-		NotAfter:    cert.NotAfter.In(c.location),
-		CurrentTime: now.In(c.location).Truncate(time.Second),
+		NotBefore:   cert.NotBefore.In(loc),
+		NotAfter:    cert.NotAfter.In(loc),
+		CurrentTime: now.In(loc).Truncate(time.Second),
 		DaysLeft:    daysLeft(cert.NotAfter, now),
+
+		SerialNumber:       fmt.Sprintf("%X", cert.SerialNumber),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		KeySize:            keySize(cert.PublicKey),
+		FingerprintSHA256:  fingerprint(sha256Sum[:]),
+		FingerprintSHA1:    fingerprint(sha1Sum[:]),
+
+		IsSelfSigned:   isSelfSigned(cert),
+		ChainDaysLeft:  chainDaysLeft(chain, now),
+		ExpiredInChain: expiredInChain(chain, now),
+		Weaknesses:     weaknesses(cert),
+		Distrust:       distrustStatus(chain, distrustEvents, now),
+
+		chain: chain,
 	}
-	return info, nil
+}
+
+// parsePEMChain parses the certs in the PEM blocks of b, leaf first, skipping
+// the blocks of other types such as a key bundled with them.
+func parsePEMChain(b []byte) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no cert in PEM data")
+	}
+	return chain, nil
+}
+
+// topExpiring returns the n infos expiring soonest, ordered by expiry.
+func topExpiring(infos []*certInfo, n int) []*certInfo {
+	res := slices.Clone(infos)
+	slices.SortStableFunc(res, func(a, b *certInfo) int {
+		return a.NotAfter.Compare(b.NotAfter)
+	})
+	if n < len(res) {
+		res = res[:n]
+	}
+	return res
+}
+
+// uniqueCerts collapses infos into one entry per distinct certificate, keeping
+// the first entry as representative and listing every host serving it.
+func uniqueCerts(infos []*certInfo) []*certInfo {
+	res := make([]*certInfo, 0, len(infos))
+	seen := make(map[string]*certInfo, len(infos))
+	for _, info := range infos {
+		hostPort := net.JoinHostPort(info.DomainName, info.AccessPort)
+		if u, ok := seen[info.FingerprintSHA256]; ok {
+			u.Hosts = append(u.Hosts, hostPort)
+			continue
+		}
+		u := *info
+		u.Hosts = []string{hostPort}
+		seen[info.FingerprintSHA256] = &u
+		res = append(res, &u)
+	}
+	return res
+}
+
+// adaptiveTimeout scales the handshake timeout from the connect RTT so that
+// distant hosts get more time while nearby hosts fail fast.
+func adaptiveTimeout(rtt time.Duration, limit time.Duration) time.Duration {
+	timeout := max(rtt*adaptiveFactor, adaptiveMinimum)
+	return min(timeout, limit)
+}
+
+// fingerprint formats a digest as colon-separated uppercase hex, as openssl does.
+func fingerprint(sum []byte) string {
+	return strings.ToUpper(strings.ReplaceAll(fmt.Sprintf("% x", sum), " ", ":"))
+}
+
+func keySize(pub any) int {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
+
+// chainDaysLeft returns the fewest days left of the leaf and intermediates of
+// chain as of now. A root sent along is left out, as clients trust their own.
+func chainDaysLeft(chain []*x509.Certificate, now time.Time) *int {
+	days := daysLeft(chain[0].NotAfter, now)
+	for _, cert := range chain[1:] {
+		if !isSelfSigned(cert) {
+			days = min(days, daysLeft(cert.NotAfter, now))
+		}
+	}
+	return &days
+}
+
+// expiredInChain returns the subjects of the intermediates of chain that have
+// expired as of now, which break clients that build the path through them.
+func expiredInChain(chain []*x509.Certificate, now time.Time) []string {
+	var res []string
+	for _, cert := range chain[1:] {
+		if !isSelfSigned(cert) && now.After(cert.NotAfter) {
+			res = append(res, cert.Subject.String())
+		}
+	}
+	return res
 }
 
 func daysLeft(t time.Time, u time.Time) int {
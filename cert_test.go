@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -272,7 +276,7 @@ func Test_getCertList(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := getCertList(tt.args.ctx, tt.args.addrs, tt.args.timeout, tt.args.insecure, tt.args.location)
+			got, err := getCertList(tt.args.ctx, tt.args.addrs, &options{timeout: tt.args.timeout, insecure: tt.args.insecure, location: tt.args.location})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getCertList() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -311,10 +315,12 @@ func Test_getCertList(t *testing.T) {
 
 func Test_newConnector(t *testing.T) {
 	type args struct {
-		addr     string
-		timeout  time.Duration
-		location *time.Location
-		insecure bool
+		addr       string
+		timeout    time.Duration
+		location   *time.Location
+		insecure   bool
+		serverName string
+		starttls   string
 	}
 	tests := []struct {
 		name    string
@@ -339,18 +345,75 @@ func Test_newConnector(t *testing.T) {
 				tlsConfig: &tls.Config{
 					ServerName:         host,
 					MinVersion:         tls.VersionTLS12,
-					InsecureSkipVerify: false, // #nosec G402
+					InsecureSkipVerify: true, // #nosec G402
+				},
+			},
+		},
+		{
+			name: "insecure",
+			args: args{
+				addr:     addr,
+				timeout:  5 * time.Second,
+				location: time.Local,
+				insecure: true,
+			},
+			want: &connector{
+				addr:     addr,
+				host:     host,
+				port:     port,
+				timeout:  5 * time.Second,
+				location: time.Local,
+				insecure: true,
+				tlsConfig: &tls.Config{
+					ServerName:         host,
+					MinVersion:         tls.VersionTLS12,
+					InsecureSkipVerify: true, // #nosec G402
+				},
+			},
+		},
+		{
+			name: "unknown starttls",
+			args: args{
+				addr:     addr,
+				timeout:  5 * time.Second,
+				location: time.Local,
+				starttls: "unknown",
+			},
+			wantErr: true,
+		},
+		{
+			name: "server name",
+			args: args{
+				addr:       addr,
+				timeout:    5 * time.Second,
+				location:   time.Local,
+				insecure:   false,
+				serverName: "example.com",
+			},
+			want: &connector{
+				addr:     addr,
+				host:     host,
+				port:     port,
+				timeout:  5 * time.Second,
+				location: time.Local,
+				tlsConfig: &tls.Config{
+					ServerName:         "example.com",
+					MinVersion:         tls.VersionTLS12,
+					InsecureSkipVerify: true, // #nosec G402
 				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newConnector(tt.args.addr, tt.args.timeout, tt.args.insecure, tt.args.location)
+			got, err := newConnector(tt.args.addr, &options{timeout: tt.args.timeout, insecure: tt.args.insecure, location: tt.args.location, serverName: tt.args.serverName, starttls: tt.args.starttls})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("newConnector() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 			if !reflect.DeepEqual(got.addr, tt.want.addr) {
 				t.Errorf("addr = %v, want %v", got.addr, tt.want.addr)
 			}
@@ -366,6 +429,9 @@ func Test_newConnector(t *testing.T) {
 			if !reflect.DeepEqual(got.location, tt.want.location) {
 				t.Errorf("location = %v, want %v", got.location, tt.want.location)
 			}
+			if got.insecure != tt.want.insecure {
+				t.Errorf("insecure = %v, want %v", got.insecure, tt.want.insecure)
+			}
 			if !reflect.DeepEqual(got.tlsConfig, tt.want.tlsConfig) {
 				t.Errorf("tlsConfig = %v, want %v", got.tlsConfig, tt.want.tlsConfig)
 			}
@@ -428,7 +494,7 @@ func Test_connector_lookupIP(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ipMap.Delete(tt.fields.host)
+			ipCache.delete(tt.fields.host)
 			c := &connector{
 				addr:      tt.fields.addr,
 				host:      tt.fields.host,
@@ -509,7 +575,7 @@ func Test_connector_getTLSConn(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			connMap.Delete(tt.fields.host)
+			connCache.delete(tt.fields.addr)
 			c := &connector{
 				addr:      tt.fields.addr,
 				host:      tt.fields.host,
@@ -570,6 +636,10 @@ func Test_connector_getServerCert(t *testing.T) {
 				NotAfter:    getNotAfter(time.Local),
 				CurrentTime: getCurrentTime(time.Local),
 				DaysLeft:    1,
+
+				SignatureAlgorithm: "SHA256-RSA",
+				PublicKeyAlgorithm: "RSA",
+				KeySize:            4096,
 			},
 			wantErr: false,
 		},
@@ -599,6 +669,10 @@ func Test_connector_getServerCert(t *testing.T) {
 				NotAfter:    getNotAfter(time.UTC),
 				CurrentTime: getCurrentTime(time.UTC),
 				DaysLeft:    1,
+
+				SignatureAlgorithm: "SHA256-RSA",
+				PublicKeyAlgorithm: "RSA",
+				KeySize:            4096,
 			},
 			wantErr: false,
 		},
@@ -618,7 +692,7 @@ func Test_connector_getServerCert(t *testing.T) {
 			if err := c.getTLSConn(ctx); err != nil {
 				t.Fatal(err)
 			}
-			got, err := c.getServerCert()
+			got, err := c.getServerCert(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("connector.getServerCert() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -647,6 +721,221 @@ func Test_connector_getServerCert(t *testing.T) {
 			if !reflect.DeepEqual(got.NotAfter, tt.want.NotAfter) {
 				t.Errorf("NotAfter = %v, want %v", got.NotAfter, tt.want.NotAfter)
 			}
+			if got.SignatureAlgorithm != tt.want.SignatureAlgorithm {
+				t.Errorf("SignatureAlgorithm = %v, want %v", got.SignatureAlgorithm, tt.want.SignatureAlgorithm)
+			}
+			if got.PublicKeyAlgorithm != tt.want.PublicKeyAlgorithm {
+				t.Errorf("PublicKeyAlgorithm = %v, want %v", got.PublicKeyAlgorithm, tt.want.PublicKeyAlgorithm)
+			}
+			if got.KeySize != tt.want.KeySize {
+				t.Errorf("KeySize = %v, want %v", got.KeySize, tt.want.KeySize)
+			}
+			if len(got.SerialNumber) == 0 || len(got.FingerprintSHA256) != 95 || len(got.FingerprintSHA1) != 59 {
+				t.Errorf("unexpected serial or fingerprints: %v %v %v", got.SerialNumber, got.FingerprintSHA256, got.FingerprintSHA1)
+			}
+		})
+	}
+}
+
+func Test_topExpiring(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &certInfo{DomainName: "a.example.com", NotAfter: base.AddDate(0, 0, 30)}
+	b := &certInfo{DomainName: "b.example.com", NotAfter: base.AddDate(0, 0, 10)}
+	c := &certInfo{DomainName: "c.example.com", NotAfter: base.AddDate(0, 0, 20)}
+	d := &certInfo{DomainName: "d.example.com", NotAfter: base.AddDate(0, 0, 10)}
+	type args struct {
+		infos []*certInfo
+		n     int
+	}
+	tests := []struct {
+		name string
+		args args
+		want []*certInfo
+	}{
+		{
+			name: "basic",
+			args: args{
+				infos: []*certInfo{a, b, c, d},
+				n:     2,
+			},
+			want: []*certInfo{b, d},
+		},
+		{
+			name: "more than length",
+			args: args{
+				infos: []*certInfo{a, b, c},
+				n:     5,
+			},
+			want: []*certInfo{b, c, a},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topExpiring(tt.args.infos, tt.args.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("topExpiring() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_uniqueCerts(t *testing.T) {
+	a := &certInfo{DomainName: "a.example.com", AccessPort: "443", CommonName: "a", FingerprintSHA256: "aaa"}
+	b := &certInfo{DomainName: "b.example.com", AccessPort: "443", CommonName: "a", FingerprintSHA256: "aaa"}
+	c := &certInfo{DomainName: "c.example.com", AccessPort: "8443", CommonName: "c", FingerprintSHA256: "ccc"}
+	tests := []struct {
+		name string
+		arg  []*certInfo
+		want []*certInfo
+	}{
+		{
+			name: "basic",
+			arg:  []*certInfo{a, b, c},
+			want: []*certInfo{
+				{DomainName: "a.example.com", AccessPort: "443", CommonName: "a", Hosts: []string{"a.example.com:443", "b.example.com:443"}, FingerprintSHA256: "aaa"},
+				{DomainName: "c.example.com", AccessPort: "8443", CommonName: "c", Hosts: []string{"c.example.com:8443"}, FingerprintSHA256: "ccc"},
+			},
+		},
+		{
+			name: "empty",
+			arg:  []*certInfo{},
+			want: []*certInfo{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uniqueCerts(tt.arg)
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(certInfo{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	if a.Hosts != nil {
+		t.Error("uniqueCerts() modified input")
+	}
+}
+
+func Test_adaptiveTimeout(t *testing.T) {
+	type args struct {
+		rtt   time.Duration
+		limit time.Duration
+	}
+	tests := []struct {
+		name string
+		args args
+		want time.Duration
+	}{
+		{
+			name: "scaled",
+			args: args{
+				rtt:   200 * time.Millisecond,
+				limit: 5 * time.Second,
+			},
+			want: 2 * time.Second,
+		},
+		{
+			name: "minimum",
+			args: args{
+				rtt:   1 * time.Millisecond,
+				limit: 5 * time.Second,
+			},
+			want: 500 * time.Millisecond,
+		},
+		{
+			name: "bounded by limit",
+			args: args{
+				rtt:   1 * time.Second,
+				limit: 5 * time.Second,
+			},
+			want: 5 * time.Second,
+		},
+		{
+			name: "limit below minimum",
+			args: args{
+				rtt:   1 * time.Millisecond,
+				limit: 100 * time.Millisecond,
+			},
+			want: 100 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adaptiveTimeout(tt.args.rtt, tt.args.limit); got != tt.want {
+				t.Errorf("adaptiveTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  []byte
+		want string
+	}{
+		{
+			name: "basic",
+			arg:  []byte{0x0a, 0xbc, 0xff},
+			want: "0A:BC:FF",
+		},
+		{
+			name: "empty",
+			arg:  []byte{},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fingerprint(tt.arg); got != tt.want {
+				t.Errorf("fingerprint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_keySize(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		arg  any
+		want int
+	}{
+		{
+			name: "rsa",
+			arg:  &rsaKey.PublicKey,
+			want: 2048,
+		},
+		{
+			name: "ecdsa",
+			arg:  &ecKey.PublicKey,
+			want: 384,
+		},
+		{
+			name: "ed25519",
+			arg:  edKey,
+			want: 256,
+		},
+		{
+			name: "unknown",
+			arg:  nil,
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keySize(tt.arg); got != tt.want {
+				t.Errorf("keySize() = %v, want %v", got, tt.want)
+			}
 		})
 	}
 }
@@ -751,6 +1040,52 @@ func Test_daysLeft(t *testing.T) {
 	}
 }
 
+func Test_chainDaysLeft(t *testing.T) {
+	// a served root is left out even when it expires first
+	root := newTestChain(t).root
+	now := root.NotAfter.AddDate(0, 0, -1)
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "www.example.com"}, NotAfter: now.AddDate(0, 0, 60)}
+	intermediate := &x509.Certificate{Subject: pkix.Name{CommonName: "R3"}, NotAfter: now.AddDate(0, 0, 10)}
+	expired := &x509.Certificate{Subject: pkix.Name{CommonName: "ISRG Root X1"}, NotAfter: now.AddDate(0, 0, -1)}
+	tests := []struct {
+		name        string
+		chain       []*x509.Certificate
+		at          time.Time
+		wantDays    int
+		wantExpired []string
+	}{
+		{
+			name:     "leaf only",
+			chain:    []*x509.Certificate{leaf},
+			at:       now,
+			wantDays: 60,
+		},
+		{
+			name:     "intermediate first",
+			chain:    []*x509.Certificate{leaf, intermediate, root},
+			at:       now,
+			wantDays: 10,
+		},
+		{
+			name:        "expired cross-sign",
+			chain:       []*x509.Certificate{leaf, intermediate, expired},
+			at:          now,
+			wantDays:    -1,
+			wantExpired: []string{"CN=ISRG Root X1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chainDaysLeft(tt.chain, tt.at); *got != tt.wantDays {
+				t.Errorf("chainDaysLeft() = %d, want %d", *got, tt.wantDays)
+			}
+			if diff := cmp.Diff(tt.wantExpired, expiredInChain(tt.chain, tt.at)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
 func Test_ensureDefaultPort(t *testing.T) {
 	type args struct {
 		addr string
@@ -784,6 +1119,40 @@ func Test_ensureDefaultPort(t *testing.T) {
 	}
 }
 
+func Test_newConnector_swept(t *testing.T) {
+	opts := &options{
+		timeout: 5 * time.Second,
+		sweep:   &sweep{hosts: map[string]bool{"10.0.5.1:443": true}},
+	}
+	tests := []struct {
+		name string
+		addr string
+		want bool
+	}{
+		{
+			name: "expanded",
+			addr: "10.0.5.1:443",
+			want: true,
+		},
+		{
+			name: "given along with a range",
+			addr: "10.0.5.9:443",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := newConnector(tt.addr, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conn.cnIdentity != tt.want {
+				t.Errorf("cnIdentity = %v, want %v", conn.cnIdentity, tt.want)
+			}
+		})
+	}
+}
+
 func Test_ensureHostPort(t *testing.T) {
 	type args struct {
 		addr string
@@ -874,3 +1243,436 @@ func Test_getSANs(t *testing.T) {
 		})
 	}
 }
+
+func Test_loadClientCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	if err := setupCert(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{
+			name:     "pair",
+			certFile: certFile,
+			keyFile:  keyFile,
+			wantNil:  false,
+			wantErr:  false,
+		},
+		{
+			name:     "none",
+			certFile: "",
+			keyFile:  "",
+			wantNil:  true,
+			wantErr:  false,
+		},
+		{
+			name:     "key mismatch",
+			certFile: certFile,
+			keyFile:  certFile,
+			wantNil:  true,
+			wantErr:  true,
+		},
+		{
+			name:     "missing file",
+			certFile: filepath.Join(dir, "missing.pem"),
+			keyFile:  keyFile,
+			wantNil:  true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadClientCert(tt.certFile, tt.keyFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadClientCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Errorf("loadClientCert() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func Test_getCertList_firstError(t *testing.T) {
+	// nothing listens on port 1, so the first host fails while the others
+	// wait for their turn
+	addrs := []string{"127.0.0.1:1", addr, addr, addr}
+	tests := []struct {
+		name string
+		opts *options
+	}{
+		{
+			name: "waiting for the spread",
+			opts: &options{timeout: 5 * time.Second, insecure: true, location: time.UTC, spread: 10 * time.Second},
+		},
+		{
+			name: "waiting for a slot",
+			opts: &options{timeout: 5 * time.Second, insecure: true, location: time.UTC, concurrency: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			_, err := getCertList(context.Background(), addrs, tt.opts)
+			if err == nil || errors.Is(err, context.Canceled) {
+				t.Errorf("getCertList() error = %v, want the error of 127.0.0.1:1", err)
+			}
+		})
+	}
+}
+
+func Test_getCertList_clientCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	if err := setupCert(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := loadClientCert(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	// in TLS 1.3 the client finishes its handshake before the server checks
+	// the client cert, so the refusal is only observable with TLS 1.2
+	config.MaxVersion = tls.VersionTLS12
+	config.ClientAuth = tls.RequireAnyClientCert
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	tests := []struct {
+		name       string
+		clientCert *tls.Certificate
+		wantErr    bool
+	}{
+		{
+			name:       "with client cert",
+			clientCert: clientCert,
+			wantErr:    false,
+		},
+		{
+			name:       "without client cert",
+			clientCert: nil,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:    5 * time.Second,
+				insecure:   true,
+				location:   time.UTC,
+				clientCert: tt.clientCert,
+			}
+			_, err := getCertList(context.Background(), []string{ln.Addr().String()}, opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getCertList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_getCertList_fallbackPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	_, openPort, _ := net.SplitHostPort(ln.Addr().String())
+	_, closedPort, _ := net.SplitHostPort(closedAddr)
+	tests := []struct {
+		name              string
+		addr              string
+		fallback          []string
+		wantAccessPort    string
+		wantRequestedPort string
+		wantErr           bool
+	}{
+		{
+			name:              "primary port open",
+			addr:              ln.Addr().String(),
+			fallback:          []string{closedPort},
+			wantAccessPort:    openPort,
+			wantRequestedPort: "",
+			wantErr:           false,
+		},
+		{
+			name:              "fallback port open",
+			addr:              closedAddr,
+			fallback:          []string{closedPort, openPort},
+			wantAccessPort:    openPort,
+			wantRequestedPort: closedPort,
+			wantErr:           false,
+		},
+		{
+			name:     "no fallback port open",
+			addr:     closedAddr,
+			fallback: []string{closedPort},
+			wantErr:  true,
+		},
+		{
+			name:     "no fallback ports",
+			addr:     closedAddr,
+			fallback: nil,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				insecure: true,
+				location: time.UTC,
+				fallback: tt.fallback,
+			}
+			got, err := getCertList(context.Background(), []string{tt.addr}, opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getCertList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got[0].AccessPort != tt.wantAccessPort {
+				t.Errorf("AccessPort = %v, want %v", got[0].AccessPort, tt.wantAccessPort)
+			}
+			if got[0].RequestedPort != tt.wantRequestedPort {
+				t.Errorf("RequestedPort = %v, want %v", got[0].RequestedPort, tt.wantRequestedPort)
+			}
+		})
+	}
+}
+
+// newSilentListener accepts connections and never writes to them, so that a
+// check hangs until its context is done.
+func newSilentListener(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func Test_getCert_cancel(t *testing.T) {
+	silent := newSilentListener(t)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	p, err := parsePAC(`function FindProxyForURL(url, host) { return "PROXY ` + silent + `"; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		addr   string
+		opts   *options
+		cancel time.Duration
+	}{
+		{
+			name:   "dns",
+			addr:   "cancel.example.com:443",
+			opts:   &options{resolver: newDNSResolver(pc.LocalAddr().String())},
+			cancel: 100 * time.Millisecond,
+		},
+		{
+			name:   "dial",
+			addr:   silent,
+			opts:   &options{},
+			cancel: 0,
+		},
+		{
+			name:   "handshake",
+			addr:   silent,
+			opts:   &options{},
+			cancel: 100 * time.Millisecond,
+		},
+		{
+			name:   "starttls",
+			addr:   silent,
+			opts:   &options{starttls: "smtp"},
+			cancel: 100 * time.Millisecond,
+		},
+		{
+			name:   "proxy",
+			addr:   "cancel.example.com:443",
+			opts:   &options{pac: p},
+			cancel: 100 * time.Millisecond,
+		},
+		{
+			name:   "partial handshake",
+			addr:   silent,
+			opts:   &options{partial: true},
+			cancel: 100 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			// the timeout is far longer than the test, so only the
+			// cancellation can end the check in time
+			tt.opts.timeout = time.Minute
+			tt.opts.insecure = true
+			tt.opts.location = time.UTC
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel > 0 {
+				time.AfterFunc(tt.cancel, cancel)
+			} else {
+				cancel()
+			}
+			start := time.Now()
+			_, err := getCert(ctx, tt.addr, tt.opts)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("getCert() error = %v, want %v", err, context.Canceled)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("getCert() returned after %v", elapsed)
+			}
+		})
+	}
+}
+
+func Test_getCertList_allIPs(t *testing.T) {
+	ln1, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln1.Close() })
+	_, port, _ := net.SplitHostPort(ln1.Addr().String())
+	ln2, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", port))
+	if err != nil {
+		t.Skip("cannot listen on 127.0.0.2:", err)
+	}
+	t.Cleanup(func() { ln2.Close() })
+	for _, ln := range []net.Listener{ln1, ln2} {
+		config := newTestTLSConfig(t)
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					tlsConn := tls.Server(conn, config)
+					if err := tlsConn.Handshake(); err != nil {
+						return
+					}
+					_, _ = tlsConn.Read(make([]byte, 1))
+				}()
+			}
+		}()
+	}
+	ips := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}
+	tests := []struct {
+		name    string
+		ips     []net.IP
+		wantIPs [][]net.IP
+		wantErr bool
+	}{
+		{
+			name:    "each address",
+			ips:     ips,
+			wantIPs: [][]net.IP{{ips[0]}, {ips[1]}},
+			wantErr: false,
+		},
+		{
+			name:    "one address down",
+			ips:     []net.IP{ips[0], net.ParseIP("127.0.0.3")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				insecure: true,
+				location: time.UTC,
+				allIPs:   true,
+				resolver: &countingResolver{ips: tt.ips},
+			}
+			infos, err := getCertList(context.Background(), []string{"lb.invalid:" + port}, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCertList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var gotIPs [][]net.IP
+			for _, info := range infos {
+				gotIPs = append(gotIPs, info.IPAddresses)
+				if info.DomainName != "lb.invalid" {
+					t.Errorf("DomainName = %s, want lb.invalid", info.DomainName)
+				}
+			}
+			if diff := cmp.Diff(tt.wantIPs, gotIPs); diff != "" {
+				t.Error(diff)
+			}
+			if infos[0].FingerprintSHA256 == infos[1].FingerprintSHA256 {
+				t.Error("same cert at each address")
+			}
+		})
+	}
+}
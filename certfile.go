@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// sourceCertFile is the source of the certs read from local files.
+const sourceCertFile = "cert-file"
+
+// certFiles reads the certs of local files, so that certs not deployed yet
+// can be reported the same way as served ones. A file has a cert, or a chain
+// with the leaf first, in PEM or DER, or is a PKCS#12 file or a Java keystore
+// with a cert per entry.
+type certFiles struct {
+	paths    []string
+	password string
+
+	// prompt asks for the password of the PKCS#12 file at path when none is
+	// given and it has one
+	prompt func(path string) (string, error)
+}
+
+// infos returns the certs of the files as if served, with the path as
+// DomainName and the alias of a keystore entry as Alias.
+func (f *certFiles) infos(loc *time.Location) ([]*certInfo, error) {
+	infos := make([]*certInfo, 0, len(f.paths))
+	now := time.Now()
+	for _, path := range f.paths {
+		entries, err := f.read(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read cert file %s: %w", path, err)
+		}
+		for _, e := range entries {
+			info := newCertInfo(e.chain, now, loc)
+			info.DomainName = path
+			info.Alias = e.alias
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func (f *certFiles) read(path string) ([]keystoreEntry, error) {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case isJKS(b):
+		if f.password == "" {
+			log.Warn("keystore integrity not verified without password", "path", path)
+		}
+		return readJKS(b, f.password)
+	case isPKCS12(path):
+		entries, err := readPKCS12(b, f.password)
+		if !errors.Is(err, errKeystorePassword) || f.password != "" || f.prompt == nil {
+			return entries, err
+		}
+		password, err := f.prompt(path)
+		if err != nil {
+			return nil, err
+		}
+		return readPKCS12(b, password)
+	}
+	chain, err := readChain(b)
+	if err != nil {
+		return nil, err
+	}
+	return []keystoreEntry{{chain: chain}}, nil
+}
+
+// readChain reads the chain in b, which is taken as DER when it has no PEM
+// block.
+func readChain(b []byte) ([]*x509.Certificate, error) {
+	if bytes.Contains(b, []byte("-----BEGIN ")) {
+		return parsePEMChain(b)
+	}
+	return x509.ParseCertificates(b)
+}
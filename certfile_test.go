@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_certFiles_infos(t *testing.T) {
+	config := newTestTLSConfig(t)
+	der := config.Certificates[0].Certificate[0]
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"leaf.der":  der,
+		"chain.pem": append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...),
+		"key.pem":   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")}),
+		"junk.der":  []byte("junk"),
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		paths     []string
+		wantChain []int
+		wantErr   bool
+	}{
+		{
+			name:      "pem and der",
+			paths:     []string{filepath.Join("testdata", "cert.pem"), filepath.Join(dir, "leaf.der"), filepath.Join(dir, "chain.pem")},
+			wantChain: []int{1, 1, 2},
+		},
+		{
+			name:    "pem without cert",
+			paths:   []string{filepath.Join(dir, "key.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "invalid der",
+			paths:   []string{filepath.Join(dir, "junk.der")},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			paths:   []string{filepath.Join(dir, "missing.pem")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &certFiles{paths: tt.paths}
+			got, err := f.infos(time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("infos() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != len(tt.paths) {
+				t.Fatalf("infos() = %d infos, want %d", len(got), len(tt.paths))
+			}
+			for i, info := range got {
+				if info.DomainName != tt.paths[i] {
+					t.Errorf("DomainName = %q, want %q", info.DomainName, tt.paths[i])
+				}
+				if len(info.chain) != tt.wantChain[i] {
+					t.Errorf("chain of %s = %d certs, want %d", tt.paths[i], len(info.chain), tt.wantChain[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	changeNew            = "new"
+	changeRemoved        = "removed"
+	changeCert           = "cert-changed"
+	changeIssuer         = "issuer-changed"
+	changeExpiryExtended = "expiry-extended"
+	changeExpiryShorter  = "expiry-shortened"
+	changeSANs           = "sans-changed"
+)
+
+// changeEvent is a change of a host since the snapshot of the previous run.
+// A renewed cert brings cert-changed along with expiry-extended, and with
+// issuer-changed when it comes from another CA or sans-changed when it names
+// other hosts, so that consumers can subscribe to the kinds they care about.
+// A cert expiring sooner than the one it replaces, as when an old cert is
+// redeployed, brings expiry-shortened instead.
+type changeEvent struct {
+	Type   string
+	Host   string
+	Time   time.Time
+	Before *certInfo `json:",omitempty"`
+	After  *certInfo `json:",omitempty"`
+}
+
+// loadSnapshot reads the snapshot of the previous run, which is a scan in the
+// JSON output format. No snapshot yet is an empty one. The history is not
+// used instead, as it keeps neither the SANs nor the whole certs the events
+// carry, and needs sqlite3.
+func loadSnapshot(path string) ([]*certInfo, error) {
+	infos, err := loadBaseline(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return infos, err
+}
+
+// diffSnapshot returns the changes from prev to cur ordered by host, and the
+// snapshot for the next run. The hosts in failed keep their entries in the
+// snapshot, since a host that cannot be checked for now is not removed.
+func diffSnapshot(prev, cur []*certInfo, failed []hostFailure, now time.Time) ([]changeEvent, []*certInfo) {
+	key := func(info *certInfo) string {
+		return net.JoinHostPort(info.DomainName, info.AccessPort)
+	}
+	before := make(map[string]*certInfo, len(prev))
+	for _, info := range prev {
+		before[key(info)] = info
+	}
+	after := make(map[string]*certInfo, len(cur))
+	for _, info := range cur {
+		after[key(info)] = info
+	}
+	next := slices.Clone(cur)
+	for _, f := range failed {
+		if info, ok := before[f.addr]; ok {
+			after[f.addr] = info
+			next = append(next, info)
+		}
+	}
+	var events []changeEvent
+	for host, a := range after {
+		b, ok := before[host]
+		switch {
+		case !ok:
+			events = append(events, changeEvent{Type: changeNew, Host: host, After: a})
+		case b.FingerprintSHA256 != a.FingerprintSHA256:
+			events = append(events, changeEvent{Type: changeCert, Host: host, Before: b, After: a})
+			if b.Issuer != a.Issuer {
+				events = append(events, changeEvent{Type: changeIssuer, Host: host, Before: b, After: a})
+			}
+			if !sameSANs(b.SANs, a.SANs) {
+				events = append(events, changeEvent{Type: changeSANs, Host: host, Before: b, After: a})
+			}
+			switch {
+			case a.NotAfter.After(b.NotAfter):
+				events = append(events, changeEvent{Type: changeExpiryExtended, Host: host, Before: b, After: a})
+			case a.NotAfter.Before(b.NotAfter):
+				events = append(events, changeEvent{Type: changeExpiryShorter, Host: host, Before: b, After: a})
+			}
+		}
+	}
+	for host, b := range before {
+		if _, ok := after[host]; !ok {
+			events = append(events, changeEvent{Type: changeRemoved, Host: host, Before: b})
+		}
+	}
+	order := []string{changeNew, changeCert, changeIssuer, changeSANs, changeExpiryExtended, changeExpiryShorter, changeRemoved}
+	slices.SortStableFunc(events, func(x, y changeEvent) int {
+		if c := strings.Compare(x.Host, y.Host); c != 0 {
+			return c
+		}
+		return slices.Index(order, x.Type) - slices.Index(order, y.Type)
+	})
+	for i := range events {
+		events[i].Time = now
+	}
+	slices.SortFunc(next, func(x, y *certInfo) int {
+		return strings.Compare(key(x), key(y))
+	})
+	return events, next
+}
+
+// sameSANs reports whether a and b name the same hosts regardless of order.
+func sameSANs(a, b []string) bool {
+	x, y := slices.Clone(a), slices.Clone(b)
+	slices.Sort(x)
+	slices.Sort(y)
+	return slices.Equal(x, y)
+}
+
+// toChanges writes an event per line.
+func toChanges(events []changeEvent, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveSnapshot replaces the snapshot at path atomically, so that an
+// interrupted run leaves the previous one for the next run to diff against.
+func saveSnapshot(path string, infos []*certInfo) (err error) {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.abort()
+		}
+	}()
+	if err := toJSON(infos, f); err != nil {
+		return err
+	}
+	return f.commit()
+}
+
+// runState keeps the results of a run in a snapshot, to report what changed
+// since then on the next run. The snapshot diffed against and the one saved
+// are usually the same file.
+type runState struct {
+	diffPath string
+	savePath string
+}
+
+// apply marks infos with the changes since the snapshot at diffPath, if any,
+// logging the hosts gone since then, and saves infos to savePath, if any. The
+// hosts in failed are kept in the saved snapshot.
+func (s *runState) apply(infos []*certInfo, failed []hostFailure, now time.Time) error {
+	if s == nil {
+		return nil
+	}
+	var prev []*certInfo
+	if s.diffPath != "" {
+		var err error
+		prev, err = loadSnapshot(s.diffPath)
+		if err != nil {
+			return err
+		}
+	}
+	events, next := diffSnapshot(prev, infos, failed, now)
+	if s.savePath != "" {
+		if err := saveSnapshot(s.savePath, next); err != nil {
+			return err
+		}
+	}
+	if s.diffPath == "" {
+		return nil
+	}
+	for _, e := range events {
+		if e.After == nil {
+			log.Warn("host is gone since the last run", "host", e.Host)
+			continue
+		}
+		e.After.Changes = append(e.After.Changes, e.Type)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_diffSnapshot(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := now.AddDate(0, 1, 0)
+	cert := func(domain, fp, issuer string, notAfter time.Time) *certInfo {
+		return &certInfo{DomainName: domain, AccessPort: "443", FingerprintSHA256: fp, Issuer: issuer, NotAfter: notAfter}
+	}
+	same := cert("same.example.com", "s", "CN=R3", notAfter)
+	renewed := cert("renewed.example.com", "r1", "CN=R3", notAfter)
+	renewedNew := cert("renewed.example.com", "r2", "CN=R3", notAfter.AddDate(0, 3, 0))
+	moved := cert("moved.example.com", "m1", "CN=R3", notAfter)
+	movedNew := cert("moved.example.com", "m2", "CN=E1", notAfter)
+	removed := cert("removed.example.com", "x", "CN=R3", notAfter)
+	down := cert("down.example.com", "d", "CN=R3", notAfter)
+	added := cert("added.example.com", "a", "CN=R3", notAfter)
+	prev := []*certInfo{same, renewed, moved, removed, down}
+	cur := []*certInfo{same, renewedNew, movedNew, added}
+	failed := []hostFailure{
+		{addr: "down.example.com:443", err: errors.New("refused")},
+		{addr: "unknown.example.com:443", err: errors.New("refused")},
+	}
+	events, next := diffSnapshot(prev, cur, failed, now)
+	want := []changeEvent{
+		{Type: changeNew, Host: "added.example.com:443", Time: now, After: added},
+		{Type: changeCert, Host: "moved.example.com:443", Time: now, Before: moved, After: movedNew},
+		{Type: changeIssuer, Host: "moved.example.com:443", Time: now, Before: moved, After: movedNew},
+		{Type: changeRemoved, Host: "removed.example.com:443", Time: now, Before: removed},
+		{Type: changeCert, Host: "renewed.example.com:443", Time: now, Before: renewed, After: renewedNew},
+		{Type: changeExpiryExtended, Host: "renewed.example.com:443", Time: now, Before: renewed, After: renewedNew},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("diffSnapshot() events = %v, want %v", events, want)
+	}
+	wantNext := []*certInfo{added, down, movedNew, renewedNew, same}
+	if !reflect.DeepEqual(next, wantNext) {
+		t.Errorf("diffSnapshot() next = %v, want %v", next, wantNext)
+	}
+	events, _ = diffSnapshot(next, next, nil, now)
+	if len(events) != 0 {
+		t.Errorf("diffSnapshot() of the same snapshot = %v, want none", events)
+	}
+}
+
+func Test_diffSnapshot_reissued(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := now.AddDate(0, 1, 0)
+	before := &certInfo{DomainName: "example.com", AccessPort: "443", FingerprintSHA256: "a", SANs: []string{"example.com", "www.example.com"}, NotAfter: notAfter}
+	tests := []struct {
+		name  string
+		after *certInfo
+		want  []string
+	}{
+		{
+			name:  "sans reordered",
+			after: &certInfo{DomainName: "example.com", AccessPort: "443", FingerprintSHA256: "b", SANs: []string{"www.example.com", "example.com"}, NotAfter: notAfter},
+			want:  []string{changeCert},
+		},
+		{
+			name:  "sans changed",
+			after: &certInfo{DomainName: "example.com", AccessPort: "443", FingerprintSHA256: "b", SANs: []string{"example.com"}, NotAfter: notAfter},
+			want:  []string{changeCert, changeSANs},
+		},
+		{
+			name:  "old cert redeployed",
+			after: &certInfo{DomainName: "example.com", AccessPort: "443", FingerprintSHA256: "b", SANs: before.SANs, NotAfter: notAfter.AddDate(0, 0, -20)},
+			want:  []string{changeCert, changeExpiryShorter},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, _ := diffSnapshot([]*certInfo{before}, []*certInfo{tt.after}, nil, now)
+			var got []string
+			for _, e := range events {
+				got = append(got, e.Type)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_runState_apply(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := now.AddDate(0, 1, 0)
+	path := filepath.Join(t.TempDir(), "state.json")
+	st := &runState{diffPath: path, savePath: path}
+	first := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "443", FingerprintSHA256: "a1", Issuer: "CN=R3", NotAfter: notAfter},
+		{DomainName: "b.example.com", AccessPort: "443", FingerprintSHA256: "b1", Issuer: "CN=R3", NotAfter: notAfter},
+	}
+	if err := st.apply(first, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range first {
+		if diff := cmp.Diff([]string{changeNew}, info.Changes); diff != "" {
+			t.Errorf("first run: %s", diff)
+		}
+	}
+	second := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "443", FingerprintSHA256: "a1", Issuer: "CN=R3", NotAfter: notAfter},
+		{DomainName: "b.example.com", AccessPort: "443", FingerprintSHA256: "b2", Issuer: "CN=E1", NotAfter: notAfter.AddDate(0, 3, 0)},
+	}
+	if err := st.apply(second, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if second[0].Changes != nil {
+		t.Errorf("unchanged host has changes %v", second[0].Changes)
+	}
+	if diff := cmp.Diff([]string{changeCert, changeIssuer, changeExpiryExtended}, second[1].Changes); diff != "" {
+		t.Error(diff)
+	}
+	saved, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved[1].FingerprintSHA256 != "b2" || saved[1].Changes != nil {
+		t.Errorf("saved snapshot = %v, want the second run without changes", saved)
+	}
+	// saving alone marks nothing
+	third := []*certInfo{{DomainName: "c.example.com", AccessPort: "443", FingerprintSHA256: "c1"}}
+	if err := (&runState{savePath: path}).apply(third, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if third[0].Changes != nil {
+		t.Errorf("changes without diff = %v", third[0].Changes)
+	}
+	if err := (*runState)(nil).apply(third, nil, now); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	got, err := loadSnapshot(path)
+	if err != nil || got != nil {
+		t.Fatalf("loadSnapshot() of no snapshot = %v, %v, want none", got, err)
+	}
+	infos := []*certInfo{
+		{DomainName: "example.com", AccessPort: "443", FingerprintSHA256: "AB", NotAfter: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if err := saveSnapshot(path, infos); err != nil {
+		t.Fatal(err)
+	}
+	got, err = loadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].FingerprintSHA256 != "AB" || !got[0].NotAfter.Equal(infos[0].NotAfter) {
+		t.Errorf("loadSnapshot() = %v, want %v", got, infos)
+	}
+	if _, err := loadSnapshot(filepath.Join("testdata", "inventory.csv")); err == nil {
+		t.Error("loadSnapshot() of a CSV file succeeded")
+	}
+}
+
+func Test_toChanges(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	events := []changeEvent{
+		{Type: changeRemoved, Host: "a.example.com:443", Time: now, Before: &certInfo{DomainName: "a.example.com", AccessPort: "443"}},
+		{Type: changeNew, Host: "b.example.com:443", Time: now, After: &certInfo{DomainName: "b.example.com", AccessPort: "443"}},
+	}
+	w := &bytes.Buffer{}
+	if err := toChanges(events, w); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(w.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("toChanges() wrote %d lines, want 2", len(lines))
+	}
+	for i, prefix := range []string{
+		`{"Type":"removed","Host":"a.example.com:443","Time":"2025-03-01T00:00:00Z","Before":{"DomainName":"a.example.com"`,
+		`{"Type":"new","Host":"b.example.com:443","Time":"2025-03-01T00:00:00Z","After":{"DomainName":"b.example.com"`,
+	} {
+		if !bytes.HasPrefix(lines[i], []byte(prefix)) {
+			t.Errorf("line %d = %s, want prefix %s", i, lines[i], prefix)
+		}
+	}
+}
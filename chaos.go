@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// chaos injects artificial failures so that alerting pipelines built on the
+// output can be rehearsed without touching real endpoints. A nil chaos
+// injects nothing.
+type chaos struct {
+	dnsFailureRate     float64
+	handshakeDelay     time.Duration
+	malformedChainRate float64
+}
+
+func newChaos(dnsFailureRate float64, handshakeDelay time.Duration, malformedChainRate float64) (*chaos, error) {
+	for _, rate := range []float64{dnsFailureRate, malformedChainRate} {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid failure rate: %v: allowed range is 0 to 1", rate)
+		}
+	}
+	if dnsFailureRate == 0 && handshakeDelay == 0 && malformedChainRate == 0 {
+		return nil, nil
+	}
+	c := &chaos{
+		dnsFailureRate:     dnsFailureRate,
+		handshakeDelay:     handshakeDelay,
+		malformedChainRate: malformedChainRate,
+	}
+	return c, nil
+}
+
+func (c *chaos) dnsFailure(host string) error {
+	if c == nil || rand.Float64() >= c.dnsFailureRate {
+		return nil
+	}
+	return &net.DNSError{Err: "no such host (injected)", Name: host, IsNotFound: true}
+}
+
+func (c *chaos) delayHandshake(ctx context.Context) error {
+	if c == nil || c.handshakeDelay == 0 {
+		return nil
+	}
+	return sleep(ctx, c.handshakeDelay)
+}
+
+func (c *chaos) chainFailure() error {
+	if c == nil || rand.Float64() >= c.malformedChainRate {
+		return nil
+	}
+	return errors.New("x509: malformed certificate (injected)")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_newChaos(t *testing.T) {
+	type args struct {
+		dnsFailureRate     float64
+		handshakeDelay     time.Duration
+		malformedChainRate float64
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantNil bool
+		wantErr bool
+	}{
+		{
+			name:    "disabled",
+			args:    args{},
+			wantNil: true,
+			wantErr: false,
+		},
+		{
+			name: "basic",
+			args: args{
+				dnsFailureRate:     0.5,
+				handshakeDelay:     time.Second,
+				malformedChainRate: 1,
+			},
+			wantNil: false,
+			wantErr: false,
+		},
+		{
+			name: "rate out of range",
+			args: args{
+				dnsFailureRate: 1.5,
+			},
+			wantNil: true,
+			wantErr: true,
+		},
+		{
+			name: "negative rate",
+			args: args{
+				malformedChainRate: -0.1,
+			},
+			wantNil: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newChaos(tt.args.dnsFailureRate, tt.args.handshakeDelay, tt.args.malformedChainRate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newChaos() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Errorf("newChaos() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func Test_chaos(t *testing.T) {
+	var none *chaos
+	if err := none.dnsFailure(host); err != nil {
+		t.Errorf("dnsFailure() error = %v", err)
+	}
+	if err := none.delayHandshake(context.Background()); err != nil {
+		t.Errorf("delayHandshake() error = %v", err)
+	}
+	if err := none.chainFailure(); err != nil {
+		t.Errorf("chainFailure() error = %v", err)
+	}
+	all := &chaos{dnsFailureRate: 1, handshakeDelay: time.Hour, malformedChainRate: 1}
+	var dnsErr *net.DNSError
+	if err := all.dnsFailure(host); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("dnsFailure() error = %v, want DNS not found error", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := all.delayHandshake(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("delayHandshake() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := all.chainFailure(); err == nil {
+		t.Error("chainFailure() error = nil")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checkpoint records each completed host of a run as a JSON line so that an
+// interrupted run can be resumed without checking those hosts again.
+type checkpoint struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	enc  *json.Encoder
+	done map[string]*certInfo
+}
+
+type checkpointEntry struct {
+	Addr string
+	Info *certInfo
+}
+
+// openCheckpoint starts a checkpoint at path, or continues the one found
+// there when resume is set. An empty path disables checkpointing.
+func openCheckpoint(path string, resume bool) (*checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+	cp := &checkpoint{
+		path: filepath.Clean(path),
+		done: make(map[string]*certInfo),
+	}
+	if resume {
+		if err := cp.load(); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(cp.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	cp.f = f
+	cp.enc = json.NewEncoder(f)
+	// rewrite what was loaded so that a line cut short by the interruption
+	// does not run into the next record
+	for addr, info := range cp.done {
+		if err := cp.enc.Encode(checkpointEntry{Addr: addr, Info: info}); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return cp, nil
+}
+
+func (cp *checkpoint) load() error {
+	f, err := os.Open(cp.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	var invalid error
+	for n := 1; scanner.Scan(); n++ {
+		// only the last line may be broken, by the interruption
+		if invalid != nil {
+			return invalid
+		}
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Info == nil {
+			invalid = fmt.Errorf("invalid checkpoint %s: line %d", cp.path, n)
+			continue
+		}
+		cp.done[entry.Addr] = entry.Info
+	}
+	return scanner.Err()
+}
+
+// pending splits addrs into those still to be checked and the results
+// already recorded for the others.
+func (cp *checkpoint) pending(addrs []string) ([]string, []*certInfo) {
+	if cp == nil {
+		return addrs, nil
+	}
+	var todo []string
+	var done []*certInfo
+	for _, addr := range addrs {
+		if info, ok := cp.done[ensureDefaultPort(addr)]; ok {
+			done = append(done, info)
+			continue
+		}
+		todo = append(todo, addr)
+	}
+	return todo, done
+}
+
+func (cp *checkpoint) record(addr string, info *certInfo) error {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.enc.Encode(checkpointEntry{Addr: ensureDefaultPort(addr), Info: info})
+}
+
+func (cp *checkpoint) close() error {
+	if cp == nil {
+		return nil
+	}
+	return cp.f.Close()
+}
+
+// remove discards the checkpoint once the run has completed.
+func (cp *checkpoint) remove() error {
+	if cp == nil {
+		return nil
+	}
+	if err := cp.f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return os.Remove(cp.path)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_checkpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	cp, err := openCheckpoint(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &certInfo{DomainName: "a.example", AccessPort: "443", DaysLeft: 10}
+	b := &certInfo{DomainName: "b.example", AccessPort: "8443", DaysLeft: 20}
+	if err := cp.record("a.example", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.record("b.example:8443", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate an interruption in the middle of writing a record
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"Addr":"c.example:443","Info":{"Doma`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cp, err = openCheckpoint(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo, done := cp.pending([]string{"a.example:443", "b.example:8443", "c.example"})
+	if diff := cmp.Diff(todo, []string{"c.example"}); diff != "" {
+		t.Error(diff)
+	}
+	if len(done) != 2 || done[0].DomainName != "a.example" || done[1].DaysLeft != 20 {
+		t.Errorf("pending() done = %+v", done)
+	}
+	c := &certInfo{DomainName: "c.example", AccessPort: "443"}
+	if err := cp.record("c.example", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err = openCheckpoint(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo, done = cp.pending([]string{"a.example", "b.example:8443", "c.example"})
+	if len(todo) != 0 || len(done) != 3 {
+		t.Errorf("pending() = %v, %d done, want all done", todo, len(done))
+	}
+	if err := cp.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint is not removed: %v", err)
+	}
+}
+
+func Test_openCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name     string
+		path     string
+		resume   bool
+		wantDone int
+		wantNil  bool
+		wantErr  bool
+	}{
+		{
+			name:    "disabled",
+			path:    "",
+			resume:  false,
+			wantNil: true,
+		},
+		{
+			name:     "resume without file",
+			path:     filepath.Join(dir, "missing.jsonl"),
+			resume:   true,
+			wantDone: 0,
+		},
+		{
+			name:     "start over",
+			path:     write("fresh.jsonl", `{"Addr":"a.example:443","Info":{"DomainName":"a.example"}}`+"\n"),
+			resume:   false,
+			wantDone: 0,
+		},
+		{
+			name:     "resume",
+			path:     write("resume.jsonl", `{"Addr":"a.example:443","Info":{"DomainName":"a.example"}}`+"\n"),
+			resume:   true,
+			wantDone: 1,
+		},
+		{
+			name:    "broken line in the middle",
+			path:    write("broken.jsonl", "{\n"+`{"Addr":"a.example:443","Info":{"DomainName":"a.example"}}`+"\n"),
+			resume:  true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp, err := openCheckpoint(tt.path, tt.resume)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("openCheckpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer cp.close()
+			if (cp == nil) != tt.wantNil {
+				t.Fatalf("openCheckpoint() = %v, wantNil %v", cp, tt.wantNil)
+			}
+			if cp == nil {
+				return
+			}
+			if len(cp.done) != tt.wantDone {
+				t.Errorf("openCheckpoint() done = %d, want %d", len(cp.done), tt.wantDone)
+			}
+		})
+	}
+}
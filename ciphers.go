@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// supportedCurves lists the curves the client can offer.
+var supportedCurves = []tls.CurveID{
+	tls.X25519,
+	tls.CurveP256,
+	tls.CurveP384,
+	tls.CurveP521,
+}
+
+// clientHello is the cipher suites and curves offered in place of the
+// defaults, to emulate the handshake of a specific client such as an old
+// Android or Java 8.
+type clientHello struct {
+	cipherSuites []uint16
+	curves       []tls.CurveID
+	maxVersion   uint16
+}
+
+// newClientHello looks up the cipher suites and curves by name. The TLS 1.3
+// suites cannot be chosen in Go, so they are only used to tell whether the
+// client speaks TLS 1.3: when none of them is listed, at most TLS 1.2 is
+// offered so that the listed suites are what the server has to pick from.
+func newClientHello(suites, curves []string) (*clientHello, error) {
+	if len(suites) == 0 && len(curves) == 0 {
+		return nil, nil
+	}
+	hello := &clientHello{}
+	if len(suites) > 0 {
+		hello.maxVersion = tls.VersionTLS12
+	}
+	for _, name := range suites {
+		s := lookupCipherSuite(name)
+		if s == nil {
+			return nil, fmt.Errorf("unsupported cipher suite: %q", name)
+		}
+		if s.SupportedVersions[0] == tls.VersionTLS13 {
+			hello.maxVersion = 0
+			continue
+		}
+		hello.cipherSuites = append(hello.cipherSuites, s.ID)
+	}
+	for _, name := range curves {
+		id, ok := lookupCurve(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve: %q", name)
+		}
+		hello.curves = append(hello.curves, id)
+	}
+	return hello, nil
+}
+
+// apply sets the offer of h to config. A nil h keeps the defaults.
+func (h *clientHello) apply(config *tls.Config) {
+	if h == nil {
+		return
+	}
+	if len(h.cipherSuites) > 0 {
+		config.CipherSuites = h.cipherSuites
+	}
+	if len(h.curves) > 0 {
+		config.CurvePreferences = h.curves
+	}
+	if h.maxVersion != 0 {
+		config.MaxVersion = h.maxVersion
+	}
+}
+
+func lookupCipherSuite(name string) *tls.CipherSuite {
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if strings.EqualFold(s.Name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+func lookupCurve(name string) (tls.CurveID, bool) {
+	for _, id := range supportedCurves {
+		if strings.EqualFold(curveName(id), name) || strings.EqualFold(id.String(), name) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// curveName returns the short name of id, e.g. P256 for CurveP256.
+func curveName(id tls.CurveID) string {
+	return strings.TrimPrefix(id.String(), "Curve")
+}
+
+func cipherSuiteNames() []string {
+	var names []string
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func curveNames() []string {
+	names := make([]string, 0, len(supportedCurves))
+	for _, id := range supportedCurves {
+		names = append(names, curveName(id))
+	}
+	return names
+}
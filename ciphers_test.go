@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_newClientHello(t *testing.T) {
+	tests := []struct {
+		name    string
+		suites  []string
+		curves  []string
+		want    *clientHello
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: nil,
+		},
+		{
+			name:   "tls 1.2 suites",
+			suites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "tls_rsa_with_aes_128_cbc_sha"},
+			want: &clientHello{
+				cipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+				maxVersion:   tls.VersionTLS12,
+			},
+		},
+		{
+			name:   "tls 1.3 suite listed",
+			suites: []string{"TLS_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			want: &clientHello{
+				cipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			},
+		},
+		{
+			name:   "curves",
+			curves: []string{"P256", "x25519", "CurveP384"},
+			want: &clientHello{
+				curves: []tls.CurveID{tls.CurveP256, tls.X25519, tls.CurveP384},
+			},
+		},
+		{
+			name:    "unknown suite",
+			suites:  []string{"TLS_NULL_WITH_NULL_NULL"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown curve",
+			curves:  []string{"P192"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newClientHello(tt.suites, tt.curves)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newClientHello() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newClientHello() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getCertList_clientHello(t *testing.T) {
+	tests := []struct {
+		name    string
+		suites  []string
+		curves  []string
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			wantErr: false,
+		},
+		{
+			name:    "shared suite",
+			suites:  []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			wantErr: false,
+		},
+		{
+			name:    "no shared suite",
+			suites:  []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+			wantErr: true,
+		},
+		{
+			name:    "no shared curve",
+			curves:  []string{"P521"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			config := newTestTLSConfig(t)
+			config.MaxVersion = tls.VersionTLS12
+			config.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+			config.CurvePreferences = []tls.CurveID{tls.CurveP256}
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+			hello, err := newClientHello(tt.suites, tt.curves)
+			if err != nil {
+				t.Fatal(err)
+			}
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				insecure: true,
+				location: time.UTC,
+				hello:    hello,
+			}
+			if _, err := getCertList(context.Background(), []string{ln.Addr().String()}, opts); (err != nil) != tt.wantErr {
+				t.Errorf("getCertList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultCMDBMapping names the columns after the fields of the certificate CI
+// class of ServiceNow, so that the CSV can be imported with a transform map
+// that matches fields by name.
+const defaultCMDBMapping = `name = {{ .DomainName }}:{{ .AccessPort }}
+fqdn = {{ .DomainName }}
+port = {{ .AccessPort }}
+subject_common_name = {{ .CommonName }}
+subject_alternative_name = {{ join .SANs "," }}
+issuer = {{ .Issuer }}
+issuer_common_name = {{ cn .Issuer }}
+serial_number = {{ .SerialNumber }}
+valid_from = {{ date .NotBefore }}
+valid_to = {{ date .NotAfter }}
+fingerprint = {{ .FingerprintSHA256 }}
+fingerprint_algorithm = SHA-256
+signature_algorithm = {{ .SignatureAlgorithm }}
+key_size = {{ .KeySize }}
+`
+
+var cmdbFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"date": func(t time.Time) string {
+		return t.UTC().Format(time.DateTime)
+	},
+	// cn returns the common name of a distinguished name such as Issuer
+	"cn": func(dn string) string {
+		for _, attr := range strings.Split(dn, ",") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(attr), "CN="); ok {
+				return v
+			}
+		}
+		return ""
+	},
+}
+
+type cmdbColumn struct {
+	name string
+	tmpl *template.Template
+}
+
+// cmdbMapping is the columns of the CMDB export, each rendered from a cert by
+// a Go template.
+type cmdbMapping []cmdbColumn
+
+// loadCMDBMapping reads a mapping with a "column = template" line per column,
+// in order. Blank lines and lines starting with # are ignored. An empty path
+// gives the default mapping.
+func loadCMDBMapping(path string) (cmdbMapping, error) {
+	if path == "" {
+		return parseCMDBMapping(defaultCMDBMapping)
+	}
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseCMDBMapping(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CMDB mapping %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func parseCMDBMapping(src string) (cmdbMapping, error) {
+	var m cmdbMapping
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, text, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: want column = template", n)
+		}
+		tmpl, err := template.New(name).Funcs(cmdbFuncs).Option("missingkey=error").Parse(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		m = append(m, cmdbColumn{name: name, tmpl: tmpl})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, errors.New("no column")
+	}
+	return m, nil
+}
+
+// toCMDB writes a CSV with a row per cert for bulk import as CI records.
+func toCMDB(infos []*certInfo, w io.Writer, m cmdbMapping) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(m))
+	for i, col := range m {
+		header[i] = col.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, info := range infos {
+		row := make([]string, len(m))
+		for i, col := range m {
+			buf.Reset()
+			if err := col.tmpl.Execute(&buf, info); err != nil {
+				return fmt.Errorf("invalid CMDB mapping: %w", err)
+			}
+			row[i] = buf.String()
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
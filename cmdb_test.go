@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_toCMDB(t *testing.T) {
+	infos := []*certInfo{
+		{
+			DomainName:         "example.com",
+			AccessPort:         "443",
+			Issuer:             "CN=R3,O=Let's Encrypt,C=US",
+			CommonName:         "example.com",
+			SANs:               []string{"example.com", "www.example.com"},
+			NotBefore:          time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:           time.Date(2025, 4, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60)),
+			SerialNumber:       "01",
+			SignatureAlgorithm: "SHA256-RSA",
+			KeySize:            2048,
+			FingerprintSHA256:  "AB:CD",
+		},
+	}
+	tests := []struct {
+		name    string
+		mapping string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "default",
+			mapping: defaultCMDBMapping,
+			want: "name,fqdn,port,subject_common_name,subject_alternative_name,issuer,issuer_common_name,serial_number,valid_from,valid_to,fingerprint,fingerprint_algorithm,signature_algorithm,key_size\n" +
+				"example.com:443,example.com,443,example.com,\"example.com,www.example.com\",\"CN=R3,O=Let's Encrypt,C=US\",R3,01,2025-01-01 00:00:00,2025-04-01 00:00:00,AB:CD,SHA-256,SHA256-RSA,2048\n",
+		},
+		{
+			name:    "custom",
+			mapping: "# comment\n\nu_host = {{ upper .DomainName }}\nu_class = {{ lower \"TLS\" }}\n",
+			want:    "u_host,u_class\nEXAMPLE.COM,tls\n",
+		},
+		{
+			name:    "unknown field",
+			mapping: "u_host = {{ .Unknown }}\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseCMDBMapping(tt.mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &bytes.Buffer{}
+			if err := toCMDB(infos, w, m); (err != nil) != tt.wantErr {
+				t.Fatalf("toCMDB() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("toCMDB() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseCMDBMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "columns in order", src: "b = {{ .DomainName }}\na = x = y\n", want: []string{"b", "a"}},
+		{name: "no separator", src: "u_host {{ .DomainName }}\n", wantErr: true},
+		{name: "no column name", src: " = {{ .DomainName }}\n", wantErr: true},
+		{name: "invalid template", src: "u_host = {{ .DomainName\n", wantErr: true},
+		{name: "unknown function", src: "u_host = {{ nope .DomainName }}\n", wantErr: true},
+		{name: "empty", src: "# nothing\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseCMDBMapping(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCMDBMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(m) != len(tt.want) {
+				t.Fatalf("parseCMDBMapping() = %d columns, want %d", len(m), len(tt.want))
+			}
+			for i, col := range m {
+				if col.name != tt.want[i] {
+					t.Errorf("column %d = %s, want %s", i, col.name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_cmdbFuncs_cn(t *testing.T) {
+	cn := cmdbFuncs["cn"].(func(string) string)
+	tests := []struct {
+		dn   string
+		want string
+	}{
+		{dn: "CN=R3,O=Let's Encrypt,C=US", want: "R3"},
+		{dn: "C=US, O=DigiCert Inc, CN=DigiCert Global G2 TLS RSA SHA256 2020 CA1", want: "DigiCert Global G2 TLS RSA SHA256 2020 CA1"},
+		{dn: "O=Example", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dn, func(t *testing.T) {
+			if got := cn(tt.dn); got != tt.want {
+				t.Errorf("cn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
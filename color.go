@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+var colorModes = []string{colorAuto, colorAlways, colorNever}
+
+// ANSI escape sequences of the row colors
+const (
+	ansiReset   = "\x1b[0m"
+	ansiRed     = "\x1b[31m"
+	ansiYellow  = "\x1b[33m"
+	ansiGreen   = "\x1b[32m"
+	ansiFailure = "\x1b[1;97;41m"
+)
+
+// colorizer colors the rows of table output by the days left, red below the
+// critical and yellow below the warning days, and marks the certs that fail
+// verification, such as revoked or distrusted ones, in white on red. A nil
+// colorizer leaves the table as is.
+type colorizer struct {
+	warn int
+	crit int
+}
+
+// newColorizer returns a colorizer unless colors are disabled by mode. In auto
+// mode, colors are used only when w is a terminal and NO_COLOR is not set, so
+// that piped output stays clean.
+func newColorizer(mode string, w io.Writer, warn, crit int) (*colorizer, error) {
+	if !slices.Contains(colorModes, mode) {
+		return nil, fmt.Errorf("invalid color mode: allowed values: %s", pipeJoin(colorModes))
+	}
+	if mode == colorNever || mode == colorAuto && !isTerminal(w) {
+		return nil, nil
+	}
+	if warn < 0 {
+		warn = highlightWarnDays
+	}
+	if crit < 0 {
+		crit = highlightCritDays
+	}
+	return &colorizer{warn: warn, crit: crit}, nil
+}
+
+func isTerminal(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (c *colorizer) color(info *certInfo) string {
+	switch {
+	case info.OCSPStatus == ocspRevoked || info.Distrust != "":
+		return ansiFailure
+	case info.DaysLeft < c.crit:
+		return ansiRed
+	case info.DaysLeft < c.warn:
+		return ansiYellow
+	default:
+		return ansiGreen
+	}
+}
+
+// table colors the lines of each row of a text table rendered from infos.
+// Escape sequences are added after rendering, as they would be counted in the
+// widths of the columns otherwise. The rows are told apart by the borders
+// that the text table draws between them.
+func (c *colorizer) table(s string, infos []*certInfo) string {
+	if c == nil {
+		return s
+	}
+	var sb strings.Builder
+	borders := 0
+	for _, line := range strings.SplitAfter(s, "\n") {
+		// the header is between the first and the second border
+		if strings.HasPrefix(line, "+") {
+			borders++
+		}
+		i := borders - 2
+		if !strings.HasPrefix(line, "|") || i < 0 || i >= len(infos) {
+			sb.WriteString(line)
+			continue
+		}
+		body, nl := strings.CutSuffix(line, "\n")
+		sb.WriteString(c.color(infos[i]) + body + ansiReset)
+		if nl {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newColorizer(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tests := []struct {
+		name    string
+		mode    string
+		w       io.Writer
+		warn    int
+		crit    int
+		want    *colorizer
+		wantErr bool
+	}{
+		{name: "always", mode: colorAlways, w: &bytes.Buffer{}, warn: -1, crit: -1, want: &colorizer{warn: 30, crit: 7}},
+		{name: "always with thresholds", mode: colorAlways, w: &bytes.Buffer{}, warn: 60, crit: 14, want: &colorizer{warn: 60, crit: 14}},
+		{name: "auto to buffer", mode: colorAuto, w: &bytes.Buffer{}, warn: -1, crit: -1, want: nil},
+		{name: "auto to file", mode: colorAuto, w: f, warn: -1, crit: -1, want: nil},
+		{name: "never", mode: colorNever, w: &bytes.Buffer{}, warn: -1, crit: -1, want: nil},
+		{name: "invalid", mode: "rainbow", w: &bytes.Buffer{}, warn: -1, crit: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newColorizer(tt.mode, tt.w, tt.warn, tt.crit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newColorizer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("newColorizer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_colorizer_color(t *testing.T) {
+	c := &colorizer{warn: 30, crit: 7}
+	tests := []struct {
+		name string
+		info *certInfo
+		want string
+	}{
+		{name: "ok", info: &certInfo{DaysLeft: 30}, want: ansiGreen},
+		{name: "warning", info: &certInfo{DaysLeft: 29}, want: ansiYellow},
+		{name: "critical", info: &certInfo{DaysLeft: 6}, want: ansiRed},
+		{name: "expired", info: &certInfo{DaysLeft: -1}, want: ansiRed},
+		{name: "revoked", info: &certInfo{DaysLeft: 90, OCSPStatus: ocspRevoked}, want: ansiFailure},
+		{name: "distrusted", info: &certInfo{DaysLeft: 90, Distrust: "Chrome"}, want: ansiFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.color(tt.info); got != tt.want {
+				t.Errorf("color() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_colorizer_table(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	infos := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "443", SANs: []string{"a.example.com", "www.a.example.com"}, NotAfter: now, CurrentTime: now, DaysLeft: 3},
+		{DomainName: "b.example.com", AccessPort: "443", SANs: []string{"b.example.com"}, NotAfter: now, CurrentTime: now, DaysLeft: 90},
+	}
+	var sb strings.Builder
+	if err := toTable(infos, &sb, formatTextTable.String(), false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	plain := sb.String()
+	if got := (*colorizer)(nil).table(plain, infos); got != plain {
+		t.Errorf("nil colorizer changed the table:\n%s", got)
+	}
+	got := (&colorizer{warn: 30, crit: 7}).table(plain, infos)
+	lines := strings.Split(got, "\n")
+	// border, header, border, two lines of a.example.com, border,
+	// b.example.com, border
+	want := []string{"", "", "", ansiRed, ansiRed, "", ansiGreen, ""}
+	if len(lines) != len(want)+1 {
+		t.Fatalf("table() has %d lines, want %d:\n%s", len(lines), len(want)+1, got)
+	}
+	for i, prefix := range want {
+		line := lines[i]
+		if prefix == "" {
+			if strings.Contains(line, "\x1b") {
+				t.Errorf("line %d is colored: %q", i, line)
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, ansiReset) {
+			t.Errorf("line %d = %q, want colored with %q", i, line, prefix)
+		}
+	}
+	if stripped := strings.NewReplacer(ansiRed, "", ansiGreen, "", ansiReset, "").Replace(got); stripped != plain {
+		t.Errorf("table() changed the content:\n%s", stripped)
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nekrassov01/mintab"
+)
+
+// column is a field of certInfo as a column of the output and as a value of
+// computed fields.
+type column struct {
+	name string
+	// get returns the field of a cert
+	get func(info *certInfo) any
+	// has reports whether a cert has a value for the field, the column being
+	// left out of the table formats when none has. A nil has keeps it.
+	has func(info *certInfo) bool
+	// timeInfo columns are left out with --no-timeinfo
+	timeInfo bool
+	// computed returns the value of the field in computed fields when it is
+	// not the field as such, nil for the field
+	computed func(info *certInfo) any
+}
+
+var (
+	hasVerification = func(info *certInfo) bool { return info.VerificationStatus != "" }
+	hasCAA          = func(info *certInfo) bool { return info.CAAStatus != "" }
+	hasChain        = func(info *certInfo) bool { return info.ChainComplete != nil }
+)
+
+// columns are the fields of certInfo in the order of the table formats, from
+// which the columns that can be chosen, the cells of the table formats and
+// the values of computed fields are all taken.
+var columns = []column{
+	{name: "DomainName", get: func(info *certInfo) any { return info.DomainName }},
+	{name: "AccessPort", get: func(info *certInfo) any { return info.AccessPort }},
+	{name: "IPAddresses", get: func(info *certInfo) any { return info.IPAddresses }},
+	{name: "Issuer", get: func(info *certInfo) any { return info.Issuer }},
+	{name: "CommonName", get: func(info *certInfo) any { return info.CommonName }},
+	{name: "SANs", get: func(info *certInfo) any { return info.SANs }},
+	{name: "NotBefore", get: func(info *certInfo) any { return info.NotBefore }},
+	{name: "NotAfter", get: func(info *certInfo) any { return info.NotAfter }},
+	{name: "CurrentTime", get: func(info *certInfo) any { return info.CurrentTime }, timeInfo: true},
+	{name: "DaysLeft", get: func(info *certInfo) any { return info.DaysLeft }, timeInfo: true},
+	{name: "SerialNumber", get: func(info *certInfo) any { return info.SerialNumber }},
+	{name: "SignatureAlgorithm", get: func(info *certInfo) any { return info.SignatureAlgorithm }},
+	{name: "PublicKeyAlgorithm", get: func(info *certInfo) any { return info.PublicKeyAlgorithm }},
+	{name: "KeySize", get: func(info *certInfo) any { return info.KeySize }},
+	{name: "FingerprintSHA256", get: func(info *certInfo) any { return info.FingerprintSHA256 }},
+	{name: "FingerprintSHA1", get: func(info *certInfo) any { return info.FingerprintSHA1 }},
+	{name: "StapledOCSP", get: func(info *certInfo) any { return info.StapledOCSP }},
+	{name: "OCSPStatus", get: func(info *certInfo) any { return info.OCSPStatus }},
+	{name: "OCSPNextUpdate", get: func(info *certInfo) any { return info.OCSPNextUpdate }},
+	{name: "VerificationStatus", get: func(info *certInfo) any { return info.VerificationStatus }, has: hasVerification},
+	{name: "VerifyError", get: func(info *certInfo) any { return info.VerifyError }, has: hasVerification},
+	{name: "HostnameMatch", get: func(info *certInfo) any { return info.HostnameMatch }, has: hasVerification},
+	{name: "TrustStatus", get: func(info *certInfo) any { return info.TrustStatus }, has: hasVerification},
+	{
+		name: "IsSelfSigned",
+		get:  func(info *certInfo) any { return info.IsSelfSigned },
+		has:  func(info *certInfo) bool { return info.IsSelfSigned },
+	},
+	{
+		name: "TrustStores",
+		get:  func(info *certInfo) any { return info.TrustStores },
+		has:  func(info *certInfo) bool { return len(info.TrustStores) > 0 },
+	},
+	{
+		name: "Weaknesses",
+		get:  func(info *certInfo) any { return info.Weaknesses },
+		has:  func(info *certInfo) bool { return len(info.Weaknesses) > 0 },
+	},
+	{name: "CAAStatus", get: func(info *certInfo) any { return info.CAAStatus }, has: hasCAA},
+	{name: "CAAIssuers", get: func(info *certInfo) any { return info.CAAIssuers }, has: hasCAA},
+	{name: "CAANames", get: func(info *certInfo) any { return info.CAANames }, has: hasCAA},
+	{name: "ChainComplete", get: func(info *certInfo) any { return info.ChainComplete }, has: hasChain},
+	{name: "FetchedIntermediates", get: func(info *certInfo) any { return info.FetchedIntermediates }, has: hasChain},
+	{
+		name: "ChainDaysLeft",
+		get:  func(info *certInfo) any { return info.ChainDaysLeft },
+		// the days left of a chain are only worth a column when an
+		// intermediate expires before the leaf
+		has: func(info *certInfo) bool {
+			return info.ChainDaysLeft != nil && *info.ChainDaysLeft != info.DaysLeft
+		},
+		timeInfo: true,
+		computed: func(info *certInfo) any {
+			if info.ChainDaysLeft == nil {
+				return float64(info.DaysLeft)
+			}
+			return float64(*info.ChainDaysLeft)
+		},
+	},
+	{
+		name: "ExpiredInChain",
+		get:  func(info *certInfo) any { return info.ExpiredInChain },
+		has:  func(info *certInfo) bool { return len(info.ExpiredInChain) > 0 },
+	},
+	{
+		name: "TLSVersions",
+		get:  func(info *certInfo) any { return info.TLSVersions },
+		has:  func(info *certInfo) bool { return len(info.TLSVersions) > 0 },
+	},
+	{
+		name: "SMIME",
+		get:  func(info *certInfo) any { return info.SMIME },
+		has:  func(info *certInfo) bool { return info.SMIME != "" },
+	},
+	{
+		name: "StaleSANs",
+		get:  func(info *certInfo) any { return info.StaleSANs },
+		has:  func(info *certInfo) bool { return len(info.StaleSANs) > 0 },
+	},
+	{
+		name: "ALPN",
+		get:  func(info *certInfo) any { return info.ALPN },
+		has:  func(info *certInfo) bool { return info.ALPN != "" },
+	},
+	{
+		name: "GRPCHealth",
+		get:  func(info *certInfo) any { return info.GRPCHealth },
+		has:  func(info *certInfo) bool { return info.GRPCHealth != "" },
+	},
+	{
+		name: "Distrust",
+		get:  func(info *certInfo) any { return info.Distrust },
+		has:  func(info *certInfo) bool { return info.Distrust != "" },
+	},
+	{
+		name: "Throttled",
+		get:  func(info *certInfo) any { return info.Throttled },
+		has:  func(info *certInfo) bool { return info.Throttled },
+	},
+	{
+		name: "Match",
+		get:  func(info *certInfo) any { return info.Match },
+		has:  func(info *certInfo) bool { return info.Match != "" },
+	},
+	{
+		name: "Violations",
+		get:  func(info *certInfo) any { return info.Violations },
+		has:  func(info *certInfo) bool { return len(info.Violations) > 0 },
+	},
+	{
+		name: "Changes",
+		get:  func(info *certInfo) any { return info.Changes },
+		has:  func(info *certInfo) bool { return len(info.Changes) > 0 },
+	},
+	{
+		name: "Error",
+		get:  func(info *certInfo) any { return info.Error },
+		has:  func(info *certInfo) bool { return info.Error != nil },
+	},
+	{
+		name: "RequestedPort",
+		get:  func(info *certInfo) any { return info.RequestedPort },
+		has:  func(info *certInfo) bool { return info.RequestedPort != "" },
+	},
+	{
+		name: "Proxy",
+		get:  func(info *certInfo) any { return info.Proxy },
+		has:  func(info *certInfo) bool { return info.Proxy != "" },
+	},
+	{
+		name: "ServerName",
+		get:  func(info *certInfo) any { return info.ServerName },
+		has:  func(info *certInfo) bool { return info.ServerName != "" },
+	},
+	{
+		name: "Tags",
+		get:  func(info *certInfo) any { return info.Tags },
+		has:  func(info *certInfo) bool { return len(info.Tags) > 0 },
+	},
+	{
+		name: "Source",
+		get:  func(info *certInfo) any { return info.Source },
+		has:  func(info *certInfo) bool { return info.Source != "" },
+	},
+	{
+		name: "Secret",
+		get:  func(info *certInfo) any { return info.Secret },
+		has:  func(info *certInfo) bool { return info.Secret != "" },
+	},
+	{
+		name: "Resource",
+		get:  func(info *certInfo) any { return info.Resource },
+		has:  func(info *certInfo) bool { return info.Resource != "" },
+	},
+	{
+		name: "Renewal",
+		get:  func(info *certInfo) any { return info.Renewal },
+		has:  func(info *certInfo) bool { return info.Renewal != "" },
+	},
+	{
+		name: "Alias",
+		get:  func(info *certInfo) any { return info.Alias },
+		has:  func(info *certInfo) bool { return info.Alias != "" },
+	},
+	{
+		name: "Hosts",
+		get:  func(info *certInfo) any { return info.Hosts },
+		has:  func(info *certInfo) bool { return len(info.Hosts) > 0 },
+	},
+}
+
+// columnNames are the columns that can be chosen for the output, in the order
+// of the table formats. They are also the keys of the JSON formats.
+var columnNames = func() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}()
+
+// cell returns the field of a cert in the table formats, with the times and
+// numbers written for loc.
+func (c column) cell(info *certInfo, loc *locale) any {
+	switch v := c.get(info).(type) {
+	case time.Time:
+		return loc.time(v)
+	case *time.Time:
+		if v != nil {
+			return loc.time(*v)
+		}
+		return v
+	case int:
+		return loc.number(v)
+	case *int:
+		if v != nil {
+			return loc.number(*v)
+		}
+		return v
+	case *bool:
+		if v != nil {
+			return *v
+		}
+		return v
+	case *checkError:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// parseColumns checks the names of the chosen columns, which are matched
+// regardless of case and returned as spelled in columnNames or computed, the
+// names of the computed fields.
+func parseColumns(names []string, computed []string) ([]string, error) {
+	allowed := slices.Concat(columnNames, computed)
+	cols := make([]string, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(allowed, func(s string) bool {
+			return strings.EqualFold(s, strings.TrimSpace(name))
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("invalid column %q: allowed values: %s", name, pipeJoin(allowed))
+		}
+		if slices.Contains(cols, allowed[i]) {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		cols = append(cols, allowed[i])
+	}
+	return cols, nil
+}
+
+// selectColumns reorders input to the chosen columns. An optional column that
+// no cert has a value for is left blank. No columns leave input as is.
+func selectColumns(input mintab.Input, cols []string) mintab.Input {
+	if len(cols) == 0 {
+		return input
+	}
+	data := make([][]any, len(input.Data))
+	for i, row := range input.Data {
+		data[i] = make([]any, len(cols))
+		for j, col := range cols {
+			data[i][j] = ""
+			if k := slices.Index(input.Header, col); k >= 0 {
+				data[i][j] = row[k]
+			}
+		}
+	}
+	return mintab.Input{
+		Header: cols,
+		Data:   data,
+	}
+}
+
+// fieldObject is a cert in the JSON formats with only the chosen fields, in
+// the order they were chosen.
+type fieldObject struct {
+	keys   []string
+	fields map[string]json.RawMessage
+}
+
+func (o fieldObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, key := range o.keys {
+		v, ok := o.fields[key]
+		if !ok {
+			// an empty field omitted from the cert
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		b, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// selectField returns the cert with only the chosen fields for the JSON
+// formats. No columns leave the cert as it is.
+func selectField(info *certInfo, cols []string) (any, error) {
+	if len(cols) == 0 {
+		return info, nil
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	obj := fieldObject{keys: cols}
+	if err := json.Unmarshal(b, &obj.fields); err != nil {
+		return nil, err
+	}
+	// computed fields are chosen as if they were fields of the cert
+	if raw, ok := obj.fields["Fields"]; ok {
+		var computed map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &computed); err != nil {
+			return nil, err
+		}
+		for k, v := range computed {
+			obj.fields[k] = v
+		}
+	}
+	return obj, nil
+}
+
+func selectFields(infos []*certInfo, cols []string) (any, error) {
+	if len(cols) == 0 {
+		return infos, nil
+	}
+	objs := make([]any, len(infos))
+	for i, info := range infos {
+		obj, err := selectField(info, cols)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+	return objs, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nekrassov01/mintab"
+)
+
+func Test_parseColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "in order", names: []string{"NotAfter", "DomainName"}, want: []string{"NotAfter", "DomainName"}},
+		{name: "case insensitive", names: []string{"domainname", " DAYSLEFT "}, want: []string{"DomainName", "DaysLeft"}},
+		{name: "fallback and proxy", names: []string{"DomainName", "Proxy", "RequestedPort"}, want: []string{"DomainName", "Proxy", "RequestedPort"}},
+		{name: "none", names: nil, want: []string{}},
+		{name: "unknown", names: []string{"DomainName", "Expiry"}, wantErr: true},
+		{name: "duplicate", names: []string{"DomainName", "domainname"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseColumns(tt.names, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_columns(t *testing.T) {
+	// every field of the cert is a column, in the order of the struct
+	var want []string
+	typ := reflect.TypeOf(certInfo{})
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.IsExported() && f.Name != "Fields" {
+			want = append(want, f.Name)
+		}
+	}
+	if !reflect.DeepEqual(columnNames, want) {
+		t.Errorf("columnNames = %v, want %v", columnNames, want)
+	}
+	info := &certInfo{DomainName: "example.com", RequestedPort: "8443", Proxy: "http://proxy.example.com:3128"}
+	input := toInput([]*certInfo{info}, false, nil)
+	scope := computedScope(info)
+	for _, name := range []string{"RequestedPort", "Proxy"} {
+		if !slices.Contains(input.Header, name) {
+			t.Errorf("toInput() header has no %s", name)
+		}
+		if _, ok := scope[name]; !ok {
+			t.Errorf("computedScope() has no %s", name)
+		}
+	}
+}
+
+func Test_selectColumns(t *testing.T) {
+	input := mintab.Input{
+		Header: []string{"DomainName", "AccessPort", "DaysLeft"},
+		Data: [][]any{
+			{"a.example.com", "443", 10},
+			{"b.example.com", "8443", 20},
+		},
+	}
+	tests := []struct {
+		name string
+		cols []string
+		want mintab.Input
+	}{
+		{
+			name: "all",
+			cols: nil,
+			want: input,
+		},
+		{
+			name: "reordered",
+			cols: []string{"DaysLeft", "DomainName"},
+			want: mintab.Input{
+				Header: []string{"DaysLeft", "DomainName"},
+				Data:   [][]any{{10, "a.example.com"}, {20, "b.example.com"}},
+			},
+		},
+		{
+			name: "optional column without values",
+			cols: []string{"DomainName", "ALPN"},
+			want: mintab.Input{
+				Header: []string{"DomainName", "ALPN"},
+				Data:   [][]any{{"a.example.com", ""}, {"b.example.com", ""}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectColumns(input, tt.cols); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_selectFields(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "example.com", AccessPort: "443", NotAfter: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), DaysLeft: 10},
+	}
+	tests := []struct {
+		name string
+		cols []string
+		want string
+	}{
+		{
+			name: "in order",
+			cols: []string{"DaysLeft", "DomainName", "NotAfter"},
+			want: `[{"DaysLeft":10,"DomainName":"example.com","NotAfter":"2025-03-01T00:00:00Z"}]`,
+		},
+		{
+			name: "empty field omitted",
+			cols: []string{"DomainName", "ALPN"},
+			want: `[{"DomainName":"example.com"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := selectFields(infos, tt.cols)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &bytes.Buffer{}
+			if err := toJSON(v, w); err != nil {
+				t.Fatal(err)
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, w.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if got := compact.String(); got != tt.want {
+				t.Errorf("selectFields() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	v, err := selectFields(infos, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, infos) {
+		t.Errorf("selectFields() = %v, want the certs as they are", v)
+	}
+}
+
+func Test_toNDJSON_columns(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "443", DaysLeft: 10},
+		{DomainName: "b.example.com", AccessPort: "8443", DaysLeft: 20},
+	}
+	w := &bytes.Buffer{}
+	if err := toNDJSON(infos, w, []string{"AccessPort", "DomainName"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"AccessPort":"443","DomainName":"a.example.com"}
+{"AccessPort":"8443","DomainName":"b.example.com"}
+`
+	if got := w.String(); got != want {
+		t.Errorf("toNDJSON() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -4,6 +4,11 @@ import (
 	_ "embed"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 )
 
 //go:embed completions/tlc3.bash
@@ -49,3 +54,70 @@ func comp(w io.Writer, s string) error {
 	}
 	return nil
 }
+
+// completeFlagValues prints the candidates for the value of the flag that
+// args end with, if completers has any for it, and reports whether it did.
+// args are the words before the one being completed.
+func completeFlagValues(w io.Writer, args []string, completers map[string]func() []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+	last := args[len(args)-1]
+	if !strings.HasPrefix(last, "-") {
+		return false
+	}
+	complete, ok := completers[strings.TrimLeft(last, "-")]
+	if !ok {
+		return false
+	}
+	for _, v := range complete() {
+		fmt.Fprintln(w, v)
+	}
+	return true
+}
+
+// zoneinfoDirs are where the tz database is usually installed.
+var zoneinfoDirs = []string{
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+}
+
+// timeZoneNames lists the zones of the first tz database found, which is what
+// time.LoadLocation reads as well. Only Local and UTC are known without one.
+func timeZoneNames() []string {
+	names := []string{"Local", "UTC"}
+	dirs := zoneinfoDirs
+	if dir := os.Getenv("ZONEINFO"); dir != "" {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// posix and right duplicate the zones for other clock conventions
+				if name == "posix" || name == "right" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// zones start with an uppercase letter, unlike tables such as zone.tab
+			if name[0] < 'A' || name[0] > 'Z' || strings.Contains(name, ".") {
+				return nil
+			}
+			names = append(names, filepath.ToSlash(name))
+			return nil
+		})
+		if err == nil {
+			break
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
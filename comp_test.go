@@ -3,7 +3,13 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func Test_comp(t *testing.T) {
@@ -52,3 +58,93 @@ func Test_comp(t *testing.T) {
 		})
 	}
 }
+
+func Test_completeFlagValues(t *testing.T) {
+	completers := map[string]func() []string{
+		"output": func() []string { return []string{"json", "table"} },
+		"o":      func() []string { return []string{"json", "table"} },
+	}
+	tests := []struct {
+		name  string
+		args  []string
+		want  string
+		found bool
+	}{
+		{
+			name:  "long flag",
+			args:  []string{appName, "--output"},
+			want:  "json\ntable\n",
+			found: true,
+		},
+		{
+			name:  "short flag",
+			args:  []string{appName, "-d", "example.com", "-o"},
+			want:  "json\ntable\n",
+			found: true,
+		},
+		{
+			name:  "flag without values",
+			args:  []string{appName, "--domain"},
+			want:  "",
+			found: false,
+		},
+		{
+			name:  "not after a flag",
+			args:  []string{appName, "-o", "json"},
+			want:  "",
+			found: false,
+		},
+		{
+			name:  "no args",
+			args:  []string{appName},
+			want:  "",
+			found: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if found := completeFlagValues(w, tt.args, completers); found != tt.found {
+				t.Errorf("completeFlagValues() = %v, want %v", found, tt.found)
+			}
+			if diff := cmp.Diff(tt.want, w.String()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_timeZoneNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Asia/Tokyo", "Europe/London", "UTC", "zone.tab", "posixrules", "posix/Asia/Tokyo", "right/UTC", "Etc/GMT+9"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("ZONEINFO", dir)
+	want := []string{"Asia/Tokyo", "Etc/GMT+9", "Europe/London", "Local", "UTC"}
+	if diff := cmp.Diff(want, timeZoneNames()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_app_valueCompleters(t *testing.T) {
+	completers := newApp(io.Discard).valueCompleters()
+	for _, name := range []string{"output", "o", "timezone", "z", "log-level", "l", "preset", "notify-format"} {
+		complete, ok := completers[name]
+		if !ok {
+			t.Errorf("no completer for %s", name)
+			continue
+		}
+		if len(complete()) == 0 {
+			t.Errorf("no values for %s", name)
+		}
+	}
+	if got := completers["output"](); !slices.Equal(got, formats) {
+		t.Errorf("output values = %v, want %v", got, formats)
+	}
+}
@@ -10,17 +10,19 @@ _cli_init_completion() {
 
 _tlc3() {
   [[ "${COMP_WORDS[0]}" == "source" ]] && return 0
-  local cur words opts comp
+  local cur prev words cword opts comp
   if declare -F _init_completion >/dev/null 2>&1; then
     _init_completion -n "=:" || return
   else
     _cli_init_completion -n "=:" || return
   fi
   cur="${COMP_WORDS[COMP_CWORD]}"
+  # pass the words before the cursor so that the value of a flag is completed
+  # from the flag itself rather than from what has been typed so far
   if [[ "$cur" == "-"* ]]; then
-    comp="${words[*]} ${cur} --generate-bash-completion"
+    comp="${words[*]:0:cword} ${cur} --generate-bash-completion"
   else
-    comp="${words[*]} --generate-bash-completion"
+    comp="${words[*]:0:cword} --generate-bash-completion"
   fi
   opts=$(eval "${comp}" 2>/dev/null)
   # shellcheck disable=SC2207
@@ -2,8 +2,14 @@
 # NOTE: Complex completions such as flag combination checks are not supported
 
 Register-ArgumentCompleter -Native -CommandName "tlc3" -ScriptBlock {
-  param($commandName, $wordToComplete, $cursorPosition)
-  (Invoke-Expression "$wordToComplete --generate-bash-completion").ForEach{
+  param($wordToComplete, $commandAst, $cursorPosition)
+  # pass the words before the cursor so that the value of a flag is completed
+  # from the flag itself rather than from what has been typed so far
+  $line = $commandAst.ToString()
+  if ($wordToComplete -ne "" -and -not $wordToComplete.StartsWith("-")) {
+    $line = $line.Substring(0, $line.Length - $wordToComplete.Length)
+  }
+  (Invoke-Expression "$line --generate-bash-completion").Where{ $_ -like "$wordToComplete*" }.ForEach{
     [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
   }
 }
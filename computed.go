@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var computedNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type computedField struct {
+	name string
+	x    pacExpr
+}
+
+// computedFields adds fields to each cert that are evaluated from the others,
+// such as "Urgent = DaysLeft < 14". The expressions are written in the
+// JavaScript subset of PAC scripts, with the PAC helpers that need no network
+// such as dnsDomainIs and shExpMatch at hand, and label(name), which returns
+// the value of a "name=value" tag of the cert. They see the fields of the cert
+// by name along with the computed fields defined above them. A nil
+// computedFields adds nothing.
+type computedFields []computedField
+
+// loadComputedFields reads a "Name = expression" line per field, in order.
+// Blank lines and lines starting with # are ignored.
+func loadComputedFields(path string) (computedFields, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseComputedFields(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid computed fields %s: %w", path, err)
+	}
+	return f, nil
+}
+
+func parseComputedFields(src string) (computedFields, error) {
+	var f computedFields
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, expr, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !computedNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("line %d: want Name = expression", n)
+		}
+		if slices.Contains(columnNames, name) || slices.ContainsFunc(f, func(c computedField) bool { return c.name == name }) {
+			return nil, fmt.Errorf("line %d: field %s is already defined", n, name)
+		}
+		// preceding newlines make the errors report the line in the file
+		x, err := parsePACExpr(strings.Repeat("\n", n-1) + expr)
+		if err != nil {
+			return nil, err
+		}
+		f = append(f, computedField{name: name, x: x})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(f) == 0 {
+		return nil, errors.New("no field")
+	}
+	return f, nil
+}
+
+func (f computedFields) names() []string {
+	names := make([]string, len(f))
+	for i, c := range f {
+		names[i] = c.name
+	}
+	return names
+}
+
+// apply returns copies of infos with the computed fields set, or the error of
+// ctx once it is done.
+func (f computedFields) apply(ctx context.Context, infos []*certInfo) ([]*certInfo, error) {
+	if f == nil {
+		return infos, nil
+	}
+	res := make([]*certInfo, len(infos))
+	for i, info := range infos {
+		v := *info
+		scope := computedScope(info)
+		in := &pacInterp{pac: &pac{}, ctx: ctx, builtins: computedBuiltins(info)}
+		v.Fields = make(fieldValues, 0, len(f))
+		for _, c := range f {
+			value, err := in.eval(c.x, scope)
+			if err != nil {
+				return nil, fmt.Errorf("cannot compute %s for %s: %w", c.name, net.JoinHostPort(info.DomainName, info.AccessPort), err)
+			}
+			scope[c.name] = value
+			v.Fields = append(v.Fields, fieldValue{Name: c.name, Value: value})
+		}
+		res[i] = &v
+	}
+	return res, nil
+}
+
+// computedBuiltins returns the functions computed fields can call for info:
+// the PAC helpers without DNS or the network, which would be called once per
+// cert, and label.
+func computedBuiltins(info *certInfo) map[string]pacBuiltin {
+	return withBuiltins(pacPureBuiltins, map[string]pacBuiltin{
+		"label": func(_ *pacInterp, args []any) (any, error) {
+			name := pacArg(args, 0)
+			for _, tag := range info.Tags {
+				if k, v, ok := strings.Cut(tag, "="); ok && k == name {
+					return v, nil
+				}
+			}
+			return "", nil
+		},
+	})
+}
+
+// computedScope exposes the fields of a cert as the values of PAC scripts:
+// numbers as float64, lists joined with commas and times in RFC 3339.
+func computedScope(info *certInfo) pacScope {
+	scope := make(pacScope, len(columns))
+	for _, c := range columns {
+		if c.computed != nil {
+			scope[c.name] = c.computed(info)
+			continue
+		}
+		scope[c.name] = computedValue(c.get(info))
+	}
+	return scope
+}
+
+func computedValue(v any) any {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.Format(time.RFC3339)
+	case *bool:
+		return v != nil && *v
+	case []string:
+		return strings.Join(v, ",")
+	case []net.IP:
+		ips := make([]string, len(v))
+		for i, ip := range v {
+			ips[i] = ip.String()
+		}
+		return strings.Join(ips, ",")
+	case *checkError:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+type fieldValue struct {
+	Name  string
+	Value any
+}
+
+// fieldValues are the computed fields of a cert, written as a JSON object
+// with the keys in the order of definition.
+type fieldValues []fieldValue
+
+func (f fieldValues) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range f {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads the fields of a previous output back, such as a
+// baseline, in the order of their keys since JSON objects have none.
+func (f *fieldValues) UnmarshalJSON(b []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	*f = make(fieldValues, len(keys))
+	for i, k := range keys {
+		(*f)[i] = fieldValue{Name: k, Value: m[k]}
+	}
+	return nil
+}
+
+// Field returns the value of a computed field, for templates such as the
+// mapping of cmdb output.
+func (info *certInfo) Field(name string) any {
+	for _, v := range info.Fields {
+		if v.Name == name {
+			return v.Value
+		}
+	}
+	return nil
+}
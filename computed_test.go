@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_parseComputedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "in order", src: "# comment\n\nUrgent = DaysLeft < 14\nOwner = \"web\"\n", want: []string{"Urgent", "Owner"}},
+		{name: "equality", src: "Short = KeySize == 256\n", want: []string{"Short"}},
+		{name: "no separator", src: "Urgent DaysLeft < 14\n", wantErr: true},
+		{name: "invalid name", src: "Is Urgent = DaysLeft < 14\n", wantErr: true},
+		{name: "name of a column", src: "DaysLeft = 1\n", wantErr: true},
+		{name: "duplicate", src: "Urgent = true\nUrgent = false\n", wantErr: true},
+		{name: "invalid expression", src: "Urgent = DaysLeft <\n", wantErr: true},
+		{name: "trailing tokens", src: "Urgent = DaysLeft 14\n", wantErr: true},
+		{name: "empty", src: "# nothing\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseComputedFields(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseComputedFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got.names(), tt.want) {
+				t.Errorf("parseComputedFields() = %v, want %v", got.names(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_computedFields_apply(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "api.pay.example.com", AccessPort: "443", IPAddresses: []net.IP{net.ParseIP("192.0.2.1")}, SANs: []string{"a", "b"}, DaysLeft: 10, Tags: []string{"prod", "team=payments"}},
+		{DomainName: "www.example.com", AccessPort: "443", DaysLeft: 90},
+	}
+	tests := []struct {
+		name    string
+		src     string
+		want    []fieldValues
+		wantErr bool
+	}{
+		{
+			name: "fields",
+			src:  "Urgent = DaysLeft < 14\nOwner = dnsDomainIs(DomainName, \".pay.example.com\") ? \"payments\" : \"web\"\n",
+			want: []fieldValues{
+				{{Name: "Urgent", Value: true}, {Name: "Owner", Value: "payments"}},
+				{{Name: "Urgent", Value: false}, {Name: "Owner", Value: "web"}},
+			},
+		},
+		{
+			name: "fields defined above",
+			src:  "Margin = DaysLeft - 14\nLabel = DomainName + \" (\" + Margin + \")\"\n",
+			want: []fieldValues{
+				{{Name: "Margin", Value: float64(-4)}, {Name: "Label", Value: "api.pay.example.com (-4)"}},
+				{{Name: "Margin", Value: float64(76)}, {Name: "Label", Value: "www.example.com (76)"}},
+			},
+		},
+		{
+			name: "lists",
+			src:  "Lists = IPAddresses + \"|\" + SANs\n",
+			want: []fieldValues{
+				{{Name: "Lists", Value: "192.0.2.1|a,b"}},
+				{{Name: "Lists", Value: "|"}},
+			},
+		},
+		{
+			name: "label",
+			src:  "Owner = label(\"team\") || \"unknown\"\n",
+			want: []fieldValues{
+				{{Name: "Owner", Value: "payments"}},
+				{{Name: "Owner", Value: "unknown"}},
+			},
+		},
+		{
+			name:    "undefined name",
+			src:     "Owner = Team\n",
+			wantErr: true,
+		},
+		{
+			name:    "DNS",
+			src:     "Resolved = dnsResolve(DomainName)\n",
+			wantErr: true,
+		},
+		{
+			name:    "local address",
+			src:     "Local = myIpAddress()\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseComputedFields(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.apply(context.Background(), infos)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for i, info := range got {
+				if !reflect.DeepEqual(info.Fields, tt.want[i]) {
+					t.Errorf("apply()[%d].Fields = %v, want %v", i, info.Fields, tt.want[i])
+				}
+				if infos[i].Fields != nil {
+					t.Errorf("apply() changed the input")
+				}
+			}
+		})
+	}
+	f, err := parseComputedFields("Urgent = dnsDomainIs(DomainName, \".example.com\")\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.apply(ctx, infos); !errors.Is(err, context.Canceled) {
+		t.Errorf("apply() error = %v, want %v", err, context.Canceled)
+	}
+	f = nil
+	got, err := f.apply(context.Background(), infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, infos) {
+		t.Errorf("nil computedFields changed the certs")
+	}
+}
+
+func Test_fieldValues_json(t *testing.T) {
+	f := fieldValues{{Name: "Urgent", Value: true}, {Name: "Owner", Value: "payments"}, {Name: "Weeks", Value: float64(2)}}
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Urgent":true,"Owner":"payments","Weeks":2}`; string(b) != want {
+		t.Errorf("MarshalJSON() = %s, want %s", b, want)
+	}
+	var got fieldValues
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := fieldValues{{Name: "Owner", Value: "payments"}, {Name: "Urgent", Value: true}, {Name: "Weeks", Value: float64(2)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalJSON() = %v, want %v", got, want)
+	}
+}
+
+func Test_computedFields_output(t *testing.T) {
+	f, err := loadComputedFields(filepath.Join("testdata", "computed.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := f.apply(context.Background(), []*certInfo{
+		{DomainName: "api.pay.example.com", AccessPort: "443", DaysLeft: 10},
+		{DomainName: "www.example.com", AccessPort: "443", DaysLeft: 90},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := toInput(infos, false, nil)
+	if got := input.Header[len(input.Header)-2:]; !reflect.DeepEqual(got, []string{"Urgent", "Owner"}) {
+		t.Errorf("header ends with %v, want the computed fields", got)
+	}
+	if got := input.Data[0][len(input.Data[0])-2:]; !reflect.DeepEqual(got, []any{true, "payments"}) {
+		t.Errorf("row ends with %v, want the computed values", got)
+	}
+	v, err := selectField(infos[1], []string{"Owner", "DomainName"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Owner":"web","DomainName":"www.example.com"}`; string(b) != want {
+		t.Errorf("selectField() = %s, want %s", b, want)
+	}
+}
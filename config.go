@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// key of the config file listing the hosts checked when neither a domain nor
+// a file is given
+const configTargets = "targets"
+
+// defaultConfigPath returns the path of the config file read when --config is
+// not given, which is tlc3/config.yaml under $XDG_CONFIG_HOME or ~/.config.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, appName, "config.yaml")
+}
+
+// config holds the values of a config file, keyed by the long name of the
+// flag they set, in the order they appear.
+type config struct {
+	keys   []string
+	values map[string][]string
+}
+
+// loadConfig reads the config file at path. A missing file is an empty
+// config unless required is set, i.e. when the path is given explicitly.
+func loadConfig(path string, required bool) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			return &config{}, nil
+		}
+		return nil, fmt.Errorf("cannot read config: %w", err)
+	}
+	defer f.Close()
+	cfg, err := parseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parseConfig reads the subset of YAML a flat config needs: a mapping of
+// scalars, quoted or not, and of lists given either as "- item" lines under
+// the key or inline as [a, b].
+func parseConfig(r io.Reader) (*config, error) {
+	cfg := &config{values: make(map[string][]string)}
+	sc := bufio.NewScanner(r)
+	key := ""
+	for n := 1; sc.Scan(); n++ {
+		line := stripComment(sc.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			item, ok := strings.CutPrefix(trimmed, "-")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("line %d: unexpected indentation", n)
+			}
+			v, err := unquote(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			cfg.values[key] = append(cfg.values[key], v)
+			continue
+		}
+		k, v, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value", n)
+		}
+		k = strings.TrimSpace(k)
+		if _, ok := cfg.values[k]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, k)
+		}
+		cfg.keys = append(cfg.keys, k)
+		cfg.values[k] = nil
+		key = k
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		// a key with an inline value takes no items
+		key = ""
+		if list, ok := strings.CutPrefix(v, "["); ok {
+			list, ok = strings.CutSuffix(list, "]")
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated list", n)
+			}
+			for _, item := range strings.Split(list, ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				item, err := unquote(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				cfg.values[k] = append(cfg.values[k], item)
+			}
+			continue
+		}
+		s, err := unquote(v)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		cfg.values[k] = []string{s}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// stripComment removes a comment, which starts with # at the beginning of the
+// line or after a space, outside quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || (s[0] != '"' && s[0] != '\'') {
+		return s, nil
+	}
+	if s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// apply sets the flags of c from cfg, except those given on the command line
+// or through the environment, so that both take precedence over the file.
+// The hosts of targets, or of file, are checked only when neither domain nor
+// file is set.
+func (cfg *config) apply(c *cli.Context, flags []cli.Flag, skip []string) error {
+	if _, ok := cfg.values[configTargets]; ok {
+		if _, ok := cfg.values["file"]; ok {
+			return fmt.Errorf("cannot be used together %s and file in config", configTargets)
+		}
+	}
+	names := make(map[string]bool)
+	for _, flag := range flags {
+		if name := flag.Names()[0]; !slices.Contains(skip, name) {
+			names[name] = true
+		}
+	}
+	// a selftest checks hosts of its own
+	given := c.IsSet("domain") || c.IsSet("file") || c.IsSet("cidr") || c.IsSet("from-kubernetes") || c.IsSet("from-aws") || c.IsSet("cert-file") || c.IsSet("selftest")
+	for _, key := range cfg.keys {
+		name := key
+		switch {
+		case key == configTargets:
+			name = "domain"
+			fallthrough
+		case key == "file":
+			if given {
+				continue
+			}
+		case !names[key]:
+			return fmt.Errorf("invalid key %q in config", key)
+		case c.IsSet(key):
+			continue
+		}
+		for _, v := range cfg.values[key] {
+			if err := c.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value %q for %s in config: %w", v, key, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/urfave/cli/v2"
+)
+
+func Test_parseConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantKeys []string
+		want     map[string][]string
+		wantErr  bool
+	}{
+		{
+			name: "scalars",
+			input: `---
+# defaults
+output: table
+timeout: 10s # per host
+timezone: "Asia/Tokyo"
+notify-webhook: 'https://hooks.example.com/#ops'
+`,
+			wantKeys: []string{"output", "timeout", "timezone", "notify-webhook"},
+			want: map[string][]string{
+				"output":         {"table"},
+				"timeout":        {"10s"},
+				"timezone":       {"Asia/Tokyo"},
+				"notify-webhook": {"https://hooks.example.com/#ops"},
+			},
+		},
+		{
+			name: "lists",
+			input: `targets:
+  - example.com
+  - "example.org:8443"
+redact: [ips, sans]
+columns: []
+`,
+			wantKeys: []string{"targets", "redact", "columns"},
+			want: map[string][]string{
+				"targets": {"example.com", "example.org:8443"},
+				"redact":  {"ips", "sans"},
+				"columns": nil,
+			},
+		},
+		{
+			name:    "item without key",
+			input:   "output: json\n  - table\n",
+			wantErr: true,
+		},
+		{
+			name:    "nested mapping",
+			input:   "notify:\n  webhook: https://hooks.example.com\n",
+			wantErr: true,
+		},
+		{
+			name:    "no colon",
+			input:   "output table\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate key",
+			input:   "output: json\noutput: table\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated list",
+			input:   "redact: [ips, sans\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated string",
+			input:   "timezone: \"Asia/Tokyo\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantKeys, got.keys); diff != "" {
+				t.Errorf("keys (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.want, got.values); diff != "" {
+				t.Errorf("values (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_loadConfig(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "config.yaml")
+	cfg, err := loadConfig(missing, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.keys) != 0 {
+		t.Errorf("loadConfig() keys = %v, want none", cfg.keys)
+	}
+	if _, err := loadConfig(missing, true); err == nil {
+		t.Error("loadConfig() error = nil, want an error for a missing file given explicitly")
+	}
+}
+
+func Test_config_apply(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		args        []string
+		env         map[string]string
+		wantOutput  string
+		wantTimeout time.Duration
+		wantDomains []string
+		wantFile    string
+		wantErr     bool
+	}{
+		{
+			name:        "config",
+			config:      "output: table\ntimeout: 10s\ntargets: [example.com, example.org]\n",
+			wantOutput:  "table",
+			wantTimeout: 10 * time.Second,
+			wantDomains: []string{"example.com", "example.org"},
+		},
+		{
+			name:        "env over config",
+			config:      "output: table\ntimeout: 10s\n",
+			env:         map[string]string{"TEST_OUTPUT": "markdown"},
+			wantOutput:  "markdown",
+			wantTimeout: 10 * time.Second,
+		},
+		{
+			name:        "flag over env and config",
+			config:      "output: table\ntimeout: 10s\n",
+			args:        []string{"-o", "json", "-t", "1s"},
+			env:         map[string]string{"TEST_OUTPUT": "markdown"},
+			wantOutput:  "json",
+			wantTimeout: time.Second,
+		},
+		{
+			name:        "domain over targets",
+			config:      "targets: [example.com]\n",
+			args:        []string{"-d", "example.net"},
+			wantOutput:  "json",
+			wantTimeout: 5 * time.Second,
+			wantDomains: []string{"example.net"},
+		},
+		{
+			name:        "file over targets",
+			config:      "targets: [example.com]\n",
+			args:        []string{"-f", "list.txt"},
+			wantOutput:  "json",
+			wantTimeout: 5 * time.Second,
+			wantFile:    "list.txt",
+		},
+		{
+			name:        "domain over file",
+			config:      "file: list.txt\n",
+			args:        []string{"-d", "example.net"},
+			wantOutput:  "json",
+			wantTimeout: 5 * time.Second,
+			wantDomains: []string{"example.net"},
+		},
+		{
+			name:    "targets and file",
+			config:  "targets: [example.com]\nfile: list.txt\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			config:  "outputs: table\n",
+			wantErr: true,
+		},
+		{
+			name:    "skipped key",
+			config:  "completion: bash\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			config:  "timeout: soon\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := parseConfig(strings.NewReader(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var (
+				output  string
+				timeout time.Duration
+				domains []string
+				file    string
+			)
+			app := &cli.App{
+				Writer: io.Discard,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "completion"},
+					&cli.StringSliceFlag{Name: "domain", Aliases: []string{"d"}},
+					&cli.PathFlag{Name: "file", Aliases: []string{"f"}},
+					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "json", EnvVars: []string{"TEST_OUTPUT"}},
+					&cli.DurationFlag{Name: "timeout", Aliases: []string{"t"}, Value: 5 * time.Second},
+				},
+				ExitErrHandler: func(*cli.Context, error) {},
+			}
+			app.Before = func(c *cli.Context) error {
+				return cfg.apply(c, app.Flags, []string{"completion", "domain"})
+			}
+			app.Action = func(c *cli.Context) error {
+				output = c.String("output")
+				timeout = c.Duration("timeout")
+				domains = c.StringSlice("domain")
+				file = c.Path("file")
+				return nil
+			}
+			err = app.RunContext(context.Background(), append([]string{appName}, tt.args...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if output != tt.wantOutput {
+				t.Errorf("output = %q, want %q", output, tt.wantOutput)
+			}
+			if timeout != tt.wantTimeout {
+				t.Errorf("timeout = %v, want %v", timeout, tt.wantTimeout)
+			}
+			if diff := cmp.Diff(tt.wantDomains, domains); diff != "" {
+				t.Errorf("domains (-want +got):\n%s", diff)
+			}
+			if file != tt.wantFile {
+				t.Errorf("file = %q, want %q", file, tt.wantFile)
+			}
+		})
+	}
+}
+
+func Test_cli_config(t *testing.T) {
+	t.Setenv(canonicalName+"_NON_INTERACTIVE", "true")
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if err := os.Mkdir(filepath.Join(dir, appName), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, appName, "config.yaml")
+	if err := os.WriteFile(path, []byte("insecure: true\ntargets:\n  - "+addr+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the targets of the default config are checked without any flag
+	if err := newApp(io.Discard).RunContext(context.Background(), []string{appName}); err != nil {
+		t.Errorf("error = %v", err)
+	}
+	if err := newApp(io.Discard).RunContext(context.Background(), []string{appName, "--config", filepath.Join(dir, "missing.yaml")}); err == nil {
+		t.Error("error = nil, want an error for a missing config")
+	}
+}
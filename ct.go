@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ctAPI     = "https://crt.sh/"
+	ctMaxSize = 64 << 20
+	ctTimeout = 2 * time.Minute
+	// ctTimeLayout is the layout of the times in crt.sh, which are in UTC
+	ctTimeLayout = "2006-01-02T15:04:05"
+)
+
+// ctEntry is a cert logged in Certificate Transparency as listed by crt.sh.
+// A precertificate and its cert are listed once.
+type ctEntry struct {
+	ID           int64  `json:"id"`
+	IssuerName   string `json:"issuer_name"`
+	CommonName   string `json:"common_name"`
+	NameValue    string `json:"name_value"`
+	SerialNumber string `json:"serial_number"`
+	NotBefore    string `json:"not_before"`
+	NotAfter     string `json:"not_after"`
+}
+
+// ctLog searches the certs issued for domains in the Certificate Transparency
+// logs through crt.sh, to find issuances nobody knows of.
+type ctLog struct {
+	client *http.Client
+	api    string
+}
+
+func newCTLog(timeout time.Duration) *ctLog {
+	return &ctLog{client: &http.Client{Timeout: timeout}, api: ctAPI}
+}
+
+// search returns the certs logged for domain, and for its subdomains with
+// subdomains, leaving out the expired ones with excludeExpired.
+func (l *ctLog) search(ctx context.Context, domain string, subdomains, excludeExpired bool) ([]ctEntry, error) {
+	q := url.Values{}
+	if subdomains {
+		q.Set("q", "%."+domain)
+	} else {
+		q.Set("q", domain)
+	}
+	q.Set("output", "json")
+	q.Set("deduplicate", "Y")
+	if excludeExpired {
+		q.Set("exclude", "expired")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.api+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", appName+"/"+Version)
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot search CT logs for %s: unexpected response: %s", domain, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, ctMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > ctMaxSize {
+		return nil, fmt.Errorf("cannot search CT logs for %s: response exceeds %d bytes", domain, ctMaxSize)
+	}
+	var entries []ctEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("cannot parse CT log entries for %s: %w", domain, err)
+	}
+	return entries, nil
+}
+
+// ctInfos returns the entries found for domain as infos, latest issuance
+// first, with the crt.sh page of the cert as Resource. An entry is listed once
+// even when it is logged under several of the names searched.
+func ctInfos(domain string, entries []ctEntry, now time.Time, loc *time.Location) ([]*certInfo, error) {
+	infos := make([]*certInfo, 0, len(entries))
+	seen := make(map[int64]bool, len(entries))
+	for _, e := range entries {
+		if seen[e.ID] {
+			continue
+		}
+		seen[e.ID] = true
+		notBefore, err := time.Parse(ctTimeLayout, e.NotBefore)
+		if err != nil {
+			return nil, fmt.Errorf("invalid not_before of crt.sh entry %d: %w", e.ID, err)
+		}
+		notAfter, err := time.Parse(ctTimeLayout, e.NotAfter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid not_after of crt.sh entry %d: %w", e.ID, err)
+		}
+		var sans []string
+		for _, name := range strings.Split(e.NameValue, "\n") {
+			if name = strings.TrimSpace(name); name != "" && !slices.Contains(sans, name) {
+				sans = append(sans, name)
+			}
+		}
+		infos = append(infos, &certInfo{
+			DomainName:   domain,
+			Issuer:       e.IssuerName,
+			CommonName:   e.CommonName,
+			SANs:         sans,
+			NotBefore:    notBefore.In(loc),
+			NotAfter:     notAfter.In(loc),
+			CurrentTime:  now.In(loc).Truncate(time.Second),
+			DaysLeft:     daysLeft(notAfter, now),
+			SerialNumber: strings.ToUpper(e.SerialNumber),
+			Resource:     ctAPI + "?id=" + strconv.FormatInt(e.ID, 10),
+		})
+	}
+	slices.SortStableFunc(infos, func(a, b *certInfo) int {
+		return b.NotBefore.Compare(a.NotBefore)
+	})
+	return infos, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testCTEntries = `[
+  {"id": 2, "issuer_name": "C=US, O=Let's Encrypt, CN=R3", "common_name": "www.example.com",
+   "name_value": "example.com\nwww.example.com", "serial_number": "04abcd",
+   "not_before": "2024-03-01T00:00:00", "not_after": "2024-05-30T00:00:00", "entry_timestamp": "2024-03-01T01:00:00.123"},
+  {"id": 1, "issuer_name": "C=US, O=Let's Encrypt, CN=R3", "common_name": "example.com",
+   "name_value": "example.com", "serial_number": "03ef",
+   "not_before": "2024-01-01T00:00:00", "not_after": "2024-03-31T00:00:00", "entry_timestamp": "2024-01-01T01:00:00.456"},
+  {"id": 2, "issuer_name": "C=US, O=Let's Encrypt, CN=R3", "common_name": "www.example.com",
+   "name_value": "www.example.com", "serial_number": "04abcd",
+   "not_before": "2024-03-01T00:00:00", "not_after": "2024-05-30T00:00:00", "entry_timestamp": "2024-03-01T01:00:00.123"}
+]`
+
+func Test_ctLog_search(t *testing.T) {
+	tests := []struct {
+		name           string
+		subdomains     bool
+		excludeExpired bool
+		status         int
+		body           string
+		wantQuery      string
+		want           int
+		wantErr        bool
+	}{
+		{
+			name:      "domain",
+			status:    http.StatusOK,
+			body:      testCTEntries,
+			wantQuery: "deduplicate=Y&output=json&q=example.com",
+			want:      3,
+		},
+		{
+			name:           "subdomains without expired",
+			subdomains:     true,
+			excludeExpired: true,
+			status:         http.StatusOK,
+			body:           "[]",
+			wantQuery:      "deduplicate=Y&exclude=expired&output=json&q=%25.example.com",
+			want:           0,
+		},
+		{
+			name:    "unavailable",
+			status:  http.StatusBadGateway,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			status:  http.StatusOK,
+			body:    "<html>",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.RawQuery
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+			l := newCTLog(time.Second)
+			l.api = ts.URL + "/"
+			got, err := l.search(context.Background(), "example.com", tt.subdomains, tt.excludeExpired)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("search() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("search() = %d entries, want %d", len(got), tt.want)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func Test_ctInfos(t *testing.T) {
+	now := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	l := newCTLog(time.Second)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testCTEntries))
+	}))
+	defer ts.Close()
+	l.api = ts.URL + "/"
+	entries, err := l.search(context.Background(), "example.com", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ctInfos("example.com", entries, now, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*certInfo{
+		{
+			DomainName:   "example.com",
+			Issuer:       "C=US, O=Let's Encrypt, CN=R3",
+			CommonName:   "www.example.com",
+			SANs:         []string{"example.com", "www.example.com"},
+			NotBefore:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:     time.Date(2024, 5, 30, 0, 0, 0, 0, time.UTC),
+			CurrentTime:  now,
+			DaysLeft:     59,
+			SerialNumber: "04ABCD",
+			Resource:     "https://crt.sh/?id=2",
+		},
+		{
+			DomainName:   "example.com",
+			Issuer:       "C=US, O=Let's Encrypt, CN=R3",
+			CommonName:   "example.com",
+			SANs:         []string{"example.com"},
+			NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:     time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+			CurrentTime:  now,
+			DaysLeft:     -1,
+			SerialNumber: "03EF",
+			Resource:     "https://crt.sh/?id=1",
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(certInfo{})); diff != "" {
+		t.Error(diff)
+	}
+	if _, err := ctInfos("example.com", []ctEntry{{ID: 1, NotBefore: "2024-01-01"}}, now, time.UTC); err == nil {
+		t.Error("ctInfos() with an invalid time: want error")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type certDiff struct {
+	Field  string
+	Before string
+	After  string
+	Detail string
+}
+
+func (d certDiff) changed() bool {
+	return d.Before != d.After
+}
+
+// diffTarget returns the leaf cert served at target, checked on its own so
+// that the certs compared are not taken from the results of the other side.
+// A target that gives no cert or several, as with --all-ips, is an error.
+func diffTarget(ctx context.Context, target string, opts *options) (*x509.Certificate, error) {
+	infos, err := getCertList(ctx, []string{target}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) != 1 {
+		return nil, fmt.Errorf("cannot compare %s: want one cert, got %d", target, len(infos))
+	}
+	if len(infos[0].chain) == 0 {
+		return nil, fmt.Errorf("cannot compare %s: no cert", target)
+	}
+	return infos[0].chain[0], nil
+}
+
+// diffCerts compares two certs field by field in a fixed order.
+func diffCerts(a, b *x509.Certificate, loc *time.Location) []certDiff {
+	sansA, sansB := getSANs(a), getSANs(b)
+	diffs := []certDiff{
+		{Field: "Subject", Before: a.Subject.String(), After: b.Subject.String()},
+		{Field: "Issuer", Before: a.Issuer.String(), After: b.Issuer.String()},
+		{Field: "SerialNumber", Before: fmt.Sprintf("%x", a.SerialNumber), After: fmt.Sprintf("%x", b.SerialNumber)},
+		{Field: "SANs", Before: strings.Join(sansA, ","), After: strings.Join(sansB, ","), Detail: diffSANs(sansA, sansB)},
+		{Field: "PublicKey", Before: describeKeyID(a), After: describeKeyID(b)},
+		{Field: "SignatureAlgorithm", Before: a.SignatureAlgorithm.String(), After: b.SignatureAlgorithm.String()},
+		{Field: "NotBefore", Before: a.NotBefore.In(loc).String(), After: b.NotBefore.In(loc).String(), Detail: shift(a.NotBefore, b.NotBefore)},
+		{Field: "NotAfter", Before: a.NotAfter.In(loc).String(), After: b.NotAfter.In(loc).String(), Detail: shift(a.NotAfter, b.NotAfter)},
+	}
+	return diffs
+}
+
+func diffSANs(a, b []string) string {
+	var added, removed []string
+	for _, san := range b {
+		if !slices.Contains(a, san) {
+			added = append(added, "+"+san)
+		}
+	}
+	for _, san := range a {
+		if !slices.Contains(b, san) {
+			removed = append(removed, "-"+san)
+		}
+	}
+	return strings.Join(append(added, removed...), " ")
+}
+
+func shift(a, b time.Time) string {
+	d := b.Sub(a)
+	switch {
+	case d == 0:
+		return ""
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%+dd", int(d.Hours()/24))
+	case d > 0:
+		return "+" + d.String()
+	default:
+		return d.String()
+	}
+}
+
+// describeKeyID describes the public key with a short hash of the
+// SubjectPublicKeyInfo, so that a replaced key of the same type is visible.
+func describeKeyID(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return fmt.Sprintf("%s (spki:%x)", describeKey(cert.PublicKey), sum[:4])
+}
+
+func describeKey(pub any) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
+
+// toDiff renders diffs in a diff-like layout where changed fields are
+// prefixed with "~" and show both values.
+func toDiff(diffs []certDiff, w io.Writer, a, b string) error {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", a, b)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, d := range diffs {
+		if !d.changed() {
+			fmt.Fprintf(tw, "  %s\t%s\n", d.Field, d.Before)
+			continue
+		}
+		line := fmt.Sprintf("~ %s\t%s -> %s", d.Field, d.Before, d.After)
+		if d.Detail != "" {
+			line += " (" + d.Detail + ")"
+		}
+		fmt.Fprintln(tw, line)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDiffCert(t *testing.T, name string, sans []string, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := newFarmCert(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: name},
+		DNSNames:  sans,
+		NotBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  notAfter,
+	}, nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.cert
+}
+
+func Test_diffCerts(t *testing.T) {
+	a := newDiffCert(t, "a.example.com", []string{"a.example.com", "www.example.com"}, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	b := newDiffCert(t, "a.example.com", []string{"b.example.com", "www.example.com"}, time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC))
+	diffs := diffCerts(a, b, time.UTC)
+	var changed []string
+	for _, d := range diffs {
+		if d.changed() {
+			changed = append(changed, d.Field)
+		}
+	}
+	want := []string{"SerialNumber", "SANs", "PublicKey", "NotAfter"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	for _, d := range diffs {
+		switch d.Field {
+		case "SANs":
+			if d.Detail != "+b.example.com -a.example.com" {
+				t.Errorf("SANs detail = %q", d.Detail)
+			}
+		case "NotAfter":
+			if d.Detail != "+60d" {
+				t.Errorf("NotAfter detail = %q", d.Detail)
+			}
+		}
+	}
+	if diffs := diffCerts(a, a, time.UTC); anyChanged(diffs) {
+		t.Error("same cert reported as changed")
+	}
+}
+
+func anyChanged(diffs []certDiff) bool {
+	for _, d := range diffs {
+		if d.changed() {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_shift(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		b    time.Time
+		want string
+	}{
+		{
+			name: "same",
+			b:    base,
+			want: "",
+		},
+		{
+			name: "days later",
+			b:    base.AddDate(0, 0, 90),
+			want: "+90d",
+		},
+		{
+			name: "days earlier",
+			b:    base.AddDate(0, 0, -3),
+			want: "-3d",
+		},
+		{
+			name: "hours later",
+			b:    base.Add(90 * time.Minute),
+			want: "+1h30m0s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shift(base, tt.b); got != tt.want {
+				t.Errorf("shift() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_toDiff(t *testing.T) {
+	diffs := []certDiff{
+		{Field: "Subject", Before: "CN=a", After: "CN=a"},
+		{Field: "NotAfter", Before: "2024-01-01", After: "2024-03-01", Detail: "+60d"},
+	}
+	w := &bytes.Buffer{}
+	if err := toDiff(diffs, w, "a:443", "b:443"); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"--- a:443",
+		"+++ b:443",
+		"  Subject   CN=a",
+		"~ NotAfter  2024-01-01 -> 2024-03-01 (+60d)",
+		"",
+	}, "\n")
+	if w.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", w.String(), want)
+	}
+}
+
+func Test_diffTarget(t *testing.T) {
+	key := newTestKey(t)
+	tmpl := testLeafTemplate(1)
+	tmpl.BasicConstraintsValid = true
+	tmpl.IsCA = true
+	cert := issueTestCert(t, tmpl, nil, &key.PublicKey, key)
+	addr := serveTestTLS(t, key, cert)
+	closed := "127.0.0.1:1"
+	tests := []struct {
+		name    string
+		target  string
+		want    *x509.Certificate
+		wantErr bool
+	}{
+		{
+			name:   "served",
+			target: addr,
+			want:   cert,
+		},
+		{
+			name:    "no cert",
+			target:  closed,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			// a swept address that does not answer leaves no result
+			opts := &options{
+				timeout:  5 * time.Second,
+				insecure: true,
+				location: time.UTC,
+				sweep:    &sweep{connectTimeout: time.Second, hosts: map[string]bool{closed: true}},
+			}
+			got, err := diffTarget(context.Background(), tt.target, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diffTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !got.Equal(tt.want) {
+				t.Error("diffTarget() returned another cert")
+			}
+		})
+	}
+}
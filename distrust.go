@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// distrustEvent is a decision of a root store to stop trusting certs that
+// chain to some roots and were issued after a date. Certs issued before stay
+// trusted until they expire, so the date matters to renewals rather than to
+// NotAfter.
+type distrustEvent struct {
+	store string
+	roots []string
+	after time.Time
+}
+
+var (
+	entrustRoots = []string{
+		"Entrust Root Certification Authority",
+		"Entrust Root Certification Authority - EC1",
+		"Entrust Root Certification Authority - G2",
+		"Entrust.net Certification Authority (2048)",
+		"AffirmTrust Commercial",
+		"AffirmTrust Networking",
+		"AffirmTrust Premium",
+		"AffirmTrust Premium ECC",
+	}
+	chunghwaRoots = []string{
+		"ePKI Root Certification Authority",
+		"HiPKI Root CA - G1",
+	}
+	microsecRoots = []string{
+		"e-Szigno Root CA 2017",
+	}
+)
+
+// distrustEvents is the built-in table of distrust events, by the roots'
+// common names. It is updated with the releases of tlc3.
+var distrustEvents = []distrustEvent{
+	{store: "Chrome", roots: entrustRoots, after: time.Date(2024, 11, 11, 23, 59, 59, 0, time.UTC)},
+	{store: "Apple", roots: entrustRoots, after: time.Date(2024, 11, 15, 0, 0, 0, 0, time.UTC)},
+	{store: "Mozilla", roots: entrustRoots, after: time.Date(2024, 11, 30, 23, 59, 59, 0, time.UTC)},
+	{store: "Chrome", roots: chunghwaRoots, after: time.Date(2025, 7, 31, 23, 59, 59, 0, time.UTC)},
+	{store: "Chrome", roots: microsecRoots, after: time.Date(2025, 7, 31, 23, 59, 59, 0, time.UTC)},
+}
+
+// distrustStatus describes the distrust events that apply to chain, whose
+// first cert is the leaf, or returns an empty string if none does. A root is
+// found by the issuer of the last cert as well, since servers rarely send it.
+func distrustStatus(chain []*x509.Certificate, events []distrustEvent, now time.Time) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(chain)+1)
+	for _, cert := range chain {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, chain[len(chain)-1].Issuer.CommonName)
+	leaf := chain[0]
+	var res []string
+	for _, e := range events {
+		if !slices.ContainsFunc(e.roots, func(root string) bool { return slices.Contains(names, root) }) {
+			continue
+		}
+		date := e.after.Format(time.DateOnly)
+		switch {
+		case leaf.NotBefore.After(e.after):
+			res = append(res, fmt.Sprintf("distrusted by %s, issued after %s", e.store, date))
+		case now.Before(e.after):
+			res = append(res, fmt.Sprintf("%s distrusts certs issued after %s", e.store, date))
+		default:
+			res = append(res, fmt.Sprintf("renewals distrusted by %s since %s", e.store, date))
+		}
+	}
+	return strings.Join(res, "; ")
+}
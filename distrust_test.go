@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+)
+
+func Test_distrustStatus(t *testing.T) {
+	cutoff := time.Date(2025, 7, 31, 23, 59, 59, 0, time.UTC)
+	events := []distrustEvent{
+		{store: "Chrome", roots: []string{"Old Root CA"}, after: cutoff},
+		{store: "Mozilla", roots: []string{"Old Root CA"}, after: cutoff.AddDate(0, 1, 0)},
+	}
+	chain := func(notBefore time.Time, names ...string) []*x509.Certificate {
+		var certs []*x509.Certificate
+		for i := 0; i < len(names)-1; i++ {
+			certs = append(certs, &x509.Certificate{
+				Subject:   pkix.Name{CommonName: names[i]},
+				Issuer:    pkix.Name{CommonName: names[i+1]},
+				NotBefore: notBefore,
+			})
+		}
+		return certs
+	}
+	tests := []struct {
+		name  string
+		chain []*x509.Certificate
+		now   time.Time
+		want  string
+	}{
+		{
+			name:  "other root",
+			chain: chain(cutoff.AddDate(0, 2, 0), "example.com", "Intermediate CA", "Other Root CA"),
+			now:   cutoff.AddDate(0, 3, 0),
+			want:  "",
+		},
+		{
+			name:  "upcoming",
+			chain: chain(cutoff.AddDate(0, -2, 0), "example.com", "Intermediate CA", "Old Root CA"),
+			now:   cutoff.AddDate(0, -1, 0),
+			want:  "Chrome distrusts certs issued after 2025-07-31; Mozilla distrusts certs issued after 2025-08-31",
+		},
+		{
+			name:  "issued before",
+			chain: chain(cutoff.AddDate(0, -2, 0), "example.com", "Intermediate CA", "Old Root CA"),
+			now:   cutoff.AddDate(0, 0, 15),
+			want:  "renewals distrusted by Chrome since 2025-07-31; Mozilla distrusts certs issued after 2025-08-31",
+		},
+		{
+			name:  "issued after",
+			chain: chain(cutoff.AddDate(0, 2, 0), "example.com", "Intermediate CA", "Old Root CA"),
+			now:   cutoff.AddDate(0, 3, 0),
+			want:  "distrusted by Chrome, issued after 2025-07-31; distrusted by Mozilla, issued after 2025-08-31",
+		},
+		{
+			name:  "root served",
+			chain: chain(cutoff.AddDate(0, 2, 0), "example.com", "Old Root CA", "Old Root CA"),
+			now:   cutoff.AddDate(0, 3, 0),
+			want:  "distrusted by Chrome, issued after 2025-07-31; distrusted by Mozilla, issued after 2025-08-31",
+		},
+		{
+			name:  "no chain",
+			chain: nil,
+			now:   cutoff,
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distrustStatus(tt.chain, events, tt.now); got != tt.want {
+				t.Errorf("distrustStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
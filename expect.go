@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	certMatch    = "MATCH"
+	certMismatch = "MISMATCH"
+)
+
+// loadExpectedCert returns the SHA-256 fingerprint of the leaf in the PEM or
+// DER file at path, the cert a host is expected to serve, e.g. once a renewal
+// is rolled out.
+func loadExpectedCert(path string) (string, error) {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		return "", fmt.Errorf("cannot load expected cert: %w", err)
+	}
+	chain, err := readChain(b)
+	if err != nil {
+		return "", fmt.Errorf("cannot load expected cert %s: %w", path, err)
+	}
+	sum := sha256.Sum256(chain[0].Raw)
+	return fingerprint(sum[:]), nil
+}
+
+// loadExpected loads the certs expected of the targets, at paths relative to
+// dir, the directory of the list, unless absolute. A cert expected of several
+// hosts is loaded once.
+func (ts targets) loadExpected(dir string) error {
+	loaded := make(map[string]string)
+	for key, t := range ts {
+		if t.expectCert == "" {
+			continue
+		}
+		path := t.expectCert
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		fp, ok := loaded[path]
+		if !ok {
+			var err error
+			fp, err = loadExpectedCert(path)
+			if err != nil {
+				return err
+			}
+			loaded[path] = fp
+		}
+		t.expected = fp
+		ts[key] = t
+	}
+	return nil
+}
+
+// matchCert returns whether the fingerprint fp of a served cert is the
+// expected one.
+func matchCert(fp, expected string) string {
+	if fp == expected {
+		return certMatch
+	}
+	return certMismatch
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// failures collects the hosts that cannot be checked so that the others are
+// still reported, as with --keep-going or in a test report, which needs a
+// case for every host, and so that the gate, the change feed and the state
+// tell an outage from a host gone. A nil failures lets the first error abort
+// the check.
+type failures struct {
+	mu    sync.Mutex
+	hosts []hostFailure
+}
+
+type hostFailure struct {
+	addr string
+	// given is addr as given, by which its source is recorded
+	given   string
+	err     error
+	elapsed time.Duration
+}
+
+func (f *failures) add(addr string, err error, elapsed time.Duration) error {
+	if f == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hosts = append(f.hosts, hostFailure{addr: ensureDefaultPort(targetAddr(addr)), given: addr, err: err, elapsed: elapsed})
+	return nil
+}
+
+// list returns the failures ordered by address.
+func (f *failures) list() []hostFailure {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res := slices.Clone(f.hosts)
+	slices.SortFunc(res, func(a, b hostFailure) int {
+		return strings.Compare(a.addr, b.addr)
+	})
+	return res
+}
+
+// infos returns a record of each failed host with its error as Error, so that
+// the hosts that cannot be checked are reported along with the others.
+func (f *failures) infos(now time.Time, loc *time.Location, s *sources) []*certInfo {
+	failed := f.list()
+	infos := make([]*certInfo, len(failed))
+	for i, h := range failed {
+		host, port, _ := net.SplitHostPort(h.addr)
+		infos[i] = &certInfo{
+			DomainName:  host,
+			AccessPort:  port,
+			CurrentTime: now.In(loc).Truncate(time.Second),
+			Error:       newCheckError(h.err, h.elapsed),
+			Source:      s.lookup(h.given),
+		}
+	}
+	return infos
+}
+
+// failedError reports the hosts that cannot be checked when the others were
+// reported anyway. It exits as critical, as for a Nagios plugin.
+type failedError struct {
+	count int
+}
+
+func (e *failedError) Error() string {
+	return fmt.Sprintf("critical: %d host(s) cannot be checked", e.count)
+}
+
+func (e *failedError) ExitCode() int {
+	return exitCritical
+}
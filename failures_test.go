@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_failures_add(t *testing.T) {
+	err := errors.New("cannot connect")
+	tests := []struct {
+		name    string
+		f       *failures
+		err     error
+		wantErr bool
+		want    int
+	}{
+		{
+			name:    "nil",
+			f:       nil,
+			err:     err,
+			wantErr: true,
+		},
+		{
+			name:    "collected",
+			f:       &failures{},
+			err:     err,
+			wantErr: false,
+			want:    1,
+		},
+		{
+			name:    "canceled",
+			f:       &failures{},
+			err:     context.Canceled,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.f.add("example.com", tt.err, time.Second); (err != nil) != tt.wantErr {
+				t.Errorf("add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := tt.f.list()
+			if len(got) != tt.want {
+				t.Fatalf("list() = %v, want %d entries", got, tt.want)
+			}
+			if tt.want > 0 && got[0].addr != "example.com:443" {
+				t.Errorf("addr = %q, want %q", got[0].addr, "example.com:443")
+			}
+		})
+	}
+}
+
+func Test_failures_infos(t *testing.T) {
+	f := &failures{}
+	dnsErr := &net.DNSError{Err: "no such host", Name: "b.example.com", IsNotFound: true}
+	_ = f.add("b.example.com", dnsErr, 20*time.Millisecond)
+	_ = f.add("a.example.com:8443", errors.New("cannot connect"), 1500*time.Millisecond)
+	s := newSources()
+	s.add("hosts.txt", []string{"b.example.com"})
+	now := time.Date(2025, 3, 1, 0, 0, 0, 500, time.UTC)
+	want := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "8443", CurrentTime: now.Truncate(time.Second), Error: &checkError{Category: categoryOther, Message: "cannot connect", Duration: "1.5s"}},
+		{DomainName: "b.example.com", AccessPort: "443", CurrentTime: now.Truncate(time.Second), Error: &checkError{Category: categoryDNS, Message: dnsErr.Error(), Duration: "20ms"}, Source: "hosts.txt"},
+	}
+	if got := f.infos(now, time.UTC, s); !reflect.DeepEqual(got, want) {
+		t.Errorf("infos() = %v, want %v", got, want)
+	}
+	err := error(&failedError{count: 2})
+	var ec interface{ ExitCode() int }
+	if !errors.As(err, &ec) || ec.ExitCode() != exitCritical {
+		t.Errorf("failedError exit code = %v, want %d", err, exitCritical)
+	}
+}
+
+func Test_getCertList_failures(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	f := &failures{}
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+		failures: f,
+	}
+	infos, err := getCertList(context.Background(), []string{closedAddr}, opts)
+	if err != nil {
+		t.Fatalf("getCertList() error = %v, want nil", err)
+	}
+	if len(infos) != 0 {
+		t.Errorf("getCertList() = %d infos, want 0", len(infos))
+	}
+	if got := f.list(); len(got) != 1 || got[0].addr != closedAddr {
+		t.Errorf("failures = %v, want %s", got, closedAddr)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/template"
+	"time"
+)
+
+const (
+	formatterJSON  = "json"
+	formatterSlack = "slack"
+	formatterTeams = "teams"
+)
+
+// formatter renders events into the request body of a webhook.
+type formatter interface {
+	format(events []notification) ([]byte, error)
+}
+
+var formatters = map[string]formatter{
+	formatterJSON:  jsonFormatter{},
+	formatterSlack: slackFormatter{},
+	formatterTeams: teamsFormatter{},
+}
+
+func formatterNames() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+type notifyPayload struct {
+	Source string
+	Events []notification
+}
+
+// jsonFormatter renders the events as they are, for programs on the
+// receiving side.
+type jsonFormatter struct{}
+
+func (jsonFormatter) format(events []notification) ([]byte, error) {
+	return json.Marshal(notifyPayload{Source: appName, Events: events})
+}
+
+// templateFormatter renders the events with a user-supplied Go template,
+// which receives the same data as jsonFormatter and a json function.
+type templateFormatter struct {
+	template *template.Template
+}
+
+func newTemplateFormatter(path string) (*templateFormatter, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(filepath.Base(path)).Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify template: %w", err)
+	}
+	return &templateFormatter{template: t}, nil
+}
+
+func (f *templateFormatter) format(events []notification) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.template.Execute(&buf, notifyPayload{Source: appName, Events: events}); err != nil {
+		return nil, fmt.Errorf("invalid notify template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// slackFormatter renders the events as Block Kit message for a Slack
+// incoming webhook.
+type slackFormatter struct{}
+
+func (slackFormatter) format(events []notification) ([]byte, error) {
+	type text struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	type block struct {
+		Type string `json:"type"`
+		Text *text  `json:"text,omitempty"`
+	}
+	blocks := []block{
+		{Type: "header", Text: &text{Type: "plain_text", Text: notifyTitle(events)}},
+	}
+	for _, e := range events {
+		blocks = append(blocks, block{
+			Type: "section",
+			Text: &text{Type: "mrkdwn", Text: fmt.Sprintf("*%s* `%s`\n%s", e.Event, e.Host, describeEvent(e))},
+		})
+	}
+	return json.Marshal(struct {
+		Text   string  `json:"text"`
+		Blocks []block `json:"blocks"`
+	}{
+		Text:   notifyTitle(events),
+		Blocks: blocks,
+	})
+}
+
+// teamsFormatter renders the events as an Adaptive Card for a Microsoft
+// Teams incoming webhook.
+type teamsFormatter struct{}
+
+func (teamsFormatter) format(events []notification) ([]byte, error) {
+	type fact struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+	}
+	type element struct {
+		Type     string `json:"type"`
+		Text     string `json:"text,omitempty"`
+		Size     string `json:"size,omitempty"`
+		Weight   string `json:"weight,omitempty"`
+		Wrap     bool   `json:"wrap,omitempty"`
+		Facts    []fact `json:"facts,omitempty"`
+		Separate bool   `json:"separator,omitempty"`
+	}
+	body := []element{
+		{Type: "TextBlock", Text: notifyTitle(events), Size: "Medium", Weight: "Bolder", Wrap: true},
+	}
+	for _, e := range events {
+		body = append(body,
+			element{Type: "TextBlock", Text: describeEvent(e), Wrap: true, Separate: true},
+			element{Type: "FactSet", Facts: []fact{
+				{Title: "Event", Value: e.Event},
+				{Title: "Host", Value: e.Host},
+				{Title: "Issuer", Value: e.Issuer},
+				{Title: "NotAfter", Value: e.NotAfter.Format(time.RFC3339)},
+			}},
+		)
+	}
+	type card struct {
+		Schema  string    `json:"$schema"`
+		Type    string    `json:"type"`
+		Version string    `json:"version"`
+		Body    []element `json:"body"`
+	}
+	type attachment struct {
+		ContentType string `json:"contentType"`
+		Content     card   `json:"content"`
+	}
+	return json.Marshal(struct {
+		Type        string       `json:"type"`
+		Attachments []attachment `json:"attachments"`
+	}{
+		Type: "message",
+		Attachments: []attachment{
+			{
+				ContentType: "application/vnd.microsoft.card.adaptive",
+				Content: card{
+					Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+					Type:    "AdaptiveCard",
+					Version: "1.4",
+					Body:    body,
+				},
+			},
+		},
+	})
+}
+
+func notifyTitle(events []notification) string {
+	return fmt.Sprintf("%s: %d TLS cert event(s)", appName, len(events))
+}
+
+func describeEvent(e notification) string {
+	switch e.Event {
+	case eventChanged:
+		return fmt.Sprintf("%s now serves serial %s from %s, previously serial %s from %s",
+			e.Host, e.SerialNumber, e.Issuer, e.PreviousSerialNumber, e.PreviousIssuer)
+	default:
+		return fmt.Sprintf("%s expires in %d days on %s (%s)",
+			e.Host, e.DaysLeft, e.NotAfter.Format(time.DateOnly), e.CommonName)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testEvents = []notification{
+	{
+		Event:        eventExpiring,
+		Host:         "a.example.com:443",
+		CommonName:   "a.example.com",
+		Issuer:       "CN=R3,O=Let's Encrypt,C=US",
+		SerialNumber: "01",
+		NotAfter:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		DaysLeft:     10,
+	},
+	{
+		Event:                eventChanged,
+		Host:                 "b.example.com:443",
+		CommonName:           "b.example.com",
+		Issuer:               "CN=R3,O=Let's Encrypt,C=US",
+		SerialNumber:         "03",
+		NotAfter:             time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		DaysLeft:             160,
+		PreviousIssuer:       "CN=R3,O=Let's Encrypt,C=US",
+		PreviousSerialNumber: "02",
+	},
+}
+
+func Test_slackFormatter_format(t *testing.T) {
+	b, err := slackFormatter{}.format(testEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"text":"tlc3: 2 TLS cert event(s)","blocks":[` +
+		`{"type":"header","text":{"type":"plain_text","text":"tlc3: 2 TLS cert event(s)"}},` +
+		`{"type":"section","text":{"type":"mrkdwn","text":"*expiring* ` + "`a.example.com:443`" + `\na.example.com:443 expires in 10 days on 2025-01-01 (a.example.com)"}},` +
+		`{"type":"section","text":{"type":"mrkdwn","text":"*changed* ` + "`b.example.com:443`" + `\nb.example.com:443 now serves serial 03 from CN=R3,O=Let's Encrypt,C=US, previously serial 02 from CN=R3,O=Let's Encrypt,C=US"}}]}`
+	if diff := cmp.Diff(string(b), want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_teamsFormatter_format(t *testing.T) {
+	b, err := teamsFormatter{}.format(testEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		Type        string
+		Attachments []struct {
+			ContentType string
+			Content     struct {
+				Type string
+				Body []struct {
+					Type  string
+					Text  string
+					Facts []struct{ Title, Value string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "message" || len(msg.Attachments) != 1 {
+		t.Fatalf("unexpected message: %s", b)
+	}
+	card := msg.Attachments[0]
+	if card.ContentType != "application/vnd.microsoft.card.adaptive" || card.Content.Type != "AdaptiveCard" {
+		t.Errorf("unexpected attachment: %s", b)
+	}
+	// a title, then a text block and a fact set per event
+	if got := len(card.Content.Body); got != 1+2*len(testEvents) {
+		t.Fatalf("body has %d elements, want %d", got, 1+2*len(testEvents))
+	}
+	if got := card.Content.Body[3].Text; got != describeEvent(testEvents[1]) {
+		t.Errorf("text = %q", got)
+	}
+	if got := card.Content.Body[4].Facts[1].Value; got != "b.example.com:443" {
+		t.Errorf("host fact = %q", got)
+	}
+}
+
+func Test_jsonFormatter_format(t *testing.T) {
+	b, err := jsonFormatter{}.format(testEvents[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Source":"tlc3","Events":[{"Event":"expiring","Host":"a.example.com:443","CommonName":"a.example.com","Issuer":"CN=R3,O=Let's Encrypt,C=US","SerialNumber":"01","NotAfter":"2025-01-01T00:00:00Z","DaysLeft":10}]}`
+	if diff := cmp.Diff(string(b), want); diff != "" {
+		t.Error(diff)
+	}
+}
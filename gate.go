@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// days below which a host counts as expiring in a gate when neither the
+// warning nor the critical days are given
+const gateExpiringDays = 30
+
+// gateStatus is the posture of a host, ordered from the best to the worst.
+type gateStatus int
+
+const (
+	gateOK gateStatus = iota
+	gateExpiring
+	gateUnverified
+	gateExpired
+	gateFailing
+)
+
+var gateStatuses = []string{"ok", "expiring", "unverified", "expired", "failing"}
+
+func (s gateStatus) String() string {
+	return gateStatuses[s]
+}
+
+// gateRegression is a host whose posture is worse than in the baseline.
+type gateRegression struct {
+	Host     string
+	Baseline gateStatus
+	Current  gateStatus
+}
+
+// gateError fails a gate with more regressions than allowed.
+type gateError struct {
+	count int
+	max   int
+}
+
+func (e *gateError) Error() string {
+	return fmt.Sprintf("%d regression(s) against the baseline exceed the maximum of %d", e.count, e.max)
+}
+
+func (e *gateError) ExitCode() int {
+	return 1
+}
+
+// loadBaseline reads a previous scan in the JSON output format.
+func loadBaseline(path string) ([]*certInfo, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var infos []*certInfo
+	if err := json.Unmarshal(b, &infos); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	return infos, nil
+}
+
+// gateStatusOf classifies a cert by the days left when it was checked, so
+// that a baseline keeps the posture it had at the time of its scan, and by
+// whether its chain verified then.
+func gateStatusOf(info *certInfo, days int) gateStatus {
+	switch {
+	case !info.NotAfter.After(info.CurrentTime):
+		return gateExpired
+	case unverified(info):
+		return gateUnverified
+	case info.DaysLeft < days:
+		return gateExpiring
+	default:
+		return gateOK
+	}
+}
+
+func gatePostures(infos []*certInfo, failed []hostFailure, days int) map[string]gateStatus {
+	statuses := make(map[string]gateStatus, len(infos)+len(failed))
+	for _, info := range infos {
+		statuses[net.JoinHostPort(info.DomainName, info.AccessPort)] = gateStatusOf(info, days)
+	}
+	for _, f := range failed {
+		statuses[f.addr] = gateFailing
+	}
+	return statuses
+}
+
+// gate compares the posture of each host with the baseline and returns the
+// hosts that got worse, ordered by host. A host missing from the baseline is
+// compared with ok, so that new hosts cannot bring in expiring certs either.
+func gate(baseline, current map[string]gateStatus) []gateRegression {
+	var res []gateRegression
+	for host, cur := range current {
+		if base := baseline[host]; cur > base {
+			res = append(res, gateRegression{Host: host, Baseline: base, Current: cur})
+		}
+	}
+	slices.SortFunc(res, func(a, b gateRegression) int {
+		return strings.Compare(a.Host, b.Host)
+	})
+	return res
+}
+
+// toGate writes the number of hosts in each posture of the baseline and the
+// current scan, followed by the regressions.
+func toGate(w io.Writer, baseline, current map[string]gateStatus, regressions []gateRegression) error {
+	count := func(statuses map[string]gateStatus, s gateStatus) int {
+		n := 0
+		for _, v := range statuses {
+			if v == s {
+				n++
+			}
+		}
+		return n
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tbaseline\tcurrent")
+	for _, s := range []gateStatus{gateExpiring, gateUnverified, gateExpired, gateFailing} {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", s, count(baseline, s), count(current, s))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "regressions: %d\n", len(regressions))
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, r := range regressions {
+		fmt.Fprintf(tw, "  %s\t%s -> %s\n", r.Host, r.Baseline, r.Current)
+	}
+	return tw.Flush()
+}
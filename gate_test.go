@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_gateStatusOf(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		info *certInfo
+		want gateStatus
+	}{
+		{name: "ok", info: &certInfo{NotAfter: now.AddDate(0, 0, 60), CurrentTime: now, DaysLeft: 60}, want: gateOK},
+		{name: "expiring", info: &certInfo{NotAfter: now.AddDate(0, 0, 10), CurrentTime: now, DaysLeft: 10}, want: gateExpiring},
+		{name: "expired", info: &certInfo{NotAfter: now.Add(-time.Hour), CurrentTime: now, DaysLeft: 0}, want: gateExpired},
+		{name: "expired just now", info: &certInfo{NotAfter: now, CurrentTime: now, DaysLeft: 0}, want: gateExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gateStatusOf(tt.info, 30); got != tt.want {
+				t.Errorf("gateStatusOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_gate(t *testing.T) {
+	baseline := map[string]gateStatus{
+		"a.example.com:443": gateOK,
+		"b.example.com:443": gateExpiring,
+		"c.example.com:443": gateFailing,
+		"d.example.com:443": gateOK,
+	}
+	current := map[string]gateStatus{
+		"a.example.com:443": gateExpiring,
+		"b.example.com:443": gateOK,
+		"c.example.com:443": gateExpired,
+		"d.example.com:443": gateOK,
+		"e.example.com:443": gateFailing,
+	}
+	want := []gateRegression{
+		{Host: "a.example.com:443", Baseline: gateOK, Current: gateExpiring},
+		{Host: "e.example.com:443", Baseline: gateOK, Current: gateFailing},
+	}
+	if got := gate(baseline, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("gate() = %v, want %v", got, want)
+	}
+}
+
+func Test_gatePostures(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	infos := []*certInfo{
+		{DomainName: "example.com", AccessPort: "443", NotAfter: now.AddDate(0, 0, 10), CurrentTime: now, DaysLeft: 10},
+		{DomainName: "::1", AccessPort: "8443", NotAfter: now.AddDate(0, 0, 60), CurrentTime: now, DaysLeft: 60},
+		{DomainName: "self.example.com", AccessPort: "443", NotAfter: now.AddDate(0, 0, 60), CurrentTime: now, DaysLeft: 60, VerificationStatus: verificationFailed},
+		{DomainName: "lab.example.com", AccessPort: "443", NotAfter: now.AddDate(0, 0, 60), CurrentTime: now, DaysLeft: 60, VerificationStatus: verificationFailed, insecure: true},
+	}
+	failed := []hostFailure{{addr: "down.example.com:443", err: errors.New("refused")}}
+	want := map[string]gateStatus{
+		"example.com:443":      gateExpiring,
+		"[::1]:8443":           gateOK,
+		"self.example.com:443": gateUnverified,
+		"lab.example.com:443":  gateOK,
+		"down.example.com:443": gateFailing,
+	}
+	if got := gatePostures(infos, failed, 30); !reflect.DeepEqual(got, want) {
+		t.Errorf("gatePostures() = %v, want %v", got, want)
+	}
+}
+
+func Test_toGate(t *testing.T) {
+	baseline := map[string]gateStatus{"a.example.com:443": gateOK, "b.example.com:443": gateExpiring}
+	current := map[string]gateStatus{"a.example.com:443": gateFailing, "b.example.com:443": gateExpiring}
+	w := &bytes.Buffer{}
+	if err := toGate(w, baseline, current, gate(baseline, current)); err != nil {
+		t.Fatal(err)
+	}
+	want := `            baseline  current
+expiring    1         1
+unverified  0         0
+expired     0         0
+failing     0         1
+regressions: 1
+  a.example.com:443  ok -> failing
+`
+	if got := w.String(); got != want {
+		t.Errorf("toGate() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_loadBaseline(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    int
+		wantErr bool
+	}{
+		{name: "json output", path: filepath.Join("testdata", "baseline.json"), want: 1},
+		{name: "not json", path: filepath.Join("testdata", "inventory.csv"), wantErr: true},
+		{name: "not found", path: filepath.Join("testdata", "missing.json"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadBaseline(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadBaseline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("loadBaseline() = %d certs, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+const (
+	alpnH2          = "h2"
+	grpcHealthPath  = "/grpc.health.v1.Health/Check"
+	grpcContentType = "application/grpc"
+)
+
+// grpcServingStatus names the values of HealthCheckResponse.ServingStatus.
+var grpcServingStatus = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+// grpcHealth calls grpc.health.v1.Health/Check for service over a separate
+// connection and returns the serving status, or the reason the call failed.
+// An empty service asks for the health of the server as a whole.
+func (c *connector) grpcHealth(ctx context.Context, service string) string {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	status, err := c.checkHealth(ctx, service)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return status
+}
+
+func (c *connector) checkHealth(ctx context.Context, service string) (string, error) {
+	config := c.tlsConfig.Clone()
+	config.NextProtos = []string{alpnH2}
+	tr := &http.Transport{
+		TLSClientConfig:   config,
+		ForceAttemptHTTP2: true,
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return c.dialAddr(ctx, addr)
+		},
+	}
+	defer tr.CloseIdleConnections()
+	u := url.URL{Scheme: "https", Host: c.addr, Path: grpcHealthPath}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(grpcFrame(healthCheckRequest(service))))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", grpcContentType)
+	req.Header.Set("TE", "trailers")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		return "", errors.New("server did not negotiate h2")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected http status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	// a call that fails before any message carries its status in the headers
+	code := resp.Trailer.Get("Grpc-Status")
+	msg := resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code, msg = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if code != "0" {
+		return "", fmt.Errorf("grpc-status %s: %s", code, msg)
+	}
+	status, err := parseHealthCheckResponse(body)
+	if err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// healthCheckRequest encodes HealthCheckRequest, whose only field is the
+// service name with number 1.
+func healthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	b := []byte{0x0a}
+	b = binary.AppendUvarint(b, uint64(len(service)))
+	return append(b, service...)
+}
+
+// grpcFrame prefixes an uncompressed message with its gRPC length header.
+func grpcFrame(msg []byte) []byte {
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// parseHealthCheckResponse decodes the framed HealthCheckResponse and returns
+// the name of its status field, number 1. Unknown fields are skipped.
+func parseHealthCheckResponse(b []byte) (string, error) {
+	if len(b) < 5 {
+		return "", errors.New("no response message")
+	}
+	if b[0] != 0 {
+		return "", errors.New("compressed response message")
+	}
+	size := binary.BigEndian.Uint32(b[1:5])
+	if uint32(len(b)-5) < size {
+		return "", errors.New("truncated response message")
+	}
+	msg := b[5 : 5+size]
+	var status uint64
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return "", errors.New("invalid response message")
+		}
+		msg = msg[n:]
+		var v uint64
+		switch tag & 0x7 {
+		case 0: // varint
+			v, n = binary.Uvarint(msg)
+		case 2: // length-delimited
+			var size uint64
+			size, n = binary.Uvarint(msg)
+			if n > 0 && uint64(len(msg)-n) >= size {
+				n += int(size)
+			} else {
+				n = -1
+			}
+		default:
+			n = -1
+		}
+		if n <= 0 {
+			return "", errors.New("invalid response message")
+		}
+		msg = msg[n:]
+		if tag == 0x08 {
+			status = v
+		}
+	}
+	if name, ok := grpcServingStatus[status]; ok {
+		return name, nil
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", status), nil
+}
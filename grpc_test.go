@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_healthCheckRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		want    []byte
+	}{
+		{
+			name:    "server",
+			service: "",
+			want:    nil,
+		},
+		{
+			name:    "service",
+			service: "grpc.test.Echo",
+			want:    append([]byte{0x0a, 14}, "grpc.test.Echo"...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(healthCheckRequest(tt.service), tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_parseHealthCheckResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "serving",
+			b:    grpcFrame([]byte{0x08, 0x01}),
+			want: "SERVING",
+		},
+		{
+			name: "not serving",
+			b:    grpcFrame([]byte{0x08, 0x02}),
+			want: "NOT_SERVING",
+		},
+		{
+			name: "default value omitted",
+			b:    grpcFrame(nil),
+			want: "UNKNOWN",
+		},
+		{
+			name: "unknown field skipped",
+			b:    grpcFrame([]byte{0x12, 0x02, 'h', 'i', 0x08, 0x01}),
+			want: "SERVING",
+		},
+		{
+			name: "unknown status",
+			b:    grpcFrame([]byte{0x08, 0x09}),
+			want: "UNKNOWN(9)",
+		},
+		{
+			name:    "empty",
+			b:       nil,
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			b:       grpcFrame([]byte{0x08, 0x01})[:6],
+			wantErr: true,
+		},
+		{
+			name:    "compressed",
+			b:       append([]byte{1}, grpcFrame([]byte{0x08, 0x01})[1:]...),
+			wantErr: true,
+		},
+		{
+			name:    "invalid field",
+			b:       grpcFrame([]byte{0x12, 0x05, 'h'}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHealthCheckResponse(tt.b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHealthCheckResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseHealthCheckResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// healthHandler answers health checks as a gRPC server would: SERVING for the
+// server and "grpc.test.Echo", NOT_FOUND for any other service.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != grpcHealthPath || r.Header.Get("Content-Type") != grpcContentType {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", grpcContentType)
+	b := make([]byte, 64)
+	n, _ := r.Body.Read(b)
+	req := string(b[:n])
+	if req != string(grpcFrame(healthCheckRequest(""))) && req != string(grpcFrame(healthCheckRequest("grpc.test.Echo"))) {
+		// trailers-only response
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "unknown service")
+		return
+	}
+	w.Header().Set("Trailer", "Grpc-Status")
+	_, _ = w.Write(grpcFrame([]byte{0x08, 0x01}))
+	w.Header().Set("Grpc-Status", "0")
+}
+
+func Test_connector_grpcHealth(t *testing.T) {
+	h2 := httptest.NewUnstartedServer(http.HandlerFunc(healthHandler))
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	t.Cleanup(h2.Close)
+	h1 := httptest.NewTLSServer(http.HandlerFunc(healthHandler))
+	t.Cleanup(h1.Close)
+	tests := []struct {
+		name     string
+		addr     string
+		service  string
+		wantALPN string
+		want     string
+	}{
+		{
+			name:     "server serving",
+			addr:     h2.Listener.Addr().String(),
+			service:  "",
+			wantALPN: alpnH2,
+			want:     "SERVING",
+		},
+		{
+			name:     "service serving",
+			addr:     h2.Listener.Addr().String(),
+			service:  "grpc.test.Echo",
+			wantALPN: alpnH2,
+			want:     "SERVING",
+		},
+		{
+			name:     "unknown service",
+			addr:     h2.Listener.Addr().String(),
+			service:  "grpc.test.Unknown",
+			wantALPN: alpnH2,
+			want:     "error: grpc-status 5: unknown service",
+		},
+		{
+			name:     "no h2",
+			addr:     h1.Listener.Addr().String(),
+			service:  "",
+			wantALPN: "http/1.1",
+			want:     "error: ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:    5 * time.Second,
+				insecure:   true,
+				location:   time.UTC,
+				grpc:       true,
+				grpcHealth: true,
+				grpcSvc:    tt.service,
+			}
+			infos, err := getCertList(context.Background(), []string{tt.addr}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if infos[0].ALPN != tt.wantALPN {
+				t.Errorf("ALPN = %q, want %q", infos[0].ALPN, tt.wantALPN)
+			}
+			if !strings.HasPrefix(infos[0].GRPCHealth, tt.want) {
+				t.Errorf("GRPCHealth = %q, want %q", infos[0].GRPCHealth, tt.want)
+			}
+		})
+	}
+}
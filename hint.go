@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// categories of the errors by which a host cannot be checked, so that
+// automation tells a host that is down from a cert that does not verify.
+const (
+	categoryDNS       = "dns"
+	categoryTimeout   = "timeout"
+	categoryRefused   = "refused"
+	categoryTLSVerify = "tls-verify"
+	categoryProtocol  = "protocol"
+	categoryOther     = "other"
+)
+
+// checkError is the error of a host that cannot be checked, as reported in
+// its record.
+type checkError struct {
+	Category string
+	Message  string
+	// Duration is how long the check took until it failed
+	Duration string
+}
+
+func newCheckError(err error, elapsed time.Duration) *checkError {
+	return &checkError{
+		Category: errorCategory(err),
+		Message:  err.Error(),
+		Duration: elapsed.Round(time.Millisecond).String(),
+	}
+}
+
+func (e *checkError) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.Category + ": " + e.Message
+}
+
+// hintError annotates a connection error with an actionable hint about its
+// likely root cause.
+type hintError struct {
+	err  error
+	Hint string
+}
+
+func (e *hintError) Error() string {
+	return e.err.Error() + " (hint: " + e.Hint + ")"
+}
+
+func (e *hintError) Unwrap() error {
+	return e.err
+}
+
+// withHint wraps err with a hint if its class is known.
+func withHint(err error) error {
+	if err == nil {
+		return nil
+	}
+	if h := hint(err); h != "" {
+		return &hintError{err: err, Hint: h}
+	}
+	return err
+}
+
+func hint(err error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		alert            tls.AlertError
+		recordHeader     tls.RecordHeaderError
+		dnsErr           *net.DNSError
+		netErr           net.Error
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "chain likely misses an intermediate or is issued by a private CA"
+	case errors.As(err, &hostname):
+		return "cert does not cover the name; check SANs or set --servername"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "cert is expired or not yet valid; check renewal and the local clock"
+	case errors.As(err, &alert):
+		return alertHint(alert)
+	case errors.As(err, &recordHeader):
+		return "server did not answer with TLS; the port may need --starttls"
+	case errors.As(err, &dnsErr):
+		return "host name does not resolve; check the name and DNS"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "nothing is listening on the port"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset during handshake; a firewall may block TLS or the port speaks another protocol"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "host is unreachable, filtered or slow; check reachability or raise --timeout"
+	default:
+		return ""
+	}
+}
+
+func errorCategory(err error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		verification     *tls.CertificateVerificationError
+		alert            tls.AlertError
+		recordHeader     tls.RecordHeaderError
+		dnsErr           *net.DNSError
+		netErr           net.Error
+	)
+	switch {
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname), errors.As(err, &invalid), errors.As(err, &verification):
+		return categoryTLSVerify
+	case errors.As(err, &alert), errors.As(err, &recordHeader), errors.Is(err, syscall.ECONNRESET):
+		return categoryProtocol
+	case errors.As(err, &dnsErr):
+		return categoryDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return categoryRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return categoryTimeout
+	default:
+		return categoryOther
+	}
+}
+
+// https://www.rfc-editor.org/rfc/rfc8446#section-6
+func alertHint(alert tls.AlertError) string {
+	switch alert {
+	case 40: // handshake_failure
+		return "server likely requires SNI or shares no cipher suite with the client; try --servername"
+	case 70: // protocol_version
+		return "server does not support TLS 1.2 or later"
+	case 112: // unrecognized_name
+		return "server does not know the SNI name; set --servername"
+	case 116: // certificate_required
+		return "server requires a client certificate"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func Test_withHint(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			want: "",
+		},
+		{
+			name: "unknown authority",
+			err:  fmt.Errorf("verify: %w", x509.UnknownAuthorityError{}),
+			want: "intermediate",
+		},
+		{
+			name: "hostname",
+			err:  x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"},
+			want: "--servername",
+		},
+		{
+			name: "expired",
+			err:  x509.CertificateInvalidError{Reason: x509.Expired},
+			want: "expired",
+		},
+		{
+			name: "handshake failure alert",
+			err:  &net.OpError{Op: "remote error", Err: tls.AlertError(40)},
+			want: "SNI",
+		},
+		{
+			name: "unknown alert",
+			err:  &net.OpError{Op: "remote error", Err: tls.AlertError(10)},
+			want: "",
+		},
+		{
+			name: "record header",
+			err:  tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"},
+			want: "--starttls",
+		},
+		{
+			name: "dns",
+			err:  &net.DNSError{Err: "no such host", Name: "invalid.invalid"},
+			want: "resolve",
+		},
+		{
+			name: "refused",
+			err:  &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED},
+			want: "listening",
+		},
+		{
+			name: "reset",
+			err:  &net.OpError{Op: "read", Err: syscall.ECONNRESET},
+			want: "reset",
+		},
+		{
+			name: "timeout",
+			err:  fmt.Errorf("dial: %w", context.DeadlineExceeded),
+			want: "--timeout",
+		},
+		{
+			name: "unknown",
+			err:  errors.New("something else"),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := withHint(tt.err)
+			var he *hintError
+			if !errors.As(err, &he) {
+				if tt.want != "" {
+					t.Errorf("withHint() = %v, want hint containing %q", err, tt.want)
+				}
+				return
+			}
+			if tt.want == "" || !strings.Contains(he.Hint, tt.want) {
+				t.Errorf("withHint() hint = %q, want %q", he.Hint, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("withHint() does not wrap %v", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err.Error()) {
+				t.Errorf("withHint() message = %q", err.Error())
+			}
+		})
+	}
+}
+
+func Test_errorCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "unknown authority",
+			err:  withHint(fmt.Errorf("verify: %w", x509.UnknownAuthorityError{})),
+			want: categoryTLSVerify,
+		},
+		{
+			name: "verification",
+			err:  &tls.CertificateVerificationError{Err: x509.CertificateInvalidError{Reason: x509.Expired}},
+			want: categoryTLSVerify,
+		},
+		{
+			name: "alert",
+			err:  &net.OpError{Op: "remote error", Err: tls.AlertError(40)},
+			want: categoryProtocol,
+		},
+		{
+			name: "record header",
+			err:  tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"},
+			want: categoryProtocol,
+		},
+		{
+			name: "dns",
+			err:  withHint(fmt.Errorf("cannot resolve: %w", &net.DNSError{Err: "no such host", Name: "invalid.invalid"})),
+			want: categoryDNS,
+		},
+		{
+			name: "refused",
+			err:  &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED},
+			want: categoryRefused,
+		},
+		{
+			name: "timeout",
+			err:  fmt.Errorf("dial: %w", context.DeadlineExceeded),
+			want: categoryTimeout,
+		},
+		{
+			name: "unknown",
+			err:  errors.New("something else"),
+			want: categoryOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCategory(tt.err); got != tt.want {
+				t.Errorf("errorCategory() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// width of the longest bar of a histogram
+const histogramWidth = 50
+
+// histogramBucket counts the certs whose days left are from min to max. The
+// first bucket counts the expired certs.
+type histogramBucket struct {
+	label string
+	min   int
+	max   int
+}
+
+var histogramBuckets = []histogramBucket{
+	{label: "expired"},
+	{label: "0-7", min: 0, max: 7},
+	{label: "8-14", min: 8, max: 14},
+	{label: "15-30", min: 15, max: 30},
+	{label: "31-60", min: 31, max: 60},
+	{label: "61-90", min: 61, max: 90},
+	{label: "91-180", min: 91, max: 180},
+	{label: "181-365", min: 181, max: 365},
+	{label: ">365", min: 366, max: math.MaxInt},
+}
+
+// histogramSection is the histogram of the certs of an issuer, or of all
+// certs when not split.
+type histogramSection struct {
+	issuer string
+	counts []int
+	total  int
+}
+
+// toHistogram writes a text histogram of the days left of infos, with a
+// section per issuer when byIssuer is set. Bars are scaled to the largest
+// bucket of all sections so that sections compare at a glance.
+func toHistogram(infos []*certInfo, w io.Writer, byIssuer bool) error {
+	var sections []*histogramSection
+	index := make(map[string]*histogramSection)
+	for _, info := range infos {
+		if info.Error != nil {
+			continue
+		}
+		key := ""
+		if byIssuer {
+			key = info.Issuer
+		}
+		s, ok := index[key]
+		if !ok {
+			s = &histogramSection{issuer: key, counts: make([]int, len(histogramBuckets))}
+			index[key] = s
+			sections = append(sections, s)
+		}
+		// a cert expired less than a day ago still has zero days left
+		if !info.NotAfter.After(info.CurrentTime) {
+			s.counts[0]++
+		} else {
+			for i, b := range histogramBuckets[1:] {
+				if info.DaysLeft >= b.min && info.DaysLeft <= b.max {
+					s.counts[i+1]++
+					break
+				}
+			}
+		}
+		s.total++
+	}
+	if len(sections) == 0 {
+		sections = append(sections, &histogramSection{counts: make([]int, len(histogramBuckets))})
+	}
+	slices.SortFunc(sections, func(a, b *histogramSection) int {
+		return cmp.Compare(a.issuer, b.issuer)
+	})
+	peak := 0
+	for _, s := range sections {
+		peak = max(peak, slices.Max(s.counts))
+	}
+	var b strings.Builder
+	for i, s := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		indent := ""
+		if byIssuer {
+			fmt.Fprintf(&b, "%s (%d)\n", s.issuer, s.total)
+			indent = "  "
+		}
+		fmt.Fprintf(&b, "%s%-8s %6s\n", indent, "DaysLeft", "Certs")
+		for j, bucket := range histogramBuckets {
+			bar := ""
+			if s.counts[j] > 0 {
+				// a non-empty bucket shows at least one mark
+				bar = " " + strings.Repeat("#", max(s.counts[j]*histogramWidth/peak, 1))
+			}
+			fmt.Fprintf(&b, "%s%-8s %6d%s\n", indent, bucket.label, s.counts[j], bar)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
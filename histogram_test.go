@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func Test_toHistogram(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := func(issuer string, days int) *certInfo {
+		notAfter := now.Add(time.Duration(days)*24*time.Hour + time.Hour)
+		return &certInfo{Issuer: issuer, NotAfter: notAfter, CurrentTime: now, DaysLeft: daysLeft(notAfter, now)}
+	}
+	infos := []*certInfo{
+		cert("CN=B", 3),
+		cert("CN=A", 3),
+		cert("CN=A", 5),
+		cert("CN=A", 45),
+		cert("CN=B", 400),
+		{Issuer: "CN=B", NotAfter: now.Add(-time.Hour), CurrentTime: now, DaysLeft: 0},
+	}
+	tests := []struct {
+		name     string
+		infos    []*certInfo
+		byIssuer bool
+		want     string
+	}{
+		{
+			name:  "all",
+			infos: infos,
+			want: `DaysLeft  Certs
+expired       1 ################
+0-7           3 ##################################################
+8-14          0
+15-30         0
+31-60         1 ################
+61-90         0
+91-180        0
+181-365       0
+>365          1 ################
+`,
+		},
+		{
+			name:     "by issuer",
+			infos:    infos,
+			byIssuer: true,
+			want: `CN=A (3)
+  DaysLeft  Certs
+  expired       0
+  0-7           2 ##################################################
+  8-14          0
+  15-30         0
+  31-60         1 #########################
+  61-90         0
+  91-180        0
+  181-365       0
+  >365          0
+
+CN=B (3)
+  DaysLeft  Certs
+  expired       1 #########################
+  0-7           1 #########################
+  8-14          0
+  15-30         0
+  31-60         0
+  61-90         0
+  91-180        0
+  181-365       0
+  >365          1 #########################
+`,
+		},
+		{
+			name:  "empty",
+			infos: nil,
+			want: `DaysLeft  Certs
+expired       0
+0-7           0
+8-14          0
+15-30         0
+31-60         0
+61-90         0
+91-180        0
+181-365       0
+>365          0
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if err := toHistogram(tt.infos, w, tt.byIssuer); err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != tt.want {
+				t.Errorf("\ngot:\n%s\nwant:\n%s", w.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nekrassov01/mintab"
+)
+
+// historyTimeLayout is the layout of the times in the history, which sort as
+// text since they are all in UTC.
+const historyTimeLayout = "2006-01-02T15:04:05Z"
+
+const historySchema = `CREATE TABLE IF NOT EXISTS results (
+  checked_at TEXT NOT NULL,
+  host TEXT NOT NULL,
+  port TEXT NOT NULL,
+  common_name TEXT,
+  issuer TEXT,
+  serial_number TEXT,
+  fingerprint_sha256 TEXT,
+  not_before TEXT,
+  not_after TEXT,
+  days_left INTEGER
+);
+CREATE INDEX IF NOT EXISTS results_host ON results (host, port, checked_at);
+`
+
+// history appends the results of every run to a SQLite database through the
+// sqlite3 CLI, which keeps the binary free of cgo, to query trends later
+// without external infrastructure. A nil history keeps nothing.
+type history struct {
+	sqlite3 string
+	path    string
+}
+
+// newHistory returns the history in the database at path, or nil without a
+// path. It fails early when sqlite3 is missing rather than after a run.
+func newHistory(path string) (*history, error) {
+	if path == "" {
+		return nil, nil
+	}
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3 is required for the history: %w", err)
+	}
+	return &history{sqlite3: sqlite3, path: path}, nil
+}
+
+// append records infos as checked at now in a single transaction.
+func (h *history) append(ctx context.Context, infos []*certInfo, now time.Time) error {
+	if h == nil || len(infos) == 0 {
+		return nil
+	}
+	checkedAt := sqlQuote(now.UTC().Format(historyTimeLayout))
+	var b strings.Builder
+	b.WriteString(historySchema)
+	b.WriteString("BEGIN;\n")
+	for _, info := range infos {
+		fmt.Fprintf(&b, "INSERT INTO results VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %d);\n",
+			checkedAt,
+			sqlQuote(info.DomainName),
+			sqlQuote(info.AccessPort),
+			sqlQuote(info.CommonName),
+			sqlQuote(info.Issuer),
+			sqlQuote(info.SerialNumber),
+			sqlQuote(info.FingerprintSHA256),
+			sqlQuote(info.NotBefore.UTC().Format(historyTimeLayout)),
+			sqlQuote(info.NotAfter.UTC().Format(historyTimeLayout)),
+			info.DaysLeft,
+		)
+	}
+	b.WriteString("COMMIT;\n")
+	_, err := h.exec(ctx, b.String())
+	return err
+}
+
+// rotation is a cert served by a host across runs, from the first run it was
+// seen in to the last.
+type rotation struct {
+	Host         string
+	SerialNumber string
+	Issuer       string
+	NotAfter     time.Time
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	Checks       int
+}
+
+type historyRow struct {
+	Host         string `json:"host"`
+	Port         string `json:"port"`
+	SerialNumber string `json:"serial_number"`
+	Issuer       string `json:"issuer"`
+	NotAfter     string `json:"not_after"`
+	FirstSeen    string `json:"first_seen"`
+	LastSeen     string `json:"last_seen"`
+	Checks       int    `json:"checks"`
+}
+
+// rotations returns the certs served by hosts, or by every host without
+// hosts, ordered by host and by when they were first seen, so that the last
+// of a host tells when its cert was last rotated. A host is given by name, or
+// by name and port.
+func (h *history) rotations(ctx context.Context, hosts []string) ([]rotation, error) {
+	var where string
+	if len(hosts) > 0 {
+		quoted := make([]string, len(hosts))
+		for i, host := range hosts {
+			quoted[i] = sqlQuote(host)
+		}
+		in := strings.Join(quoted, ", ")
+		where = fmt.Sprintf("WHERE host IN (%s) OR host || ':' || port IN (%s)\n", in, in)
+	}
+	query := historySchema + `SELECT host, port, serial_number, issuer, not_after,
+  MIN(checked_at) AS first_seen, MAX(checked_at) AS last_seen, COUNT(*) AS checks
+FROM results
+` + where + `GROUP BY host, port, fingerprint_sha256
+ORDER BY host, port, first_seen;
+`
+	b, err := h.exec(ctx, query, "-json")
+	if err != nil {
+		return nil, err
+	}
+	// no rows print nothing at all
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+	var rows []historyRow
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return nil, fmt.Errorf("cannot parse history: %w", err)
+	}
+	res := make([]rotation, 0, len(rows))
+	for _, row := range rows {
+		r := rotation{
+			Host:         row.Host,
+			SerialNumber: row.SerialNumber,
+			Issuer:       row.Issuer,
+			Checks:       row.Checks,
+		}
+		if row.Port != "" {
+			r.Host += ":" + row.Port
+		}
+		for _, t := range []struct {
+			dst *time.Time
+			src string
+		}{
+			{&r.NotAfter, row.NotAfter},
+			{&r.FirstSeen, row.FirstSeen},
+			{&r.LastSeen, row.LastSeen},
+		} {
+			*t.dst, err = time.Parse(historyTimeLayout, t.src)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time in history: %w", err)
+			}
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// exec runs sql against the database with args, creating it if missing.
+func (h *history) exec(ctx context.Context, sql string, args ...string) ([]byte, error) {
+	args = append([]string{"-bail"}, args...)
+	args = append(args, h.path)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.sqlite3, args...) // #nosec G204
+	cmd.Stdin = strings.NewReader(sql)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("cannot access history %s: %s", h.path, msg)
+		}
+		return nil, fmt.Errorf("cannot access history %s: %w", h.path, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// sqlQuote returns s as a SQL string literal.
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// toHistory writes rotations in JSON or as a table, with times in loc.
+func toHistory(rotations []rotation, w io.Writer, format string, loc *time.Location) error {
+	for i := range rotations {
+		rotations[i].NotAfter = rotations[i].NotAfter.In(loc)
+		rotations[i].FirstSeen = rotations[i].FirstSeen.In(loc)
+		rotations[i].LastSeen = rotations[i].LastSeen.In(loc)
+	}
+	switch format {
+	case formatJSON.String():
+		b := json.NewEncoder(w)
+		b.SetIndent("", "  ")
+		return b.Encode(rotations)
+	case formatTextTable.String(), formatMarkdownTable.String(), formatBacklogTable.String():
+	default:
+		return fmt.Errorf("invalid format for history: allowed values: %s", pipeJoin(formats[:formatBacklogTable+1]))
+	}
+	opts := make([]mintab.Option, 0, 1)
+	switch format {
+	case formatMarkdownTable.String():
+		opts = append(opts, mintab.WithFormat(mintab.MarkdownFormat))
+	case formatBacklogTable.String():
+		opts = append(opts, mintab.WithFormat(mintab.BacklogFormat))
+	}
+	data := make([][]any, len(rotations))
+	for i, r := range rotations {
+		data[i] = []any{r.Host, r.SerialNumber, r.Issuer, r.NotAfter, r.FirstSeen, r.LastSeen, strconv.Itoa(r.Checks)}
+	}
+	table := mintab.New(w, opts...)
+	if err := table.Load(mintab.Input{
+		Header: []string{"Host", "SerialNumber", "Issuer", "NotAfter", "FirstSeen", "LastSeen", "Checks"},
+		Data:   data,
+	}); err != nil {
+		return err
+	}
+	table.Render()
+	return nil
+}
+
+// burndownDay counts the certs of the hosts checked on a day, in UTC, by how
+// soon they expire as of the last check of each host that day. The counts
+// within 30, 60 and 90 days include one another but not the expired certs.
+type burndownDay struct {
+	Day      time.Time
+	Expired  int
+	Within30 int
+	Within60 int
+	Within90 int
+	Total    int
+}
+
+type burndownRow struct {
+	Day      string `json:"day"`
+	Expired  int    `json:"expired"`
+	Within30 int    `json:"within_30"`
+	Within60 int    `json:"within_60"`
+	Within90 int    `json:"within_90"`
+	Total    int    `json:"total"`
+}
+
+// burndown returns the counts of each day in the history, oldest first.
+// Hosts that could not be checked have no cert to count.
+func (h *history) burndown(ctx context.Context) ([]burndownDay, error) {
+	query := historySchema + `WITH latest AS (
+  SELECT substr(checked_at, 1, 10) AS day, days_left,
+    ROW_NUMBER() OVER (PARTITION BY substr(checked_at, 1, 10), host, port ORDER BY checked_at DESC) AS n
+  FROM results
+  WHERE fingerprint_sha256 <> ''
+)
+SELECT day,
+  SUM(days_left < 0) AS expired,
+  SUM(days_left BETWEEN 0 AND 30) AS within_30,
+  SUM(days_left BETWEEN 0 AND 60) AS within_60,
+  SUM(days_left BETWEEN 0 AND 90) AS within_90,
+  COUNT(*) AS total
+FROM latest
+WHERE n = 1
+GROUP BY day
+ORDER BY day;
+`
+	b, err := h.exec(ctx, query, "-json")
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+	var rows []burndownRow
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return nil, fmt.Errorf("cannot parse history: %w", err)
+	}
+	res := make([]burndownDay, 0, len(rows))
+	for _, row := range rows {
+		day, err := time.Parse(time.DateOnly, row.Day)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time in history: %w", err)
+		}
+		res = append(res, burndownDay{
+			Day:      day,
+			Expired:  row.Expired,
+			Within30: row.Within30,
+			Within60: row.Within60,
+			Within90: row.Within90,
+			Total:    row.Total,
+		})
+	}
+	return res, nil
+}
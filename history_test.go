@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newTestHistory(t *testing.T) *history {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not found")
+	}
+	h, err := newHistory(filepath.Join(t.TempDir(), "history.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func Test_history(t *testing.T) {
+	h := newTestHistory(t)
+	ctx := context.Background()
+	day1 := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	day3 := day1.AddDate(0, 0, 2)
+	notAfter := day1.AddDate(0, 3, 0)
+	run := func(now time.Time, infos ...*certInfo) {
+		t.Helper()
+		if err := h.append(ctx, infos, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(day1,
+		&certInfo{DomainName: "a.example.com", AccessPort: "443", SerialNumber: "01", FingerprintSHA256: "a1", Issuer: "CN=R3", NotAfter: notAfter},
+		&certInfo{DomainName: "b.example.com", AccessPort: "8443", SerialNumber: "02", FingerprintSHA256: "b1", Issuer: "O=Let's Encrypt", NotAfter: notAfter},
+	)
+	run(day2,
+		&certInfo{DomainName: "a.example.com", AccessPort: "443", SerialNumber: "01", FingerprintSHA256: "a1", Issuer: "CN=R3", NotAfter: notAfter},
+	)
+	run(day3,
+		&certInfo{DomainName: "a.example.com", AccessPort: "443", SerialNumber: "03", FingerprintSHA256: "a2", Issuer: "CN=R3", NotAfter: notAfter.AddDate(0, 1, 0)},
+	)
+	tests := []struct {
+		name  string
+		hosts []string
+		want  []rotation
+	}{
+		{
+			name: "all",
+			want: []rotation{
+				{Host: "a.example.com:443", SerialNumber: "01", Issuer: "CN=R3", NotAfter: notAfter, FirstSeen: day1, LastSeen: day2, Checks: 2},
+				{Host: "a.example.com:443", SerialNumber: "03", Issuer: "CN=R3", NotAfter: notAfter.AddDate(0, 1, 0), FirstSeen: day3, LastSeen: day3, Checks: 1},
+				{Host: "b.example.com:8443", SerialNumber: "02", Issuer: "O=Let's Encrypt", NotAfter: notAfter, FirstSeen: day1, LastSeen: day1, Checks: 1},
+			},
+		},
+		{
+			name:  "host with port",
+			hosts: []string{"b.example.com:8443"},
+			want: []rotation{
+				{Host: "b.example.com:8443", SerialNumber: "02", Issuer: "O=Let's Encrypt", NotAfter: notAfter, FirstSeen: day1, LastSeen: day1, Checks: 1},
+			},
+		},
+		{
+			name:  "unknown",
+			hosts: []string{"c.example.com", "a.example.com:8443"},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.rotations(ctx, tt.hosts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	if err := (*history)(nil).append(ctx, []*certInfo{{DomainName: "a.example.com"}}, day1); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_history_burndown(t *testing.T) {
+	h := newTestHistory(t)
+	ctx := context.Background()
+	if got, err := h.burndown(ctx); err != nil || got != nil {
+		t.Fatalf("burndown() of an empty history = %v, %v, want none", got, err)
+	}
+	day1 := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	cert := func(host string, daysLeft int) *certInfo {
+		return &certInfo{DomainName: host, AccessPort: "443", FingerprintSHA256: host, DaysLeft: daysLeft}
+	}
+	run := func(now time.Time, infos ...*certInfo) {
+		t.Helper()
+		if err := h.append(ctx, infos, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(day1, cert("a.example.com", -2), cert("b.example.com", 10), cert("c.example.com", 45), cert("d.example.com", 200))
+	// the last check of a host in a day counts, and a failed check nothing
+	run(day1.Add(time.Hour), cert("b.example.com", 80), &certInfo{DomainName: "e.example.com", AccessPort: "443"})
+	run(day2, cert("a.example.com", 89), cert("b.example.com", 79), cert("c.example.com", 44))
+	got, err := h.burndown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []burndownDay{
+		{Day: day1, Expired: 1, Within30: 0, Within60: 1, Within90: 2, Total: 4},
+		{Day: day2, Expired: 0, Within30: 0, Within60: 1, Within90: 3, Total: 3},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_newHistory(t *testing.T) {
+	h, err := newHistory("")
+	if err != nil || h != nil {
+		t.Errorf("newHistory() without path = %v, %v, want none", h, err)
+	}
+	t.Setenv("PATH", t.TempDir())
+	if _, err := newHistory("history.sqlite"); err == nil {
+		t.Error("newHistory() without sqlite3 succeeded")
+	}
+}
+
+func Test_toHistory(t *testing.T) {
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	rotations := []rotation{
+		{Host: "a.example.com:443", SerialNumber: "01", Issuer: "CN=R3", NotAfter: at, FirstSeen: at, LastSeen: at, Checks: 2},
+	}
+	tests := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "markdown",
+			format: formatMarkdownTable.String(),
+			want:   "| a.example.com:443 |           01 | CN=R3  | 2025-03-01 09:00:00 +0900 JST |",
+		},
+		{
+			name:   "json",
+			format: formatJSON.String(),
+			want:   `"FirstSeen": "2025-03-01T09:00:00+09:00"`,
+		},
+		{
+			name:    "nagios",
+			format:  formatNagios.String(),
+			wantErr: true,
+		},
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := toHistory(append([]rotation(nil), rotations...), w, tt.format, tokyo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(w.String(), tt.want) {
+				t.Errorf("toHistory() = %s, want to contain %s", w.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+const (
+	hookPre  = "pre"
+	hookPost = "post"
+)
+
+// hook runs user commands before and after a check, e.g. to create tickets or
+// warm caches. The pre-hook receives the targets and the post-hook the result
+// as JSON on stdin, and TLC3_HOOK tells them apart when one script serves
+// both. A nil hook runs nothing.
+type hook struct {
+	pre  string
+	post string
+	w    io.Writer
+}
+
+func newHook(pre, post string) *hook {
+	if pre == "" && post == "" {
+		return nil
+	}
+	return &hook{
+		pre:  pre,
+		post: post,
+		w:    os.Stderr,
+	}
+}
+
+// before runs the pre-hook with the targets. A failure aborts the check.
+func (h *hook) before(ctx context.Context, domains []string) error {
+	if h == nil || h.pre == "" {
+		return nil
+	}
+	b, err := json.Marshal(domains)
+	if err != nil {
+		return err
+	}
+	return h.run(ctx, h.pre, hookPre, b)
+}
+
+// after runs the post-hook with the result in the same JSON as -o json.
+func (h *hook) after(ctx context.Context, infos []*certInfo) error {
+	if h == nil || h.post == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := toJSON(infos, &buf); err != nil {
+		return err
+	}
+	return h.run(ctx, h.post, hookPost, buf.Bytes())
+}
+
+// run executes command through the shell. Its output goes to stderr so that it
+// does not mix with the output of tlc3.
+func (h *hook) run(ctx context.Context, command, phase string, input []byte) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command) // #nosec G204
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command) // #nosec G204
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = h.w
+	cmd.Stderr = h.w
+	cmd.Env = append(os.Environ(), canonicalName+"_HOOK="+phase)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s-hook failed: %w", phase, err)
+	}
+	return nil
+}
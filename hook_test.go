@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_hook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in the test are written for sh")
+	}
+	dir := t.TempDir()
+	infos := []*certInfo{
+		{DomainName: "example.com", AccessPort: "443", CommonName: "example.com"},
+	}
+	var want bytes.Buffer
+	if err := toJSON(infos, &want); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		pre       string
+		post      string
+		wantPre   string
+		wantPost  string
+		wantPhase string
+		wantErr   bool
+	}{
+		{
+			name:      "pre",
+			pre:       "cat > " + filepath.Join(dir, "pre.json") + "; echo $TLC3_HOOK > " + filepath.Join(dir, "phase"),
+			wantPre:   `["example.com:443","example.net"]`,
+			wantPhase: "pre\n",
+		},
+		{
+			name:      "post",
+			post:      "cat > " + filepath.Join(dir, "post.json") + "; echo $TLC3_HOOK > " + filepath.Join(dir, "phase"),
+			wantPost:  want.String(),
+			wantPhase: "post\n",
+		},
+		{
+			name:    "pre fails",
+			pre:     "exit 3",
+			wantErr: true,
+		},
+		{
+			name:    "post fails",
+			post:    "exit 3",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"pre.json", "post.json", "phase"} {
+				_ = os.Remove(filepath.Join(dir, name))
+			}
+			h := newHook(tt.pre, tt.post)
+			h.w = io.Discard
+			err := h.before(context.Background(), []string{"example.com:443", "example.net"})
+			if err == nil {
+				err = h.after(context.Background(), infos)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("hook error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			for name, want := range map[string]string{"pre.json": tt.wantPre, "post.json": tt.wantPost, "phase": tt.wantPhase} {
+				b, _ := os.ReadFile(filepath.Join(dir, name))
+				if diff := cmp.Diff(want, string(b)); diff != "" {
+					t.Errorf("%s: %s", name, diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_newHook(t *testing.T) {
+	if h := newHook("", ""); h != nil {
+		t.Errorf("newHook() = %v, want nil", h)
+	}
+	var h *hook
+	if err := h.before(context.Background(), []string{"example.com"}); err != nil {
+		t.Error(err)
+	}
+	if err := h.after(context.Background(), nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nekrassov01/mintab"
+)
+
+const (
+	inventoryDeployed    = "deployed"
+	inventoryNotDeployed = "not-deployed"
+	inventoryUnknown     = "unknown"
+)
+
+// inventoryCert is a cert issued by the internal CA as found in its export.
+type inventoryCert struct {
+	SerialNumber string
+	CommonName   string
+	NotAfter     string
+}
+
+// inventoryColumns maps the accepted CSV header names to the field they fill.
+var inventoryColumns = map[string]string{
+	"serial":        "SerialNumber",
+	"serialnumber":  "SerialNumber",
+	"serial_number": "SerialNumber",
+	"cn":            "CommonName",
+	"commonname":    "CommonName",
+	"common_name":   "CommonName",
+	"notafter":      "NotAfter",
+	"not_after":     "NotAfter",
+	"expires":       "NotAfter",
+}
+
+// loadInventory reads a CA export in JSON when the file name says so and in
+// CSV with a header line otherwise.
+func loadInventory(path string) ([]inventoryCert, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var certs []inventoryCert
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		certs, err = parseInventoryJSON(f)
+	} else {
+		certs, err = parseInventoryCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid inventory %s: %w", path, err)
+	}
+	return certs, nil
+}
+
+func parseInventoryJSON(r io.Reader) ([]inventoryCert, error) {
+	var certs []inventoryCert
+	if err := json.NewDecoder(r).Decode(&certs); err != nil {
+		return nil, err
+	}
+	for i, cert := range certs {
+		if cert.SerialNumber == "" {
+			return nil, fmt.Errorf("entry %d: no serial number", i+1)
+		}
+	}
+	return certs, nil
+}
+
+func parseInventoryCSV(r io.Reader) ([]inventoryCert, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		if field, ok := inventoryColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			index[field] = i
+		}
+	}
+	if _, ok := index["SerialNumber"]; !ok {
+		return nil, errors.New("no serial number column")
+	}
+	get := func(record []string, field string) string {
+		if i, ok := index[field]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var certs []inventoryCert
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		cert := inventoryCert{
+			SerialNumber: get(record, "SerialNumber"),
+			CommonName:   get(record, "CommonName"),
+			NotAfter:     get(record, "NotAfter"),
+		}
+		if cert.SerialNumber == "" {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: no serial number", line)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// normalizeSerial makes serial numbers from different tools comparable:
+// uppercase hex without separators or leading zeros.
+func normalizeSerial(s string) string {
+	s = strings.NewReplacer(":", "", " ", "", "-", "").Replace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	s = strings.TrimLeft(strings.ToUpper(s), "0")
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+type reconcileEntry struct {
+	Status       string
+	SerialNumber string
+	CommonName   string
+	NotAfter     string
+	Hosts        []string `json:",omitempty"`
+}
+
+// reconcile matches the certs served by the hosts against the inventory by
+// serial number, and reports each cert as deployed, not-deployed (issued but
+// served by none of the hosts) or unknown (served but not in the inventory).
+func reconcile(inventory []inventoryCert, infos []*certInfo) []reconcileEntry {
+	live := make(map[string]*reconcileEntry)
+	var order []string
+	for _, info := range infos {
+		serial := normalizeSerial(info.SerialNumber)
+		e, ok := live[serial]
+		if !ok {
+			e = &reconcileEntry{
+				Status:       inventoryUnknown,
+				SerialNumber: serial,
+				CommonName:   info.CommonName,
+				NotAfter:     info.NotAfter.Format(time.RFC3339),
+			}
+			live[serial] = e
+			order = append(order, serial)
+		}
+		e.Hosts = append(e.Hosts, net.JoinHostPort(info.DomainName, info.AccessPort))
+	}
+	var res []reconcileEntry
+	for _, cert := range inventory {
+		serial := normalizeSerial(cert.SerialNumber)
+		if e, ok := live[serial]; ok {
+			e.Status = inventoryDeployed
+			continue
+		}
+		res = append(res, reconcileEntry{
+			Status:       inventoryNotDeployed,
+			SerialNumber: serial,
+			CommonName:   cert.CommonName,
+			NotAfter:     cert.NotAfter,
+		})
+	}
+	for _, serial := range order {
+		res = append(res, *live[serial])
+	}
+	slices.SortStableFunc(res, func(a, b reconcileEntry) int {
+		return cmp.Compare(a.Status, b.Status)
+	})
+	return res
+}
+
+func toReconcile(entries []reconcileEntry, w io.Writer, format string) error {
+	switch format {
+	case formatJSON.String():
+		b := json.NewEncoder(w)
+		b.SetIndent("", "  ")
+		return b.Encode(entries)
+	case formatTextTable.String(), formatMarkdownTable.String(), formatBacklogTable.String():
+	default:
+		return fmt.Errorf("invalid format for reconcile: allowed values: %s", pipeJoin(formats[:formatBacklogTable+1]))
+	}
+	opts := make([]mintab.Option, 0, 1)
+	switch format {
+	case formatMarkdownTable.String():
+		opts = append(opts, mintab.WithFormat(mintab.MarkdownFormat))
+	case formatBacklogTable.String():
+		opts = append(opts, mintab.WithFormat(mintab.BacklogFormat))
+	}
+	data := make([][]any, len(entries))
+	for i, e := range entries {
+		data[i] = []any{e.Status, e.SerialNumber, e.CommonName, e.NotAfter, e.Hosts}
+	}
+	table := mintab.New(w, opts...)
+	if err := table.Load(mintab.Input{
+		Header: []string{"Status", "SerialNumber", "CommonName", "NotAfter", "Hosts"},
+		Data:   data,
+	}); err != nil {
+		return err
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parseInventoryCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []inventoryCert
+		wantErr bool
+	}{
+		{
+			name:    "no serial column",
+			input:   "Serial Number,CN,Expires\n0A:1B,www.example.com,2030-01-01\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:  "known columns",
+			input: "common_name, serial, not_after, owner\nwww.example.com, 0A:1B, 2030-01-01, web team\n",
+			want: []inventoryCert{
+				{SerialNumber: "0A:1B", CommonName: "www.example.com", NotAfter: "2030-01-01"},
+			},
+		},
+		{
+			name:  "serial only",
+			input: "SerialNumber\n0a1b\n3c4d\n",
+			want: []inventoryCert{
+				{SerialNumber: "0a1b"},
+				{SerialNumber: "3c4d"},
+			},
+		},
+		{
+			name:    "empty serial",
+			input:   "serial,cn\n,www.example.com\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInventoryCSV(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInventoryCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_loadInventory(t *testing.T) {
+	want := inventoryCert{SerialNumber: "0A:1B:2C", CommonName: "www.example.com", NotAfter: "2030-01-01T00:00:00Z"}
+	tests := []struct {
+		name    string
+		path    string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "csv",
+			path: filepath.Join("testdata", "inventory.csv"),
+			want: 2,
+		},
+		{
+			name: "json",
+			path: filepath.Join("testdata", "inventory.json"),
+			want: 1,
+		},
+		{
+			name:    "not an inventory",
+			path:    filepath.Join("testdata", "1.txt"),
+			wantErr: true,
+		},
+		{
+			name:    "missing",
+			path:    filepath.Join("testdata", "missing.csv"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadInventory(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadInventory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Fatalf("loadInventory() = %d entries, want %d", len(got), tt.want)
+			}
+			if len(got) > 0 && got[0] != want {
+				t.Errorf("loadInventory() = %+v, want %+v", got[0], want)
+			}
+		})
+	}
+}
+
+func Test_normalizeSerial(t *testing.T) {
+	tests := []struct {
+		serial string
+		want   string
+	}{
+		{serial: "0A:1B:2C", want: "A1B2C"},
+		{serial: "0a 1b 2c", want: "A1B2C"},
+		{serial: "0x0A1B2C", want: "A1B2C"},
+		{serial: "A1B2C", want: "A1B2C"},
+		{serial: "00", want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serial, func(t *testing.T) {
+			if got := normalizeSerial(tt.serial); got != tt.want {
+				t.Errorf("normalizeSerial() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reconcile(t *testing.T) {
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	inventory := []inventoryCert{
+		{SerialNumber: "0A:1B:2C", CommonName: "www.example.com", NotAfter: "2030-01-01"},
+		{SerialNumber: "3d4e5f", CommonName: "api.example.com", NotAfter: "2030-06-01"},
+	}
+	infos := []*certInfo{
+		{DomainName: "www1.example.com", AccessPort: "443", CommonName: "www.example.com", SerialNumber: "A1B2C", NotAfter: notAfter},
+		{DomainName: "www2.example.com", AccessPort: "443", CommonName: "www.example.com", SerialNumber: "A1B2C", NotAfter: notAfter},
+		{DomainName: "rogue.example.com", AccessPort: "8443", CommonName: "rogue.example.com", SerialNumber: "FF", NotAfter: notAfter},
+	}
+	want := []reconcileEntry{
+		{Status: inventoryDeployed, SerialNumber: "A1B2C", CommonName: "www.example.com", NotAfter: "2030-01-01T00:00:00Z", Hosts: []string{"www1.example.com:443", "www2.example.com:443"}},
+		{Status: inventoryNotDeployed, SerialNumber: "3D4E5F", CommonName: "api.example.com", NotAfter: "2030-06-01"},
+		{Status: inventoryUnknown, SerialNumber: "FF", CommonName: "rogue.example.com", NotAfter: "2030-01-01T00:00:00Z", Hosts: []string{"rogue.example.com:8443"}},
+	}
+	if diff := cmp.Diff(reconcile(inventory, infos), want); diff != "" {
+		t.Error(diff)
+	}
+}
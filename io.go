@@ -3,13 +3,16 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/nekrassov01/mintab"
 )
 
@@ -20,6 +23,14 @@ const (
 	formatTextTable
 	formatMarkdownTable
 	formatBacklogTable
+	formatNagios
+	formatPrometheus
+	formatNDJSON
+	formatJUnit
+	formatText
+	formatXLSX
+	formatCMDB
+	formatHistogram
 )
 
 var formats = []string{
@@ -27,6 +38,14 @@ var formats = []string{
 	"table",
 	"markdown",
 	"backlog",
+	"nagios",
+	"prometheus",
+	"ndjson",
+	"junit",
+	"text",
+	"xlsx",
+	"cmdb",
+	"histogram",
 }
 
 func (f format) String() string {
@@ -36,33 +55,48 @@ func (f format) String() string {
 	return ""
 }
 
-func fromList(fp string) ([]string, error) {
+// fromList reads the addresses of a newline-delimited list, or of a
+// structured list in CSV or TSV along with the options of each host.
+func fromList(fp string) ([]string, targets, error) {
 	if fp == "" {
-		return nil, errors.New("no file provided")
+		return nil, nil, errors.New("no file provided")
 	}
 	f, err := os.Open(filepath.Clean(fp))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer f.Close()
+	if comma := listComma(fp); comma != 0 {
+		addrs, ts, err := parseTargets(f, comma)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid list %s: %w", fp, err)
+		}
+		if len(addrs) == 0 {
+			return nil, nil, fmt.Errorf("no line provided: %s", fp)
+		}
+		if err := ts.loadExpected(filepath.Dir(fp)); err != nil {
+			return nil, nil, err
+		}
+		return addrs, ts, nil
+	}
 	scanner := bufio.NewScanner(f)
 	var lines []string
 	for scanner.Scan() {
 		line, err := checkLine(scanner.Text())
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if line != "" {
 			lines = append(lines, line)
 		}
 	}
 	if len(lines) == 0 {
-		return nil, fmt.Errorf("no line provided: %s", fp)
+		return nil, nil, fmt.Errorf("no line provided: %s", fp)
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return lines, nil
+	return lines, nil, nil
 }
 
 func checkLine(line string) (string, error) {
@@ -74,25 +108,74 @@ func checkLine(line string) (string, error) {
 	return line, nil
 }
 
-func out(infos []*certInfo, w io.Writer, format string, omit bool) error {
+func out(infos []*certInfo, w io.Writer, format string, omit bool, loc *locale, cols []string) error {
 	switch format {
 	case formatJSON.String():
-		return toJSON(infos, w)
+		v, err := selectFields(infos, cols)
+		if err != nil {
+			return err
+		}
+		return toJSON(v, w)
 	case formatTextTable.String(), formatMarkdownTable.String(), formatBacklogTable.String():
-		return toTable(infos, w, format, omit)
+		return toTable(infos, w, format, omit, loc, cols)
+	case formatNagios.String():
+		return toNagios(infos, w, -1, -1)
+	case formatPrometheus.String():
+		return toPrometheus(infos, w)
+	case formatNDJSON.String():
+		return toNDJSON(infos, w, cols)
+	case formatJUnit.String():
+		return toJUnit(infos, nil, w, -1, -1)
+	case formatText.String():
+		return toText(infos, w)
+	case formatXLSX.String():
+		return toXLSX(infos, w, omit, cols, -1, -1)
+	case formatCMDB.String():
+		m, err := loadCMDBMapping("")
+		if err != nil {
+			return err
+		}
+		return toCMDB(infos, w, m)
+	case formatHistogram.String():
+		return toHistogram(infos, w, false)
 	default:
 		return fmt.Errorf("invalid format: allowed values: %s", pipeJoin(formats))
 	}
 }
 
-func toJSON(infos []*certInfo, w io.Writer) error {
+func toJSON(v any, w io.Writer) error {
 	b := json.NewEncoder(w)
 	b.SetIndent("", "  ")
-	return b.Encode(infos)
+	return b.Encode(v)
 }
 
-func toTable(infos []*certInfo, w io.Writer, format string, omit bool) error {
-	opts := make([]mintab.Option, 0, 2)
+func toNDJSON(infos []*certInfo, w io.Writer, cols []string) error {
+	b := json.NewEncoder(w)
+	for _, info := range infos {
+		v, err := selectField(info, cols)
+		if err != nil {
+			return err
+		}
+		if err := b.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toPEM(infos []*certInfo, w io.Writer) error {
+	for _, info := range infos {
+		for _, cert := range info.chain {
+			if err := pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func toTable(infos []*certInfo, w io.Writer, format string, omit bool, loc *locale, cols []string) error {
+	opts := make([]mintab.Option, 0, 1)
 	switch format {
 	case formatTextTable.String():
 	case formatMarkdownTable.String():
@@ -100,43 +183,58 @@ func toTable(infos []*certInfo, w io.Writer, format string, omit bool) error {
 	case formatBacklogTable.String():
 		opts = append(opts, mintab.WithFormat(mintab.BacklogFormat))
 	}
-	if omit {
-		opts = append(opts, mintab.WithIgnoreFields([]int{8, 9}))
-	}
 	table := mintab.New(w, opts...)
-	if err := table.Load(toInput(infos)); err != nil {
+	if err := table.Load(selectColumns(toInput(infos, omit, loc), cols)); err != nil {
 		return err
 	}
 	table.Render()
 	return nil
 }
 
-func toInput(infos []*certInfo) mintab.Input {
-	header := []string{
-		"DomainName",
-		"AccessPort",
-		"IPAddresses",
-		"Issuer",
-		"CommonName",
-		"SANs",
-		"NotBefore",
-		"NotAfter",
-		"CurrentTime",
-		"DaysLeft",
+// setAmbiguousWidth sets whether ambiguous-width runes such as "①" occupy two
+// columns in tables. Under CJK locales they default to two, while most
+// terminals and browsers draw them in one, which shifts column borders.
+func setAmbiguousWidth(wide bool) {
+	runewidth.DefaultCondition.EastAsianWidth = wide
+}
+
+func toInput(infos []*certInfo, omit bool, loc *locale) mintab.Input {
+	var cols []column
+	for _, c := range columns {
+		if omit && c.timeInfo {
+			continue
+		}
+		if c.has != nil && !slices.ContainsFunc(infos, c.has) {
+			continue
+		}
+		cols = append(cols, c)
+	}
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.name
 	}
+	// computed fields follow in the order of definition
+	var computed []string
+	for _, info := range infos {
+		for _, v := range info.Fields {
+			if !slices.Contains(computed, v.Name) {
+				computed = append(computed, v.Name)
+			}
+		}
+	}
+	header = append(header, computed...)
 	data := make([][]any, len(infos))
 	for i, info := range infos {
-		data[i] = []any{
-			info.DomainName,
-			info.AccessPort,
-			info.IPAddresses,
-			info.Issuer,
-			info.CommonName,
-			info.SANs,
-			info.NotBefore,
-			info.NotAfter,
-			info.CurrentTime,
-			info.DaysLeft,
+		data[i] = make([]any, 0, len(header))
+		for _, c := range cols {
+			data[i] = append(data[i], c.cell(info, loc))
+		}
+		for _, name := range computed {
+			v := info.Field(name)
+			if v == nil {
+				v = ""
+			}
+			data[i] = append(data[i], v)
 		}
 	}
 	return mintab.Input{
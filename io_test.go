@@ -2,12 +2,17 @@ package main
 
 import (
 	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/mattn/go-runewidth"
 )
 
 var input = []*certInfo{
@@ -22,6 +27,13 @@ var input = []*certInfo{
 		NotAfter:    getTime("2025-01-01T09:00:00+09:00", time.Local),
 		CurrentTime: getTime("2024-01-01T09:00:00+09:00", time.Local),
 		DaysLeft:    365,
+
+		SerialNumber:       "1A2B3C",
+		SignatureAlgorithm: "SHA256-RSA",
+		PublicKeyAlgorithm: "RSA",
+		KeySize:            4096,
+		FingerprintSHA256:  "AB:CD:EF",
+		FingerprintSHA1:    "12:34:56",
 	},
 }
 
@@ -91,6 +103,22 @@ func Test_fromList(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 		},
+		{
+			name: "csv",
+			args: args{
+				fp: "testdata/targets.csv",
+			},
+			want:    []string{"localhost:8443", "localhost@127.0.0.1:8443", "mail.example.com"},
+			wantErr: false,
+		},
+		{
+			name: "tsv",
+			args: args{
+				fp: "testdata/targets.tsv",
+			},
+			want:    []string{"localhost@[::1]:8443"},
+			wantErr: false,
+		},
 		{
 			name: "no file provided",
 			args: args{
@@ -102,7 +130,7 @@ func Test_fromList(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := fromList(tt.args.fp)
+			got, _, err := fromList(tt.args.fp)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
 				return
@@ -144,7 +172,14 @@ func Test_out(t *testing.T) {
     "NotBefore": "2023-01-01T09:00:00+09:00",
     "NotAfter": "2025-01-01T09:00:00+09:00",
     "CurrentTime": "2024-01-01T09:00:00+09:00",
-    "DaysLeft": 365
+    "DaysLeft": 365,
+    "SerialNumber": "1A2B3C",
+    "SignatureAlgorithm": "SHA256-RSA",
+    "PublicKeyAlgorithm": "RSA",
+    "KeySize": 4096,
+    "FingerprintSHA256": "AB:CD:EF",
+    "FingerprintSHA1": "12:34:56",
+    "StapledOCSP": false
   }
 ]
 `,
@@ -157,11 +192,11 @@ func Test_out(t *testing.T) {
 				format: formatTextTable.String(),
 				omit:   false,
 			},
-			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+
-| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+
+			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
 `,
 			wantErr: false,
 		},
@@ -172,9 +207,9 @@ func Test_out(t *testing.T) {
 				format: formatMarkdownTable.String(),
 				omit:   false,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft |
-|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|-------------------------------|----------|
-| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
+|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|-------------------------------|----------|--------------|--------------------|--------------------|---------|-------------------|-----------------|-------------|------------|----------------|
+| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | \-         | \-             |
 `,
 			wantErr: false,
 		},
@@ -185,8 +220,8 @@ func Test_out(t *testing.T) {
 				format: formatBacklogTable.String(),
 				omit:   false,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft |h
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |h
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
 `,
 			wantErr: false,
 		},
@@ -208,9 +243,51 @@ func Test_out(t *testing.T) {
     "NotBefore": "2023-01-01T09:00:00+09:00",
     "NotAfter": "2025-01-01T09:00:00+09:00",
     "CurrentTime": "2024-01-01T09:00:00+09:00",
-    "DaysLeft": 365
+    "DaysLeft": 365,
+    "SerialNumber": "1A2B3C",
+    "SignatureAlgorithm": "SHA256-RSA",
+    "PublicKeyAlgorithm": "RSA",
+    "KeySize": 4096,
+    "FingerprintSHA256": "AB:CD:EF",
+    "FingerprintSHA1": "12:34:56",
+    "StapledOCSP": false
   }
 ]
+`,
+			wantErr: false,
+		},
+		{
+			name: "markdown+hosts",
+			args: args{
+				input: []*certInfo{
+					{
+						DomainName:  host,
+						AccessPort:  port,
+						IPAddresses: []net.IP{},
+						Issuer:      "CN=local test CA",
+						CommonName:  "local test CA",
+						SANs:        []string{},
+						NotBefore:   getTime("2023-01-01T09:00:00+09:00", time.Local),
+						NotAfter:    getTime("2025-01-01T09:00:00+09:00", time.Local),
+						CurrentTime: getTime("2024-01-01T09:00:00+09:00", time.Local),
+						DaysLeft:    365,
+
+						SerialNumber:       "1A2B3C",
+						SignatureAlgorithm: "SHA256-RSA",
+						PublicKeyAlgorithm: "RSA",
+						KeySize:            4096,
+						FingerprintSHA256:  "AB:CD:EF",
+						FingerprintSHA1:    "12:34:56",
+
+						Hosts: []string{addr, "127.0.0.1:8443"},
+					},
+				},
+				format: formatMarkdownTable.String(),
+				omit:   true,
+			},
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate | Hosts                            |
+|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|--------------|--------------------|--------------------|---------|-------------------|-----------------|-------------|------------|----------------|----------------------------------|
+| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | \-         | \-             | localhost:8443<br>127.0.0.1:8443 |
 `,
 			wantErr: false,
 		},
@@ -221,11 +298,11 @@ func Test_out(t *testing.T) {
 				format: formatTextTable.String(),
 				omit:   true,
 			},
-			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+
-| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+
+			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
 `,
 			wantErr: false,
 		},
@@ -236,9 +313,9 @@ func Test_out(t *testing.T) {
 				format: formatMarkdownTable.String(),
 				omit:   true,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      |
-|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|
-| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
+|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|--------------|--------------------|--------------------|---------|-------------------|-----------------|-------------|------------|----------------|
+| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | \-         | \-             |
 `,
 			wantErr: false,
 		},
@@ -249,8 +326,39 @@ func Test_out(t *testing.T) {
 				format: formatBacklogTable.String(),
 				omit:   true,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      |h
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |h
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
+`,
+			wantErr: false,
+		},
+		{
+			name: "prometheus",
+			args: args{
+				input:  input,
+				format: formatPrometheus.String(),
+				omit:   false,
+			},
+			want: `# HELP tlc3_cert_not_before_timestamp_seconds Start of the validity period of the cert.
+# TYPE tlc3_cert_not_before_timestamp_seconds gauge
+tlc3_cert_not_before_timestamp_seconds{host="localhost:8443",common_name="local test CA",issuer="CN=local test CA",serial_number="1A2B3C"} 1672531200
+# HELP tlc3_cert_not_after_timestamp_seconds End of the validity period of the cert.
+# TYPE tlc3_cert_not_after_timestamp_seconds gauge
+tlc3_cert_not_after_timestamp_seconds{host="localhost:8443",common_name="local test CA",issuer="CN=local test CA",serial_number="1A2B3C"} 1735689600
+# HELP tlc3_cert_days_left Days until the cert expires.
+# TYPE tlc3_cert_days_left gauge
+tlc3_cert_days_left{host="localhost:8443",common_name="local test CA",issuer="CN=local test CA",serial_number="1A2B3C"} 365
+`,
+			wantErr: false,
+		},
+		{
+			name: "ndjson",
+			args: args{
+				input:  append(input, input...),
+				format: formatNDJSON.String(),
+				omit:   false,
+			},
+			want: `{"DomainName":"localhost","AccessPort":"8443","IPAddresses":[],"Issuer":"CN=local test CA","CommonName":"local test CA","SANs":[],"NotBefore":"2023-01-01T09:00:00+09:00","NotAfter":"2025-01-01T09:00:00+09:00","CurrentTime":"2024-01-01T09:00:00+09:00","DaysLeft":365,"SerialNumber":"1A2B3C","SignatureAlgorithm":"SHA256-RSA","PublicKeyAlgorithm":"RSA","KeySize":4096,"FingerprintSHA256":"AB:CD:EF","FingerprintSHA1":"12:34:56","StapledOCSP":false}
+{"DomainName":"localhost","AccessPort":"8443","IPAddresses":[],"Issuer":"CN=local test CA","CommonName":"local test CA","SANs":[],"NotBefore":"2023-01-01T09:00:00+09:00","NotAfter":"2025-01-01T09:00:00+09:00","CurrentTime":"2024-01-01T09:00:00+09:00","DaysLeft":365,"SerialNumber":"1A2B3C","SignatureAlgorithm":"SHA256-RSA","PublicKeyAlgorithm":"RSA","KeySize":4096,"FingerprintSHA256":"AB:CD:EF","FingerprintSHA1":"12:34:56","StapledOCSP":false}
 `,
 			wantErr: false,
 		},
@@ -278,7 +386,7 @@ func Test_out(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			output := &bytes.Buffer{}
-			if err := out(tt.args.input, output, tt.args.format, tt.args.omit); (err != nil) != tt.wantErr {
+			if err := out(tt.args.input, output, tt.args.format, tt.args.omit, nil, nil); (err != nil) != tt.wantErr {
 				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
 				return
 			}
@@ -315,7 +423,14 @@ func Test_toJSON(t *testing.T) {
     "NotBefore": "2023-01-01T09:00:00+09:00",
     "NotAfter": "2025-01-01T09:00:00+09:00",
     "CurrentTime": "2024-01-01T09:00:00+09:00",
-    "DaysLeft": 365
+    "DaysLeft": 365,
+    "SerialNumber": "1A2B3C",
+    "SignatureAlgorithm": "SHA256-RSA",
+    "PublicKeyAlgorithm": "RSA",
+    "KeySize": 4096,
+    "FingerprintSHA256": "AB:CD:EF",
+    "FingerprintSHA1": "12:34:56",
+    "StapledOCSP": false
   }
 ]
 `,
@@ -340,10 +455,13 @@ func Test_toJSON(t *testing.T) {
 }
 
 func Test_toTable(t *testing.T) {
+	versions := *input[0]
+	versions.TLSVersions = []string{"TLS 1.2", "TLS 1.3"}
 	type args struct {
 		input  []*certInfo
 		format string
 		omit   bool
+		loc    *locale
 	}
 	tests := []struct {
 		name    string
@@ -358,11 +476,11 @@ func Test_toTable(t *testing.T) {
 				format: formatTextTable.String(),
 				omit:   false,
 			},
-			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+
-| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+
+			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+-------------------------------+----------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
 `,
 			wantErr: false,
 		},
@@ -373,9 +491,9 @@ func Test_toTable(t *testing.T) {
 				format: formatMarkdownTable.String(),
 				omit:   false,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft |
-|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|-------------------------------|----------|
-| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
+|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|-------------------------------|----------|--------------|--------------------|--------------------|---------|-------------------|-----------------|-------------|------------|----------------|
+| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | \-         | \-             |
 `,
 			wantErr: false,
 		},
@@ -386,8 +504,8 @@ func Test_toTable(t *testing.T) {
 				format: formatBacklogTable.String(),
 				omit:   false,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft |h
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | CurrentTime                   | DaysLeft | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |h
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 2024-01-01 09:00:00 +0900 JST |      365 | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
 `,
 			wantErr: false,
 		},
@@ -398,11 +516,11 @@ func Test_toTable(t *testing.T) {
 				format: formatTextTable.String(),
 				omit:   true,
 			},
-			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+
-| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST |
-+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+
+			want: `+------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
++------------+------------+-------------+------------------+---------------+------+-------------------------------+-------------------------------+--------------+--------------------+--------------------+---------+-------------------+-----------------+-------------+------------+----------------+
 `,
 			wantErr: false,
 		},
@@ -413,9 +531,9 @@ func Test_toTable(t *testing.T) {
 				format: formatMarkdownTable.String(),
 				omit:   true,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      |
-|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|
-| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
+|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|--------------|--------------------|--------------------|---------|-------------------|-----------------|-------------|------------|----------------|
+| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | \-         | \-             |
 `,
 			wantErr: false,
 		},
@@ -426,8 +544,35 @@ func Test_toTable(t *testing.T) {
 				format: formatBacklogTable.String(),
 				omit:   true,
 			},
-			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      |h
-| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST |
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |h
+| localhost  |       8443 | -           | CN=local test CA | local test CA | -    | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | -          | -              |
+`,
+			wantErr: false,
+		},
+		{
+			name: "markdown+locale",
+			args: args{
+				input:  input,
+				format: formatMarkdownTable.String(),
+				omit:   false,
+				loc:    locales["en-US"],
+			},
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                  | NotAfter                   | CurrentTime                | DaysLeft | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate |
+|------------|------------|-------------|------------------|---------------|------|----------------------------|----------------------------|----------------------------|----------|--------------|--------------------|--------------------|---------|-------------------|-----------------|-------------|------------|----------------|
+| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | Jan 1, 2023 9:00:00 AM JST | Jan 1, 2025 9:00:00 AM JST | Jan 1, 2024 9:00:00 AM JST |      365 | 1A2B3C       | SHA256-RSA         | RSA                | 4,096   | AB:CD:EF          | 12:34:56        | false       | \-         | \-             |
+`,
+			wantErr: false,
+		},
+		{
+			name: "markdown+versions",
+			args: args{
+				input:  []*certInfo{&versions},
+				format: formatMarkdownTable.String(),
+				omit:   true,
+			},
+			want: `| DomainName | AccessPort | IPAddresses | Issuer           | CommonName    | SANs | NotBefore                     | NotAfter                      | SerialNumber | SignatureAlgorithm | PublicKeyAlgorithm | KeySize | FingerprintSHA256 | FingerprintSHA1 | StapledOCSP | OCSPStatus | OCSPNextUpdate | TLSVersions        |
+|------------|------------|-------------|------------------|---------------|------|-------------------------------|-------------------------------|--------------|--------------------|--------------------|---------|-------------------|-----------------|-------------|------------|----------------|--------------------|
+| localhost  |       8443 | \-          | CN=local test CA | local test CA | \-   | 2023-01-01 09:00:00 +0900 JST | 2025-01-01 09:00:00 +0900 JST | 1A2B3C       | SHA256-RSA         | RSA                |    4096 | AB:CD:EF          | 12:34:56        | false       | \-         | \-             | TLS 1.2<br>TLS 1.3 |
 `,
 			wantErr: false,
 		},
@@ -435,7 +580,7 @@ func Test_toTable(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			output := &bytes.Buffer{}
-			if err := toTable(tt.args.input, output, tt.args.format, tt.args.omit); (err != nil) != tt.wantErr {
+			if err := toTable(tt.args.input, output, tt.args.format, tt.args.omit, tt.args.loc, nil); (err != nil) != tt.wantErr {
 				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
 				return
 			}
@@ -445,3 +590,69 @@ func Test_toTable(t *testing.T) {
 		})
 	}
 }
+
+func Test_toPEM(t *testing.T) {
+	leaf := &x509.Certificate{Raw: []byte("leaf")}
+	inter := &x509.Certificate{Raw: []byte("intermediate")}
+	infos := []*certInfo{
+		{DomainName: "a.example.com", chain: []*x509.Certificate{leaf, inter}},
+		{DomainName: "b.example.com", chain: []*x509.Certificate{leaf}},
+	}
+	w := &bytes.Buffer{}
+	if err := toPEM(infos, w); err != nil {
+		t.Fatal(err)
+	}
+	var got [][]byte
+	rest := w.Bytes()
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			t.Errorf("Type = %v, want CERTIFICATE", block.Type)
+		}
+		got = append(got, block.Bytes)
+	}
+	want := [][]byte{[]byte("leaf"), []byte("intermediate"), []byte("leaf")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %q, want %q", got, want)
+	}
+}
+
+func Test_toTable_eastAsianWidth(t *testing.T) {
+	wide := []*certInfo{
+		{
+			DomainName:  "intra.example.jp",
+			AccessPort:  "443",
+			IPAddresses: []net.IP{net.ParseIP("192.0.2.1")},
+			Issuer:      "CN=社内認証局①,O=株式会社テスト",
+			CommonName:  "社内ポータル",
+			SANs:        []string{"intra.example.jp"},
+			NotBefore:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		input[0],
+	}
+	for _, ambiguous := range []bool{false, true} {
+		for _, format := range []string{formatTextTable.String(), formatMarkdownTable.String()} {
+			t.Run(fmt.Sprintf("%s/wide=%t", format, ambiguous), func(t *testing.T) {
+				setAmbiguousWidth(ambiguous)
+				defer setAmbiguousWidth(false)
+				output := &bytes.Buffer{}
+				if err := toTable(wide, output, format, true, locales["ja-JP"], nil); err != nil {
+					t.Fatal(err)
+				}
+				lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+				want := runewidth.StringWidth(lines[0])
+				for _, line := range lines[1:] {
+					if got := runewidth.StringWidth(line); got != want {
+						t.Errorf("line width = %d, want %d:\n%s", got, want, output.String())
+						break
+					}
+				}
+			})
+		}
+	}
+}
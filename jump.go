@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// identity files tried after the keys of the agent, as ssh does
+var sshIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// dialSSH connects to addr through the SSH jump host of r, as ssh -J does.
+// The client authenticates with the keys of the SSH agent and the identity
+// files without a passphrase in ~/.ssh, and the host key of the jump host
+// must be in ~/.ssh/known_hosts. Each connection has an SSH session of its
+// own, closed along with it.
+func dialSSH(ctx context.Context, r route, addr string) (net.Conn, error) {
+	config, done, err := sshClientConfig(r.user)
+	if err != nil {
+		return nil, fmt.Errorf("via %s: %w", r, err)
+	}
+	defer done()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("via %s: %w", r, err)
+	}
+	release := bindContext(ctx, conn)
+	c, chans, reqs, err := ssh.NewClientConn(conn, r.addr, config)
+	release()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("via %s: %w", r, contextErr(ctx, err))
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	tunnel, err := client.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("via %s: %w", r, err)
+	}
+	return &sshConn{Conn: tunnel, client: client}, nil
+}
+
+// sshClientConfig returns the config to log in as name, and a func that
+// releases the agent, which signs through its connection, once logged in.
+func sshClientConfig(name string) (*ssh.ClientConfig, func(), error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := filepath.Join(home, ".ssh")
+	hostKeys, err := knownhosts.New(filepath.Join(dir, "known_hosts"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load known hosts: %w", err)
+	}
+	done := func() {}
+	var signers []ssh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			done = func() { conn.Close() }
+			if keys, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, keys...)
+			}
+		}
+	}
+	for _, file := range sshIdentityFiles {
+		b, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(b); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) == 0 {
+		done()
+		return nil, nil, errors.New("no SSH key found in the agent or ~/.ssh")
+	}
+	config := &ssh.ClientConfig{
+		User:            name,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostKeys,
+	}
+	return config, done, nil
+}
+
+// sshConn is a connection tunneled through a jump host, whose SSH session is
+// closed with it.
+type sshConn struct {
+	net.Conn
+	client *ssh.Client
+}
+
+func (c *sshConn) Close() error {
+	err := c.Conn.Close()
+	if cerr := c.client.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startTestJumpHost starts an SSH server that forwards direct-tcpip channels
+// as a jump host does, and sets up HOME with a key it accepts and its host
+// key. It returns the listening address.
+func startTestJumpHost(t *testing.T) string {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestJumpHost(conn, config)
+		}
+	}()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	dir := filepath.Join(home, ".ssh")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "id_ed25519"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	line := knownhosts.Line([]string{knownhosts.Normalize(ln.Addr().String())}, hostSigner.PublicKey())
+	if err := os.WriteFile(filepath.Join(dir, "known_hosts"), []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ln.Addr().String()
+}
+
+func serveTestJumpHost(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for ch := range chans {
+		if ch.ChannelType() != "direct-tcpip" {
+			_ = ch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var payload struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(ch.ExtraData(), &payload); err != nil {
+			_ = ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			_ = ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := ch.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			defer upstream.Close()
+			defer channel.Close()
+			go func() { _, _ = io.Copy(upstream, channel) }()
+			_, _ = io.Copy(channel, upstream)
+		}()
+	}
+}
+
+func Test_getCertList_jump(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	jump := startTestJumpHost(t)
+	connCache.purge()
+	r := route{kind: routeSSH, addr: jump, user: "ops"}
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+		targets: targets{
+			ln.Addr().String(): {routes: []route{r}},
+		},
+	}
+	infos, err := getCertList(context.Background(), []string{ln.Addr().String()}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SSH ops@" + jump; infos[0].Proxy != want {
+		t.Errorf("Proxy = %q, want %q", infos[0].Proxy, want)
+	}
+}
+
+func Test_dialSSH_unknownHost(t *testing.T) {
+	jump := startTestJumpHost(t)
+	// a jump host missing from known_hosts is refused
+	if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := dialSSH(ctx, route{kind: routeSSH, addr: jump, user: "ops"}, "127.0.0.1:443"); err == nil {
+		t.Error("dialSSH() error = nil, want a host key error")
+	}
+}
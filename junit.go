@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// toJUnit writes a JUnit XML report with a test case per host, so that CI
+// servers show expiring certs in their test results. A case fails when the
+// host cannot be checked, when its chain does not verify, or when its cert has
+// fewer days left than the warning or critical days. It returns the error
+// that sets the exit code, as with the other outputs.
+func toJUnit(infos []*certInfo, failed []hostFailure, w io.Writer, warn, crit int) error {
+	suite := junitTestSuite{
+		Name:      appName,
+		Timestamp: time.Now().UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, f := range failed {
+		// a throttled host is up, so it is told apart from an outage
+		typ := "error"
+		var throttled *throttledError
+		if errors.As(f.err, &throttled) {
+			typ = "throttled"
+		}
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      f.addr,
+			ClassName: appName,
+			Failure: &junitFailure{
+				Message: f.err.Error(),
+				Type:    typ,
+				Text:    f.err.Error(),
+			},
+		})
+	}
+	for _, info := range infos {
+		tc := junitTestCase{
+			Name:      net.JoinHostPort(info.DomainName, info.AccessPort),
+			ClassName: appName,
+			SystemOut: fmt.Sprintf("issuer: %s\nnot after: %s\ndays left: %d\n", info.Issuer, info.NotAfter.Format(time.RFC3339), info.DaysLeft),
+		}
+		if unverified(info) {
+			msg := "cert fails verification: " + info.VerifyError
+			tc.Failure = &junitFailure{Message: msg, Type: "verification", Text: msg}
+		}
+		for _, t := range []struct {
+			level string
+			days  int
+		}{{"critical", crit}, {"warning", warn}} {
+			if tc.Failure == nil && t.days >= 0 && info.DaysLeft < t.days {
+				msg := fmt.Sprintf("cert expires in %d days, within %d days", info.DaysLeft, t.days)
+				tc.Failure = &junitFailure{Message: msg, Type: t.level, Text: msg}
+				break
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	slices.SortStableFunc(suite.Cases, func(a, b junitTestCase) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	suite.Tests = len(suite.Cases)
+	for _, tc := range suite.Cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+	report := junitTestSuites{
+		Name:     appName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot check %d host(s)", len(failed))
+	}
+	if n := unverifiedCount(infos); n > 0 {
+		return &verificationError{count: n}
+	}
+	return checkThresholds(infos, warn, crit)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func Test_toJUnit(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "b.example.com", AccessPort: "8443", DaysLeft: 20},
+		{DomainName: "a.example.com", AccessPort: "443", DaysLeft: 90},
+		{DomainName: "c.example.com", AccessPort: "443", DaysLeft: 5},
+	}
+	failed := []hostFailure{
+		{addr: "bad.example.com:443", err: errors.New("x509: certificate signed by unknown authority")},
+	}
+	type result struct {
+		name    string
+		failure string
+	}
+	tests := []struct {
+		name     string
+		failed   []hostFailure
+		warn     int
+		crit     int
+		want     []result
+		wantErr  bool
+		wantCode int
+	}{
+		{
+			name: "ok",
+			warn: -1,
+			crit: -1,
+			want: []result{
+				{name: "a.example.com:443"},
+				{name: "b.example.com:8443"},
+				{name: "c.example.com:443"},
+			},
+		},
+		{
+			name: "warning and critical",
+			warn: 30,
+			crit: 7,
+			want: []result{
+				{name: "a.example.com:443"},
+				{name: "b.example.com:8443", failure: "warning"},
+				{name: "c.example.com:443", failure: "critical"},
+			},
+			wantErr:  true,
+			wantCode: exitCritical,
+		},
+		{
+			name:   "cannot be checked",
+			failed: failed,
+			warn:   30,
+			crit:   -1,
+			want: []result{
+				{name: "a.example.com:443"},
+				{name: "b.example.com:8443", failure: "warning"},
+				{name: "bad.example.com:443", failure: "error"},
+				{name: "c.example.com:443", failure: "warning"},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "throttled",
+			failed: []hostFailure{{addr: "waf.example.com:443", err: &throttledError{attempts: 3, err: syscall.ECONNRESET}}},
+			warn:   -1,
+			crit:   -1,
+			want: []result{
+				{name: "a.example.com:443"},
+				{name: "b.example.com:8443"},
+				{name: "c.example.com:443"},
+				{name: "waf.example.com:443", failure: "throttled"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := toJUnit(infos, tt.failed, w, tt.warn, tt.crit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("toJUnit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantCode != 0 {
+				var te *thresholdError
+				if !errors.As(err, &te) || te.code != tt.wantCode {
+					t.Errorf("toJUnit() error = %v, want code %d", err, tt.wantCode)
+				}
+			}
+			if !strings.HasPrefix(w.String(), xml.Header) {
+				t.Errorf("toJUnit() output has no XML header: %q", w.String())
+			}
+			var report junitTestSuites
+			if err := xml.Unmarshal(w.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Suites) != 1 {
+				t.Fatalf("suites = %d, want 1", len(report.Suites))
+			}
+			var got []result
+			failures := 0
+			for _, tc := range report.Suites[0].Cases {
+				r := result{name: tc.Name}
+				if tc.Failure != nil {
+					r.failure = tc.Failure.Type
+					failures++
+				}
+				got = append(got, r)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cases = %v, want %v", got, tt.want)
+			}
+			if report.Tests != len(tt.want) || report.Suites[0].Tests != len(tt.want) {
+				t.Errorf("tests = %d, want %d", report.Tests, len(tt.want))
+			}
+			if report.Failures != failures || report.Suites[0].Failures != failures {
+				t.Errorf("failures = %d, want %d", report.Failures, failures)
+			}
+		})
+	}
+}
+
+func Test_toJUnit_unverified(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "443", DaysLeft: 90, VerificationStatus: verificationFailed, VerifyError: "x509: certificate signed by unknown authority"},
+		{DomainName: "b.example.com", AccessPort: "443", DaysLeft: 90, VerificationStatus: verificationFailed, insecure: true},
+	}
+	var buf bytes.Buffer
+	err := toJUnit(infos, nil, &buf, -1, -1)
+	var ve *verificationError
+	if !errors.As(err, &ve) || ve.count != 1 {
+		t.Fatalf("toJUnit() error = %v, want verificationError", err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	cases := report.Suites[0].Cases
+	if cases[0].Failure == nil || cases[0].Failure.Type != "verification" {
+		t.Errorf("failure of %s = %v, want verification", cases[0].Name, cases[0].Failure)
+	}
+	if cases[1].Failure != nil {
+		t.Errorf("failure of %s = %v, want none", cases[1].Name, cases[1].Failure)
+	}
+}
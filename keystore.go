@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1" // #nosec G505
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/crypto/pkcs12"
+)
+
+const (
+	jksMagic   = 0xFEEDFEED
+	jceksMagic = 0xCECECECE
+
+	jksPrivateKey  = 1
+	jksTrustedCert = 2
+	jksSecretKey   = 3
+
+	// jksWhitener is mixed into the integrity digest of Java keystores
+	jksWhitener = "Mighty Aphrodite"
+)
+
+// pkcs12Exts are the extensions of PKCS#12 files, which cannot be told from
+// other DER by their content.
+var pkcs12Exts = []string{".p12", ".pfx"}
+
+// errKeystorePassword is returned for a keystore whose password is wrong.
+var errKeystorePassword = errors.New("keystore password was incorrect")
+
+// keystoreEntry is a cert in a keystore with its chain, leaf first, named
+// after its alias.
+type keystoreEntry struct {
+	alias string
+	chain []*x509.Certificate
+}
+
+// isJKS reports whether b is a Java keystore in JKS or JCEKS.
+func isJKS(b []byte) bool {
+	if len(b) < 4 {
+		return false
+	}
+	magic := binary.BigEndian.Uint32(b)
+	return magic == jksMagic || magic == jceksMagic
+}
+
+// isPKCS12 reports whether the file at path is a PKCS#12 file.
+func isPKCS12(path string) bool {
+	return slices.Contains(pkcs12Exts, strings.ToLower(filepath.Ext(path)))
+}
+
+// readPKCS12 returns an entry per private key in b, with the cert of the key
+// and the chain built from the other certs, or an entry per cert when no cert
+// has a key, as in a trust store. The legacy encryption of PKCS#12, with 3DES
+// or RC2, is read with x/crypto/pkcs12, and PBES2 with AES, the default of
+// keytool since JDK 18 and of OpenSSL 3, by decodePKCS12.
+func readPKCS12(b []byte, password string) ([]keystoreEntry, error) {
+	bags, keyIDs, err := readLegacyPKCS12(b, password)
+	var notImplemented pkcs12.NotImplementedError
+	if errors.As(err, &notImplemented) {
+		bags, keyIDs, err = decodePKCS12(b, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	hasKey := func(b pkcs12Bag) bool {
+		return b.keyID != "" && slices.Contains(keyIDs, b.keyID)
+	}
+	var (
+		entries []keystoreEntry
+		pool    []*x509.Certificate
+	)
+	for _, bag := range bags {
+		if !hasKey(bag) {
+			pool = append(pool, bag.cert)
+		}
+	}
+	for _, bag := range bags {
+		if hasKey(bag) {
+			entries = append(entries, keystoreEntry{alias: bag.name, chain: buildChain(bag.cert, pool)})
+		}
+	}
+	if len(entries) == 0 {
+		for _, bag := range bags {
+			entries = append(entries, keystoreEntry{alias: bag.name, chain: []*x509.Certificate{bag.cert}})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no cert in PKCS#12 data")
+	}
+	return entries, nil
+}
+
+// readLegacyPKCS12 returns the certs of the PKCS#12 data in b with the legacy
+// encryption, and the local key IDs of its private keys.
+func readLegacyPKCS12(b []byte, password string) ([]pkcs12Bag, []string, error) {
+	blocks, err := pkcs12.ToPEM(b, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, nil, errKeystorePassword
+		}
+		return nil, nil, err
+	}
+	var (
+		bags   []pkcs12Bag
+		keyIDs []string
+	)
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			bags = append(bags, pkcs12Bag{cert: cert, name: block.Headers["friendlyName"], keyID: block.Headers["localKeyId"]})
+		case "PRIVATE KEY":
+			keyIDs = append(keyIDs, block.Headers["localKeyId"])
+		}
+	}
+	return bags, keyIDs, nil
+}
+
+// buildChain returns leaf followed by its issuers found in pool.
+func buildChain(leaf *x509.Certificate, pool []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{leaf}
+	for cert := leaf; !bytes.Equal(cert.RawIssuer, cert.RawSubject); {
+		i := slices.IndexFunc(pool, func(c *x509.Certificate) bool {
+			return bytes.Equal(c.RawSubject, cert.RawIssuer) && !slices.Contains(chain, c)
+		})
+		if i < 0 {
+			break
+		}
+		cert = pool[i]
+		chain = append(chain, cert)
+	}
+	return chain
+}
+
+// readJKS returns the entries of the Java keystore in b, the chain of a
+// private key entry or the cert of a trusted cert entry. The private keys are
+// not decrypted, so the password is only used to check the integrity of the
+// keystore, which is not checked without it. The secret key entries of JCEKS
+// hold no cert, so they are skipped with a warning.
+func readJKS(b []byte, password string) ([]keystoreEntry, error) {
+	if len(b) < sha1.Size {
+		return nil, errors.New("truncated keystore")
+	}
+	data, digest := b[:len(b)-sha1.Size], b[len(b)-sha1.Size:]
+	r := &jksReader{b: data}
+	magic := r.uint32()
+	if r.err == nil && magic != jksMagic && magic != jceksMagic {
+		return nil, fmt.Errorf("not a Java keystore: magic %#x", magic)
+	}
+	if password != "" && !bytes.Equal(jksDigest(data, password), digest) {
+		return nil, errKeystorePassword
+	}
+	version := r.uint32()
+	if r.err == nil && version != 1 && version != 2 {
+		return nil, fmt.Errorf("unsupported keystore version %d", version)
+	}
+	count := r.uint32()
+	var entries []keystoreEntry
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		tag := r.uint32()
+		alias := r.utf()
+		r.next(8) // creation time
+		switch {
+		case tag == jksPrivateKey:
+			r.next(int(r.uint32())) // encrypted key
+			n := r.uint32()
+			var chain []*x509.Certificate
+			for j := uint32(0); j < n && r.err == nil; j++ {
+				if cert := r.cert(version); cert != nil {
+					chain = append(chain, cert)
+				}
+			}
+			if r.err == nil && len(chain) > 0 {
+				entries = append(entries, keystoreEntry{alias: alias, chain: chain})
+			}
+		case tag == jksTrustedCert:
+			if cert := r.cert(version); cert != nil {
+				entries = append(entries, keystoreEntry{alias: alias, chain: []*x509.Certificate{cert}})
+			}
+		case tag == jksSecretKey && magic == jceksMagic:
+			r.skipSealedKey()
+			if r.err == nil {
+				log.Warn("skipping secret key entry, which holds no cert", "alias", alias)
+			}
+		default:
+			return nil, fmt.Errorf("unknown keystore entry type %d", tag)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no cert in keystore")
+	}
+	return entries, nil
+}
+
+// jksDigest returns the integrity digest of a Java keystore with data and
+// password.
+func jksDigest(data []byte, password string) []byte {
+	h := sha1.New() // #nosec G401
+	for _, c := range utf16.Encode([]rune(password)) {
+		h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	h.Write([]byte(jksWhitener))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// jksReader reads the big-endian fields of a Java keystore, keeping the first
+// error so that the fields can be read in a row.
+type jksReader struct {
+	b   []byte
+	err error
+}
+
+func (r *jksReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errors.New("truncated keystore")
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *jksReader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *jksReader) uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *jksReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *jksReader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// utf reads a string prefixed by its length in two bytes, as written by
+// DataOutput.writeUTF.
+func (r *jksReader) utf() string {
+	n := r.uint16()
+	if r.err != nil {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+func (r *jksReader) cert(version uint32) *x509.Certificate {
+	if version == 2 {
+		if typ := r.utf(); r.err == nil && typ != "X.509" {
+			r.err = fmt.Errorf("unsupported cert type %q", typ)
+			return nil
+		}
+	}
+	der := r.next(int(r.uint32()))
+	if r.err != nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return cert
+}
+
+// codes of the Java serialization stream, in which JCEKS writes the sealed
+// key of a secret key entry without its length
+const (
+	javaStreamMagic   = 0xACED
+	javaStreamVersion = 5
+	javaBaseHandle    = 0x7E0000
+	// javaMaxDepth bounds the nesting of the objects read from a stream
+	javaMaxDepth = 32
+
+	tcNull           = 0x70
+	tcReference      = 0x71
+	tcClassDesc      = 0x72
+	tcObject         = 0x73
+	tcString         = 0x74
+	tcArray          = 0x75
+	tcBlockData      = 0x77
+	tcEndBlockData   = 0x78
+	tcReset          = 0x79
+	tcBlockDataLong  = 0x7A
+	tcLongString     = 0x7C
+	tcProxyClassDesc = 0x7D
+	tcEnum           = 0x7E
+
+	scWriteMethod    = 0x01
+	scSerializable   = 0x02
+	scExternalizable = 0x04
+	scBlockData      = 0x08
+)
+
+// javaClass is the description of a class in a Java serialization stream,
+// with the type code of each of its fields.
+type javaClass struct {
+	name   string
+	flags  byte
+	fields []byte
+	super  *javaClass
+}
+
+// javaEndBlock marks the end of the block data of an object.
+type javaEndBlock struct{}
+
+// javaStream reads past the objects of a Java serialization stream, keeping
+// the class descriptions that later ones refer to by handle.
+type javaStream struct {
+	r       *jksReader
+	handles []*javaClass
+}
+
+// skipSealedKey reads past the sealed key of a JCEKS secret key entry, a
+// SealedObject written by an ObjectOutputStream of its own.
+func (r *jksReader) skipSealedKey() {
+	if magic, version := r.uint16(), r.uint16(); r.err == nil && (magic != javaStreamMagic || version != javaStreamVersion) {
+		r.err = errors.New("invalid secret key entry")
+		return
+	}
+	s := &javaStream{r: r}
+	s.content(0)
+}
+
+func (s *javaStream) fail(msg string) {
+	if s.r.err == nil {
+		s.r.err = fmt.Errorf("invalid secret key entry: %s", msg)
+	}
+}
+
+// content reads the next item of the stream, returning the description of a
+// class, javaEndBlock, or nil for anything else.
+func (s *javaStream) content(depth int) any {
+	r := s.r
+	if depth > javaMaxDepth {
+		s.fail("objects nested too deep")
+		return nil
+	}
+	tc := r.byte()
+	if r.err != nil {
+		return nil
+	}
+	switch tc {
+	case tcNull:
+	case tcReset:
+		s.handles = nil
+	case tcReference:
+		h := int(r.uint32()) - javaBaseHandle
+		if r.err != nil {
+			return nil
+		}
+		if h < 0 || h >= len(s.handles) {
+			s.fail("invalid handle")
+			return nil
+		}
+		if c := s.handles[h]; c != nil {
+			return c
+		}
+	case tcClassDesc:
+		c := &javaClass{name: r.utf()}
+		r.next(8) // serialVersionUID
+		s.handles = append(s.handles, c)
+		c.flags = r.byte()
+		n := r.uint16()
+		for i := uint16(0); i < n && r.err == nil; i++ {
+			code := r.byte()
+			r.utf()
+			if code == '[' || code == 'L' {
+				s.content(depth + 1) // class name of the field
+			}
+			c.fields = append(c.fields, code)
+		}
+		s.annotation(depth)
+		c.super, _ = s.content(depth + 1).(*javaClass)
+		return c
+	case tcProxyClassDesc:
+		c := &javaClass{}
+		s.handles = append(s.handles, c)
+		n := r.uint32()
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			r.utf()
+		}
+		s.annotation(depth)
+		c.super, _ = s.content(depth + 1).(*javaClass)
+		return c
+	case tcString:
+		s.handles = append(s.handles, nil)
+		r.utf()
+	case tcLongString:
+		s.handles = append(s.handles, nil)
+		n := r.uint64()
+		if n > uint64(len(r.b)) {
+			s.fail("truncated string")
+			return nil
+		}
+		r.next(int(n))
+	case tcArray:
+		c, _ := s.content(depth + 1).(*javaClass)
+		s.handles = append(s.handles, nil)
+		n := int(r.uint32())
+		if r.err != nil {
+			return nil
+		}
+		if c == nil || len(c.name) < 2 || c.name[0] != '[' {
+			s.fail("invalid array")
+			return nil
+		}
+		if size := javaFieldSize(c.name[1]); size > 0 {
+			if n > len(r.b)/size {
+				s.fail("truncated array")
+				return nil
+			}
+			r.next(n * size)
+			return nil
+		}
+		for i := 0; i < n && r.err == nil; i++ {
+			s.content(depth + 1)
+		}
+	case tcObject:
+		c, _ := s.content(depth + 1).(*javaClass)
+		s.handles = append(s.handles, nil)
+		if r.err == nil && c == nil {
+			s.fail("object without class")
+			return nil
+		}
+		s.classData(c, depth)
+	case tcEnum:
+		s.content(depth + 1)
+		s.handles = append(s.handles, nil)
+		s.content(depth + 1) // name of the constant
+	case tcBlockData:
+		r.next(int(r.byte()))
+	case tcBlockDataLong:
+		r.next(int(r.uint32()))
+	case tcEndBlockData:
+		return javaEndBlock{}
+	default:
+		s.fail(fmt.Sprintf("unknown type code %#x", tc))
+	}
+	return nil
+}
+
+// classData reads the fields of an object of class c, those of its topmost
+// superclass first.
+func (s *javaStream) classData(c *javaClass, depth int) {
+	var classes []*javaClass
+	for ; c != nil && len(classes) <= javaMaxDepth; c = c.super {
+		classes = append([]*javaClass{c}, classes...)
+	}
+	for _, c := range classes {
+		switch {
+		case c.flags&scExternalizable != 0:
+			if c.flags&scBlockData == 0 {
+				s.fail("externalizable object without block data")
+				return
+			}
+			s.annotation(depth)
+		case c.flags&scSerializable != 0:
+			for _, code := range c.fields {
+				if size := javaFieldSize(code); size > 0 {
+					s.r.next(size)
+				} else {
+					s.content(depth + 1)
+				}
+			}
+			if c.flags&scWriteMethod != 0 {
+				s.annotation(depth)
+			}
+		}
+	}
+}
+
+// annotation reads the items written by a class until the end of its block
+// data.
+func (s *javaStream) annotation(depth int) {
+	for s.r.err == nil {
+		if _, ok := s.content(depth + 1).(javaEndBlock); ok {
+			return
+		}
+	}
+}
+
+// javaFieldSize returns the size of a primitive field of type code, or 0 for
+// an object.
+func javaFieldSize(code byte) int {
+	switch code {
+	case 'B', 'Z':
+		return 1
+	case 'C', 'S':
+		return 2
+	case 'I', 'F':
+		return 4
+	case 'J', 'D':
+		return 8
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testJKSEntry struct {
+	tag   uint32
+	alias string
+	certs [][]byte
+}
+
+// newTestJKS writes a Java keystore of version 2 with entries as keytool
+// does, with a dummy encrypted key for the private key entries.
+func newTestJKS(magic uint32, entries []testJKSEntry, password string) []byte {
+	var b bytes.Buffer
+	u32 := func(v uint32) { _ = binary.Write(&b, binary.BigEndian, v) }
+	utf := func(s string) {
+		_ = binary.Write(&b, binary.BigEndian, uint16(len(s)))
+		b.WriteString(s)
+	}
+	cert := func(der []byte) {
+		utf("X.509")
+		u32(uint32(len(der)))
+		b.Write(der)
+	}
+	u32(magic)
+	u32(2)
+	u32(uint32(len(entries)))
+	for _, e := range entries {
+		u32(e.tag)
+		utf(e.alias)
+		_ = binary.Write(&b, binary.BigEndian, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli())
+		switch e.tag {
+		case jksPrivateKey:
+			u32(3)
+			b.WriteString("key")
+			u32(uint32(len(e.certs)))
+			for _, der := range e.certs {
+				cert(der)
+			}
+		case jksTrustedCert:
+			cert(e.certs[0])
+		case jksSecretKey:
+			b.Write(newTestSealedKey())
+		}
+	}
+	return append(b.Bytes(), jksDigest(b.Bytes(), password)...)
+}
+
+// newTestSealedKey returns the sealed key of a JCEKS secret key entry as
+// serialized by an ObjectOutputStream.
+func newTestSealedKey() []byte {
+	var b bytes.Buffer
+	u16 := func(v uint16) { _ = binary.Write(&b, binary.BigEndian, v) }
+	u32 := func(v uint32) { _ = binary.Write(&b, binary.BigEndian, v) }
+	utf := func(s string) {
+		u16(uint16(len(s)))
+		b.WriteString(s)
+	}
+	classDesc := func(name string, flags byte) {
+		b.WriteByte(tcClassDesc)
+		utf(name)
+		b.Write(make([]byte, 8)) // serialVersionUID
+		b.WriteByte(flags)
+	}
+	u16(javaStreamMagic)
+	u16(javaStreamVersion)
+	b.WriteByte(tcObject)
+	classDesc("com.sun.crypto.provider.SealedObjectForKeyProtector", scSerializable) // handle 0
+	u16(0)
+	b.WriteByte(tcEndBlockData)
+	classDesc("javax.crypto.SealedObject", scSerializable) // handle 1
+	u16(4)
+	b.WriteByte('[')
+	utf("encodedParams")
+	b.WriteByte(tcString)
+	utf("[B") // handle 2
+	b.WriteByte('[')
+	utf("encryptedContent")
+	b.WriteByte(tcReference)
+	u32(javaBaseHandle + 2)
+	b.WriteByte('L')
+	utf("paramsAlg")
+	b.WriteByte(tcString)
+	utf("Ljava/lang/String;") // handle 3
+	b.WriteByte('L')
+	utf("sealAlg")
+	b.WriteByte(tcReference)
+	u32(javaBaseHandle + 3)
+	b.WriteByte(tcEndBlockData)
+	b.WriteByte(tcNull)
+	// the object is handle 4, followed by the fields of SealedObject
+	b.WriteByte(tcArray)
+	classDesc("[B", scSerializable) // handle 5
+	u16(0)
+	b.WriteByte(tcEndBlockData)
+	b.WriteByte(tcNull)
+	u32(4)
+	b.WriteString("salt")
+	b.WriteByte(tcArray)
+	b.WriteByte(tcReference)
+	u32(javaBaseHandle + 5)
+	u32(16)
+	b.Write(make([]byte, 16))
+	b.WriteByte(tcString)
+	utf("PBEWithMD5AndTripleDES")
+	b.WriteByte(tcString)
+	utf("PBEWithMD5AndTripleDES")
+	return b.Bytes()
+}
+
+func testKeystoreCerts(t *testing.T) (leaf, ca []byte) {
+	t.Helper()
+	f := &certFiles{password: "changeit"}
+	entries, err := f.read(filepath.Join("testdata", "keystore.p12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries[0].chain[0].Raw, entries[0].chain[1].Raw
+}
+
+func aliases(entries []keystoreEntry) map[string]int {
+	m := make(map[string]int, len(entries))
+	for _, e := range entries {
+		m[e.alias] = len(e.chain)
+	}
+	return m
+}
+
+func Test_readJKS(t *testing.T) {
+	leaf, ca := testKeystoreCerts(t)
+	keystore := newTestJKS(jksMagic, []testJKSEntry{
+		{tag: jksPrivateKey, alias: "www", certs: [][]byte{leaf, ca}},
+		{tag: jksTrustedCert, alias: "ca", certs: [][]byte{ca}},
+	}, "changeit")
+	tests := []struct {
+		name     string
+		b        []byte
+		password string
+		want     map[string]int
+		wantErr  error
+	}{
+		{
+			name:     "jks",
+			b:        keystore,
+			password: "changeit",
+			want:     map[string]int{"www": 2, "ca": 1},
+		},
+		{
+			name: "jks without password",
+			b:    keystore,
+			want: map[string]int{"www": 2, "ca": 1},
+		},
+		{
+			name:     "jceks",
+			b:        newTestJKS(jceksMagic, []testJKSEntry{{tag: jksTrustedCert, alias: "ca", certs: [][]byte{ca}}}, "changeit"),
+			password: "changeit",
+			want:     map[string]int{"ca": 1},
+		},
+		{
+			name:     "wrong password",
+			b:        keystore,
+			password: "secret",
+			wantErr:  errKeystorePassword,
+		},
+		{
+			name: "jceks with secret key entry",
+			b: newTestJKS(jceksMagic, []testJKSEntry{
+				{tag: jksSecretKey, alias: "aes"},
+				{tag: jksTrustedCert, alias: "ca", certs: [][]byte{ca}},
+			}, "changeit"),
+			password: "changeit",
+			want:     map[string]int{"ca": 1},
+		},
+		{
+			name:    "jceks with secret key entry only",
+			b:       newTestJKS(jceksMagic, []testJKSEntry{{tag: jksSecretKey, alias: "aes"}}, ""),
+			wantErr: errors.New("no cert in keystore"),
+		},
+		{
+			name:    "jks with secret key entry",
+			b:       newTestJKS(jksMagic, []testJKSEntry{{tag: jksSecretKey, alias: "aes"}}, ""),
+			wantErr: errors.New("unknown keystore entry type 3"),
+		},
+		{
+			name:    "truncated",
+			b:       keystore[:len(keystore)/2],
+			wantErr: errors.New("truncated keystore"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !isJKS(tt.b) {
+				t.Fatal("isJKS() = false")
+			}
+			got, err := readJKS(tt.b, tt.password)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("readJKS() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, aliases(got)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_readJKS_magic(t *testing.T) {
+	b := newTestJKS(0xCAFEBABE, nil, "")
+	if _, err := readJKS(b, ""); err == nil || err.Error() != "not a Java keystore: magic 0xcafebabe" {
+		t.Errorf("readJKS() error = %v", err)
+	}
+}
+
+func Test_readPKCS12(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{
+			name: "3des",
+			file: "keystore.p12",
+		},
+		{
+			// written with AES-256-CBC, PBKDF2 with HMAC-SHA256 and a
+			// SHA-256 MAC, the defaults of keytool since JDK 18
+			name: "pbes2",
+			file: "keystore_aes.p12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readPKCS12(b, "changeit")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(map[string]int{"www": 2}, aliases(got)); diff != "" {
+				t.Error(diff)
+			}
+			if got[0].chain[0].Subject.CommonName != "keystore.example.com" || got[0].chain[1].Subject.CommonName != "keystore test CA" {
+				t.Errorf("chain = %s, %s", got[0].chain[0].Subject, got[0].chain[1].Subject)
+			}
+			if _, err := readPKCS12(b, "secret"); !errors.Is(err, errKeystorePassword) {
+				t.Errorf("readPKCS12() with wrong password error = %v, want %v", err, errKeystorePassword)
+			}
+		})
+	}
+}
+
+func Test_certFiles_read_prompt(t *testing.T) {
+	path := filepath.Join("testdata", "keystore.p12")
+	var prompted []string
+	f := &certFiles{prompt: func(path string) (string, error) {
+		prompted = append(prompted, path)
+		return "changeit", nil
+	}}
+	entries, err := f.read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("read() = %d entries, want 1", len(entries))
+	}
+	if diff := cmp.Diff([]string{path}, prompted); diff != "" {
+		t.Error(diff)
+	}
+	f = &certFiles{password: "secret", prompt: f.prompt}
+	if _, err := f.read(path); !errors.Is(err, errKeystorePassword) {
+		t.Errorf("read() with wrong password error = %v, want %v", err, errKeystorePassword)
+	}
+}
+
+func Test_buildChain(t *testing.T) {
+	leaf, ca := testKeystoreCerts(t)
+	l, err := x509.ParseCertificate(leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buildChain(l, []*x509.Certificate{c, l}); len(got) != 2 || got[1] != c {
+		t.Errorf("buildChain() = %d certs, want leaf and CA", len(got))
+	}
+	if got := buildChain(c, []*x509.Certificate{c}); len(got) != 1 {
+		t.Errorf("buildChain() of a root = %d certs, want 1", len(got))
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// sourceKubernetes is the source of the hosts discovered in a cluster.
+const sourceKubernetes = "kubernetes"
+
+const (
+	kubeIngresses = "ingresses.networking.k8s.io"
+	kubeGateways  = "gateways.gateway.networking.k8s.io"
+)
+
+// kube discovers the hosts served by the Ingresses and Gateways of a cluster
+// through kubectl, so that the kubeconfig, its contexts and credential
+// plugins work as they do for the operator.
+type kube struct {
+	kubectl   string
+	context   string
+	namespace string
+}
+
+func newKube(context, namespace string) *kube {
+	return &kube{kubectl: "kubectl", context: context, namespace: namespace}
+}
+
+// kubeSecret refers to a TLS secret used by the hosts of an Ingress or a
+// Gateway listener.
+type kubeSecret struct {
+	namespace string
+	name      string
+	hosts     []string
+}
+
+func (s kubeSecret) String() string {
+	return s.namespace + "/" + s.name
+}
+
+type kubeMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type kubeIngressList struct {
+	Items []struct {
+		Metadata kubeMetadata `json:"metadata"`
+		Spec     struct {
+			Rules []struct {
+				Host string `json:"host"`
+			} `json:"rules"`
+			TLS []struct {
+				Hosts      []string `json:"hosts"`
+				SecretName string   `json:"secretName"`
+			} `json:"tls"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type kubeGatewayList struct {
+	Items []struct {
+		Metadata kubeMetadata `json:"metadata"`
+		Spec     struct {
+			Listeners []struct {
+				Hostname string `json:"hostname"`
+				Port     int    `json:"port"`
+				Protocol string `json:"protocol"`
+				TLS      *struct {
+					CertificateRefs []struct {
+						Kind      string `json:"kind"`
+						Name      string `json:"name"`
+						Namespace string `json:"namespace"`
+					} `json:"certificateRefs"`
+				} `json:"tls"`
+			} `json:"listeners"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type kubeSecretData struct {
+	Data map[string][]byte `json:"data"`
+}
+
+// discover returns the hosts of the Ingresses, on port 443, and of the HTTPS
+// and TLS listeners of the Gateways, along with the secrets they use. Hosts
+// without a name and wildcards cannot be connected to and are left out.
+// A cluster without the Gateway API has only Ingresses.
+func (k *kube) discover(ctx context.Context) ([]string, []kubeSecret, error) {
+	var (
+		addrs   []string
+		secrets []kubeSecret
+	)
+	addHost := func(host, port string) {
+		if host == "" || strings.HasPrefix(host, "*") {
+			return
+		}
+		addr := net.JoinHostPort(host, port)
+		if !slices.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	addSecret := func(namespace, name string, hosts []string) {
+		if name == "" {
+			return
+		}
+		i := slices.IndexFunc(secrets, func(s kubeSecret) bool {
+			return s.namespace == namespace && s.name == name
+		})
+		if i < 0 {
+			secrets = append(secrets, kubeSecret{namespace: namespace, name: name})
+			i = len(secrets) - 1
+		}
+		for _, host := range hosts {
+			if host != "" && !slices.Contains(secrets[i].hosts, host) {
+				secrets[i].hosts = append(secrets[i].hosts, host)
+			}
+		}
+	}
+	b, err := k.get(ctx, kubeIngresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ingresses kubeIngressList
+	if err := json.Unmarshal(b, &ingresses); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse %s: %w", kubeIngresses, err)
+	}
+	for _, item := range ingresses.Items {
+		for _, rule := range item.Spec.Rules {
+			addHost(rule.Host, "443")
+		}
+		for _, tls := range item.Spec.TLS {
+			for _, host := range tls.Hosts {
+				addHost(host, "443")
+			}
+			addSecret(item.Metadata.Namespace, tls.SecretName, tls.Hosts)
+		}
+	}
+	b, err = k.get(ctx, kubeGateways)
+	if err != nil {
+		if !strings.Contains(err.Error(), "doesn't have a resource type") {
+			return nil, nil, err
+		}
+		log.Debug("no Gateway API in the cluster", "err", err)
+		b = []byte("{}")
+	}
+	var gateways kubeGatewayList
+	if err := json.Unmarshal(b, &gateways); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse %s: %w", kubeGateways, err)
+	}
+	for _, item := range gateways.Items {
+		for _, l := range item.Spec.Listeners {
+			if l.Protocol != "HTTPS" && l.Protocol != "TLS" {
+				continue
+			}
+			addHost(l.Hostname, strconv.Itoa(l.Port))
+			if l.TLS == nil {
+				continue
+			}
+			for _, ref := range l.TLS.CertificateRefs {
+				if ref.Kind != "" && ref.Kind != "Secret" {
+					continue
+				}
+				namespace := ref.Namespace
+				if namespace == "" {
+					namespace = item.Metadata.Namespace
+				}
+				addSecret(namespace, ref.Name, []string{l.Hostname})
+			}
+		}
+	}
+	return addrs, secrets, nil
+}
+
+// secretInfos returns the certs stored in secrets, named after the first of
+// their hosts, without connecting to them. Secrets that cannot be read are
+// logged and left out.
+func (k *kube) secretInfos(ctx context.Context, secrets []kubeSecret, loc *time.Location) []*certInfo {
+	var infos []*certInfo
+	now := time.Now()
+	for _, s := range secrets {
+		chain, err := k.secretChain(ctx, s)
+		if err != nil {
+			log.Warn("cannot read TLS secret", "secret", s, "err", err)
+			continue
+		}
+		info := newCertInfo(chain, now, loc)
+		if len(s.hosts) > 0 {
+			info.DomainName = s.hosts[0]
+		}
+		info.Secret = s.String()
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (k *kube) secretChain(ctx context.Context, s kubeSecret) ([]*x509.Certificate, error) {
+	b, err := k.run(ctx, "get", "secret", s.name, "--namespace", s.namespace, "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+	var data kubeSecretData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return parsePEMChain(data.Data["tls.crt"])
+}
+
+// get lists resource in the namespace, or in all namespaces.
+func (k *kube) get(ctx context.Context, resource string) ([]byte, error) {
+	args := []string{"get", resource, "--output", "json"}
+	if k.namespace != "" {
+		args = append(args, "--namespace", k.namespace)
+	} else {
+		args = append(args, "--all-namespaces")
+	}
+	return k.run(ctx, args...)
+}
+
+func (k *kube) run(ctx context.Context, args ...string) ([]byte, error) {
+	if k.context != "" {
+		args = append([]string{"--context", k.context}, args...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, k.kubectl, args...) // #nosec G204
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("kubectl %s: %s", strings.Join(args, " "), msg)
+		}
+		return nil, fmt.Errorf("kubectl %s: %w", strings.Join(args, " "), err)
+	}
+	return stdout.Bytes(), nil
+}
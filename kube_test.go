@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testIngresses = `{"items": [
+  {"metadata": {"name": "web", "namespace": "shop"},
+   "spec": {
+     "rules": [{"host": "www.example.com"}, {"host": "*.example.com"}, {}],
+     "tls": [{"hosts": ["www.example.com", "api.example.com"], "secretName": "web-tls"}]}},
+  {"metadata": {"name": "admin", "namespace": "ops"},
+   "spec": {"rules": [{"host": "www.example.com"}]}}
+]}`
+
+const testGateways = `{"items": [
+  {"metadata": {"name": "edge", "namespace": "infra"},
+   "spec": {"listeners": [
+     {"name": "https", "hostname": "shop.example.com", "port": 8443, "protocol": "HTTPS",
+      "tls": {"certificateRefs": [{"kind": "Secret", "name": "shop-tls"}, {"kind": "ConfigMap", "name": "ca"}]}},
+     {"name": "http", "hostname": "shop.example.com", "port": 80, "protocol": "HTTP"},
+     {"name": "passthrough", "hostname": "db.example.com", "port": 5432, "protocol": "TLS",
+      "tls": {"certificateRefs": [{"name": "db-tls", "namespace": "data"}]}}
+   ]}}
+]}`
+
+// newTestKubectl writes a kubectl that answers from the files in its
+// directory named after the resource it is asked for, fails for a resource
+// without one as kubectl does for an unknown type, and logs its arguments.
+func newTestKubectl(t *testing.T, files map[string]string) (*kube, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := `#!/bin/sh
+dir=$(dirname "$0")
+echo "$@" >> "$dir/args"
+for arg in "$@"; do
+  case "$arg" in
+  ingresses.*|gateways.*|*-tls)
+    if [ -f "$dir/$arg" ]; then cat "$dir/$arg"; exit 0; fi
+    echo "error: the server doesn't have a resource type \"$arg\"" >&2
+    exit 1
+    ;;
+  esac
+done
+exit 1
+`
+	path := filepath.Join(dir, "kubectl")
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil { // #nosec G306
+		t.Fatal(err)
+	}
+	return &kube{kubectl: path}, filepath.Join(dir, "args")
+}
+
+func Test_kube_discover(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		context     string
+		namespace   string
+		wantAddrs   []string
+		wantSecrets []string
+		wantArgs    string
+		wantErr     bool
+	}{
+		{
+			name:        "ingresses without gateway api",
+			files:       map[string]string{kubeIngresses: testIngresses},
+			wantAddrs:   []string{"www.example.com:443", "api.example.com:443"},
+			wantSecrets: []string{"shop/web-tls"},
+			wantArgs:    "get ingresses.networking.k8s.io --output json --all-namespaces",
+		},
+		{
+			name:        "ingresses and gateways",
+			files:       map[string]string{kubeIngresses: testIngresses, kubeGateways: testGateways},
+			context:     "prod",
+			namespace:   "shop",
+			wantAddrs:   []string{"www.example.com:443", "api.example.com:443", "shop.example.com:8443", "db.example.com:5432"},
+			wantSecrets: []string{"shop/web-tls", "infra/shop-tls", "data/db-tls"},
+			wantArgs:    "--context prod get ingresses.networking.k8s.io --output json --namespace shop",
+		},
+		{
+			name:    "kubectl failure",
+			files:   nil,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			files:   map[string]string{kubeIngresses: "No resources found"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, args := newTestKubectl(t, tt.files)
+			k.context = tt.context
+			k.namespace = tt.namespace
+			addrs, secrets, err := k.discover(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantAddrs, addrs); diff != "" {
+				t.Error(diff)
+			}
+			var names []string
+			for _, s := range secrets {
+				names = append(names, s.String())
+			}
+			if diff := cmp.Diff(tt.wantSecrets, names); diff != "" {
+				t.Error(diff)
+			}
+			b, err := os.ReadFile(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first, _, _ := strings.Cut(string(b), "\n"); first != tt.wantArgs {
+				t.Errorf("args = %q, want %q", first, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_kube_secretInfos(t *testing.T) {
+	config := newTestTLSConfig(t)
+	crt := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: config.Certificates[0].Certificate[0]})
+	secret := `{"data": {"tls.crt": "` + base64.StdEncoding.EncodeToString(crt) + `", "tls.key": ""}}`
+	k, _ := newTestKubectl(t, map[string]string{
+		"web-tls":   secret,
+		"empty-tls": `{"data": {}}`,
+	})
+	secrets := []kubeSecret{
+		{namespace: "shop", name: "web-tls", hosts: []string{"www.example.com", "api.example.com"}},
+		{namespace: "shop", name: "empty-tls"},
+		{namespace: "shop", name: "missing-tls"},
+	}
+	infos := k.secretInfos(context.Background(), secrets, time.UTC)
+	if len(infos) != 1 {
+		t.Fatalf("secretInfos() = %d infos, want 1", len(infos))
+	}
+	if infos[0].DomainName != "www.example.com" || infos[0].Secret != "shop/web-tls" {
+		t.Errorf("secretInfos() = %s %s, want www.example.com shop/web-tls", infos[0].DomainName, infos[0].Secret)
+	}
+	if infos[0].CommonName != "starttls test" {
+		t.Errorf("CommonName = %q", infos[0].CommonName)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// locale controls how dates and numbers are rendered in human outputs.
+type locale struct {
+	timeLayout string
+	groupSep   string
+}
+
+var locales = map[string]*locale{
+	"en-US": {
+		timeLayout: "Jan 2, 2006 3:04:05 PM MST",
+		groupSep:   ",",
+	},
+	"ja-JP": {
+		timeLayout: "2006年01月02日 15:04:05 MST",
+		groupSep:   ",",
+	},
+}
+
+func localeNames() []string {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// lookupLocale returns nil for an empty name, which keeps the default rendering.
+func lookupLocale(name string) (*locale, error) {
+	if name == "" {
+		return nil, nil
+	}
+	l, ok := locales[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid locale: allowed values: %s", pipeJoin(localeNames()))
+	}
+	return l, nil
+}
+
+func (l *locale) time(t time.Time) any {
+	if l == nil {
+		return t
+	}
+	return t.Format(l.timeLayout)
+}
+
+func (l *locale) number(n int) any {
+	if l == nil {
+		return n
+	}
+	s := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(l.groupSep)
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_lookupLocale(t *testing.T) {
+	tests := []struct {
+		name    string
+		locale  string
+		want    *locale
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			locale:  "",
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name:    "ja-JP",
+			locale:  "ja-JP",
+			want:    locales["ja-JP"],
+			wantErr: false,
+		},
+		{
+			name:    "invalid",
+			locale:  "xx-XX",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupLocale(tt.locale)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("lookupLocale() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("lookupLocale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_locale_time(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		locale *locale
+		want   any
+	}{
+		{
+			name:   "nil",
+			locale: nil,
+			want:   ts,
+		},
+		{
+			name:   "en-US",
+			locale: locales["en-US"],
+			want:   "Jan 2, 2024 3:04:05 PM UTC",
+		},
+		{
+			name:   "ja-JP",
+			locale: locales["ja-JP"],
+			want:   "2024年01月02日 15:04:05 UTC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.locale.time(ts); got != tt.want {
+				t.Errorf("locale.time() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_locale_number(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale *locale
+		n      int
+		want   any
+	}{
+		{
+			name:   "nil",
+			locale: nil,
+			n:      4096,
+			want:   4096,
+		},
+		{
+			name:   "small",
+			locale: locales["ja-JP"],
+			n:      365,
+			want:   "365",
+		},
+		{
+			name:   "thousands",
+			locale: locales["ja-JP"],
+			n:      4096,
+			want:   "4,096",
+		},
+		{
+			name:   "millions",
+			locale: locales["en-US"],
+			n:      1234567,
+			want:   "1,234,567",
+		},
+		{
+			name:   "negative",
+			locale: locales["ja-JP"],
+			n:      -1024,
+			want:   "-1,024",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.locale.number(tt.n); got != tt.want {
+				t.Errorf("locale.number() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	mozillaStore = "mozilla"
+
+	mozillaBundleURL  = "https://curl.se/ca/cacert.pem"
+	mozillaBundleName = "cacert.pem"
+	mozillaMaxSize    = 4 << 20
+	defaultRootsTTL   = 24 * time.Hour
+)
+
+// rootSources are the roots hosts may be verified against.
+var rootSources = []string{systemStore, mozillaStore}
+
+// loadRootSource returns the roots of source, nil for the system roots, the
+// Mozilla CA bundle being reused for ttl once downloaded.
+func loadRootSource(ctx context.Context, source string, ttl, timeout time.Duration) (*x509.CertPool, error) {
+	switch source {
+	case systemStore:
+		return nil, nil
+	case mozillaStore:
+		bundle, err := newMozillaBundle(ttl, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return bundle.roots(ctx, time.Now())
+	default:
+		return nil, fmt.Errorf("invalid roots: allowed values: %s", pipeJoin(rootSources))
+	}
+}
+
+// mozillaBundle is the CA bundle extracted from the Mozilla root store by the
+// curl project, downloaded to path and reused for ttl, so that hosts running
+// tlc3 verify against the same roots whatever their OS ships.
+type mozillaBundle struct {
+	client *http.Client
+	url    string
+	path   string
+	ttl    time.Duration
+}
+
+// newMozillaBundle returns the bundle cached as tlc3/cacert.pem under the
+// cache directory of the user, such as $XDG_CACHE_HOME or ~/.cache.
+func newMozillaBundle(ttl, timeout time.Duration) (*mozillaBundle, error) {
+	if ttl <= 0 {
+		return nil, errors.New("invalid roots ttl: must be greater than 0")
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("cannot cache the Mozilla CA bundle: %w", err)
+	}
+	return &mozillaBundle{
+		client: &http.Client{Timeout: timeout},
+		url:    mozillaBundleURL,
+		path:   filepath.Join(dir, appName, mozillaBundleName),
+		ttl:    ttl,
+	}, nil
+}
+
+// roots returns the roots of the bundle, downloading it first when the cached
+// one is older than ttl as of now. When it cannot be downloaded, the cached
+// one is used with a warning rather than failing the run.
+func (b *mozillaBundle) roots(ctx context.Context, now time.Time) (*x509.CertPool, error) {
+	info, statErr := os.Stat(b.path)
+	if statErr != nil || now.Sub(info.ModTime()) >= b.ttl {
+		if err := b.download(ctx); err != nil {
+			if statErr != nil {
+				return nil, fmt.Errorf("cannot download the Mozilla CA bundle: %w", err)
+			}
+			log.Warn("cannot update the Mozilla CA bundle, using the cached one", "path", b.path, "modified", info.ModTime().Format(time.RFC3339), "err", err)
+		}
+	}
+	certs, err := readCAFile(b.path)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	for _, cert := range certs {
+		roots.AddCert(cert)
+	}
+	log.Info("verifying with the Mozilla CA bundle", "path", b.path, "certs", len(certs))
+	return roots, nil
+}
+
+// download writes the bundle to path after checking it against the checksum
+// published next to it.
+func (b *mozillaBundle) download(ctx context.Context) error {
+	data, err := b.get(ctx, b.url)
+	if err != nil {
+		return err
+	}
+	checksums, err := b.get(ctx, b.url+".sha256")
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(checksums, mozillaBundleName, data); err != nil {
+		return err
+	}
+	if _, err := parsePEMChain(data); err != nil {
+		return fmt.Errorf("invalid bundle: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return err
+	}
+	f, err := createAtomic(b.path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.abort()
+		return err
+	}
+	if err := f.commit(); err != nil {
+		return err
+	}
+	log.Debug("downloaded the Mozilla CA bundle", "url", b.url, "path", b.path)
+	return nil
+}
+
+func (b *mozillaBundle) get(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", appName+"/"+Version)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot fetch %s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, mozillaMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > mozillaMaxSize {
+		return nil, fmt.Errorf("cannot fetch %s: response exceeds %d bytes", u, mozillaMaxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_mozillaBundle_roots(t *testing.T) {
+	bundle, err := os.ReadFile(filepath.Join("testdata", "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(bundle)
+	now := time.Now()
+	tests := []struct {
+		name          string
+		cached        []byte
+		cacheAge      time.Duration
+		status        int
+		checksum      string
+		wantDownloads int32
+		wantErr       bool
+	}{
+		{
+			name:          "download",
+			status:        http.StatusOK,
+			checksum:      hex.EncodeToString(sum[:]) + "  cacert.pem\n",
+			wantDownloads: 1,
+			wantErr:       false,
+		},
+		{
+			name:          "cached",
+			cached:        bundle,
+			cacheAge:      time.Hour,
+			status:        http.StatusOK,
+			checksum:      hex.EncodeToString(sum[:]) + "  cacert.pem\n",
+			wantDownloads: 0,
+			wantErr:       false,
+		},
+		{
+			name:          "expired",
+			cached:        []byte("stale"),
+			cacheAge:      48 * time.Hour,
+			status:        http.StatusOK,
+			checksum:      hex.EncodeToString(sum[:]) + "  cacert.pem\n",
+			wantDownloads: 1,
+			wantErr:       false,
+		},
+		{
+			name:          "expired and unavailable",
+			cached:        bundle,
+			cacheAge:      48 * time.Hour,
+			status:        http.StatusServiceUnavailable,
+			wantDownloads: 1,
+			wantErr:       false,
+		},
+		{
+			name:          "unavailable",
+			status:        http.StatusServiceUnavailable,
+			wantDownloads: 1,
+			wantErr:       true,
+		},
+		{
+			name:          "checksum mismatch",
+			status:        http.StatusOK,
+			checksum:      hex.EncodeToString(make([]byte, sha256.Size)) + "  cacert.pem\n",
+			wantDownloads: 1,
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var downloads atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status != http.StatusOK {
+					downloads.Add(1)
+					w.WriteHeader(tt.status)
+					return
+				}
+				switch r.URL.Path {
+				case "/cacert.pem":
+					downloads.Add(1)
+					_, _ = w.Write(bundle)
+				case "/cacert.pem.sha256":
+					_, _ = w.Write([]byte(tt.checksum))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+			path := filepath.Join(t.TempDir(), appName, mozillaBundleName)
+			if tt.cached != nil {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, tt.cached, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				modified := now.Add(-tt.cacheAge)
+				if err := os.Chtimes(path, modified, modified); err != nil {
+					t.Fatal(err)
+				}
+			}
+			b := &mozillaBundle{
+				client: srv.Client(),
+				url:    srv.URL + "/cacert.pem",
+				path:   path,
+				ttl:    24 * time.Hour,
+			}
+			got, err := b.roots(context.Background(), now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("roots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got == nil {
+				t.Error("roots() = nil")
+			}
+			if n := downloads.Load(); n != tt.wantDownloads {
+				t.Errorf("downloads = %d, want %d", n, tt.wantDownloads)
+			}
+		})
+	}
+}
+
+func Test_loadRootSource(t *testing.T) {
+	got, err := loadRootSource(context.Background(), systemStore, defaultRootsTTL, time.Second)
+	if err != nil || got != nil {
+		t.Errorf("loadRootSource(system) = %v, %v, want nil, nil", got, err)
+	}
+	if _, err := loadRootSource(context.Background(), "android", defaultRootsTTL, time.Second); err == nil {
+		t.Error("loadRootSource(android) error = nil")
+	}
+	if _, err := loadRootSource(context.Background(), mozillaStore, 0, time.Second); err == nil {
+		t.Error("loadRootSource(mozilla) with no ttl error = nil")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const exitUnknown = 3
+
+var nagiosStatuses = map[int]string{
+	0:            "OK",
+	exitWarning:  "WARNING",
+	exitCritical: "CRITICAL",
+	exitUnknown:  "UNKNOWN",
+}
+
+// statusError is an error already reported as a plugin status line, carrying
+// the exit code that Nagios and Icinga map to the service state.
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+func (e *statusError) ExitCode() int {
+	return e.code
+}
+
+// toNagios writes a single plugin status line with the days left of each host
+// as perfdata, and returns the error that sets the exit code: critical for
+// chains that do not verify, or else that of the thresholds.
+func toNagios(infos []*certInfo, w io.Writer, warn, crit int) error {
+	err := checkThresholds(infos, warn, crit)
+	status := nagiosStatuses[0]
+	summary := fmt.Sprintf("%d cert(s)", len(infos))
+	var te *thresholdError
+	if n := unverifiedCount(infos); n > 0 {
+		err = &verificationError{count: n}
+		status = nagiosStatuses[exitCritical]
+		summary = fmt.Sprintf("%d cert(s) fail verification", n)
+	} else if errors.As(err, &te) {
+		status = nagiosStatuses[te.code]
+		summary = fmt.Sprintf("%d cert(s) expire within %d days", te.count, te.days)
+	}
+	var soonest *certInfo
+	perfdata := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if soonest == nil || info.DaysLeft < soonest.DaysLeft {
+			soonest = info
+		}
+		perfdata = append(perfdata, fmt.Sprintf("'%s'=%d;%s;%s;;", nagiosLabel(info), info.DaysLeft, nagiosRange(warn), nagiosRange(crit)))
+	}
+	if soonest != nil {
+		summary += fmt.Sprintf(", soonest %s in %d days", nagiosLabel(soonest), soonest.DaysLeft)
+	}
+	if _, werr := fmt.Fprintf(w, "%s %s - %s | %s\n", canonicalName, status, summary, strings.Join(perfdata, " ")); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// nagiosFailure reports an error that was not already turned into a status
+// line. Hosts that cannot be checked are critical; anything else, such as
+// invalid arguments, is unknown.
+func nagiosFailure(w io.Writer, err error, checked bool) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	code := exitUnknown
+	if checked {
+		code = exitCritical
+	}
+	msg := strings.ReplaceAll(err.Error(), "|", "/")
+	if _, werr := fmt.Fprintf(w, "%s %s - %s\n", canonicalName, nagiosStatuses[code], msg); werr != nil {
+		return werr
+	}
+	return &statusError{code: code, err: err}
+}
+
+func nagiosLabel(info *certInfo) string {
+	return strings.ReplaceAll(net.JoinHostPort(info.DomainName, info.AccessPort), "'", "''")
+}
+
+// nagiosRange turns a threshold into a range that alerts below it, since
+// fewer days left is worse. A disabled threshold is left empty.
+func nagiosRange(days int) string {
+	if days < 0 {
+		return ""
+	}
+	return strconv.Itoa(days) + ":"
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_toNagios(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "443", DaysLeft: 90},
+		{DomainName: "b.example.com", AccessPort: "8443", DaysLeft: 20},
+		{DomainName: "c.example.com", AccessPort: "443", DaysLeft: 5},
+	}
+	tests := []struct {
+		name     string
+		warn     int
+		crit     int
+		want     string
+		wantCode int
+	}{
+		{
+			name:     "ok",
+			warn:     3,
+			crit:     1,
+			want:     "TLC3 OK - 3 cert(s), soonest c.example.com:443 in 5 days | 'a.example.com:443'=90;3:;1:;; 'b.example.com:8443'=20;3:;1:;; 'c.example.com:443'=5;3:;1:;;\n",
+			wantCode: 0,
+		},
+		{
+			name:     "warning",
+			warn:     30,
+			crit:     1,
+			want:     "TLC3 WARNING - 2 cert(s) expire within 30 days, soonest c.example.com:443 in 5 days | 'a.example.com:443'=90;30:;1:;; 'b.example.com:8443'=20;30:;1:;; 'c.example.com:443'=5;30:;1:;;\n",
+			wantCode: exitWarning,
+		},
+		{
+			name:     "critical",
+			warn:     30,
+			crit:     7,
+			want:     "TLC3 CRITICAL - 1 cert(s) expire within 7 days, soonest c.example.com:443 in 5 days | 'a.example.com:443'=90;30:;7:;; 'b.example.com:8443'=20;30:;7:;; 'c.example.com:443'=5;30:;7:;;\n",
+			wantCode: exitCritical,
+		},
+		{
+			name:     "no thresholds",
+			warn:     -1,
+			crit:     -1,
+			want:     "TLC3 OK - 3 cert(s), soonest c.example.com:443 in 5 days | 'a.example.com:443'=90;;;; 'b.example.com:8443'=20;;;; 'c.example.com:443'=5;;;;\n",
+			wantCode: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := toNagios(infos, &buf, tt.warn, tt.crit)
+			code := 0
+			var te *thresholdError
+			if errors.As(err, &te) {
+				code = te.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.wantCode {
+				t.Errorf("toNagios() code = %d, want %d", code, tt.wantCode)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("toNagios() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nagiosFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		checked  bool
+		want     string
+		wantCode int
+	}{
+		{
+			name:     "nil",
+			err:      nil,
+			want:     "",
+			wantCode: 0,
+		},
+		{
+			name:     "already reported",
+			err:      &thresholdError{code: exitWarning, count: 1, days: 30},
+			want:     "",
+			wantCode: exitWarning,
+		},
+		{
+			name:     "check failed",
+			err:      errors.New("tls: failed to verify certificate"),
+			checked:  true,
+			want:     "TLC3 CRITICAL - tls: failed to verify certificate\n",
+			wantCode: exitCritical,
+		},
+		{
+			name:     "invalid arguments",
+			err:      errors.New("invalid line detected: a|b"),
+			checked:  false,
+			want:     "TLC3 UNKNOWN - invalid line detected: a/b\n",
+			wantCode: exitUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := nagiosFailure(&buf, tt.err, tt.checked)
+			code := 0
+			var exitErr interface{ ExitCode() int }
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			}
+			if code != tt.wantCode {
+				t.Errorf("nagiosFailure() code = %d, want %d", code, tt.wantCode)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("nagiosFailure() error = %v, want wrapping %v", err, tt.err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("nagiosFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_toNagios_unverified(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "a.example.com", AccessPort: "443", DaysLeft: 90, VerificationStatus: verificationFailed},
+		{DomainName: "b.example.com", AccessPort: "443", DaysLeft: 20, VerificationStatus: verificationFailed, insecure: true},
+	}
+	var buf bytes.Buffer
+	err := toNagios(infos, &buf, 30, 7)
+	var ve *verificationError
+	if !errors.As(err, &ve) || ve.ExitCode() != exitCritical {
+		t.Fatalf("toNagios() error = %v, want verificationError", err)
+	}
+	want := "TLC3 CRITICAL - 1 cert(s) fail verification, soonest b.example.com:443 in 20 days | 'a.example.com:443'=90;30:;7:;; 'b.example.com:443'=20;30:;7:;;\n"
+	if got := buf.String(); got != want {
+		t.Errorf("toNagios() = %q, want %q", got, want)
+	}
+}
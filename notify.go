@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	eventExpiring = "expiring"
+	eventChanged  = "changed"
+
+	notifyRetries = 3
+	notifyBackoff = time.Second
+)
+
+type notification struct {
+	Event                string
+	Host                 string
+	CommonName           string
+	Issuer               string
+	SerialNumber         string
+	NotAfter             time.Time
+	DaysLeft             int
+	PreviousIssuer       string `json:",omitempty"`
+	PreviousSerialNumber string `json:",omitempty"`
+}
+
+// notifier posts events to a webhook: certs that fall below the warning
+// threshold and certs replaced by one with a new serial or issuer. It keeps
+// the certs of the previous call so that in watch mode each event is sent
+// once rather than on every check. A nil notifier sends nothing.
+type notifier struct {
+	url       string
+	formatter formatter
+	client    *http.Client
+	retries   int
+	backoff   time.Duration
+	seen      map[string]*certInfo
+}
+
+// newNotifier returns a notifier posting to rawURL with the payload rendered
+// by the named formatter, or by the template at templatePath if given.
+func newNotifier(rawURL, format, templatePath string, timeout time.Duration) (*notifier, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook url: %q", rawURL)
+	}
+	f, ok := formatters[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid notify format: allowed values: %s", pipeJoin(formatterNames()))
+	}
+	if templatePath != "" {
+		f, err = newTemplateFormatter(templatePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	n := &notifier{
+		url:       rawURL,
+		formatter: f,
+		client:    &http.Client{Timeout: timeout},
+		retries:   notifyRetries,
+		backoff:   notifyBackoff,
+	}
+	return n, nil
+}
+
+// events returns what changed since the previous call. warn below zero
+// disables expiry events.
+func (n *notifier) events(infos []*certInfo, warn int) []notification {
+	first := n.seen == nil
+	seen := make(map[string]*certInfo, len(infos))
+	var events []notification
+	for _, info := range infos {
+		host := net.JoinHostPort(info.DomainName, info.AccessPort)
+		seen[host] = info
+		prev, ok := n.seen[host]
+		event := notification{
+			Host:         host,
+			CommonName:   info.CommonName,
+			Issuer:       info.Issuer,
+			SerialNumber: info.SerialNumber,
+			NotAfter:     info.NotAfter,
+			DaysLeft:     info.DaysLeft,
+		}
+		if ok && (prev.SerialNumber != info.SerialNumber || prev.Issuer != info.Issuer) {
+			event.Event = eventChanged
+			event.PreviousIssuer = prev.Issuer
+			event.PreviousSerialNumber = prev.SerialNumber
+			events = append(events, event)
+			continue
+		}
+		if warn >= 0 && info.DaysLeft < warn && (first || !ok || prev.DaysLeft >= warn) {
+			event.Event = eventExpiring
+			events = append(events, event)
+		}
+	}
+	n.seen = seen
+	return events
+}
+
+// notify posts the events for infos, if any, retrying with exponential
+// backoff on network errors and on 429 and 5xx responses.
+func (n *notifier) notify(ctx context.Context, infos []*certInfo, warn int) error {
+	if n == nil {
+		return nil
+	}
+	events := n.events(infos, warn)
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := n.formatter.format(events)
+	if err != nil {
+		return err
+	}
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil || attempt == n.retries {
+			break
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			break
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("cannot notify webhook: %w", err)
+	}
+	return nil
+}
+
+// permanentError is a response that will not change by retrying.
+type permanentError struct {
+	status string
+}
+
+func (e *permanentError) Error() string {
+	return "unexpected response: " + e.status
+}
+
+func (n *notifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	default:
+		return &permanentError{status: resp.Status}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		format   string
+		template string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{
+			name:    "url",
+			url:     "https://hooks.example.com/services/T000",
+			format:  formatterJSON,
+			wantNil: false,
+		},
+		{
+			name:     "template",
+			url:      "https://hooks.example.com/services/T000",
+			format:   formatterJSON,
+			template: filepath.Join("testdata", "notify.tmpl"),
+			wantNil:  false,
+		},
+		{
+			name:    "none",
+			url:     "",
+			format:  formatterJSON,
+			wantNil: true,
+		},
+		{
+			name:    "slack",
+			url:     "https://hooks.slack.com/services/T000",
+			format:  formatterSlack,
+			wantNil: false,
+		},
+		{
+			name:    "unknown format",
+			url:     "https://hooks.example.com/services/T000",
+			format:  "discord",
+			wantNil: true,
+			wantErr: true,
+		},
+		{
+			name:    "not http",
+			url:     "ftp://hooks.example.com/",
+			format:  formatterJSON,
+			wantNil: true,
+			wantErr: true,
+		},
+		{
+			name:     "invalid template",
+			url:      "https://hooks.example.com/services/T000",
+			format:   formatterJSON,
+			template: filepath.Join("testdata", "notify_invalid.tmpl"),
+			wantNil:  true,
+			wantErr:  true,
+		},
+		{
+			name:     "missing template",
+			url:      "https://hooks.example.com/services/T000",
+			format:   formatterJSON,
+			template: filepath.Join("testdata", "missing.tmpl"),
+			wantNil:  true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newNotifier(tt.url, tt.format, tt.template, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Errorf("newNotifier() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func Test_notifier_events(t *testing.T) {
+	cert := func(host, serial, issuer string, daysLeft int) *certInfo {
+		return &certInfo{DomainName: host, AccessPort: "443", SerialNumber: serial, Issuer: issuer, DaysLeft: daysLeft}
+	}
+	type event struct {
+		Event, Host, Previous string
+	}
+	checks := []struct {
+		name  string
+		infos []*certInfo
+		want  []event
+	}{
+		{
+			name:  "first check reports all expiring",
+			infos: []*certInfo{cert("a.example.com", "01", "CN=CA", 10), cert("b.example.com", "02", "CN=CA", 90)},
+			want:  []event{{Event: eventExpiring, Host: "a.example.com:443"}},
+		},
+		{
+			name:  "still expiring is not reported again",
+			infos: []*certInfo{cert("a.example.com", "01", "CN=CA", 9), cert("b.example.com", "02", "CN=CA", 89)},
+			want:  nil,
+		},
+		{
+			name:  "falling below and renewal",
+			infos: []*certInfo{cert("a.example.com", "03", "CN=CA", 365), cert("b.example.com", "02", "CN=CA", 29)},
+			want:  []event{{Event: eventChanged, Host: "a.example.com:443", Previous: "01"}, {Event: eventExpiring, Host: "b.example.com:443"}},
+		},
+		{
+			name:  "new issuer",
+			infos: []*certInfo{cert("a.example.com", "03", "CN=Other CA", 365), cert("b.example.com", "02", "CN=CA", 28)},
+			want:  []event{{Event: eventChanged, Host: "a.example.com:443", Previous: "03"}},
+		},
+	}
+	n := &notifier{}
+	for _, check := range checks {
+		events := n.events(check.infos, 30)
+		var got []event
+		for _, e := range events {
+			got = append(got, event{Event: e.Event, Host: e.Host, Previous: e.PreviousSerialNumber})
+		}
+		if diff := cmp.Diff(got, check.want); diff != "" {
+			t.Errorf("%s: %s", check.name, diff)
+		}
+	}
+}
+
+func Test_notifier_notify(t *testing.T) {
+	infos := []*certInfo{{DomainName: "a.example.com", AccessPort: "443", SerialNumber: "01", DaysLeft: 10}}
+	tests := []struct {
+		name         string
+		statuses     []int
+		template     string
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:         "ok",
+			statuses:     []int{http.StatusOK},
+			wantAttempts: 1,
+		},
+		{
+			name:         "retried",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			wantAttempts: 3,
+		},
+		{
+			name:         "retries exhausted",
+			statuses:     []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			wantAttempts: 4,
+			wantErr:      true,
+		},
+		{
+			name:         "not retried",
+			statuses:     []int{http.StatusBadRequest},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "template",
+			statuses:     []int{http.StatusOK},
+			template:     filepath.Join("testdata", "notify.tmpl"),
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer server.Close()
+			n, err := newNotifier(server.URL, formatterJSON, tt.template, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n.backoff = time.Millisecond
+			err = n.notify(context.Background(), infos, 30)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("payload is not JSON: %s", body)
+			}
+			if tt.template != "" && payload["text"] != "expiring a.example.com:443 10d;" {
+				t.Errorf("payload = %s", body)
+			}
+		})
+	}
+	t.Run("nil", func(t *testing.T) {
+		var n *notifier
+		if err := n.notify(context.Background(), infos, 30); err != nil {
+			t.Error(err)
+		}
+	})
+}
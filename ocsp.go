@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/x509"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspGood    = "good"
+	ocspRevoked = "revoked"
+	ocspUnknown = "unknown"
+	ocspExpired = "expired"
+	ocspInvalid = "invalid"
+)
+
+// stapledOCSP parses the OCSP response stapled during the handshake and
+// returns its status and next update. The signature is verified against the
+// issuer when the server sends one.
+func stapledOCSP(raw []byte, chain []*x509.Certificate, now time.Time) (string, *time.Time) {
+	if len(raw) == 0 || len(chain) == 0 {
+		return "", nil
+	}
+	var issuer *x509.Certificate
+	if len(chain) > 1 {
+		issuer = chain[1]
+	}
+	resp, err := ocsp.ParseResponseForCert(raw, chain[0], issuer)
+	if err != nil {
+		return ocspInvalid, nil
+	}
+	var next *time.Time
+	if !resp.NextUpdate.IsZero() {
+		next = &resp.NextUpdate
+		if now.After(resp.NextUpdate) {
+			return ocspExpired, next
+		}
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return ocspGood, next
+	case ocsp.Revoked:
+		return ocspRevoked, next
+	default:
+		return ocspUnknown, next
+	}
+}
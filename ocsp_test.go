@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func Test_stapledOCSP(t *testing.T) {
+	now := time.Now()
+	newIssuer := func(cn string, parent *farmIssuer, isCA bool) *farmIssuer {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, err := newFarmCert(&x509.Certificate{
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(time.Hour),
+			IsCA:                  isCA,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		}, parent, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi
+	}
+	ca := newIssuer("ocsp test CA", nil, true)
+	other := newIssuer("other CA", nil, true)
+	leaf := newIssuer("leaf", ca, false)
+	chain := []*x509.Certificate{leaf.cert, ca.cert}
+	staple := func(signer *farmIssuer, status int, next time.Time) []byte {
+		t.Helper()
+		b, err := ocsp.CreateResponse(signer.cert, signer.cert, ocsp.Response{
+			Status:       status,
+			SerialNumber: leaf.cert.SerialNumber,
+			ThisUpdate:   now.Add(-time.Hour),
+			NextUpdate:   next,
+			RevokedAt:    now.Add(-time.Minute),
+		}, signer.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	next := now.Add(24 * time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name     string
+		raw      []byte
+		chain    []*x509.Certificate
+		want     string
+		wantNext bool
+	}{
+		{
+			name:     "not stapled",
+			raw:      nil,
+			chain:    chain,
+			want:     "",
+			wantNext: false,
+		},
+		{
+			name:     "good",
+			raw:      staple(ca, ocsp.Good, next),
+			chain:    chain,
+			want:     ocspGood,
+			wantNext: true,
+		},
+		{
+			name:     "revoked",
+			raw:      staple(ca, ocsp.Revoked, next),
+			chain:    chain,
+			want:     ocspRevoked,
+			wantNext: true,
+		},
+		{
+			name:     "unknown",
+			raw:      staple(ca, ocsp.Unknown, next),
+			chain:    chain,
+			want:     ocspUnknown,
+			wantNext: true,
+		},
+		{
+			name:     "expired",
+			raw:      staple(ca, ocsp.Good, now.Add(-time.Minute)),
+			chain:    chain,
+			want:     ocspExpired,
+			wantNext: true,
+		},
+		{
+			name:     "no next update",
+			raw:      staple(ca, ocsp.Good, time.Time{}),
+			chain:    chain,
+			want:     ocspGood,
+			wantNext: false,
+		},
+		{
+			name:     "signed by other CA",
+			raw:      staple(other, ocsp.Good, next),
+			chain:    chain,
+			want:     ocspInvalid,
+			wantNext: false,
+		},
+		{
+			name:     "malformed",
+			raw:      []byte("not an ocsp response"),
+			chain:    chain,
+			want:     ocspInvalid,
+			wantNext: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotNext := stapledOCSP(tt.raw, tt.chain, now)
+			if got != tt.want {
+				t.Errorf("stapledOCSP() status = %q, want %q", got, tt.want)
+			}
+			if (gotNext != nil) != tt.wantNext {
+				t.Errorf("stapledOCSP() nextUpdate = %v, wantNext %v", gotNext, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,1907 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pac is a proxy auto-config script. Without a JavaScript engine at hand it
+// runs the subset that PAC files are written in: functions, var, if/else,
+// loops, switch, return, ternaries, the usual operators, arrays, regular
+// expression literals, the common string methods and the PAC helper
+// functions. Other syntax, such as function expressions, objects and try, is
+// rejected when loading, and other functions when called. A run of the script
+// is bounded by its context, pacMaxSteps and pacMaxLength.
+type pac struct {
+	funcs   map[string]*pacFunc
+	globals map[string]any
+}
+
+// loadPAC reads a PAC script from a file or an http(s) URL.
+func loadPAC(ctx context.Context, location string, timeout time.Duration) (*pac, error) {
+	var src []byte
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get PAC: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("cannot get PAC: unexpected response: %s", resp.Status)
+		}
+		src, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return nil, fmt.Errorf("cannot get PAC: %w", err)
+		}
+	} else {
+		src, err = os.ReadFile(filepath.Clean(location))
+		if err != nil {
+			return nil, err
+		}
+	}
+	p, err := parsePAC(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PAC %s: %w", location, err)
+	}
+	return p, nil
+}
+
+func parsePAC(src string) (*pac, error) {
+	tokens, err := pacTokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	ps := &pacParser{tokens: tokens}
+	p := &pac{
+		funcs:   make(map[string]*pacFunc),
+		globals: make(map[string]any),
+	}
+	var top []pacStmt
+	for !ps.at(pacEOF, "") {
+		if ps.at(pacIdent, "function") {
+			fn, err := ps.function()
+			if err != nil {
+				return nil, err
+			}
+			p.funcs[fn.name] = fn
+			continue
+		}
+		stmt, err := ps.statement()
+		if err != nil {
+			return nil, err
+		}
+		top = append(top, stmt)
+	}
+	if _, ok := p.funcs["FindProxyForURL"]; !ok {
+		return nil, errors.New("no FindProxyForURL function")
+	}
+	// top-level statements only set globals, which need no DNS
+	in := &pacInterp{pac: p, ctx: context.Background(), builtins: pacBuiltins}
+	if _, _, err := in.block(top, p.globals); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parsePACExpr parses a single expression in the syntax of PAC scripts, so
+// that the interpreter can evaluate expressions outside of a script.
+func parsePACExpr(src string) (pacExpr, error) {
+	tokens, err := pacTokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	ps := &pacParser{tokens: tokens}
+	x, err := ps.expr()
+	if err != nil {
+		return nil, err
+	}
+	if !ps.at(pacEOF, "") {
+		return nil, ps.unexpected()
+	}
+	return x, nil
+}
+
+// findProxy calls FindProxyForURL for an https URL to host:port and returns
+// the proxies it chose. r resolves names for the DNS helper functions.
+func (p *pac) findProxy(ctx context.Context, r resolver, host, port string) (string, error) {
+	u := "https://" + host + "/"
+	if port != "443" {
+		u = "https://" + net.JoinHostPort(host, port) + "/"
+	}
+	in := &pacInterp{pac: p, ctx: ctx, resolver: r, builtins: pacBuiltins}
+	v, err := in.call("FindProxyForURL", []any{u, host})
+	if err != nil {
+		return "", fmt.Errorf("cannot run PAC: %w", err)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.New("cannot run PAC: FindProxyForURL did not return a string")
+	}
+	return s, nil
+}
+
+// tokens
+
+const (
+	pacEOF = iota
+	pacIdent
+	pacString
+	pacNumber
+	pacRegexp
+	pacPunct
+)
+
+type pacToken struct {
+	kind int
+	text string
+	line int
+}
+
+var pacPuncts = []string{
+	"===", "!==", "==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=", "%=",
+	"(", ")", "{", "}", "[", "]", ";", ",", ".", "!", "=", "+", "-", "*", "/", "%", "<", ">", "?", ":",
+}
+
+func pacTokenize(src string) ([]pacToken, error) {
+	var tokens []pacToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == '/' && pacRegexpAllowed(tokens):
+			j, class := i+1, false
+			for ; j < len(src) && (src[j] != '/' || class); j++ {
+				switch src[j] {
+				case '\n':
+					return nil, fmt.Errorf("line %d: unterminated regular expression", line)
+				case '\\':
+					j++
+				case '[':
+					class = true
+				case ']':
+					class = false
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated regular expression", line)
+			}
+			for j++; j < len(src) && src[j] >= 'a' && src[j] <= 'z'; j++ {
+			}
+			tokens = append(tokens, pacToken{kind: pacRegexp, text: src[i:j], line: line})
+			i = j
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[j])
+					}
+					continue
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			tokens = append(tokens, pacToken{kind: pacString, text: sb.String(), line: line})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, pacToken{kind: pacNumber, text: src[i:j], line: line})
+			i = j
+		case c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '$' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, pacToken{kind: pacIdent, text: src[i:j], line: line})
+			i = j
+		default:
+			matched := false
+			for _, p := range pacPuncts {
+				if strings.HasPrefix(src[i:], p) {
+					tokens = append(tokens, pacToken{kind: pacPunct, text: p, line: line})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+			}
+		}
+	}
+	return append(tokens, pacToken{kind: pacEOF, line: line}), nil
+}
+
+// pacRegexpAllowed reports whether a slash after tokens starts a regular
+// expression rather than a division, which follows a value.
+func pacRegexpAllowed(tokens []pacToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	t := tokens[len(tokens)-1]
+	switch t.kind {
+	case pacNumber, pacString, pacRegexp:
+		return false
+	case pacIdent:
+		return slices.Contains([]string{"return", "typeof", "case", "else", "do"}, t.text)
+	}
+	return t.text != ")" && t.text != "]"
+}
+
+// syntax tree
+
+type pacFunc struct {
+	name   string
+	params []string
+	body   []pacStmt
+}
+
+type (
+	pacStmt any
+	pacExpr any
+
+	pacVar struct {
+		names  []string
+		values []pacExpr
+	}
+	pacIf struct {
+		cond      pacExpr
+		then, els pacStmt
+	}
+	pacFor struct {
+		init       pacStmt
+		cond, post pacExpr
+		body       pacStmt
+	}
+	pacWhile struct {
+		cond pacExpr
+		body pacStmt
+		do   bool
+	}
+	pacSwitch struct {
+		x     pacExpr
+		cases []pacCase
+	}
+	pacCase struct {
+		x    pacExpr // nil for default
+		body []pacStmt
+	}
+	pacBlock    []pacStmt
+	pacReturn   struct{ x pacExpr }
+	pacBreak    struct{}
+	pacContinue struct{}
+	pacExprStmt struct{ x pacExpr }
+
+	pacLiteral struct{ v any }
+	pacName    struct{ name string }
+	pacUnary   struct {
+		op string
+		x  pacExpr
+	}
+	pacBinary struct {
+		op   string
+		x, y pacExpr
+	}
+	pacCond   struct{ cond, then, els pacExpr }
+	pacAssign struct {
+		target pacExpr
+		op     string
+		x      pacExpr
+	}
+	pacUpdate struct {
+		target pacExpr
+		op     string
+		prefix bool
+	}
+	pacCall struct {
+		fn   pacExpr
+		args []pacExpr
+	}
+	pacMember struct {
+		x    pacExpr
+		name string
+	}
+	pacIndex struct {
+		x, index pacExpr
+	}
+	pacArrayLit struct{ elems []pacExpr }
+)
+
+type pacParser struct {
+	tokens []pacToken
+	pos    int
+}
+
+func (ps *pacParser) peek() pacToken {
+	return ps.tokens[ps.pos]
+}
+
+func (ps *pacParser) next() pacToken {
+	t := ps.tokens[ps.pos]
+	if t.kind != pacEOF {
+		ps.pos++
+	}
+	return t
+}
+
+func (ps *pacParser) at(kind int, text string) bool {
+	t := ps.peek()
+	return t.kind == kind && (text == "" || t.text == text)
+}
+
+func (ps *pacParser) accept(text string) bool {
+	if t := ps.peek(); (t.kind == pacPunct || t.kind == pacIdent) && t.text == text {
+		ps.pos++
+		return true
+	}
+	return false
+}
+
+func (ps *pacParser) expect(text string) error {
+	if !ps.accept(text) {
+		return ps.unexpected()
+	}
+	return nil
+}
+
+func (ps *pacParser) unexpected() error {
+	t := ps.peek()
+	if t.kind == pacEOF {
+		return fmt.Errorf("line %d: unexpected end of script", t.line)
+	}
+	return fmt.Errorf("line %d: unsupported syntax at %q", t.line, t.text)
+}
+
+// newline reports whether the next token starts a line.
+func (ps *pacParser) newline() bool {
+	return ps.pos > 0 && ps.peek().line > ps.tokens[ps.pos-1].line
+}
+
+func (ps *pacParser) ident() (string, error) {
+	if !ps.at(pacIdent, "") {
+		return "", ps.unexpected()
+	}
+	return ps.next().text, nil
+}
+
+func (ps *pacParser) function() (*pacFunc, error) {
+	ps.next()
+	name, err := ps.ident()
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.expect("("); err != nil {
+		return nil, err
+	}
+	fn := &pacFunc{name: name}
+	for !ps.accept(")") {
+		if len(fn.params) > 0 {
+			if err := ps.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		param, err := ps.ident()
+		if err != nil {
+			return nil, err
+		}
+		fn.params = append(fn.params, param)
+	}
+	body, err := ps.block()
+	if err != nil {
+		return nil, err
+	}
+	fn.body = body
+	return fn, nil
+}
+
+func (ps *pacParser) block() (pacBlock, error) {
+	if err := ps.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts pacBlock
+	for !ps.accept("}") {
+		if ps.at(pacEOF, "") {
+			return nil, ps.unexpected()
+		}
+		stmt, err := ps.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// paren parses an expression in parentheses, as the condition of if.
+func (ps *pacParser) paren() (pacExpr, error) {
+	if err := ps.expect("("); err != nil {
+		return nil, err
+	}
+	x, err := ps.expr()
+	if err != nil {
+		return nil, err
+	}
+	return x, ps.expect(")")
+}
+
+func (ps *pacParser) statement() (pacStmt, error) {
+	switch {
+	case ps.at(pacPunct, "{"):
+		return ps.block()
+	case ps.accept(";"):
+		return pacBlock(nil), nil
+	case ps.accept("var"), ps.accept("let"), ps.accept("const"):
+		v := &pacVar{}
+		for {
+			name, err := ps.ident()
+			if err != nil {
+				return nil, err
+			}
+			var x pacExpr = pacLiteral{}
+			if ps.accept("=") {
+				if x, err = ps.expr(); err != nil {
+					return nil, err
+				}
+			}
+			v.names = append(v.names, name)
+			v.values = append(v.values, x)
+			if !ps.accept(",") {
+				break
+			}
+		}
+		return v, ps.end()
+	case ps.accept("if"):
+		cond, err := ps.paren()
+		if err != nil {
+			return nil, err
+		}
+		stmt := &pacIf{cond: cond}
+		if stmt.then, err = ps.statement(); err != nil {
+			return nil, err
+		}
+		if ps.accept("else") {
+			if stmt.els, err = ps.statement(); err != nil {
+				return nil, err
+			}
+		}
+		return stmt, nil
+	case ps.accept("for"):
+		return ps.forStatement()
+	case ps.accept("while"):
+		cond, err := ps.paren()
+		if err != nil {
+			return nil, err
+		}
+		body, err := ps.statement()
+		if err != nil {
+			return nil, err
+		}
+		return &pacWhile{cond: cond, body: body}, nil
+	case ps.accept("do"):
+		body, err := ps.statement()
+		if err != nil {
+			return nil, err
+		}
+		if err := ps.expect("while"); err != nil {
+			return nil, err
+		}
+		cond, err := ps.paren()
+		if err != nil {
+			return nil, err
+		}
+		return &pacWhile{cond: cond, body: body, do: true}, ps.end()
+	case ps.accept("switch"):
+		return ps.switchStatement()
+	case ps.accept("break"):
+		return pacBreak{}, ps.end()
+	case ps.accept("continue"):
+		return pacContinue{}, ps.end()
+	case ps.accept("return"):
+		if ps.accept(";") || ps.at(pacPunct, "}") || ps.newline() {
+			return &pacReturn{x: pacLiteral{}}, nil
+		}
+		x, err := ps.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &pacReturn{x: x}, ps.end()
+	}
+	if t := ps.peek(); t.kind == pacIdent {
+		switch t.text {
+		case "try", "function", "new", "throw", "with":
+			return nil, ps.unexpected()
+		}
+	}
+	x, err := ps.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &pacExprStmt{x: x}, ps.end()
+}
+
+func (ps *pacParser) forStatement() (pacStmt, error) {
+	if err := ps.expect("("); err != nil {
+		return nil, err
+	}
+	stmt := &pacFor{}
+	switch {
+	case ps.accept(";"):
+	case ps.at(pacIdent, "var"), ps.at(pacIdent, "let"), ps.at(pacIdent, "const"):
+		// the statement consumes the semicolon; for-in loops fail here
+		init, err := ps.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmt.init = init
+	default:
+		x, err := ps.expr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.init = &pacExprStmt{x: x}
+		if err := ps.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+	if !ps.accept(";") {
+		cond, err := ps.expr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.cond = cond
+		if err := ps.expect(";"); err != nil {
+			return nil, err
+		}
+	}
+	if !ps.accept(")") {
+		post, err := ps.expr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.post = post
+		if err := ps.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	body, err := ps.statement()
+	if err != nil {
+		return nil, err
+	}
+	stmt.body = body
+	return stmt, nil
+}
+
+func (ps *pacParser) switchStatement() (pacStmt, error) {
+	x, err := ps.paren()
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.expect("{"); err != nil {
+		return nil, err
+	}
+	stmt := &pacSwitch{x: x}
+	for !ps.accept("}") {
+		var c pacCase
+		if !ps.accept("default") {
+			if err := ps.expect("case"); err != nil {
+				return nil, err
+			}
+			if c.x, err = ps.expr(); err != nil {
+				return nil, err
+			}
+		}
+		if err := ps.expect(":"); err != nil {
+			return nil, err
+		}
+		for !ps.at(pacPunct, "}") && !ps.at(pacIdent, "case") && !ps.at(pacIdent, "default") {
+			if ps.at(pacEOF, "") {
+				return nil, ps.unexpected()
+			}
+			s, err := ps.statement()
+			if err != nil {
+				return nil, err
+			}
+			c.body = append(c.body, s)
+		}
+		stmt.cases = append(stmt.cases, c)
+	}
+	return stmt, nil
+}
+
+// end consumes the semicolon of a statement, which may be left out before a
+// closing brace or a line break.
+func (ps *pacParser) end() error {
+	if ps.accept(";") || ps.at(pacPunct, "}") || ps.at(pacEOF, "") || ps.newline() {
+		return nil
+	}
+	return ps.unexpected()
+}
+
+var pacAssignOps = []string{"=", "+=", "-=", "*=", "/=", "%="}
+
+func (ps *pacParser) expr() (pacExpr, error) {
+	cond, err := ps.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := ps.peek(); t.kind == pacPunct && slices.Contains(pacAssignOps, t.text) {
+		if !pacAssignable(cond) {
+			return nil, ps.unexpected()
+		}
+		ps.next()
+		x, err := ps.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &pacAssign{target: cond, op: t.text, x: x}, nil
+	}
+	if !ps.accept("?") {
+		return cond, nil
+	}
+	then, err := ps.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := ps.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &pacCond{cond: cond, then: then, els: els}, nil
+}
+
+// pacAssignable reports whether x can be assigned to: a variable or an
+// element of an array.
+func pacAssignable(x pacExpr) bool {
+	switch x.(type) {
+	case *pacName, *pacIndex:
+		return true
+	}
+	return false
+}
+
+// pacPrecedence lists the binary operators from the loosest binding.
+var pacPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "===", "!=="},
+	{"<", ">", "<=", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (ps *pacParser) binary(level int) (pacExpr, error) {
+	if level == len(pacPrecedence) {
+		return ps.unary()
+	}
+	x, err := ps.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := ps.peek()
+		if t.kind != pacPunct || !slices.Contains(pacPrecedence[level], t.text) {
+			return x, nil
+		}
+		ps.next()
+		y, err := ps.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &pacBinary{op: t.text, x: x, y: y}
+	}
+}
+
+func (ps *pacParser) unary() (pacExpr, error) {
+	for _, op := range []string{"!", "-", "+", "typeof"} {
+		if ps.accept(op) {
+			x, err := ps.unary()
+			return &pacUnary{op: op, x: x}, err
+		}
+	}
+	for _, op := range []string{"++", "--"} {
+		if ps.accept(op) {
+			x, err := ps.unary()
+			if err != nil {
+				return nil, err
+			}
+			if !pacAssignable(x) {
+				return nil, ps.unexpected()
+			}
+			return &pacUpdate{target: x, op: op, prefix: true}, nil
+		}
+	}
+	return ps.postfix()
+}
+
+func (ps *pacParser) postfix() (pacExpr, error) {
+	x, err := ps.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case ps.accept("("):
+			call := &pacCall{fn: x}
+			for !ps.accept(")") {
+				if len(call.args) > 0 {
+					if err := ps.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				arg, err := ps.expr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+			}
+			x = call
+		case ps.accept("."):
+			name, err := ps.ident()
+			if err != nil {
+				return nil, err
+			}
+			x = &pacMember{x: x, name: name}
+		case ps.accept("["):
+			index, err := ps.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := ps.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &pacIndex{x: x, index: index}
+		case pacAssignable(x) && !ps.newline() && (ps.at(pacPunct, "++") || ps.at(pacPunct, "--")):
+			return &pacUpdate{target: x, op: ps.next().text}, nil
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (ps *pacParser) primary() (pacExpr, error) {
+	t := ps.peek()
+	switch t.kind {
+	case pacString:
+		ps.next()
+		return pacLiteral{v: t.text}, nil
+	case pacNumber:
+		ps.next()
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid number %q", t.line, t.text)
+		}
+		return pacLiteral{v: n}, nil
+	case pacRegexp:
+		ps.next()
+		re, err := newPACRegexp(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", t.line, err)
+		}
+		return pacLiteral{v: re}, nil
+	case pacIdent:
+		switch t.text {
+		case "true", "false":
+			ps.next()
+			return pacLiteral{v: t.text == "true"}, nil
+		case "null", "undefined":
+			ps.next()
+			return pacLiteral{}, nil
+		case "function", "new", "this":
+			return nil, ps.unexpected()
+		}
+		ps.next()
+		return &pacName{name: t.text}, nil
+	}
+	if ps.accept("(") {
+		x, err := ps.expr()
+		if err != nil {
+			return nil, err
+		}
+		return x, ps.expect(")")
+	}
+	if ps.accept("[") {
+		a := &pacArrayLit{}
+		for !ps.accept("]") {
+			if len(a.elems) > 0 {
+				if err := ps.expect(","); err != nil {
+					return nil, err
+				}
+				// a trailing comma ends the list
+				if ps.accept("]") {
+					break
+				}
+			}
+			x, err := ps.expr()
+			if err != nil {
+				return nil, err
+			}
+			a.elems = append(a.elems, x)
+		}
+		return a, nil
+	}
+	return nil, ps.unexpected()
+}
+
+// values
+
+// pacArray is an array of a script, shared by reference as in JavaScript.
+type pacArray struct {
+	elems []any
+}
+
+// pacRegexpValue is a regular expression literal. The syntax is that of Go,
+// which covers the patterns of PAC scripts but lookarounds and
+// backreferences.
+type pacRegexpValue struct {
+	re     *regexp.Regexp
+	src    string
+	global bool
+}
+
+func newPACRegexp(literal string) (*pacRegexpValue, error) {
+	end := strings.LastIndexByte(literal, '/')
+	pattern, flags := literal[1:end], literal[end+1:]
+	v := &pacRegexpValue{src: literal}
+	var prefix string
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			v.global = true
+		case 'i', 'm':
+			prefix += string(f)
+		default:
+			return nil, fmt.Errorf("unsupported flag %q in regular expression %s", f, literal)
+		}
+	}
+	if prefix != "" {
+		pattern = "(?" + prefix + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported regular expression %s: %w", literal, err)
+	}
+	v.re = re
+	return v, nil
+}
+
+// evaluation
+
+const (
+	// pacMaxSteps caps the statements and expressions evaluated by a run of a
+	// script, so that a loop that never ends fails instead of hanging.
+	pacMaxSteps = 1 << 20
+
+	// pacMaxLength caps the length of strings and arrays built by a script.
+	pacMaxLength = 1 << 20
+)
+
+type pacInterp struct {
+	pac      *pac
+	ctx      context.Context
+	resolver resolver
+	builtins map[string]pacBuiltin
+	now      time.Time
+	depth    int
+	steps    int
+}
+
+type pacScope map[string]any
+
+// pacFlow tells how a statement left its block.
+type pacFlow int
+
+const (
+	pacNext pacFlow = iota
+	pacReturned
+	pacBroke
+	pacContinued
+)
+
+// step counts a statement or an expression against pacMaxSteps, and checks
+// ctx now and then.
+func (in *pacInterp) step() error {
+	in.steps++
+	if in.steps > pacMaxSteps {
+		return errors.New("too many steps")
+	}
+	if in.steps%1024 == 0 {
+		return in.ctx.Err()
+	}
+	return nil
+}
+
+func (in *pacInterp) call(name string, args []any) (any, error) {
+	if err := in.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if fn, ok := in.pac.funcs[name]; ok {
+		in.depth++
+		defer func() { in.depth-- }()
+		if in.depth > 64 {
+			return nil, errors.New("call stack exceeded")
+		}
+		scope := pacScope{}
+		for i, param := range fn.params {
+			if i < len(args) {
+				scope[param] = args[i]
+			} else {
+				scope[param] = nil
+			}
+		}
+		v, _, err := in.block(fn.body, scope)
+		return v, err
+	}
+	return in.builtin(name, args)
+}
+
+// block runs stmts until one of them returns, breaks or continues.
+func (in *pacInterp) block(stmts []pacStmt, scope pacScope) (any, pacFlow, error) {
+	for _, stmt := range stmts {
+		v, flow, err := in.stmt(stmt, scope)
+		if err != nil || flow != pacNext {
+			return v, flow, err
+		}
+	}
+	return nil, pacNext, nil
+}
+
+func (in *pacInterp) stmt(stmt pacStmt, scope pacScope) (any, pacFlow, error) {
+	if err := in.step(); err != nil {
+		return nil, pacNext, err
+	}
+	switch s := stmt.(type) {
+	case pacBlock:
+		return in.block(s, scope)
+	case *pacVar:
+		for i, name := range s.names {
+			v, err := in.eval(s.values[i], scope)
+			if err != nil {
+				return nil, pacNext, err
+			}
+			scope[name] = v
+		}
+	case *pacIf:
+		cond, err := in.eval(s.cond, scope)
+		if err != nil {
+			return nil, pacNext, err
+		}
+		if pacTruthy(cond) {
+			return in.stmt(s.then, scope)
+		}
+		if s.els != nil {
+			return in.stmt(s.els, scope)
+		}
+	case *pacFor:
+		if s.init != nil {
+			if _, _, err := in.stmt(s.init, scope); err != nil {
+				return nil, pacNext, err
+			}
+		}
+		return in.loop(s.cond, s.body, s.post, false, scope)
+	case *pacWhile:
+		return in.loop(s.cond, s.body, nil, s.do, scope)
+	case *pacSwitch:
+		return in.switchStmt(s, scope)
+	case *pacReturn:
+		v, err := in.eval(s.x, scope)
+		return v, pacReturned, err
+	case pacBreak:
+		return nil, pacBroke, nil
+	case pacContinue:
+		return nil, pacContinued, nil
+	case *pacExprStmt:
+		_, err := in.eval(s.x, scope)
+		return nil, pacNext, err
+	}
+	return nil, pacNext, nil
+}
+
+// loop runs body while cond holds, evaluating post after each run; a nil cond
+// always holds. A do loop runs body once before checking cond.
+func (in *pacInterp) loop(cond pacExpr, body pacStmt, post pacExpr, do bool, scope pacScope) (any, pacFlow, error) {
+	for first := true; ; first = false {
+		if cond != nil && !(do && first) {
+			v, err := in.eval(cond, scope)
+			if err != nil {
+				return nil, pacNext, err
+			}
+			if !pacTruthy(v) {
+				return nil, pacNext, nil
+			}
+		}
+		v, flow, err := in.stmt(body, scope)
+		if err != nil || flow == pacReturned {
+			return v, flow, err
+		}
+		if flow == pacBroke {
+			return nil, pacNext, nil
+		}
+		if post != nil {
+			if _, err := in.eval(post, scope); err != nil {
+				return nil, pacNext, err
+			}
+		}
+	}
+}
+
+// switchStmt runs the statements from the case strictly equal to the value,
+// or from default, until a break.
+func (in *pacInterp) switchStmt(s *pacSwitch, scope pacScope) (any, pacFlow, error) {
+	v, err := in.eval(s.x, scope)
+	if err != nil {
+		return nil, pacNext, err
+	}
+	start := -1
+	for i, c := range s.cases {
+		if c.x == nil {
+			continue
+		}
+		cv, err := in.eval(c.x, scope)
+		if err != nil {
+			return nil, pacNext, err
+		}
+		if v == cv {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		start = slices.IndexFunc(s.cases, func(c pacCase) bool { return c.x == nil })
+		if start < 0 {
+			return nil, pacNext, nil
+		}
+	}
+	for _, c := range s.cases[start:] {
+		v, flow, err := in.block(c.body, scope)
+		if flow == pacBroke {
+			return nil, pacNext, err
+		}
+		if err != nil || flow != pacNext {
+			return v, flow, err
+		}
+	}
+	return nil, pacNext, nil
+}
+
+func (in *pacInterp) lookup(name string, scope pacScope) (any, error) {
+	if v, ok := scope[name]; ok {
+		return v, nil
+	}
+	if v, ok := in.pac.globals[name]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s is not defined", name)
+}
+
+func (in *pacInterp) eval(x pacExpr, scope pacScope) (any, error) {
+	if err := in.step(); err != nil {
+		return nil, err
+	}
+	switch e := x.(type) {
+	case pacLiteral:
+		return e.v, nil
+	case *pacName:
+		return in.lookup(e.name, scope)
+	case *pacArrayLit:
+		a := &pacArray{elems: make([]any, len(e.elems))}
+		for i, elem := range e.elems {
+			v, err := in.eval(elem, scope)
+			if err != nil {
+				return nil, err
+			}
+			a.elems[i] = v
+		}
+		return a, nil
+	case *pacAssign:
+		v, err := in.eval(e.x, scope)
+		if err != nil {
+			return nil, err
+		}
+		if e.op != "=" {
+			old, err := in.eval(e.target, scope)
+			if err != nil {
+				return nil, err
+			}
+			if v, err = pacOperate(strings.TrimSuffix(e.op, "="), old, v); err != nil {
+				return nil, err
+			}
+		}
+		return v, in.assign(e.target, v, scope)
+	case *pacUpdate:
+		old, err := in.eval(e.target, scope)
+		if err != nil {
+			return nil, err
+		}
+		n := pacNumberOf(old)
+		v := n + 1
+		if e.op == "--" {
+			v = n - 1
+		}
+		if err := in.assign(e.target, v, scope); err != nil {
+			return nil, err
+		}
+		if e.prefix {
+			return v, nil
+		}
+		return n, nil
+	case *pacUnary:
+		if e.op == "typeof" {
+			return in.typeOf(e.x, scope)
+		}
+		v, err := in.eval(e.x, scope)
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "!":
+			return !pacTruthy(v), nil
+		case "+":
+			return pacNumberOf(v), nil
+		}
+		return -pacNumberOf(v), nil
+	case *pacCond:
+		cond, err := in.eval(e.cond, scope)
+		if err != nil {
+			return nil, err
+		}
+		if pacTruthy(cond) {
+			return in.eval(e.then, scope)
+		}
+		return in.eval(e.els, scope)
+	case *pacBinary:
+		return in.binary(e, scope)
+	case *pacMember:
+		v, err := in.eval(e.x, scope)
+		if err != nil {
+			return nil, err
+		}
+		if e.name == "length" {
+			switch v := v.(type) {
+			case string:
+				return float64(len(v)), nil
+			case *pacArray:
+				return float64(len(v.elems)), nil
+			}
+		}
+		return nil, fmt.Errorf("property %s is not supported", e.name)
+	case *pacIndex:
+		v, err := in.eval(e.x, scope)
+		if err != nil {
+			return nil, err
+		}
+		index, err := in.eval(e.index, scope)
+		if err != nil {
+			return nil, err
+		}
+		i := int(pacNumberOf(index))
+		switch v := v.(type) {
+		case string:
+			if i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			return v[i : i+1], nil
+		case *pacArray:
+			if i < 0 || i >= len(v.elems) {
+				return nil, nil
+			}
+			return v.elems[i], nil
+		}
+		return nil, errors.New("index of a value that is neither a string nor an array")
+	case *pacCall:
+		args := make([]any, len(e.args))
+		for i, arg := range e.args {
+			v, err := in.eval(arg, scope)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		switch fn := e.fn.(type) {
+		case *pacName:
+			return in.call(fn.name, args)
+		case *pacMember:
+			recv, err := in.eval(fn.x, scope)
+			if err != nil {
+				return nil, err
+			}
+			return pacMethod(recv, fn.name, args)
+		}
+		return nil, errors.New("call of a non-function")
+	}
+	return nil, fmt.Errorf("unsupported expression %T", x)
+}
+
+// assign sets a variable, or an element of an array, to v.
+func (in *pacInterp) assign(target pacExpr, v any, scope pacScope) error {
+	switch t := target.(type) {
+	case *pacName:
+		// globals are shared by concurrent calls, so a function assigning to
+		// one only changes its own copy
+		scope[t.name] = v
+		return nil
+	case *pacIndex:
+		x, err := in.eval(t.x, scope)
+		if err != nil {
+			return err
+		}
+		a, ok := x.(*pacArray)
+		if !ok {
+			return errors.New("assignment to an index of a value that is not an array")
+		}
+		index, err := in.eval(t.index, scope)
+		if err != nil {
+			return err
+		}
+		i := int(pacNumberOf(index))
+		if i < 0 || i >= pacMaxLength {
+			return fmt.Errorf("invalid array index %d", i)
+		}
+		for len(a.elems) <= i {
+			a.elems = append(a.elems, nil)
+		}
+		a.elems[i] = v
+		return nil
+	}
+	return errors.New("assignment to a non-variable")
+}
+
+// typeOf returns the type of the value of x as typeof does, which is
+// "undefined" rather than an error for a name that is not defined.
+func (in *pacInterp) typeOf(x pacExpr, scope pacScope) (any, error) {
+	if n, ok := x.(*pacName); ok {
+		if _, err := in.lookup(n.name, scope); err != nil {
+			if _, ok := in.pac.funcs[n.name]; ok {
+				return "function", nil
+			}
+			if _, ok := in.builtins[n.name]; ok {
+				return "function", nil
+			}
+			return "undefined", nil
+		}
+	}
+	v, err := in.eval(x, scope)
+	if err != nil {
+		return nil, err
+	}
+	switch v.(type) {
+	case nil:
+		return "undefined", nil
+	case bool:
+		return "boolean", nil
+	case string:
+		return "string", nil
+	case float64:
+		return "number", nil
+	}
+	return "object", nil
+}
+
+func (in *pacInterp) binary(e *pacBinary, scope pacScope) (any, error) {
+	x, err := in.eval(e.x, scope)
+	if err != nil {
+		return nil, err
+	}
+	// logical operators evaluate the right side only when needed and return
+	// the deciding operand, as in JavaScript
+	switch e.op {
+	case "||":
+		if pacTruthy(x) {
+			return x, nil
+		}
+		return in.eval(e.y, scope)
+	case "&&":
+		if !pacTruthy(x) {
+			return x, nil
+		}
+		return in.eval(e.y, scope)
+	}
+	y, err := in.eval(e.y, scope)
+	if err != nil {
+		return nil, err
+	}
+	return pacOperate(e.op, x, y)
+}
+
+// pacOperate applies a binary operator other than the logical ones.
+func pacOperate(op string, x, y any) (any, error) {
+	switch op {
+	case "==":
+		return pacLooseEqual(x, y), nil
+	case "!=":
+		return !pacLooseEqual(x, y), nil
+	case "===":
+		return x == y, nil
+	case "!==":
+		return x != y, nil
+	case "+":
+		xs, xok := x.(string)
+		ys, yok := y.(string)
+		if xok || yok {
+			if !xok {
+				xs = pacToString(x)
+			}
+			if !yok {
+				ys = pacToString(y)
+			}
+			if len(xs)+len(ys) > pacMaxLength {
+				return nil, errors.New("string too long")
+			}
+			return xs + ys, nil
+		}
+		return pacNumberOf(x) + pacNumberOf(y), nil
+	case "-":
+		return pacNumberOf(x) - pacNumberOf(y), nil
+	case "*":
+		return pacNumberOf(x) * pacNumberOf(y), nil
+	case "/":
+		return pacNumberOf(x) / pacNumberOf(y), nil
+	case "%":
+		return math.Mod(pacNumberOf(x), pacNumberOf(y)), nil
+	}
+	xs, xok := x.(string)
+	ys, yok := y.(string)
+	if xok && yok {
+		switch op {
+		case "<":
+			return xs < ys, nil
+		case ">":
+			return xs > ys, nil
+		case "<=":
+			return xs <= ys, nil
+		default:
+			return xs >= ys, nil
+		}
+	}
+	a, b := pacNumberOf(x), pacNumberOf(y)
+	switch op {
+	case "<":
+		return a < b, nil
+	case ">":
+		return a > b, nil
+	case "<=":
+		return a <= b, nil
+	default:
+		return a >= b, nil
+	}
+}
+
+func pacTruthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && v == v
+	}
+	return true
+}
+
+func pacNumberOf(v any) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	return 0
+}
+
+func pacToString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case *pacArray:
+		return pacJoin(v, ",")
+	case *pacRegexpValue:
+		return v.src
+	}
+	return fmt.Sprint(v)
+}
+
+// pacJoin joins the elements of a, with nulls as empty strings.
+func pacJoin(a *pacArray, sep string) string {
+	s := make([]string, len(a.elems))
+	for i, v := range a.elems {
+		if v != nil {
+			s[i] = pacToString(v)
+		}
+	}
+	return strings.Join(s, sep)
+}
+
+func pacLooseEqual(x, y any) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	if _, ok := x.(float64); ok {
+		return x == pacNumberOf(y)
+	}
+	if _, ok := y.(float64); ok {
+		return pacNumberOf(x) == y
+	}
+	return x == y
+}
+
+// pacStrings returns ss as an array of a script.
+func pacStrings(ss []string) *pacArray {
+	a := &pacArray{elems: make([]any, len(ss))}
+	for i, s := range ss {
+		a.elems[i] = s
+	}
+	return a
+}
+
+func pacMethod(recv any, name string, args []any) (any, error) {
+	switch recv := recv.(type) {
+	case string:
+		return pacStringMethod(recv, name, args)
+	case *pacArray:
+		switch name {
+		case "indexOf":
+			if len(args) > 0 {
+				if i := slices.Index(recv.elems, args[0]); i >= 0 {
+					return float64(i), nil
+				}
+			}
+			return float64(-1), nil
+		case "join":
+			sep := ","
+			if len(args) > 0 && args[0] != nil {
+				sep = pacToString(args[0])
+			}
+			s := pacJoin(recv, sep)
+			if len(s) > pacMaxLength {
+				return nil, errors.New("string too long")
+			}
+			return s, nil
+		case "push":
+			if len(recv.elems)+len(args) > pacMaxLength {
+				return nil, errors.New("array too long")
+			}
+			recv.elems = append(recv.elems, args...)
+			return float64(len(recv.elems)), nil
+		}
+	case *pacRegexpValue:
+		if name == "test" {
+			return recv.re.MatchString(pacArg(args, 0)), nil
+		}
+	}
+	return nil, fmt.Errorf("method %s is not supported", name)
+}
+
+func pacStringMethod(s, name string, args []any) (any, error) {
+	num := func(i, def int) int {
+		if i < len(args) && args[i] != nil {
+			return int(pacNumberOf(args[i]))
+		}
+		return def
+	}
+	switch name {
+	case "toLowerCase":
+		return strings.ToLower(s), nil
+	case "toUpperCase":
+		return strings.ToUpper(s), nil
+	case "trim":
+		return strings.TrimSpace(s), nil
+	case "indexOf":
+		return float64(strings.Index(s, pacArg(args, 0))), nil
+	case "lastIndexOf":
+		return float64(strings.LastIndex(s, pacArg(args, 0))), nil
+	case "startsWith":
+		return strings.HasPrefix(s, pacArg(args, 0)), nil
+	case "endsWith":
+		return strings.HasSuffix(s, pacArg(args, 0)), nil
+	case "charAt":
+		i := num(0, 0)
+		if i < 0 || i >= len(s) {
+			return "", nil
+		}
+		return s[i : i+1], nil
+	case "substring":
+		start := min(max(num(0, 0), 0), len(s))
+		end := min(max(num(1, len(s)), 0), len(s))
+		if start > end {
+			start, end = end, start
+		}
+		return s[start:end], nil
+	case "substr":
+		start := num(0, 0)
+		if start < 0 {
+			start = max(len(s)+start, 0)
+		}
+		start = min(start, len(s))
+		end := min(start+max(num(1, len(s)), 0), len(s))
+		return s[start:end], nil
+	case "split":
+		if len(args) == 0 || args[0] == nil {
+			return pacStrings([]string{s}), nil
+		}
+		if re, ok := args[0].(*pacRegexpValue); ok {
+			return pacStrings(re.re.Split(s, -1)), nil
+		}
+		return pacStrings(strings.Split(s, pacArg(args, 0))), nil
+	case "replace":
+		repl := pacArg(args, 1)
+		re, ok := pacArgAt(args, 0).(*pacRegexpValue)
+		if !ok {
+			return strings.Replace(s, pacArg(args, 0), repl, 1), nil
+		}
+		if re.global {
+			return re.re.ReplaceAllString(s, repl), nil
+		}
+		loc := re.re.FindStringSubmatchIndex(s)
+		if loc == nil {
+			return s, nil
+		}
+		return s[:loc[0]] + string(re.re.ExpandString(nil, repl, s, loc)) + s[loc[1]:], nil
+	case "match":
+		re, ok := pacArgAt(args, 0).(*pacRegexpValue)
+		if !ok {
+			return nil, errors.New("match of a value that is not a regular expression")
+		}
+		var m []string
+		if re.global {
+			m = re.re.FindAllString(s, -1)
+		} else {
+			m = re.re.FindStringSubmatch(s)
+		}
+		if m == nil {
+			return nil, nil
+		}
+		return pacStrings(m), nil
+	}
+	return nil, fmt.Errorf("method %s is not supported", name)
+}
+
+// pacBuiltin is a function that scripts can call besides their own.
+type pacBuiltin func(in *pacInterp, args []any) (any, error)
+
+// pacArg returns the i-th argument as a string, or "" when not given.
+func pacArg(args []any, i int) string {
+	if i < len(args) {
+		return pacToString(args[i])
+	}
+	return ""
+}
+
+// pacArgAt returns the i-th argument, or nil when not given.
+func pacArgAt(args []any, i int) any {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+// pacPureBuiltins are the PAC helper functions that work on their arguments
+// alone, without DNS or the network.
+var pacPureBuiltins = map[string]pacBuiltin{
+	"isPlainHostName": func(_ *pacInterp, args []any) (any, error) {
+		return !strings.Contains(pacArg(args, 0), "."), nil
+	},
+	"dnsDomainIs": func(_ *pacInterp, args []any) (any, error) {
+		return strings.HasSuffix(strings.ToLower(pacArg(args, 0)), strings.ToLower(pacArg(args, 1))), nil
+	},
+	"localHostOrDomainIs": func(_ *pacInterp, args []any) (any, error) {
+		host, hostdom := strings.ToLower(pacArg(args, 0)), strings.ToLower(pacArg(args, 1))
+		return host == hostdom || !strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+"."), nil
+	},
+	"dnsDomainLevels": func(_ *pacInterp, args []any) (any, error) {
+		return float64(strings.Count(pacArg(args, 0), ".")), nil
+	},
+	"shExpMatch": func(_ *pacInterp, args []any) (any, error) {
+		return shExpMatch(pacArg(args, 0), pacArg(args, 1)), nil
+	},
+	"convert_addr": func(_ *pacInterp, args []any) (any, error) {
+		ip := net.ParseIP(pacArg(args, 0)).To4()
+		if ip == nil {
+			return float64(0), nil
+		}
+		return float64(uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])), nil
+	},
+	"alert": func(_ *pacInterp, _ []any) (any, error) {
+		return nil, nil
+	},
+	"weekdayRange": pacWeekdayRange,
+	"dateRange":    pacDateRange,
+	"timeRange":    pacTimeRange,
+}
+
+// pacBuiltins are all the PAC helper functions, including those that
+// resolve names and find the local address.
+var pacBuiltins = withBuiltins(pacPureBuiltins, map[string]pacBuiltin{
+	"isResolvable": func(in *pacInterp, args []any) (any, error) {
+		return in.resolve(pacArg(args, 0)) != nil, nil
+	},
+	"dnsResolve": func(in *pacInterp, args []any) (any, error) {
+		if ip := in.resolve(pacArg(args, 0)); ip != nil {
+			return ip.String(), nil
+		}
+		return nil, nil
+	},
+	"isInNet": func(in *pacInterp, args []any) (any, error) {
+		ip := in.resolve(pacArg(args, 0))
+		pattern, mask := net.ParseIP(pacArg(args, 1)).To4(), net.ParseIP(pacArg(args, 2)).To4()
+		if ip == nil || pattern == nil || mask == nil {
+			return false, nil
+		}
+		m := net.IPMask(mask)
+		return ip.Mask(m).Equal(pattern.Mask(m)), nil
+	},
+	"myIpAddress": func(_ *pacInterp, _ []any) (any, error) {
+		return myIPAddress(), nil
+	},
+})
+
+// withBuiltins returns a set of the builtins of base and extra, extra taking
+// precedence.
+func withBuiltins(base, extra map[string]pacBuiltin) map[string]pacBuiltin {
+	res := maps.Clone(base)
+	maps.Copy(res, extra)
+	return res
+}
+
+// builtin runs a function of the builtins given to the interpreter.
+func (in *pacInterp) builtin(name string, args []any) (any, error) {
+	fn, ok := in.builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("function %s is not supported", name)
+	}
+	return fn(in, args)
+}
+
+// resolve returns the IPv4 address of host, which PAC helpers work with.
+func (in *pacInterp) resolve(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4()
+	}
+	if in.resolver == nil {
+		return nil
+	}
+	ips, _, err := in.resolver.lookupIP(in.ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+	}
+	return nil
+}
+
+// myIPAddress returns the address of the interface that routes outside. No
+// packet is sent when connecting a UDP socket.
+func myIPAddress() string {
+	conn, err := net.Dial("udp4", "192.0.2.1:80")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP.String()
+	}
+	return "127.0.0.1"
+}
+
+// shExpMatch matches str against a shell expression where * matches any run
+// of characters, slashes included, and ? a single one.
+func shExpMatch(str, shexp string) bool {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range shexp {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(str)
+}
+
+// clock returns the time the date helpers see: in UTC when the last of args
+// is "GMT", which is then dropped from args, and in the local time zone
+// otherwise.
+func (in *pacInterp) clock(args []any) (time.Time, []any) {
+	now := in.now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if n := len(args); n > 0 && args[n-1] == "GMT" {
+		return now.UTC(), args[:n-1]
+	}
+	return now.Local(), args
+}
+
+// pacInRange reports whether v is within lo and hi, both included, wrapping
+// around when lo is above hi as in FRI to MON.
+func pacInRange(v, lo, hi int) bool {
+	if lo <= hi {
+		return lo <= v && v <= hi
+	}
+	return v >= lo || v <= hi
+}
+
+var (
+	pacWeekdays = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+	pacMonths   = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+)
+
+// pacWeekdayRange is weekdayRange(wd1[, wd2][, "GMT"]).
+func pacWeekdayRange(in *pacInterp, args []any) (any, error) {
+	now, args := in.clock(args)
+	wd1 := slices.Index(pacWeekdays, pacArg(args, 0))
+	if wd1 < 0 {
+		return false, nil
+	}
+	if len(args) < 2 {
+		return int(now.Weekday()) == wd1, nil
+	}
+	wd2 := slices.Index(pacWeekdays, pacArg(args, 1))
+	if wd2 < 0 {
+		return false, nil
+	}
+	return pacInRange(int(now.Weekday()), wd1, wd2), nil
+}
+
+// pacDateRange is dateRange with a day, a month, a year or a range of two of
+// the same kind, such as (1, "JAN", 15, "JAN") or ("JAN", 2026, "MAR", 2026),
+// followed by "GMT" optionally.
+func pacDateRange(in *pacInterp, args []any) (any, error) {
+	now, args := in.clock(args)
+	// a date is encoded as year*10000+month*100+day, with the parts given
+	const (
+		day = 1 << iota
+		month
+		year
+	)
+	date := func(args []any) (int, int, bool) {
+		var kinds, v int
+		for _, arg := range args {
+			if i := slices.Index(pacMonths, pacToString(arg)); i >= 0 {
+				kinds, v = kinds|month, v+(i+1)*100
+				continue
+			}
+			n, ok := arg.(float64)
+			switch {
+			case !ok || n < 1:
+				return 0, 0, false
+			case n <= 31:
+				kinds, v = kinds|day, v+int(n)
+			default:
+				kinds, v = kinds|year, v+int(n)*10000
+			}
+		}
+		return kinds, v, true
+	}
+	var lo, hi []any
+	switch len(args) {
+	case 1:
+		lo, hi = args, args
+	case 2, 4, 6:
+		lo, hi = args[:len(args)/2], args[len(args)/2:]
+	default:
+		return false, nil
+	}
+	kinds, from, ok := date(lo)
+	hiKinds, to, hiOK := date(hi)
+	if !ok || !hiOK || kinds != hiKinds {
+		return false, nil
+	}
+	var today int
+	if kinds&day != 0 {
+		today += now.Day()
+	}
+	if kinds&month != 0 {
+		today += int(now.Month()) * 100
+	}
+	if kinds&year != 0 {
+		today += now.Year() * 10000
+	}
+	return pacInRange(today, from, to), nil
+}
+
+// pacTimeRange is timeRange with an hour, two hours, the end excluded, or two
+// times of hours and minutes, or of hours, minutes and seconds, followed by
+// "GMT" optionally.
+func pacTimeRange(in *pacInterp, args []any) (any, error) {
+	now, args := in.clock(args)
+	n := make([]int, len(args))
+	for i, arg := range args {
+		n[i] = int(pacNumberOf(arg))
+	}
+	secs := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	switch len(n) {
+	case 1:
+		return now.Hour() == n[0], nil
+	case 2:
+		if n[0] == n[1] {
+			return now.Hour() == n[0], nil
+		}
+		return pacInRange(now.Hour(), n[0], n[1]-1), nil
+	case 4:
+		return pacInRange(secs, n[0]*3600+n[1]*60, n[2]*3600+n[3]*60), nil
+	case 6:
+		return pacInRange(secs, n[0]*3600+n[1]*60+n[2], n[3]*3600+n[4]*60+n[5]), nil
+	}
+	return false, nil
+}
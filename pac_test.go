@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPAC = `// routes internal hosts directly
+var proxy = "PROXY proxy.example.com:8080";
+var fallback = proxy + "; DIRECT";
+
+function isInternal(host) {
+	return dnsDomainIs(host, ".corp.example.com") || isInNet(host, "10.0.0.0", "255.0.0.0");
+}
+
+function FindProxyForURL(url, host) {
+	host = host.toLowerCase();
+	if (isPlainHostName(host) || isInternal(host)) {
+		return "DIRECT";
+	}
+	if (shExpMatch(url, "https://*:8443/*")) {
+		return "SOCKS socks.example.com:1080";
+	}
+	/* hosts outside of the usual zones */
+	if (dnsDomainLevels(host) > 2 && !host.endsWith(".example.org")) {
+		return host.indexOf("api") === 0 ? proxy : fallback;
+	}
+	return fallback;
+}
+`
+
+func Test_pac_findProxy(t *testing.T) {
+	p, err := parsePAC(testPAC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &countingResolver{ips: []net.IP{net.ParseIP("10.1.2.3")}}
+	tests := []struct {
+		name     string
+		host     string
+		port     string
+		resolver resolver
+		want     string
+	}{
+		{
+			name: "plain host name",
+			host: "intranet",
+			port: "443",
+			want: "DIRECT",
+		},
+		{
+			name: "internal domain",
+			host: "Wiki.Corp.Example.com",
+			port: "443",
+			want: "DIRECT",
+		},
+		{
+			name:     "internal network",
+			host:     "db.example.net",
+			port:     "443",
+			resolver: r,
+			want:     "DIRECT",
+		},
+		{
+			name: "port in url",
+			host: "example.net",
+			port: "8443",
+			want: "SOCKS socks.example.com:1080",
+		},
+		{
+			name: "method and ternary",
+			host: "api.sub.example.net",
+			port: "443",
+			want: "PROXY proxy.example.com:8080",
+		},
+		{
+			name: "globals",
+			host: "example.net",
+			port: "443",
+			want: "PROXY proxy.example.com:8080; DIRECT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.findProxy(context.Background(), tt.resolver, tt.host, tt.port)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("findProxy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parsePAC(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			src:     testPAC,
+			wantErr: false,
+		},
+		{
+			name:    "no FindProxyForURL",
+			src:     `function f(url, host) { return "DIRECT"; }`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported syntax",
+			src:     `function FindProxyForURL(url, host) { try { return "DIRECT"; } catch (e) {} }`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated string",
+			src:     `function FindProxyForURL(url, host) { return "DIRECT; }`,
+			wantErr: true,
+		},
+		{
+			name:    "for-in loop",
+			src:     `var hosts = ["a"]; function FindProxyForURL(url, host) { for (var h in hosts) {} return "DIRECT"; }`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported regular expression",
+			src:     `function FindProxyForURL(url, host) { return /^(?!www)/.test(host) ? "DIRECT" : "PROXY p:1"; }`,
+			wantErr: true,
+		},
+		{
+			name:    "endless top-level loop",
+			src:     `while (true) {} function FindProxyForURL(url, host) { return "DIRECT"; }`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated comment",
+			src:     `/* function FindProxyForURL(url, host) { return "DIRECT"; }`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePAC(tt.src); (err != nil) != tt.wantErr {
+				t.Errorf("parsePAC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_pac_findProxy_error(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "unsupported function",
+			src:  `function FindProxyForURL(url, host) { return dnsResolveEx(host) ? "DIRECT" : "PROXY p:1"; }`,
+		},
+		{
+			name: "endless loop",
+			src:  `function FindProxyForURL(url, host) { for (;;) {} }`,
+		},
+		{
+			name: "endless recursion",
+			src:  `function FindProxyForURL(url, host) { return FindProxyForURL(url, host); }`,
+		},
+		{
+			name: "string too long",
+			src:  `function FindProxyForURL(url, host) { var s = host; while (true) s += s; }`,
+		},
+		{
+			name: "not a string",
+			src:  `function FindProxyForURL(url, host) { return 1; }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePAC(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.findProxy(context.Background(), nil, "example.com", "443"); err == nil {
+				t.Error("findProxy() error = nil, want error")
+			}
+		})
+	}
+}
+
+func Test_pac_findProxy_canceled(t *testing.T) {
+	p, err := parsePAC(`function FindProxyForURL(url, host) { var i = 0; while (true) i++; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.findProxy(ctx, nil, "example.com", "443"); !errors.Is(err, context.Canceled) {
+		t.Errorf("findProxy() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_pac_findProxy_files(t *testing.T) {
+	public := &countingResolver{ips: []net.IP{net.ParseIP("203.0.113.10")}}
+	private := &countingResolver{ips: []net.IP{net.ParseIP("10.1.2.3")}}
+	shared := &countingResolver{ips: []net.IP{net.ParseIP("100.64.1.1")}}
+	tests := []struct {
+		file     string
+		host     string
+		port     string
+		resolver resolver
+		want     string
+	}{
+		{file: "wikipedia.pac", host: "www.example.com", port: "443", want: "DIRECT"},
+		{file: "wikipedia.pac", host: "10.0.7.1", port: "443", want: "PROXY fastproxy.example.com:8080"},
+		{file: "wikipedia.pac", host: "10.0.8.1", port: "443", want: "PROXY proxy.example.com:8080; DIRECT"},
+		{file: "cloud.pac", host: "intranet", port: "443", want: "DIRECT"},
+		{file: "cloud.pac", host: "db.example.net", port: "443", resolver: private, want: "DIRECT"},
+		{file: "cloud.pac", host: "app.example.net", port: "443", resolver: shared, want: "DIRECT"},
+		{file: "cloud.pac", host: "trust.cloudproxy.example", port: "443", resolver: public, want: "DIRECT"},
+		{file: "cloud.pac", host: "www.example.net", port: "443", resolver: public, want: "PROXY gateway.cloudproxy.example:80; PROXY gateway2.cloudproxy.example:80; DIRECT"},
+		{file: "offices.pac", host: "Wiki.Corp.Example.com", port: "443", want: "DIRECT"},
+		{file: "offices.pac", host: "localhost", port: "443", want: "DIRECT"},
+		{file: "offices.pac", host: "db.example.net", port: "443", resolver: private, want: "DIRECT"},
+		{file: "offices.pac", host: "www.example.net", port: "8443", resolver: public, want: "PROXY proxy-alt.example.com:3128"},
+		{file: "offices.pac", host: "api.eu.pay.example.net", port: "443", resolver: public, want: "PROXY api-proxy.example.com:3128"},
+		{file: "offices.pac", host: "www.example.net", port: "443", resolver: public, want: "PROXY proxy1.example.com:3128; PROXY proxy2.example.com:3128; DIRECT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file+" "+tt.host, func(t *testing.T) {
+			p, err := loadPAC(context.Background(), filepath.Join("testdata", "pac", tt.file), 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.findProxy(context.Background(), tt.resolver, tt.host, tt.port)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("findProxy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pacInterp_eval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{src: `7 % 4 * 2 + 10 / 4`, want: 8.5},
+		{src: `[1, 2, 3].length`, want: float64(3)},
+		{src: `["a", "b"].indexOf("b")`, want: float64(1)},
+		{src: `"a.b.c".split(".").join("/")`, want: "a/b/c"},
+		{src: `"a.b.c".split(".")[2]`, want: "c"},
+		{src: `"www.example.com".replace(/^www\./, "")`, want: "example.com"},
+		{src: `"a-b-c".replace(/-/g, "+")`, want: "a+b+c"},
+		{src: `"host:8443".match(/:(\d+)$/)[1]`, want: "8443"},
+		{src: `/EXAMPLE/i.test("www.example.com")`, want: true},
+		{src: `"example".charAt(1) + "example".substr(-3, 2)`, want: "xpl"},
+		{src: `typeof missing`, want: "undefined"},
+		{src: `typeof dnsDomainIs`, want: "function"},
+		{src: `typeof "a" + typeof 1 + typeof []`, want: "stringnumberobject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			x, err := parsePACExpr(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := &pacInterp{pac: &pac{}, ctx: context.Background(), builtins: pacPureBuiltins}
+			got, err := in.eval(x, pacScope{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pacInterp_dateHelpers(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2026, time.March, 4, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: `weekdayRange("WED", "GMT")`, want: true},
+		{src: `weekdayRange("MON", "FRI", "GMT")`, want: true},
+		{src: `weekdayRange("SAT", "SUN", "GMT")`, want: false},
+		{src: `weekdayRange("FRI", "WED", "GMT")`, want: true},
+		{src: `dateRange(4, "GMT")`, want: true},
+		{src: `dateRange("MAR", "GMT")`, want: true},
+		{src: `dateRange(2025, "GMT")`, want: false},
+		{src: `dateRange(1, "MAR", 15, "MAR", "GMT")`, want: true},
+		{src: `dateRange("NOV", "FEB", "GMT")`, want: false},
+		{src: `dateRange("DEC", 2025, "MAR", 2026, "GMT")`, want: true},
+		{src: `dateRange(1, "JAN", 2026, 3, "MAR", 2026, "GMT")`, want: false},
+		{src: `timeRange(9, "GMT")`, want: true},
+		{src: `timeRange(8, 10, "GMT")`, want: true},
+		{src: `timeRange(8, 9, "GMT")`, want: false},
+		{src: `timeRange(22, 10, "GMT")`, want: true},
+		{src: `timeRange(9, 0, 9, 30, "GMT")`, want: true},
+		{src: `timeRange(9, 30, 1, 10, 0, 0, "GMT")`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			x, err := parsePACExpr(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := &pacInterp{pac: &pac{}, ctx: context.Background(), builtins: pacPureBuiltins, now: now}
+			got, err := in.eval(x, pacScope{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_shExpMatch(t *testing.T) {
+	tests := []struct {
+		str   string
+		shexp string
+		want  bool
+	}{
+		{str: "www.example.com", shexp: "*.example.com", want: true},
+		{str: "example.com", shexp: "*.example.com", want: false},
+		{str: "https://a/b/c", shexp: "https://*/c", want: true},
+		{str: "host1", shexp: "host?", want: true},
+		{str: "host10", shexp: "host?", want: false},
+		{str: "a+b", shexp: "a+b", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.str+" "+tt.shexp, func(t *testing.T) {
+			if got := shExpMatch(tt.str, tt.shexp); got != tt.want {
+				t.Errorf("shExpMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadPAC(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "proxy.pac")
+	if err := os.WriteFile(file, []byte(testPAC), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/proxy.pac" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+		_, _ = w.Write([]byte(testPAC))
+	}))
+	defer server.Close()
+	tests := []struct {
+		name     string
+		location string
+		wantErr  bool
+	}{
+		{
+			name:     "file",
+			location: file,
+			wantErr:  false,
+		},
+		{
+			name:     "url",
+			location: server.URL + "/proxy.pac",
+			wantErr:  false,
+		},
+		{
+			name:     "missing file",
+			location: filepath.Join(t.TempDir(), "missing.pac"),
+			wantErr:  true,
+		},
+		{
+			name:     "not found",
+			location: server.URL + "/missing.pac",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadPAC(context.Background(), tt.location, 5*time.Second); (err != nil) != tt.wantErr {
+				t.Errorf("loadPAC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
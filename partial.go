@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// errCertReceived aborts a partial handshake once the cert has been received.
+var errCertReceived = errors.New("cert received")
+
+// partialHandshake runs the TLS handshake on conn only as far as the cert of
+// the server and then aborts it, saving the rest of the handshake for each
+// host of a large scan. The cert is still verified unless insecure, and the
+// state seen so far, which lacks nothing getServerCert needs, is kept in c.
+// With TLS 1.2 the client key exchange is never sent; with TLS 1.3 the cert
+// is encrypted, so only the last flight of the client is saved.
+func (c *connector) partialHandshake(ctx context.Context, conn net.Conn) error {
+	config := c.tlsConfig.Clone()
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		c.state = &cs
+		return errCertReceived
+	}
+	err := tls.Client(conn, config).HandshakeContext(ctx)
+	conn.Close()
+	if errors.Is(err, errCertReceived) {
+		return nil
+	}
+	if err == nil {
+		err = errors.New("handshake completed without a cert")
+	}
+	return fmt.Errorf("cannot connect to %q: %w", c.addr, err)
+}
+
+// connectionState returns the state of the full or partial handshake.
+func (c *connector) connectionState() tls.ConnectionState {
+	if c.state != nil {
+		return *c.state
+	}
+	return c.tlsConn.ConnectionState()
+}
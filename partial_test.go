@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_getCertList_partial(t *testing.T) {
+	tests := []struct {
+		name           string
+		version        uint16
+		insecure       bool
+		wantUnverified bool
+	}{
+		{
+			name:           "tls 1.2",
+			version:        tls.VersionTLS12,
+			insecure:       true,
+			wantUnverified: false,
+		},
+		{
+			name:           "tls 1.3",
+			version:        tls.VersionTLS13,
+			insecure:       true,
+			wantUnverified: false,
+		},
+		{
+			name:           "verification failure",
+			version:        tls.VersionTLS13,
+			insecure:       false,
+			wantUnverified: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			config := newTestTLSConfig(t)
+			config.MaxVersion = tt.version
+			completed := make(chan bool, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				completed <- tls.Server(conn, config).Handshake() == nil
+			}()
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				insecure: tt.insecure,
+				location: time.UTC,
+				partial:  true,
+			}
+			infos, err := getCertList(context.Background(), []string{ln.Addr().String()}, opts)
+			if err != nil {
+				t.Fatalf("getCertList() error = %v", err)
+			}
+			if <-completed {
+				t.Error("handshake completed, want aborted")
+			}
+			if got := unverified(infos[0]); got != tt.wantUnverified {
+				t.Errorf("unverified() = %v, want %v", got, tt.wantUnverified)
+			}
+			if infos[0].CommonName != "starttls test" {
+				t.Errorf("CommonName = %q, want %q", infos[0].CommonName, "starttls test")
+			}
+			conn, err := newConnector(ln.Addr().String(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := connCache.load(conn.connKey()); ok {
+				t.Error("aborted connection is cached")
+			}
+		})
+	}
+}
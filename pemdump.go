@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dumpPEM writes the cert of each host to a PEM file in dir named after the
+// host and port, e.g. example.com_443.pem, with the intermediates following
+// the leaf when chain is set. Hosts restored from a checkpoint carry no certs
+// and are skipped.
+func dumpPEM(dir string, infos []*certInfo, chain bool) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil { // #nosec G301
+		return err
+	}
+	for _, info := range infos {
+		if len(info.chain) == 0 {
+			continue
+		}
+		certs := info.chain[:1]
+		if chain {
+			certs = info.chain
+		}
+		var buf bytes.Buffer
+		for _, cert := range certs {
+			if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+				return err
+			}
+		}
+		path := filepath.Join(dir, pemFileName(info))
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil { // #nosec G306
+			return fmt.Errorf("cannot dump cert: %w", err)
+		}
+	}
+	return nil
+}
+
+// pemFileName returns a file name for the host of info that is safe on every
+// OS, replacing the colons of IPv6 addresses among others.
+func pemFileName(info *certInfo) string {
+	host := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, info.DomainName)
+	return host + "_" + info.AccessPort + ".pem"
+}
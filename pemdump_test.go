@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_dumpPEM(t *testing.T) {
+	leaf, err := x509.ParseCertificate(newTestTLSConfig(t).Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, err := x509.ParseCertificate(newTestTLSConfig(t).Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := []*certInfo{
+		{DomainName: "example.com", AccessPort: "443", chain: []*x509.Certificate{leaf, intermediate}},
+		{DomainName: "2001:db8::1", AccessPort: "8443", chain: []*x509.Certificate{leaf}},
+		{DomainName: "restored.example.com", AccessPort: "443"},
+	}
+	tests := []struct {
+		name  string
+		chain bool
+		want  map[string]int
+	}{
+		{
+			name:  "leaf",
+			chain: false,
+			want:  map[string]int{"example.com_443.pem": 1, "2001_db8__1_8443.pem": 1},
+		},
+		{
+			name:  "chain",
+			chain: true,
+			want:  map[string]int{"example.com_443.pem": 2, "2001_db8__1_8443.pem": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "certs")
+			if err := dumpPEM(dir, infos, tt.chain); err != nil {
+				t.Fatal(err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Errorf("files = %d, want %d", len(entries), len(tt.want))
+			}
+			for name, n := range tt.want {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var blocks []*pem.Block
+				for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+					blocks = append(blocks, block)
+				}
+				if len(blocks) != n {
+					t.Errorf("%s: certs = %d, want %d", name, len(blocks), n)
+				}
+				if len(blocks) > 0 && string(blocks[0].Bytes) != string(leaf.Raw) {
+					t.Errorf("%s: first cert is not the leaf", name)
+				}
+			}
+		})
+	}
+}
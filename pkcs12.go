@@ -0,0 +1,371 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// OIDs of the PKCS#12 structures that x/crypto/pkcs12 cannot read, those
+// written by keytool since JDK 18 and by OpenSSL 3 with PBES2 (RFC 8018).
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidCertBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidKeyBag        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedBag   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidX509Cert      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidDESEDE3CBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// pkcs12Digests are the digests of the MAC and of the PRF of PBKDF2, keyed by
+// the OID of the digest for the former and of the HMAC for the latter.
+var pkcs12Digests = []struct {
+	digest asn1.ObjectIdentifier
+	hmac   asn1.ObjectIdentifier
+	new    func() hash.Hash
+}{
+	{digest: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, hmac: asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}, new: sha1.New},
+	{digest: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, hmac: asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}, new: sha256.New},
+	{digest: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}, hmac: asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}, new: sha512.New384},
+	{digest: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}, hmac: asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}, new: sha512.New},
+}
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MacData `asn1:"optional"`
+}
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs12MacData struct {
+	Mac struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Digest    []byte
+	}
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo struct {
+		ContentType                asn1.ObjectIdentifier
+		ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+		EncryptedContent           []byte `asn1:"tag:0,optional"`
+	}
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type pkcs12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// pkcs12Bag is a cert of a PKCS#12 file with the attributes of its bag.
+type pkcs12Bag struct {
+	cert  *x509.Certificate
+	name  string
+	keyID string
+}
+
+// decodePKCS12 returns the certs of the PKCS#12 data in b, and the local key
+// IDs of its private keys, which are not decrypted. The MAC is checked with
+// password, and the bags encrypted with PBES2 are decrypted with it.
+func decodePKCS12(b []byte, password string) ([]pkcs12Bag, []string, error) {
+	var pfx pfxPDU
+	if rest, err := asn1.Unmarshal(b, &pfx); err != nil {
+		return nil, nil, err
+	} else if len(rest) > 0 {
+		return nil, nil, errors.New("trailing data after PKCS#12 data")
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, errors.New("only password-protected PKCS#12 data is supported")
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, nil, err
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyPKCS12MAC(&pfx.MacData, authSafe, password); err != nil {
+			return nil, nil, err
+		}
+	}
+	var contents []pkcs12ContentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, nil, err
+	}
+	var (
+		bags   []pkcs12Bag
+		keyIDs []string
+	)
+	for _, ci := range contents {
+		var data []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &data); err != nil {
+				return nil, nil, err
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			var ed pkcs12EncryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, nil, err
+			}
+			var err error
+			data, err = decryptPBES2(ed.EncryptedContentInfo.ContentEncryptionAlgorithm, ed.EncryptedContentInfo.EncryptedContent, password)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupported PKCS#12 content %s", ci.ContentType)
+		}
+		var safe []pkcs12SafeBag
+		if _, err := asn1.Unmarshal(data, &safe); err != nil {
+			return nil, nil, err
+		}
+		for _, bag := range safe {
+			name, keyID := pkcs12Attributes(bag.Attributes)
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb pkcs12CertBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, nil, err
+				}
+				if !cb.ID.Equal(oidX509Cert) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, nil, err
+				}
+				bags = append(bags, pkcs12Bag{cert: cert, name: name, keyID: keyID})
+			case bag.ID.Equal(oidKeyBag), bag.ID.Equal(oidShroudedBag):
+				keyIDs = append(keyIDs, keyID)
+			}
+		}
+	}
+	return bags, keyIDs, nil
+}
+
+// pkcs12Attributes returns the friendly name and the local key ID, in hex,
+// of a bag.
+func pkcs12Attributes(attrs []pkcs12Attribute) (name, keyID string) {
+	for _, attr := range attrs {
+		switch {
+		case attr.ID.Equal(oidFriendlyName):
+			var v asn1.RawValue
+			if _, err := asn1.Unmarshal(attr.Value.Bytes, &v); err == nil && len(v.Bytes)%2 == 0 {
+				s := make([]uint16, len(v.Bytes)/2)
+				for i := range s {
+					s[i] = uint16(v.Bytes[2*i])<<8 | uint16(v.Bytes[2*i+1])
+				}
+				name = string(utf16.Decode(s))
+			}
+		case attr.ID.Equal(oidLocalKeyID):
+			var id []byte
+			if _, err := asn1.Unmarshal(attr.Value.Bytes, &id); err == nil {
+				keyID = hex.EncodeToString(id)
+			}
+		}
+	}
+	return name, keyID
+}
+
+// verifyPKCS12MAC checks the MAC of authSafe, whose key is derived from
+// password as described in RFC 7292 appendix B.
+func verifyPKCS12MAC(md *pkcs12MacData, authSafe []byte, password string) error {
+	newHash, err := pkcs12Digest(md.Mac.Algorithm.Algorithm, false)
+	if err != nil {
+		return err
+	}
+	key := pkcs12KDF(newHash, bmpPassword(password), md.MacSalt, md.Iterations, newHash().Size())
+	mac := hmac.New(newHash, key)
+	mac.Write(authSafe)
+	if subtle.ConstantTimeCompare(mac.Sum(nil), md.Mac.Digest) != 1 {
+		return errKeystorePassword
+	}
+	return nil
+}
+
+// pkcs12Digest returns the hash of oid, that of a digest or with isHMAC set,
+// that of an HMAC.
+func pkcs12Digest(oid asn1.ObjectIdentifier, isHMAC bool) (func() hash.Hash, error) {
+	for _, d := range pkcs12Digests {
+		if !isHMAC && oid.Equal(d.digest) || isHMAC && oid.Equal(d.hmac) {
+			return d.new, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported PKCS#12 digest %s", oid)
+}
+
+// bmpPassword returns password as a null-terminated BMPString, as the key
+// derivation of PKCS#12 takes it.
+func bmpPassword(password string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(password)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return append(b, 0, 0)
+}
+
+// pkcs12KDF derives n bytes of MAC key from password and salt with the
+// method of RFC 7292 appendix B.2.
+func pkcs12KDF(newHash func() hash.Hash, password, salt []byte, iterations, n int) []byte {
+	const macID = 3
+	h := newHash()
+	v := h.BlockSize()
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		res := make([]byte, v*((len(b)+v-1)/v))
+		for i := range res {
+			res[i] = b[i%len(b)]
+		}
+		return res
+	}
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = macID
+	}
+	in := append(fill(salt), fill(password)...)
+	var key []byte
+	for len(key) < n {
+		h.Reset()
+		h.Write(d)
+		h.Write(in)
+		a := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		key = append(key, a...)
+		// each block of in is incremented by a repeated to v bytes, plus one
+		b := fill(a)
+		for j := 0; j < len(in); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(in[j+k]) + int(b[k])
+				in[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return key[:n]
+}
+
+// decryptPBES2 decrypts data encrypted with PBES2 using PBKDF2 and a block
+// cipher in CBC mode.
+func decryptPBES2(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	if !alg.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported PKCS#12 encryption %s", alg.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported PBES2 key derivation %s", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+	prf := sha1.New
+	if len(kdf.PRF.Algorithm) > 0 {
+		var err error
+		if prf, err = pkcs12Digest(kdf.PRF.Algorithm, true); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		keyLen   int
+		newBlock func([]byte) (cipher.Block, error)
+	)
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLen, newBlock = 16, aes.NewCipher
+	case scheme.Equal(oidAES192CBC):
+		keyLen, newBlock = 24, aes.NewCipher
+	case scheme.Equal(oidAES256CBC):
+		keyLen, newBlock = 32, aes.NewCipher
+	case scheme.Equal(oidDESEDE3CBC):
+		keyLen, newBlock = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("unsupported PBES2 encryption %s", scheme)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	block, err := newBlock(pbkdf2.Key([]byte(password), kdf.Salt, kdf.Iterations, keyLen, prf))
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid PBES2 encrypted data")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	// a wrong password leaves the padding broken, when there is no MAC to
+	// tell it first
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, errKeystorePassword
+	}
+	for _, c := range out[len(out)-pad:] {
+		if int(c) != pad {
+			return nil, errKeystorePassword
+		}
+	}
+	return out[:len(out)-pad], nil
+}
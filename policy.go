@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// policyKeys are the assertions a policy rule may make on the certs of the
+// hosts it matches.
+var policyKeys = []string{"issuer", "sans", "min_days_left", "min_tls_version", "key_type"}
+
+// policyVersions are the TLS versions a rule may require at least, by the
+// names of the versions probed.
+var policyVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// policyRule is a set of expectations on the certs of the hosts whose names
+// match pattern, as with path.Match, so that * matches every host. A zero
+// value asserts nothing.
+type policyRule struct {
+	pattern       string
+	issuer        string
+	sans          []string
+	minDaysLeft   *int
+	minTLSVersion uint16
+	keyType       string
+}
+
+// policy asserts expectations on the checked certs. Every rule matching a
+// host applies, in the order of the file. A nil policy asserts nothing.
+type policy []policyRule
+
+// loadPolicy reads a policy file: a mapping of host patterns to mappings of
+// assertions, in the subset of YAML the config file is written in.
+//
+//	"*":
+//	  min_days_left: 30
+//	"*.example.com":
+//	  issuer: Let's Encrypt
+//	  sans: [example.com, www.example.com]
+//	  key_type: ECDSA
+//	  min_tls_version: "1.2"
+func loadPolicy(file string) (policy, error) {
+	if file == "" {
+		return nil, nil
+	}
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := parsePolicy(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+	}
+	return p, nil
+}
+
+func parsePolicy(r io.Reader) (policy, error) {
+	var p policy
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := stripComment(sc.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		k, v, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key: value", n)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if line[0] != ' ' && line[0] != '\t' {
+			pattern, err := unquote(k)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" || v != "" {
+				return nil, fmt.Errorf("line %d: invalid host pattern %q", n, k)
+			}
+			p = append(p, policyRule{pattern: strings.ToLower(pattern)})
+			continue
+		}
+		if len(p) == 0 {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		if err := p[len(p)-1].set(k, v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// set sets the assertion of key from its value v, a scalar or, for sans, an
+// inline list.
+func (r *policyRule) set(key, v string) error {
+	if list, ok := strings.CutPrefix(v, "["); ok && key == "sans" {
+		list, ok = strings.CutSuffix(list, "]")
+		if !ok {
+			return fmt.Errorf("unterminated list")
+		}
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			item, err := unquote(item)
+			if err != nil {
+				return err
+			}
+			r.sans = append(r.sans, strings.ToLower(item))
+		}
+		return nil
+	}
+	s, err := unquote(v)
+	if err != nil {
+		return err
+	}
+	switch key {
+	case "issuer":
+		r.issuer = s
+	case "sans":
+		r.sans = append(r.sans, strings.ToLower(s))
+	case "min_days_left":
+		days, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q is not a number", key, s)
+		}
+		r.minDaysLeft = &days
+	case "min_tls_version":
+		version, ok := policyVersions[strings.TrimPrefix(s, "TLS ")]
+		if !ok {
+			return fmt.Errorf("invalid value for %s: %q: allowed values: 1.0|1.1|1.2|1.3", key, s)
+		}
+		r.minTLSVersion = version
+	case "key_type":
+		r.keyType = s
+	default:
+		return fmt.Errorf("invalid key %q: allowed values: %s", key, pipeJoin(policyKeys))
+	}
+	return nil
+}
+
+// probes reports whether the policy asserts the TLS versions a host accepts,
+// which are known only by probing them.
+func (p policy) probes() bool {
+	return slices.ContainsFunc(p, func(r policyRule) bool {
+		return r.minTLSVersion != 0
+	})
+}
+
+// violations describes each expectation of the rules matching the host of
+// info that its cert does not meet.
+func (p policy) violations(info *certInfo) []string {
+	var res []string
+	for _, r := range p {
+		if ok, _ := path.Match(r.pattern, strings.ToLower(info.DomainName)); ok {
+			res = append(res, r.violations(info)...)
+		}
+	}
+	return res
+}
+
+// violating returns the number of infos with violations of a policy.
+func violating(infos []*certInfo) int {
+	count := 0
+	for _, info := range infos {
+		if len(info.Violations) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// violations describes each expectation of r that info does not meet.
+func (r policyRule) violations(info *certInfo) []string {
+	var res []string
+	if r.issuer != "" && !strings.Contains(info.Issuer, r.issuer) {
+		res = append(res, fmt.Sprintf("issuer %q does not contain %q", info.Issuer, r.issuer))
+	}
+	for _, san := range r.sans {
+		if !slices.ContainsFunc(info.SANs, func(s string) bool { return strings.EqualFold(s, san) }) {
+			res = append(res, fmt.Sprintf("SANs do not include %s", san))
+		}
+	}
+	if r.minDaysLeft != nil && info.DaysLeft < *r.minDaysLeft {
+		res = append(res, fmt.Sprintf("%d days left, fewer than %d", info.DaysLeft, *r.minDaysLeft))
+	}
+	if r.minTLSVersion != 0 {
+		for _, name := range info.TLSVersions {
+			if v := versionByName(name); v != 0 && v < r.minTLSVersion {
+				res = append(res, fmt.Sprintf("accepts %s, older than %s", name, tls.VersionName(r.minTLSVersion)))
+			}
+		}
+	}
+	if r.keyType != "" && !strings.EqualFold(info.PublicKeyAlgorithm, r.keyType) {
+		res = append(res, fmt.Sprintf("key type %s is not %s", info.PublicKeyAlgorithm, r.keyType))
+	}
+	return res
+}
+
+// versionByName returns the TLS version named as by tls.VersionName, or 0.
+func versionByName(name string) uint16 {
+	for _, v := range probedVersions {
+		if tls.VersionName(v) == name {
+			return v
+		}
+	}
+	return 0
+}
+
+// policyError fails a check with hosts violating the policy. It exits as
+// critical, as for a Nagios plugin.
+type policyError struct {
+	count int
+}
+
+func (e *policyError) Error() string {
+	return fmt.Sprintf("critical: %d host(s) violate the policy", e.count)
+}
+
+func (e *policyError) ExitCode() int {
+	return exitCritical
+}
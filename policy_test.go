@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parsePolicy(t *testing.T) {
+	days := 30
+	tests := []struct {
+		name    string
+		src     string
+		want    policy
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			src: `# defaults
+"*":
+  min_days_left: 30 # a month
+"*.Example.com":
+  issuer: "Let's Encrypt"
+  sans: [example.com, "WWW.example.com"]
+  key_type: ECDSA
+  min_tls_version: TLS 1.2
+api.example.com:
+  sans: api.example.com
+`,
+			want: policy{
+				{pattern: "*", minDaysLeft: &days},
+				{pattern: "*.example.com", issuer: "Let's Encrypt", sans: []string{"example.com", "www.example.com"}, keyType: "ECDSA", minTLSVersion: tls.VersionTLS12},
+				{pattern: "api.example.com", sans: []string{"api.example.com"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "empty",
+			src:     "",
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name:    "assertion without host",
+			src:     "  issuer: R3\n",
+			wantErr: true,
+		},
+		{
+			name:    "host with value",
+			src:     "example.com: R3\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			src:     "\"[example.com\":\n  issuer: R3\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid key",
+			src:     "example.com:\n  subject: R3\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid days",
+			src:     "example.com:\n  min_days_left: month\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			src:     "example.com:\n  min_tls_version: 1.4\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated list",
+			src:     "example.com:\n  sans: [example.com\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePolicy(strings.NewReader(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(policyRule{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_policy_violations(t *testing.T) {
+	p, err := parsePolicy(strings.NewReader(`"*":
+  min_days_left: 30
+"*.example.com":
+  issuer: Let's Encrypt
+  sans: [example.com, www.example.com]
+  key_type: ECDSA
+  min_tls_version: "1.2"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.probes() {
+		t.Error("probes() = false, want true")
+	}
+	tests := []struct {
+		name string
+		info *certInfo
+		want []string
+	}{
+		{
+			name: "compliant",
+			info: &certInfo{
+				DomainName:         "www.example.com",
+				Issuer:             "CN=R3,O=Let's Encrypt,C=US",
+				SANs:               []string{"example.com", "www.example.com"},
+				DaysLeft:           60,
+				PublicKeyAlgorithm: "ECDSA",
+				TLSVersions:        []string{"TLS 1.2", "TLS 1.3"},
+			},
+			want: nil,
+		},
+		{
+			name: "other host",
+			info: &certInfo{DomainName: "example.net", DaysLeft: 10},
+			want: []string{"10 days left, fewer than 30"},
+		},
+		{
+			name: "violating",
+			info: &certInfo{
+				DomainName:         "WWW.example.com",
+				Issuer:             "CN=Internal CA",
+				SANs:               []string{"www.example.com"},
+				DaysLeft:           60,
+				PublicKeyAlgorithm: "RSA",
+				TLSVersions:        []string{"TLS 1.0", "TLS 1.2"},
+			},
+			want: []string{
+				`issuer "CN=Internal CA" does not contain "Let's Encrypt"`,
+				"SANs do not include example.com",
+				"accepts TLS 1.0, older than TLS 1.2",
+				"key type RSA is not ECDSA",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, p.violations(tt.info)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	if got := policy(nil).violations(&certInfo{DomainName: "example.com"}); got != nil {
+		t.Errorf("nil policy violations() = %v", got)
+	}
+	infos := []*certInfo{{Violations: []string{"expired"}}, {}}
+	if got := violating(infos); got != 1 {
+		t.Errorf("violating() = %d, want 1", got)
+	}
+}
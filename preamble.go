@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+)
+
+// preamble is a plaintext exchange performed on the raw connection before the
+// TLS handshake starts, such as a STARTTLS negotiation or a PROXY protocol header.
+// New protocols are added by registering an implementation in preambles.
+type preamble interface {
+	negotiate(conn net.Conn, host string) error
+}
+
+// preambleFunc allows an ordinary function to be used as a preamble.
+type preambleFunc func(conn net.Conn, host string) error
+
+func (f preambleFunc) negotiate(conn net.Conn, host string) error {
+	return f(conn, host)
+}
+
+var preambles = map[string]preamble{}
+
+func registerPreamble(name string, p preamble) {
+	if _, ok := preambles[name]; ok {
+		panic(fmt.Sprintf("preamble already registered: %s", name))
+	}
+	preambles[name] = p
+}
+
+func lookupPreamble(name string) (preamble, error) {
+	p, ok := preambles[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid protocol: allowed values: %s", pipeJoin(preambleNames()))
+	}
+	return p, nil
+}
+
+func preambleNames() []string {
+	names := make([]string, 0, len(preambles))
+	for name := range preambles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
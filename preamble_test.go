@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_lookupPreamble(t *testing.T) {
+	registerPreamble("test", preambleFunc(func(net.Conn, string) error { return nil }))
+	t.Cleanup(func() { delete(preambles, "test") })
+	tests := []struct {
+		name    string
+		arg     string
+		wantErr bool
+	}{
+		{
+			name:    "basic",
+			arg:     "test",
+			wantErr: false,
+		},
+		{
+			name:    "unknown",
+			arg:     "unknown",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupPreamble(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("lookupPreamble() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got == nil {
+				t.Error("lookupPreamble() returned nil preamble")
+			}
+		})
+	}
+}
+
+func Test_preambleNames(t *testing.T) {
+	registerPreamble("zzz", preambleFunc(func(net.Conn, string) error { return nil }))
+	registerPreamble("aaa", preambleFunc(func(net.Conn, string) error { return nil }))
+	t.Cleanup(func() {
+		delete(preambles, "zzz")
+		delete(preambles, "aaa")
+	})
+	got := preambleNames()
+	if got[0] != "aaa" || got[len(got)-1] != "zzz" {
+		t.Errorf("preambleNames() = %v, want sorted names", got)
+	}
+}
+
+func Test_connector_getTLSConn_preamble(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		preamble preamble
+		wantErr  bool
+	}{
+		{
+			name: "basic",
+			preamble: preambleFunc(func(_ net.Conn, host string) error {
+				if host != "localhost" {
+					return errors.New("unexpected host")
+				}
+				return nil
+			}),
+			wantErr: false,
+		},
+		{
+			name: "error",
+			preamble: preambleFunc(func(net.Conn, string) error {
+				return errors.New("negotiation failed")
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &connector{
+				addr:     addr,
+				host:     host,
+				port:     port,
+				timeout:  5 * time.Second,
+				preamble: tt.preamble,
+				tlsConfig: &tls.Config{
+					ServerName:         host,
+					MinVersion:         tls.VersionTLS12,
+					InsecureSkipVerify: true, // #nosec G402
+				},
+			}
+			connCache.delete(c.connKey())
+			t.Cleanup(func() { connCache.delete(c.connKey()) })
+			err := c.getTLSConn(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("connector.getTLSConn() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !c.tlsConn.ConnectionState().HandshakeComplete {
+				t.Error("handshake is not complete")
+			}
+		})
+	}
+}
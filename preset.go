@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// preset bundles the default port and upgrade protocol of a well-known kind
+// of TLS endpoint. clientAuth marks endpoints that usually refuse the
+// handshake unless a client cert is presented.
+type preset struct {
+	port       string
+	protocol   string
+	clientAuth bool
+}
+
+var presets = map[string]preset{
+	"etcd": {
+		port:       "2379",
+		clientAuth: true,
+	},
+	"kube-apiserver": {
+		port: "6443",
+	},
+	"kubelet": {
+		port: "10250",
+	},
+	"rdp": {
+		port:     "3389",
+		protocol: "rdp",
+	},
+	"winrm": {
+		port: "5986",
+	},
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func lookupPreset(name string) (preset, error) {
+	p, ok := presets[name]
+	if !ok {
+		return preset{}, fmt.Errorf("invalid preset: allowed values: %s", pipeJoin(presetNames()))
+	}
+	return p, nil
+}
+
+// applyPort appends port to the addresses that have none.
+func applyPort(addrs []string, port string) []string {
+	res := make([]string, len(addrs))
+	for i, addr := range addrs {
+		if !strings.Contains(addr, ":") {
+			addr += ":" + port
+		}
+		res[i] = addr
+	}
+	return res
+}
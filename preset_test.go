@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_lookupPreset(t *testing.T) {
+	tests := []struct {
+		name    string
+		preset  string
+		want    preset
+		wantErr bool
+	}{
+		{
+			name:   "rdp",
+			preset: "rdp",
+			want:   preset{port: "3389", protocol: "rdp"},
+		},
+		{
+			name:   "winrm",
+			preset: "winrm",
+			want:   preset{port: "5986"},
+		},
+		{
+			name:   "etcd",
+			preset: "etcd",
+			want:   preset{port: "2379", clientAuth: true},
+		},
+		{
+			name:    "unknown",
+			preset:  "ssh",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupPreset(tt.preset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupPreset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("lookupPreset() = %+v, want %+v", got, tt.want)
+			}
+			if tt.want.protocol != "" {
+				if _, err := lookupPreamble(tt.want.protocol); err != nil {
+					t.Errorf("preset protocol is not registered: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func Test_applyPort(t *testing.T) {
+	got := applyPort([]string{"win01.example.com", "win02.example.com:13389", "10.0.0.0/30"}, "3389")
+	want := []string{"win01.example.com:3389", "win02.example.com:13389", "10.0.0.0/30:3389"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// prioritize strips scheduling attributes from input lines and orders the
+// addresses by descending weight, keeping the input order among equals, so
+// that the most important hosts are checked first. Attributes follow the
+// address separated by spaces: "priority=N" sets the weight and "label=NAME"
+// takes it from labelWeights. Lines without attributes weigh zero.
+func prioritize(lines []string, labelWeights map[string]int) ([]string, error) {
+	type target struct {
+		addr   string
+		weight int
+	}
+	targets := make([]target, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		t := target{addr: strings.Trim(fields[0], `'"`)}
+		explicit := false
+		for _, attr := range fields[1:] {
+			key, value, ok := strings.Cut(attr, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid attribute %q for %s: must be key=value", attr, t.addr)
+			}
+			switch key {
+			case "priority":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid priority %q for %s: must be an integer", value, t.addr)
+				}
+				t.weight, explicit = n, true
+			case "label":
+				if w, ok := labelWeights[value]; ok && !explicit {
+					t.weight = w
+				}
+			default:
+				return nil, fmt.Errorf("invalid attribute %q for %s: allowed keys: priority|label", key, t.addr)
+			}
+		}
+		targets = append(targets, t)
+	}
+	slices.SortStableFunc(targets, func(a, b target) int {
+		return cmp.Compare(b.weight, a.weight)
+	})
+	addrs := make([]string, len(targets))
+	for i, t := range targets {
+		addrs[i] = t.addr
+	}
+	return addrs, nil
+}
+
+// parseLabelWeights parses "label=weight" pairs.
+func parseLabelWeights(pairs []string) (map[string]int, error) {
+	weights := make(map[string]int, len(pairs))
+	for _, pair := range pairs {
+		label, value, ok := strings.Cut(pair, "=")
+		if !ok || label == "" {
+			return nil, fmt.Errorf("invalid label weight %q: must be label=weight", pair)
+		}
+		w, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label weight %q: weight must be an integer", pair)
+		}
+		weights[label] = w
+	}
+	return weights, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_prioritize(t *testing.T) {
+	weights := map[string]int{"prod": 100, "stg": 10}
+	tests := []struct {
+		name    string
+		lines   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "no attributes",
+			lines: []string{"a.example", "b.example:8443"},
+			want:  []string{"a.example", "b.example:8443"},
+		},
+		{
+			name:  "priority",
+			lines: []string{"a.example", "b.example priority=5", "c.example priority=-1", "d.example priority=5"},
+			want:  []string{"b.example", "d.example", "a.example", "c.example"},
+		},
+		{
+			name:  "label",
+			lines: []string{"a.example label=stg", "b.example label=prod", "c.example label=dev"},
+			want:  []string{"b.example", "a.example", "c.example"},
+		},
+		{
+			name:  "priority over label",
+			lines: []string{"a.example label=prod", "b.example priority=500 label=prod", "c.example label=prod priority=1"},
+			want:  []string{"b.example", "a.example", "c.example"},
+		},
+		{
+			name:  "quoted",
+			lines: []string{`"a.example"`, `'b.example' priority=1`},
+			want:  []string{"b.example", "a.example"},
+		},
+		{
+			name:    "invalid priority",
+			lines:   []string{"a.example priority=high"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown attribute",
+			lines:   []string{"a.example owner=me"},
+			wantErr: true,
+		},
+		{
+			name:    "not key value",
+			lines:   []string{"a.example b.example"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prioritize(tt.lines, weights)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("prioritize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_parseLabelWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		pairs   []string
+		want    map[string]int
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			pairs: nil,
+			want:  map[string]int{},
+		},
+		{
+			name:  "pairs",
+			pairs: []string{"prod=100", "stg=10"},
+			want:  map[string]int{"prod": 100, "stg": 10},
+		},
+		{
+			name:    "missing weight",
+			pairs:   []string{"prod"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid weight",
+			pairs:   []string{"prod=high"},
+			wantErr: true,
+		},
+		{
+			name:    "missing label",
+			pairs:   []string{"=1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLabelWeights(tt.pairs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLabelWeights() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if diff := cmp.Diff(got, tt.want); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+)
+
+// probedVersions lists the protocol versions tried by probeVersions, oldest first.
+var probedVersions = []uint16{
+	tls.VersionTLS10,
+	tls.VersionTLS11,
+	tls.VersionTLS12,
+	tls.VersionTLS13,
+}
+
+// probeCipherSuites offers every suite the client implements, insecure ones
+// included, so that a version is not reported as refused for lack of a suite.
+var probeCipherSuites = func() []uint16 {
+	var ids []uint16
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}()
+
+// probeVersions attempts a separate handshake pinned to each TLS version and
+// returns the names of the versions the server accepts.
+func (c *connector) probeVersions(ctx context.Context) []string {
+	accepted := make([]string, 0, len(probedVersions))
+	for _, version := range probedVersions {
+		if err := c.probeVersion(ctx, version); err == nil {
+			accepted = append(accepted, tls.VersionName(version))
+		}
+	}
+	return accepted
+}
+
+func (c *connector) probeVersion(ctx context.Context, version uint16) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := c.dialAddr(ctx, c.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := c.negotiate(ctx, conn); err != nil {
+		return err
+	}
+	// only the negotiation matters here, so the cert is not verified
+	config := &tls.Config{
+		ServerName:         c.tlsConfig.ServerName,
+		MinVersion:         version,
+		MaxVersion:         version,
+		CipherSuites:       probeCipherSuites,
+		Certificates:       c.tlsConfig.Certificates,
+		InsecureSkipVerify: true, // #nosec G402
+	}
+	return tls.Client(conn, config).HandshakeContext(ctx)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func startVersionServer(t *testing.T, minVersion, maxVersion uint16) string {
+	t.Helper()
+	config := newTestTLSConfig(t)
+	config.MinVersion = minVersion
+	config.MaxVersion = maxVersion
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func Test_connector_probeVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		min  uint16
+		max  uint16
+		want []string
+	}{
+		{
+			name: "modern",
+			min:  tls.VersionTLS12,
+			max:  tls.VersionTLS13,
+			want: []string{"TLS 1.2", "TLS 1.3"},
+		},
+		{
+			name: "tls13 only",
+			min:  tls.VersionTLS13,
+			max:  tls.VersionTLS13,
+			want: []string{"TLS 1.3"},
+		},
+		{
+			name: "legacy",
+			min:  tls.VersionTLS10,
+			max:  tls.VersionTLS11,
+			want: []string{"TLS 1.0", "TLS 1.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startVersionServer(t, tt.min, tt.max)
+			c, err := newConnector(addr, &options{timeout: 5 * time.Second, location: time.UTC})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(c.probeVersions(context.Background()), tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_connector_probeVersions_unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	c, err := newConnector(addr, &options{timeout: time.Second, location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.probeVersions(context.Background()); len(got) != 0 {
+		t.Errorf("probeVersions() = %v, want none", got)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+const metricPrefix = appName + "_cert_"
+
+type metric struct {
+	name  string
+	help  string
+	value func(*certInfo) int64
+}
+
+var metrics = []metric{
+	{
+		name:  "not_before_timestamp_seconds",
+		help:  "Start of the validity period of the cert.",
+		value: func(info *certInfo) int64 { return info.NotBefore.Unix() },
+	},
+	{
+		name:  "not_after_timestamp_seconds",
+		help:  "End of the validity period of the cert.",
+		value: func(info *certInfo) int64 { return info.NotAfter.Unix() },
+	},
+	{
+		name:  "days_left",
+		help:  "Days until the cert expires.",
+		value: func(info *certInfo) int64 { return int64(info.DaysLeft) },
+	},
+}
+
+// toPrometheus writes infos in the Prometheus text exposition format read by
+// the textfile collector of node_exporter.
+func toPrometheus(infos []*certInfo, w io.Writer) error {
+	var b strings.Builder
+	for _, m := range metrics {
+		name := metricPrefix + m.name
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, m.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		for _, info := range infos {
+			if info.Error != nil {
+				continue
+			}
+			fmt.Fprintf(&b, "%s{%s} %d\n", name, metricLabels(info), m.value(info))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func metricLabels(info *certInfo) string {
+	labels := [][2]string{
+		{"host", net.JoinHostPort(info.DomainName, info.AccessPort)},
+		{"common_name", info.CommonName},
+		{"issuer", info.Issuer},
+		{"serial_number", info.SerialNumber},
+	}
+	s := make([]string, len(labels))
+	for i, l := range labels {
+		s[i] = fmt.Sprintf(`%s="%s"`, l[0], escapeLabelValue(l[1]))
+	}
+	return strings.Join(s, ",")
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
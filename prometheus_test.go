@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func Test_metricLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		info *certInfo
+		want string
+	}{
+		{
+			name: "basic",
+			info: &certInfo{DomainName: "example.com", AccessPort: "443", CommonName: "example.com", Issuer: "CN=R3,O=Let's Encrypt,C=US", SerialNumber: "01"},
+			want: `host="example.com:443",common_name="example.com",issuer="CN=R3,O=Let's Encrypt,C=US",serial_number="01"`,
+		},
+		{
+			name: "ipv6",
+			info: &certInfo{DomainName: "::1", AccessPort: "443"},
+			want: `host="[::1]:443",common_name="",issuer="",serial_number=""`,
+		},
+		{
+			name: "escaped",
+			info: &certInfo{DomainName: "example.com", AccessPort: "443", CommonName: `a "quoted" \ name` + "\n", Issuer: "CN=x"},
+			want: `host="example.com:443",common_name="a \"quoted\" \\ name\n",issuer="CN=x",serial_number=""`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metricLabels(tt.info); got != tt.want {
+				t.Errorf("metricLabels() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
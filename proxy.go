@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	routeDirect = "DIRECT"
+	routeHTTP   = "PROXY"
+	routeHTTPS  = "HTTPS"
+	routeSOCKS  = "SOCKS5"
+	routeSSH    = "SSH"
+)
+
+// route is one way to reach a target, as listed in the result of a PAC script
+// or in the via column of a structured list.
+type route struct {
+	kind string
+	addr string
+	// user logs in to an SSH jump host
+	user string
+}
+
+func (r route) String() string {
+	switch r.kind {
+	case routeDirect:
+		return r.kind
+	case routeSSH:
+		return r.kind + " " + r.user + "@" + r.addr
+	}
+	return r.kind + " " + r.addr
+}
+
+// viaSchemes maps the schemes of an egress path to the route they take and
+// its default port.
+var viaSchemes = map[string]route{
+	"http":    {kind: routeHTTP, addr: "80"},
+	"https":   {kind: routeHTTPS, addr: "443"},
+	"socks5":  {kind: routeSOCKS, addr: "1080"},
+	"socks5h": {kind: routeSOCKS, addr: "1080"},
+	"ssh":     {kind: routeSSH, addr: "22"},
+}
+
+// parseVia parses the egress paths of a host such as
+// "socks5://a:1080; ssh://user@jump; direct" into the routes to try in order.
+// A jump host without a user is logged in to as the current user.
+func parseVia(s string) ([]route, error) {
+	var routes []route
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.EqualFold(part, "direct") {
+			routes = append(routes, route{kind: routeDirect})
+			continue
+		}
+		u, err := url.Parse(part)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid via %q: want direct or scheme://host[:port]", part)
+		}
+		scheme, ok := viaSchemes[strings.ToLower(u.Scheme)]
+		if !ok {
+			return nil, fmt.Errorf("invalid via %q: allowed schemes: http|https|socks5|ssh", part)
+		}
+		r := route{kind: scheme.kind, addr: u.Host}
+		if u.Port() == "" {
+			r.addr = net.JoinHostPort(u.Hostname(), scheme.addr)
+		}
+		if u.User != nil {
+			if r.kind != routeSSH {
+				return nil, fmt.Errorf("invalid via %q: credentials are allowed only for ssh", part)
+			}
+			r.user = u.User.Username()
+		}
+		if r.kind == routeSSH && r.user == "" {
+			r.user = currentUser()
+		}
+		routes = append(routes, r)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no usable route in %q", s)
+	}
+	return routes, nil
+}
+
+// parseRoutes parses a PAC result such as "PROXY a:8080; SOCKS b:1080; DIRECT"
+// into the routes to try in order. Kinds that cannot be dialed, e.g. SOCKS4,
+// are skipped.
+func parseRoutes(s string) ([]route, error) {
+	var routes []route
+	for _, part := range strings.Split(s, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		r := route{kind: strings.ToUpper(fields[0])}
+		switch r.kind {
+		case routeDirect:
+			routes = append(routes, r)
+			continue
+		case "HTTP":
+			r.kind = routeHTTP
+		case "SOCKS":
+			r.kind = routeSOCKS
+		case routeHTTP, routeHTTPS, routeSOCKS:
+		default:
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid proxy %q", strings.TrimSpace(part))
+		}
+		r.addr = fields[1]
+		routes = append(routes, r)
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no usable proxy in %q", s)
+	}
+	return routes, nil
+}
+
+// dialRoutes connects to addr through the first of routes that works.
+func dialRoutes(ctx context.Context, routes []route, addr string) (net.Conn, route, error) {
+	var errs []error
+	for _, r := range routes {
+		conn, err := r.dial(ctx, addr)
+		if err == nil {
+			return conn, r, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, route{}, errors.Join(errs...)
+}
+
+func (r route) dial(ctx context.Context, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	switch r.kind {
+	case routeDirect:
+		return dialer.DialContext(ctx, "tcp", addr)
+	case routeSSH:
+		return dialSSH(ctx, r, addr)
+	case routeSOCKS:
+		d, err := proxy.SOCKS5("tcp", r.addr, nil, &dialer)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("via %s: %w", r, err)
+		}
+		return conn, nil
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("via %s: %w", r, err)
+	}
+	if r.kind == routeHTTPS {
+		host, _, _ := net.SplitHostPort(r.addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("via %s: %w", r, err)
+		}
+		conn = tlsConn
+	}
+	conn, err = httpConnect(ctx, conn, addr)
+	if err != nil {
+		return nil, fmt.Errorf("via %s: %w", r, err)
+	}
+	return conn, nil
+}
+
+// tunnelError is the response of an HTTP proxy that refused a tunnel.
+type tunnelError struct {
+	code   int
+	status string
+}
+
+func (e *tunnelError) Error() string {
+	return "proxy refused the tunnel: " + e.status
+}
+
+// httpConnect asks an HTTP proxy on conn for a tunnel to addr.
+func httpConnect(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	release := bindContext(ctx, conn)
+	defer release()
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    nil,
+		Host:   addr,
+		Header: http.Header{"User-Agent": {appName + "/" + Version}},
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\n\r\n", addr, addr, req.Header.Get("User-Agent")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, contextErr(ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, &tunnelError{code: resp.StatusCode, status: resp.Status}
+	}
+	// servers that speak first, as with STARTTLS, may have sent their greeting
+	// along with the response
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_parseRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []route
+		wantErr bool
+	}{
+		{
+			name: "direct",
+			s:    "DIRECT",
+			want: []route{{kind: routeDirect}},
+		},
+		{
+			name: "list",
+			s:    "PROXY a:8080; HTTPS b:443;SOCKS c:1080 ; DIRECT",
+			want: []route{
+				{kind: routeHTTP, addr: "a:8080"},
+				{kind: routeHTTPS, addr: "b:443"},
+				{kind: routeSOCKS, addr: "c:1080"},
+				{kind: routeDirect},
+			},
+		},
+		{
+			name: "aliases and case",
+			s:    "http a:8080; socks5 c:1080",
+			want: []route{
+				{kind: routeHTTP, addr: "a:8080"},
+				{kind: routeSOCKS, addr: "c:1080"},
+			},
+		},
+		{
+			name: "unsupported skipped",
+			s:    "SOCKS4 c:1080; PROXY a:8080",
+			want: []route{{kind: routeHTTP, addr: "a:8080"}},
+		},
+		{
+			name:    "no address",
+			s:       "PROXY",
+			wantErr: true,
+		},
+		{
+			name:    "nothing usable",
+			s:       "SOCKS4 c:1080",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			s:       "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRoutes(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRoutes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseVia(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []route
+		wantErr bool
+	}{
+		{
+			name: "direct",
+			s:    "DIRECT",
+			want: []route{{kind: routeDirect}},
+		},
+		{
+			name: "list",
+			s:    "http://a:8080; https://b; socks5://c ; ssh://ops@d:2222; direct",
+			want: []route{
+				{kind: routeHTTP, addr: "a:8080"},
+				{kind: routeHTTPS, addr: "b:443"},
+				{kind: routeSOCKS, addr: "c:1080"},
+				{kind: routeSSH, addr: "d:2222", user: "ops"},
+				{kind: routeDirect},
+			},
+		},
+		{
+			name:    "unknown scheme",
+			s:       "socks4://c:1080",
+			wantErr: true,
+		},
+		{
+			name:    "no host",
+			s:       "proxy.example.com:8080",
+			wantErr: true,
+		},
+		{
+			name:    "credentials for proxy",
+			s:       "http://user:pass@a:8080",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			s:       " ; ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVia(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseVia() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVia() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	routes, err := parseVia("ssh://jump.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes[0].user != currentUser() {
+		t.Errorf("user = %q, want %q", routes[0].user, currentUser())
+	}
+}
+
+func Test_getCertList_via(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	proxyAddr := newTestConnectProxy(t)
+	// the PAC script would send the host through a proxy that is down
+	p, err := parsePAC(`function FindProxyForURL(url, host) { return "PROXY 127.0.0.1:1"; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connCache.purge()
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+		pac:      p,
+		targets: targets{
+			ln.Addr().String(): {routes: []route{{kind: routeHTTP, addr: proxyAddr}}},
+		},
+	}
+	infos, err := getCertList(context.Background(), []string{ln.Addr().String()}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "PROXY " + proxyAddr; infos[0].Proxy != want {
+		t.Errorf("Proxy = %q, want %q", infos[0].Proxy, want)
+	}
+}
+
+// newTestConnectProxy starts an HTTP proxy that tunnels CONNECT requests and
+// returns its address.
+func newTestConnectProxy(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer upstream.Close()
+				fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go func() { _, _ = io.Copy(upstream, br) }()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func Test_getCertList_pac(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	proxyAddr := newTestConnectProxy(t)
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	tests := []struct {
+		name      string
+		result    string
+		wantProxy string
+		wantErr   bool
+	}{
+		{
+			name:      "direct",
+			result:    "DIRECT",
+			wantProxy: "",
+		},
+		{
+			name:      "proxy",
+			result:    "PROXY " + proxyAddr,
+			wantProxy: "PROXY " + proxyAddr,
+		},
+		{
+			name:      "proxy down",
+			result:    "PROXY " + closedAddr + "; PROXY " + proxyAddr,
+			wantProxy: "PROXY " + proxyAddr,
+		},
+		{
+			name:      "all proxies down",
+			result:    "PROXY " + closedAddr + "; DIRECT",
+			wantProxy: "",
+		},
+		{
+			name:    "no route",
+			result:  "PROXY " + closedAddr,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			p, err := parsePAC(`function FindProxyForURL(url, host) { return "` + tt.result + `"; }`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := &options{
+				timeout:  5 * time.Second,
+				insecure: true,
+				location: time.UTC,
+				pac:      p,
+			}
+			infos, err := getCertList(context.Background(), []string{ln.Addr().String()}, opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getCertList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if infos[0].Proxy != tt.wantProxy {
+				t.Errorf("Proxy = %q, want %q", infos[0].Proxy, tt.wantProxy)
+			}
+		})
+	}
+}
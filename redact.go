@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+)
+
+const (
+	redactHash = "hash"
+	redactOmit = "omit"
+)
+
+var (
+	redactFields = []string{"cn", "domains", "ips", "sans"}
+	redactModes  = []string{redactHash, redactOmit}
+)
+
+// redactor hides sensitive fields of the output, e.g. for reports shared
+// outside the team. Hashing keeps equal values recognizable across entries
+// and runs; IP addresses cannot be hashed in place and are always omitted.
+// A nil redactor leaves the output as is.
+type redactor struct {
+	fields map[string]bool
+	hash   bool
+}
+
+func newRedactor(fields []string, mode string) (*redactor, error) {
+	if !slices.Contains(redactModes, mode) {
+		return nil, fmt.Errorf("invalid redact mode: allowed values: %s", pipeJoin(redactModes))
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	r := &redactor{
+		fields: make(map[string]bool, len(fields)),
+		hash:   mode == redactHash,
+	}
+	for _, field := range fields {
+		if !slices.Contains(redactFields, field) {
+			return nil, fmt.Errorf("invalid redact field: allowed values: %s", pipeJoin(redactFields))
+		}
+		r.fields[field] = true
+	}
+	return r, nil
+}
+
+// apply returns redacted copies of infos.
+func (r *redactor) apply(infos []*certInfo) []*certInfo {
+	if r == nil {
+		return infos
+	}
+	res := make([]*certInfo, len(infos))
+	for i, info := range infos {
+		v := *info
+		if r.fields["cn"] {
+			v.CommonName = r.value(v.CommonName)
+		}
+		if r.fields["domains"] {
+			v.DomainName = r.value(v.DomainName)
+			v.Hosts = r.values(v.Hosts)
+		}
+		if r.fields["ips"] {
+			v.IPAddresses = []net.IP{}
+		}
+		if r.fields["sans"] {
+			v.SANs = r.values(v.SANs)
+		}
+		res[i] = &v
+	}
+	return res
+}
+
+func (r *redactor) value(s string) string {
+	if !r.hash || s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+func (r *redactor) values(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	if !r.hash {
+		return []string{}
+	}
+	res := make([]string, len(s))
+	for i, v := range s {
+		res[i] = r.value(v)
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newRedactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		mode    string
+		wantNil bool
+		wantErr bool
+	}{
+		{
+			name:   "fields",
+			fields: []string{"sans", "ips"},
+			mode:   redactHash,
+		},
+		{
+			name:    "no fields",
+			fields:  nil,
+			mode:    redactHash,
+			wantNil: true,
+		},
+		{
+			name:    "unknown field",
+			fields:  []string{"issuer"},
+			mode:    redactHash,
+			wantNil: true,
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			fields:  []string{"sans"},
+			mode:    "mask",
+			wantNil: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRedactor(tt.fields, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRedactor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Errorf("newRedactor() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func Test_redactor_apply(t *testing.T) {
+	info := &certInfo{
+		DomainName:  "internal.example.com",
+		AccessPort:  "443",
+		IPAddresses: []net.IP{net.ParseIP("192.0.2.1")},
+		CommonName:  "internal.example.com",
+		SANs:        []string{"internal.example.com", "db.internal.example.com"},
+		Issuer:      "CN=Example CA",
+		Hosts:       []string{"internal.example.com:443"},
+	}
+	tests := []struct {
+		name   string
+		fields []string
+		mode   string
+		want   *certInfo
+	}{
+		{
+			name:   "hash",
+			fields: []string{"sans", "ips"},
+			mode:   redactHash,
+			want: &certInfo{
+				DomainName:  "internal.example.com",
+				AccessPort:  "443",
+				IPAddresses: []net.IP{},
+				CommonName:  "internal.example.com",
+				SANs:        []string{"sha256:0df0a79185f5", "sha256:e802fb306c5f"},
+				Issuer:      "CN=Example CA",
+				Hosts:       []string{"internal.example.com:443"},
+			},
+		},
+		{
+			name:   "omit",
+			fields: []string{"cn", "domains", "sans"},
+			mode:   redactOmit,
+			want: &certInfo{
+				DomainName:  "",
+				AccessPort:  "443",
+				IPAddresses: []net.IP{net.ParseIP("192.0.2.1")},
+				CommonName:  "",
+				SANs:        []string{},
+				Issuer:      "CN=Example CA",
+				Hosts:       []string{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newRedactor(tt.fields, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := r.apply([]*certInfo{info})
+			if diff := cmp.Diff(got[0], tt.want, cmp.AllowUnexported(certInfo{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	t.Run("nil", func(t *testing.T) {
+		var r *redactor
+		if got := r.apply([]*certInfo{info}); got[0] != info {
+			t.Errorf("apply() = %v, want %v", got[0], info)
+		}
+	})
+	t.Run("original kept", func(t *testing.T) {
+		r, err := newRedactor([]string{"domains"}, redactOmit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.apply([]*certInfo{info})
+		if info.DomainName != "internal.example.com" {
+			t.Errorf("apply() modified the original: %q", info.DomainName)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// pins are the addresses to connect to instead of those a host resolves to,
+// keyed by host:port as with --resolve of curl, to check a backend behind a
+// name before the name points at it. The name is still used for SNI and
+// verification.
+type pins map[string]net.IP
+
+// parsePins parses values of host:port:addr, where an IPv6 addr may be in
+// brackets.
+func parsePins(values []string) (pins, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	p := make(pins, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid resolve entry %q: must be host:port:addr", v)
+		}
+		host, port, addr := parts[0], parts[1], parts[2]
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid resolve entry %q: %q is not a port number", v, port)
+		}
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid resolve entry %q: %q is not an IP address", v, addr)
+		}
+		p[net.JoinHostPort(strings.ToLower(host), port)] = ip
+	}
+	return p, nil
+}
+
+// lookup returns the address pinned for host and port, or nil.
+func (p pins) lookup(host, port string) net.IP {
+	return p[net.JoinHostPort(strings.ToLower(host), port)]
+}
+
+// addr returns the address to dial for addr, which is addr itself unless its
+// host is pinned on its port.
+func (p pins) addr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := p.lookup(host, port); ip != nil {
+		return net.JoinHostPort(ip.String(), port)
+	}
+	return addr
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parsePins(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    pins
+		wantErr bool
+	}{
+		{
+			name:    "none",
+			values:  nil,
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name:   "ipv4 and ipv6",
+			values: []string{"Example.com:443:10.1.2.3", "example.com:8443:[2001:db8::1]", "example.net:443:2001:db8::2"},
+			want: pins{
+				"example.com:443":  net.ParseIP("10.1.2.3"),
+				"example.com:8443": net.ParseIP("2001:db8::1"),
+				"example.net:443":  net.ParseIP("2001:db8::2"),
+			},
+			wantErr: false,
+		},
+		{
+			name:    "no addr",
+			values:  []string{"example.com:443"},
+			wantErr: true,
+		},
+		{
+			name:    "no host",
+			values:  []string{":443:10.1.2.3"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			values:  []string{"example.com:https:10.1.2.3"},
+			wantErr: true,
+		},
+		{
+			name:    "name as addr",
+			values:  []string{"example.com:443:backend.example.com"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePins(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_pins_addr(t *testing.T) {
+	p := pins{"example.com:443": net.ParseIP("10.1.2.3")}
+	tests := []struct {
+		name string
+		addr string
+		want string
+	}{
+		{
+			name: "pinned",
+			addr: "EXAMPLE.com:443",
+			want: "10.1.2.3:443",
+		},
+		{
+			name: "other port",
+			addr: "example.com:8443",
+			want: "example.com:8443",
+		},
+		{
+			name: "other host",
+			addr: "example.net:443",
+			want: "example.net:443",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.addr(tt.addr); got != tt.want {
+				t.Errorf("pins.addr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if got := pins(nil).addr("example.com:443"); got != "example.com:443" {
+		t.Errorf("nil pins.addr() = %s", got)
+	}
+}
+
+func Test_getCertList_resolve(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	serverNames := make(chan string, 1)
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		serverNames <- hello.ServerName
+		return nil, nil
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := tls.Server(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		_, _ = tlsConn.Read(make([]byte, 1))
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, err := parsePins([]string{"backend.invalid:" + port + ":127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connCache.purge()
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+		pins:     p,
+	}
+	infos, err := getCertList(context.Background(), []string{"backend.invalid:" + port}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-serverNames; got != "backend.invalid" {
+		t.Errorf("server name = %s, want backend.invalid", got)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("getCertList() = %d infos, want 1", len(infos))
+	}
+	if diff := cmp.Diff([]net.IP{net.ParseIP("127.0.0.1")}, infos[0].IPAddresses); diff != "" {
+		t.Error(diff)
+	}
+	if infos[0].DomainName != "backend.invalid" {
+		t.Errorf("DomainName = %s, want backend.invalid", infos[0].DomainName)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	defaultDNSTTL         = time.Minute
+	defaultDNSNegativeTTL = 30 * time.Second
+	maxDNSTTL             = defaultCacheTTL
+	dnsUDPSize            = 1232
+	dnsMessageType        = "application/dns-message"
+	// dnsMaxSize is the largest DNS message, as over TCP
+	dnsMaxSize = 65535
+)
+
+// resolver looks up the IP addresses of a host. The returned ttl says how
+// long the answer, positive or not-found, may be reused; zero means unknown.
+type resolver interface {
+	lookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+}
+
+type lookupResult struct {
+	ips []net.IP
+	err error
+}
+
+var defaultResolver = newCachingResolver(systemResolver{}, defaultDNSNegativeTTL)
+
+// cachingResolver caches answers of another resolver in ipCache. Positive
+// answers live for their TTL, not-found answers for the negative TTL, and
+// transient failures are not cached at all. Positive answers are also shared
+// with other instances through shared, if set.
+type cachingResolver struct {
+	resolver    resolver
+	negativeTTL time.Duration
+	shared      *sharedCache
+}
+
+func newCachingResolver(r resolver, negativeTTL time.Duration) *cachingResolver {
+	return &cachingResolver{
+		resolver:    r,
+		negativeTTL: negativeTTL,
+	}
+}
+
+func (r *cachingResolver) lookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if res, ok := ipCache.load(host); ok {
+		return res.ips, 0, res.err
+	}
+	if ips, ok := r.shared.loadIPs(ctx, host); ok {
+		ipCache.storeTTL(host, lookupResult{ips: ips}, defaultDNSTTL)
+		return ips, 0, nil
+	}
+	ips, ttl, err := r.resolver.lookupIP(ctx, host)
+	switch {
+	case err == nil:
+		if ttl <= 0 {
+			ttl = defaultDNSTTL
+		}
+	case isNotFound(err):
+		if ttl <= 0 {
+			ttl = r.negativeTTL
+		}
+		if ttl <= 0 {
+			return ips, ttl, err
+		}
+	default:
+		return ips, ttl, err
+	}
+	ttl = min(ttl, maxDNSTTL)
+	ipCache.storeTTL(host, lookupResult{ips: ips, err: err}, ttl)
+	if err == nil {
+		r.shared.storeIPs(ctx, host, ips, ttl)
+	}
+	return ips, ttl, err
+}
+
+// systemResolver uses the resolver of the operating system, which does not
+// expose record TTLs.
+type systemResolver struct{}
+
+func (systemResolver) lookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	var r net.Resolver
+	ips, err := r.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, 0, err
+	}
+	sortIPs(ips)
+	return ips, 0, nil
+}
+
+// dnsResolver queries a DNS server directly so that record TTLs are known.
+// The server is queried over UDP and TCP on port 53, over TLS on port 853
+// (RFC 7858) or over HTTPS (RFC 8484), the latter two being what gets through
+// networks that intercept plain DNS.
+type dnsResolver struct {
+	server string
+	// tls is the config of the connections to a DNS over TLS server
+	tls *tls.Config
+	// client posts the queries to server as a DNS over HTTPS URL
+	client *http.Client
+}
+
+func newDNSResolver(server string) *dnsResolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &dnsResolver{server: server}
+}
+
+// newDoTResolver returns a resolver of the DNS over TLS server, whose cert is
+// verified against its name or address.
+func newDoTResolver(server string) *dnsResolver {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		host = server
+		server = net.JoinHostPort(server, "853")
+	}
+	return &dnsResolver{
+		server: server,
+		tls:    &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12},
+	}
+}
+
+// newDoHResolver returns a resolver of the DNS over HTTPS server at rawURL,
+// e.g. https://1.1.1.1/dns-query.
+func newDoHResolver(rawURL string, timeout time.Duration) (*dnsResolver, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid DNS over HTTPS URL %q: must be https://host/path", rawURL)
+	}
+	return &dnsResolver{server: rawURL, client: &http.Client{Timeout: timeout}}, nil
+}
+
+func (r *dnsResolver) lookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, 0, nil
+	}
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, r.dnsError(host, err.Error(), false)
+	}
+	var ips []net.IP
+	var ttl, negativeTTL time.Duration
+	for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		msg, err := r.exchange(ctx, name, typ)
+		if err != nil {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+			return nil, 0, r.dnsError(host, err.Error(), false)
+		}
+		switch msg.RCode {
+		case dnsmessage.RCodeSuccess:
+		case dnsmessage.RCodeNameError:
+			return nil, soaTTL(msg), r.dnsError(host, "no such host", true)
+		default:
+			return nil, 0, r.dnsError(host, "server misbehaving: "+msg.RCode.String(), false)
+		}
+		for _, rr := range msg.Answers {
+			switch body := rr.Body.(type) {
+			case *dnsmessage.AResource:
+				ips = append(ips, net.IPv4(body.A[0], body.A[1], body.A[2], body.A[3]))
+			case *dnsmessage.AAAAResource:
+				ips = append(ips, net.IP(slices.Clone(body.AAAA[:])))
+			default:
+				continue
+			}
+			ttl = minTTL(ttl, time.Duration(rr.Header.TTL)*time.Second)
+		}
+		negativeTTL = minTTL(negativeTTL, soaTTL(msg))
+	}
+	if len(ips) == 0 {
+		return nil, negativeTTL, r.dnsError(host, "no such host", true)
+	}
+	sortIPs(ips)
+	return ips, ttl, nil
+}
+
+func (r *dnsResolver) dnsError(host, msg string, notFound bool) error {
+	return &net.DNSError{
+		Err:        msg,
+		Name:       host,
+		Server:     r.server,
+		IsNotFound: notFound,
+	}
+}
+
+func (r *dnsResolver) exchange(ctx context.Context, name dnsmessage.Name, typ dnsmessage.Type) (*dnsmessage.Message, error) {
+	id := uint16(rand.Uint32()) // #nosec G404
+	// DNS over HTTPS uses 0 so that responses can be cached by HTTP
+	if r.client != nil {
+		id = 0
+	}
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               id,
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{
+			{
+				Name:  name,
+				Type:  typ,
+				Class: dnsmessage.ClassINET,
+			},
+		},
+	}
+	b, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	var msg *dnsmessage.Message
+	switch {
+	case r.client != nil:
+		msg, err = r.post(ctx, b)
+	case r.tls != nil:
+		msg, err = r.roundTrip(ctx, "tcp", b)
+	default:
+		msg, err = r.roundTrip(ctx, "udp", b)
+		if err == nil && msg.Truncated {
+			msg, err = r.roundTrip(ctx, "tcp", b)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.ID != id || !msg.Response {
+		return nil, errors.New("unexpected response id")
+	}
+	return msg, nil
+}
+
+func (r *dnsResolver) roundTrip(ctx context.Context, network string, query []byte) (*dnsmessage.Message, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if r.tls != nil {
+		dialer := tls.Dialer{Config: r.tls}
+		conn, err = dialer.DialContext(ctx, network, r.server)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, network, r.server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	release := bindContext(ctx, conn)
+	defer release()
+	var b []byte
+	if network == "tcp" {
+		// DNS over TCP prefixes each message with its two-byte length
+		if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(query)))); err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		b = make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		b = make([]byte, dnsUDPSize)
+		n, err := conn.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[:n]
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(b); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// post sends query to the DNS over HTTPS server.
+func (r *dnsResolver) post(ctx context.Context, query []byte) (*dnsmessage.Message, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.server, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageType)
+	req.Header.Set("Accept", dnsMessageType)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, dnsMaxSize))
+	if err != nil {
+		return nil, err
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(b); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// soaTTL returns the negative caching TTL of a response, which is the lesser
+// of the SOA record TTL and its minimum field (RFC 2308).
+func soaTTL(msg *dnsmessage.Message) time.Duration {
+	for _, rr := range msg.Authorities {
+		if soa, ok := rr.Body.(*dnsmessage.SOAResource); ok {
+			return time.Duration(min(rr.Header.TTL, soa.MinTTL)) * time.Second
+		}
+	}
+	return 0
+}
+
+func minTTL(a, b time.Duration) time.Duration {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 {
+		return a
+	}
+	return min(a, b)
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+func sortIPs(ips []net.IP) {
+	slices.SortFunc(ips, func(a, b net.IP) int {
+		return bytes.Compare(a, b)
+	})
+}
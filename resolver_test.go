@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsAnswer builds the response of the fake DNS server to q. With udp set,
+// "tc.test." is answered truncated to force a retry over TCP.
+func dnsAnswer(t *testing.T, q []byte, udp bool) []byte {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(q); err != nil {
+		t.Error(err)
+		return nil
+	}
+	question := msg.Questions[0]
+	msg.Response = true
+	header := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 300}
+	switch question.Name.String() {
+	case "a.test.", "tc.test.":
+		if udp && question.Name.String() == "tc.test." {
+			msg.Truncated = true
+			break
+		}
+		switch question.Type {
+		case dnsmessage.TypeA:
+			msg.Answers = []dnsmessage.Resource{
+				{Header: header, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}},
+			}
+		case dnsmessage.TypeAAAA:
+			header.TTL = 60
+			msg.Answers = []dnsmessage.Resource{
+				{Header: header, Body: &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}},
+			}
+		}
+	case "caa.test.":
+		if question.Type == typeCAA {
+			msg.Answers = []dnsmessage.Resource{
+				{Header: header, Body: &dnsmessage.UnknownResource{Type: typeCAA, Data: append([]byte{0, 5}, "issueletsencrypt.org; validationmethods=dns-01"...)}},
+				{Header: header, Body: &dnsmessage.UnknownResource{Type: typeCAA, Data: append([]byte{0, 9}, "issuewild;"...)}},
+			}
+		}
+	case "broken.caa.test.":
+		if question.Type == typeCAA {
+			msg.Answers = []dnsmessage.Resource{
+				{Header: header, Body: &dnsmessage.UnknownResource{Type: typeCAA, Data: []byte{0, 9}}},
+			}
+		}
+	case "nx.test.":
+		msg.RCode = dnsmessage.RCodeNameError
+		header.TTL = 20
+		msg.Authorities = []dnsmessage.Resource{
+			{Header: header, Body: &dnsmessage.SOAResource{NS: question.Name, MBox: question.Name, MinTTL: 15}},
+		}
+	case "fail.test.":
+		msg.RCode = dnsmessage.RCodeServerFailure
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		t.Error(err)
+	}
+	return b
+}
+
+func startTestDNSServer(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		ln.Close()
+	})
+	go func() {
+		b := make([]byte, dnsUDPSize)
+		for {
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			_, _ = pc.WriteTo(dnsAnswer(t, b[:n], true), addr)
+		}
+	}()
+	go serveTestDNSStream(t, ln)
+	return pc.LocalAddr().String()
+}
+
+// serveTestDNSStream answers the queries on the connections of ln, each
+// prefixed by its length as over TCP and TLS.
+func serveTestDNSStream(t *testing.T, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var size [2]byte
+			if _, err := io.ReadFull(conn, size[:]); err != nil {
+				return
+			}
+			q := make([]byte, binary.BigEndian.Uint16(size[:]))
+			if _, err := io.ReadFull(conn, q); err != nil {
+				return
+			}
+			resp := dnsAnswer(t, q, false)
+			_, _ = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+		}()
+	}
+}
+
+// newTestDoTResolver returns a resolver of a fake DNS over TLS server.
+func newTestDoTResolver(t *testing.T) *dnsResolver {
+	t.Helper()
+	config := newTestTLSConfig(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go serveTestDNSStream(t, ln)
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newDoTResolver(ln.Addr().String())
+	r.tls.ServerName = host
+	r.tls.RootCAs = x509.NewCertPool()
+	r.tls.RootCAs.AddCert(leaf)
+	return r
+}
+
+// newTestDoHResolver returns a resolver of a fake DNS over HTTPS server.
+func newTestDoHResolver(t *testing.T) *dnsResolver {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dnsMessageType {
+			http.Error(w, "unsupported query", http.StatusBadRequest)
+			return
+		}
+		q, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dnsMessageType)
+		_, _ = w.Write(dnsAnswer(t, q, false))
+	}))
+	t.Cleanup(srv.Close)
+	r, err := newDoHResolver(srv.URL+"/dns-query", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.client = srv.Client()
+	return r
+}
+
+func Test_dnsResolver_lookupIP(t *testing.T) {
+	resolvers := map[string]*dnsResolver{
+		"dns": newDNSResolver(startTestDNSServer(t)),
+		"dot": newTestDoTResolver(t),
+		"doh": newTestDoHResolver(t),
+	}
+	tests := []struct {
+		name         string
+		host         string
+		want         []net.IP
+		wantTTL      time.Duration
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{
+			name:    "a and aaaa",
+			host:    "a.test",
+			want:    []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+			wantTTL: 60 * time.Second,
+		},
+		{
+			name:    "truncated",
+			host:    "tc.test",
+			want:    []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+			wantTTL: 60 * time.Second,
+		},
+		{
+			name:    "ip literal",
+			host:    "192.0.2.10",
+			want:    []net.IP{net.ParseIP("192.0.2.10")},
+			wantTTL: 0,
+		},
+		{
+			name:         "nxdomain",
+			host:         "nx.test",
+			want:         nil,
+			wantTTL:      15 * time.Second,
+			wantErr:      true,
+			wantNotFound: true,
+		},
+		{
+			name:         "no data",
+			host:         "empty.test",
+			want:         nil,
+			wantTTL:      0,
+			wantErr:      true,
+			wantNotFound: true,
+		},
+		{
+			name:         "server failure",
+			host:         "fail.test",
+			want:         nil,
+			wantTTL:      0,
+			wantErr:      true,
+			wantNotFound: false,
+		},
+	}
+	for name, r := range resolvers {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				got, ttl, err := r.lookupIP(ctx, tt.host)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("dnsResolver.lookupIP() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if isNotFound(err) != tt.wantNotFound {
+					t.Errorf("dnsResolver.lookupIP() error = %v, wantNotFound %v", err, tt.wantNotFound)
+				}
+				if diff := cmp.Diff(got, tt.want); diff != "" {
+					t.Error(diff)
+				}
+				if ttl != tt.wantTTL {
+					t.Errorf("dnsResolver.lookupIP() ttl = %v, want %v", ttl, tt.wantTTL)
+				}
+			})
+		}
+	}
+}
+
+func Test_newDoHResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		wantErr bool
+	}{
+		{
+			name:    "https",
+			rawURL:  "https://1.1.1.1/dns-query",
+			wantErr: false,
+		},
+		{
+			name:    "http",
+			rawURL:  "http://1.1.1.1/dns-query",
+			wantErr: true,
+		},
+		{
+			name:    "no host",
+			rawURL:  "https:///dns-query",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newDoHResolver(tt.rawURL, time.Second); (err != nil) != tt.wantErr {
+				t.Errorf("newDoHResolver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newDoTResolver(t *testing.T) {
+	tests := []struct {
+		name           string
+		server         string
+		wantServer     string
+		wantServerName string
+	}{
+		{
+			name:           "default port",
+			server:         "1.1.1.1",
+			wantServer:     "1.1.1.1:853",
+			wantServerName: "1.1.1.1",
+		},
+		{
+			name:           "port",
+			server:         "dns.example.com:8853",
+			wantServer:     "dns.example.com:8853",
+			wantServerName: "dns.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newDoTResolver(tt.server)
+			if r.server != tt.wantServer || r.tls.ServerName != tt.wantServerName {
+				t.Errorf("newDoTResolver() = %s %s, want %s %s", r.server, r.tls.ServerName, tt.wantServer, tt.wantServerName)
+			}
+		})
+	}
+}
+
+type countingResolver struct {
+	calls int
+	ips   []net.IP
+	ttl   time.Duration
+	err   error
+}
+
+func (r *countingResolver) lookupIP(_ context.Context, _ string) ([]net.IP, time.Duration, error) {
+	r.calls++
+	return r.ips, r.ttl, r.err
+}
+
+func Test_cachingResolver_lookupIP(t *testing.T) {
+	notFound := &net.DNSError{Err: "no such host", Name: "example.test", IsNotFound: true}
+	tests := []struct {
+		name        string
+		base        *countingResolver
+		negativeTTL time.Duration
+		wantCalls   int
+		wantTTL     time.Duration
+	}{
+		{
+			name:      "positive with ttl",
+			base:      &countingResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}, ttl: 30 * time.Second},
+			wantCalls: 1,
+			wantTTL:   30 * time.Second,
+		},
+		{
+			name:      "positive without ttl",
+			base:      &countingResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}},
+			wantCalls: 1,
+			wantTTL:   defaultDNSTTL,
+		},
+		{
+			name:      "positive ttl capped",
+			base:      &countingResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}, ttl: 24 * time.Hour},
+			wantCalls: 1,
+			wantTTL:   maxDNSTTL,
+		},
+		{
+			name:        "negative",
+			base:        &countingResolver{err: notFound},
+			negativeTTL: time.Minute,
+			wantCalls:   1,
+			wantTTL:     time.Minute,
+		},
+		{
+			name:        "negative with soa ttl",
+			base:        &countingResolver{err: notFound, ttl: 15 * time.Second},
+			negativeTTL: time.Minute,
+			wantCalls:   1,
+			wantTTL:     15 * time.Second,
+		},
+		{
+			name:        "negative caching disabled",
+			base:        &countingResolver{err: notFound},
+			negativeTTL: 0,
+			wantCalls:   2,
+			wantTTL:     0,
+		},
+		{
+			name:        "transient failure",
+			base:        &countingResolver{err: errors.New("i/o timeout")},
+			negativeTTL: time.Minute,
+			wantCalls:   2,
+			wantTTL:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipCache.delete("example.test")
+			defer ipCache.delete("example.test")
+			r := newCachingResolver(tt.base, tt.negativeTTL)
+			_, ttl, err1 := r.lookupIP(context.Background(), "example.test")
+			ips, _, err2 := r.lookupIP(context.Background(), "example.test")
+			if tt.base.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", tt.base.calls, tt.wantCalls)
+			}
+			if ttl != tt.wantTTL {
+				t.Errorf("ttl = %v, want %v", ttl, tt.wantTTL)
+			}
+			if !errors.Is(err2, err1) {
+				t.Errorf("cached error = %v, want %v", err2, err1)
+			}
+			if diff := cmp.Diff(ips, tt.base.ips); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
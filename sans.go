@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const sanLookupConcurrency = 8
+
+// staleSANs returns the DNS names of cert that no longer resolve, which are
+// candidates to drop at the next renewal. Wildcard names cannot be looked up
+// and are skipped, as are names whose lookup failed for other reasons than
+// not existing.
+func (c *connector) staleSANs(ctx context.Context, cert *x509.Certificate) []string {
+	r := c.getResolver()
+	stale := make([]bool, len(cert.DNSNames))
+	var eg errgroup.Group
+	eg.SetLimit(sanLookupConcurrency)
+	for i, name := range cert.DNSNames {
+		if strings.HasPrefix(name, "*.") {
+			continue
+		}
+		i, name := i, name
+		eg.Go(func() error {
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			_, _, err := r.lookupIP(ctx, name)
+			stale[i] = isNotFound(err)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+	var res []string
+	for i, name := range cert.DNSNames {
+		if stale[i] {
+			res = append(res, name)
+		}
+	}
+	return res
+}
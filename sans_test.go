@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// mapResolver answers names found in it with their error, and any other name
+// with an address.
+type mapResolver map[string]error
+
+func (r mapResolver) lookupIP(_ context.Context, host string) ([]net.IP, time.Duration, error) {
+	if err, ok := r[host]; ok {
+		return nil, 0, err
+	}
+	return []net.IP{net.ParseIP("192.0.2.1")}, 0, nil
+}
+
+func Test_connector_staleSANs(t *testing.T) {
+	r := mapResolver{
+		"old.example.com":  &net.DNSError{Err: "no such host", Name: "old.example.com", IsNotFound: true},
+		"gone.example.com": &net.DNSError{Err: "no such host", Name: "gone.example.com", IsNotFound: true},
+		"slow.example.com": errors.New("i/o timeout"),
+	}
+	tests := []struct {
+		name     string
+		dnsNames []string
+		want     []string
+	}{
+		{
+			name:     "all resolve",
+			dnsNames: []string{"example.com", "www.example.com"},
+			want:     nil,
+		},
+		{
+			name:     "stale",
+			dnsNames: []string{"gone.example.com", "www.example.com", "old.example.com"},
+			want:     []string{"gone.example.com", "old.example.com"},
+		},
+		{
+			name:     "transient failure",
+			dnsNames: []string{"slow.example.com"},
+			want:     nil,
+		},
+		{
+			name:     "wildcard",
+			dnsNames: []string{"*.old.example.com"},
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &connector{resolver: r, timeout: time.Second}
+			got := c.staleSANs(context.Background(), &x509.Certificate{DNSNames: tt.dnsNames})
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// randomDelay returns a random delay from zero up to limit, so that runs
+// started by many schedulers at the same moment do not hit endpoints at once.
+func randomDelay(limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit) // #nosec G404
+}
+
+// spreadOffset returns when the i-th of n targets starts if their checks are
+// spread evenly across window. The first target starts immediately.
+func spreadOffset(i, n int, window time.Duration) time.Duration {
+	if window <= 0 || n <= 1 {
+		return 0
+	}
+	return window / time.Duration(n) * time.Duration(i)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateUnits are the units a rate can be given per besides a duration.
+var rateUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// limiter paces new connections across a run, whatever the concurrency, as a
+// token bucket holding a single token, so that the attempts never burst. A
+// nil limiter does not wait.
+type limiter struct {
+	mu    sync.Mutex
+	every time.Duration
+	next  time.Time
+}
+
+// parseRate returns the limiter of a rate given as N/unit, where unit is s, m,
+// h or a duration such as 500ms, or as N alone per second. An empty rate
+// means no limit.
+func parseRate(rate string) (*limiter, error) {
+	if rate == "" {
+		return nil, nil
+	}
+	count, unit, found := strings.Cut(rate, "/")
+	per := time.Second
+	if found {
+		var ok bool
+		if per, ok = rateUnits[unit]; !ok {
+			d, err := time.ParseDuration(unit)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid rate %q: unit must be s, m, h or a positive duration", rate)
+			}
+			per = d
+		}
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid rate %q: count must be greater than 0", rate)
+	}
+	return &limiter{every: per / time.Duration(n)}, nil
+}
+
+// wait waits for the turn of the next connection or until ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.every)
+	l.mu.Unlock()
+	return sleep(ctx, at.Sub(now))
+}
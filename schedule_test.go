@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_randomDelay(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit time.Duration
+	}{
+		{
+			name:  "zero",
+			limit: 0,
+		},
+		{
+			name:  "negative",
+			limit: -time.Second,
+		},
+		{
+			name:  "positive",
+			limit: 300 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				got := randomDelay(tt.limit)
+				if got < 0 || got > max(tt.limit, 0) {
+					t.Fatalf("randomDelay() = %v, want from 0 to %v", got, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func Test_spreadOffset(t *testing.T) {
+	tests := []struct {
+		name   string
+		i      int
+		n      int
+		window time.Duration
+		want   time.Duration
+	}{
+		{
+			name:   "first",
+			i:      0,
+			n:      4,
+			window: time.Hour,
+			want:   0,
+		},
+		{
+			name:   "last",
+			i:      3,
+			n:      4,
+			window: time.Hour,
+			want:   45 * time.Minute,
+		},
+		{
+			name:   "single target",
+			i:      0,
+			n:      1,
+			window: time.Hour,
+			want:   0,
+		},
+		{
+			name:   "no window",
+			i:      3,
+			n:      4,
+			window: 0,
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spreadOffset(tt.i, tt.n, tt.window); got != tt.want {
+				t.Errorf("spreadOffset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sleep(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		d       time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "elapsed",
+			ctx:     context.Background(),
+			d:       time.Millisecond,
+			wantErr: false,
+		},
+		{
+			name:    "zero",
+			ctx:     context.Background(),
+			d:       0,
+			wantErr: false,
+		},
+		{
+			name:    "canceled",
+			ctx:     canceled,
+			d:       time.Hour,
+			wantErr: true,
+		},
+		{
+			name:    "canceled zero",
+			ctx:     canceled,
+			d:       0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sleep(tt.ctx, tt.d); (err != nil) != tt.wantErr {
+				t.Errorf("sleep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_getCertList_spread(t *testing.T) {
+	connCache.purge()
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+		spread:   300 * time.Millisecond,
+	}
+	start := time.Now()
+	if _, err := getCertList(context.Background(), []string{addr, "127.0.0.1:" + port, "localhost:" + port}, opts); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("checks finished in %v, want spread across 300ms", elapsed)
+	}
+}
+
+func Test_parseRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      string
+		wantEvery time.Duration
+		wantNil   bool
+		wantErr   bool
+	}{
+		{
+			name:    "none",
+			rate:    "",
+			wantNil: true,
+		},
+		{
+			name:      "per second",
+			rate:      "10/s",
+			wantEvery: 100 * time.Millisecond,
+		},
+		{
+			name:      "per minute",
+			rate:      "120/m",
+			wantEvery: 500 * time.Millisecond,
+		},
+		{
+			name:      "per duration",
+			rate:      "5/500ms",
+			wantEvery: 100 * time.Millisecond,
+		},
+		{
+			name:      "count alone",
+			rate:      "4",
+			wantEvery: 250 * time.Millisecond,
+		},
+		{
+			name:    "zero count",
+			rate:    "0/s",
+			wantErr: true,
+		},
+		{
+			name:    "unknown unit",
+			rate:    "10/d",
+			wantErr: true,
+		},
+		{
+			name:    "negative duration",
+			rate:    "10/-1s",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRate(tt.rate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("parseRate() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got != nil && got.every != tt.wantEvery {
+				t.Errorf("parseRate() every = %v, want %v", got.every, tt.wantEvery)
+			}
+		})
+	}
+}
+
+func Test_limiter_wait(t *testing.T) {
+	l := &limiter{every: 50 * time.Millisecond}
+	start := time.Now()
+	for range 4 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first connection does not wait
+	if got := time.Since(start); got < 150*time.Millisecond {
+		t.Errorf("4 waits took %v, want at least 150ms", got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = &limiter{every: time.Hour, next: time.Now().Add(time.Hour)}
+	if err := l.wait(ctx); err == nil {
+		t.Error("wait() of a canceled context succeeded")
+	}
+	if err := (*limiter)(nil).wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const selftestHosts = 200
+
+// farm is a fleet of in-process TLS listeners on the loopback interface, each
+// serving its own leaf cert. Leaves alternate between RSA and ECDSA keys and
+// between chains issued by a root, by an intermediate, or self-signed.
+type farm struct {
+	listeners []net.Listener
+	want      map[string]string
+	mu        sync.Mutex
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+type farmIssuer struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newFarm(n int) (*farm, error) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	root, err := newFarmCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "tlc3 selftest root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, ecKey)
+	if err != nil {
+		return nil, err
+	}
+	inter, err := newFarmCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "tlc3 selftest intermediate"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(5, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, root, ecKey)
+	if err != nil {
+		return nil, err
+	}
+	f := &farm{
+		want:  make(map[string]string, n),
+		conns: make(map[net.Conn]struct{}),
+	}
+	for i := 0; i < n; i++ {
+		var key crypto.Signer = ecKey
+		if i%2 == 1 {
+			key = rsaKey
+		}
+		name := fmt.Sprintf("host%d.selftest.invalid", i)
+		tmpl := &x509.Certificate{
+			Subject:     pkix.Name{CommonName: name},
+			DNSNames:    []string{name},
+			NotBefore:   now.Add(-time.Hour),
+			NotAfter:    now.AddDate(0, 0, i+1),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		var chain [][]byte
+		switch i % 3 {
+		case 0:
+			leaf, err := newFarmCert(tmpl, root, key)
+			if err != nil {
+				f.close()
+				return nil, err
+			}
+			chain = [][]byte{leaf.cert.Raw}
+		case 1:
+			leaf, err := newFarmCert(tmpl, inter, key)
+			if err != nil {
+				f.close()
+				return nil, err
+			}
+			chain = [][]byte{leaf.cert.Raw, inter.cert.Raw}
+		default:
+			leaf, err := newFarmCert(tmpl, nil, key)
+			if err != nil {
+				f.close()
+				return nil, err
+			}
+			chain = [][]byte{leaf.cert.Raw}
+		}
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{{Certificate: chain, PrivateKey: key}},
+		})
+		if err != nil {
+			f.close()
+			return nil, err
+		}
+		f.listeners = append(f.listeners, ln)
+		f.want[ln.Addr().String()] = name
+		f.wg.Add(1)
+		go f.serve(ln)
+	}
+	return f, nil
+}
+
+// newFarmCert signs tmpl with parent, or self-signs it if parent is nil.
+func newFarmCert(tmpl *x509.Certificate, parent *farmIssuer, key crypto.Signer) (*farmIssuer, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl.SerialNumber = serial
+	issuer, signer := tmpl, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, key.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &farmIssuer{cert: cert, key: key}, nil
+}
+
+func (f *farm) serve(ln net.Listener) {
+	defer f.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns[conn] = struct{}{}
+		f.mu.Unlock()
+		go func() {
+			defer func() {
+				f.mu.Lock()
+				delete(f.conns, conn)
+				f.mu.Unlock()
+				conn.Close()
+			}()
+			_, _ = io.Copy(io.Discard, conn)
+		}()
+	}
+}
+
+func (f *farm) addrs() []string {
+	addrs := make([]string, 0, len(f.listeners))
+	for _, ln := range f.listeners {
+		addrs = append(addrs, ln.Addr().String())
+	}
+	return addrs
+}
+
+func (f *farm) close() {
+	for _, ln := range f.listeners {
+		ln.Close()
+	}
+	f.mu.Lock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+}
+
+// selftest scans a simulated fleet of n hosts and reports the throughput.
+// It fails if any host returns a cert other than the one it serves.
+func selftest(ctx context.Context, w io.Writer, n int) error {
+	log.Info("starting simulated fleet", "hosts", n)
+	f, err := newFarm(n)
+	if err != nil {
+		return err
+	}
+	defer f.close()
+	addrs := f.addrs()
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.Local,
+	}
+	log.Info("getting certificate information...")
+	start := time.Now()
+	infos, err := getCertList(ctx, addrs, opts)
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	for i, info := range infos {
+		if want := f.want[addrs[i]]; info.CommonName != want {
+			return fmt.Errorf("selftest failed: %s: got %q, want %q", addrs[i], info.CommonName, want)
+		}
+	}
+	fmt.Fprintf(w, "hosts: %d\nelapsed: %s\nper host: %s\n", len(infos), elapsed, elapsed/time.Duration(len(infos)))
+	log.Info("completed")
+	return nil
+}
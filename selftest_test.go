@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newFarm(t *testing.T) {
+	f, err := newFarm(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.close()
+	addrs := f.addrs()
+	if len(addrs) != 6 {
+		t.Fatalf("len(addrs) = %v, want 6", len(addrs))
+	}
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+	}
+	infos, err := getCertList(context.Background(), addrs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, info := range infos {
+		if want := f.want[addrs[i]]; info.CommonName != want {
+			t.Errorf("CommonName = %v, want %v", info.CommonName, want)
+		}
+		if info.DaysLeft != i {
+			t.Errorf("DaysLeft = %v, want %v", info.DaysLeft, i)
+		}
+	}
+}
+
+func Test_selftest(t *testing.T) {
+	w := &bytes.Buffer{}
+	if err := selftest(context.Background(), w, 3); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(w.String(), "hosts: 3\n") {
+		t.Errorf("unexpected output: %s", w.String())
+	}
+}
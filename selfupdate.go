@@ -0,0 +1,330 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	releaseAPI       = "https://api.github.com/repos/nekrassov01/tlc3/releases/latest"
+	releaseChecksums = "checksums.txt"
+	releaseMaxSize   = 100 << 20
+	releaseTimeout   = 5 * time.Minute
+)
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// updater replaces the running binary with the archive of the latest GitHub
+// release for the platform, after checking it against the checksums published
+// with the release.
+type updater struct {
+	client *http.Client
+	api    string
+	goos   string
+	goarch string
+}
+
+func newUpdater(timeout time.Duration) *updater {
+	return &updater{
+		client: &http.Client{Timeout: timeout},
+		api:    releaseAPI,
+		goos:   runtime.GOOS,
+		goarch: runtime.GOARCH,
+	}
+}
+
+// selfUpdate installs the latest release over exe unless it is not newer than
+// the running version. With checkOnly it only reports the latest version.
+func (u *updater) selfUpdate(ctx context.Context, w io.Writer, exe string, checkOnly bool) error {
+	rel, err := u.latest(ctx)
+	if err != nil {
+		return err
+	}
+	newer, err := newerVersion(Version, rel.TagName)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		fmt.Fprintf(w, "%s is up to date: %s\n", appName, Version)
+		return nil
+	}
+	if checkOnly {
+		fmt.Fprintf(w, "%s can be updated: %s -> %s\n", appName, Version, strings.TrimPrefix(rel.TagName, "v"))
+		return nil
+	}
+	name := archiveName(u.goos, u.goarch)
+	archiveURL, err := rel.assetURL(name)
+	if err != nil {
+		return err
+	}
+	checksumsURL, err := rel.assetURL(releaseChecksums)
+	if err != nil {
+		return err
+	}
+	log.Info("downloading release...", "version", rel.TagName, "asset", name)
+	archive, err := u.download(ctx, archiveURL)
+	if err != nil {
+		return err
+	}
+	checksums, err := u.download(ctx, checksumsURL)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(checksums, name, archive); err != nil {
+		return err
+	}
+	bin, err := extractBinary(name, archive, binaryName(u.goos))
+	if err != nil {
+		return err
+	}
+	if err := replaceExecutable(exe, bin); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s is updated: %s -> %s\n", appName, Version, strings.TrimPrefix(rel.TagName, "v"))
+	return nil
+}
+
+func (u *updater) latest(ctx context.Context) (*release, error) {
+	b, err := u.get(ctx, u.api, "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the latest release: %w", err)
+	}
+	var rel release
+	if err := json.Unmarshal(b, &rel); err != nil {
+		return nil, fmt.Errorf("cannot get the latest release: %w", err)
+	}
+	if rel.TagName == "" {
+		return nil, errors.New("cannot get the latest release: no tag name")
+	}
+	return &rel, nil
+}
+
+func (u *updater) download(ctx context.Context, url string) ([]byte, error) {
+	b, err := u.get(ctx, url, "application/octet-stream")
+	if err != nil {
+		return nil, fmt.Errorf("cannot download %s: %w", path.Base(url), err)
+	}
+	return b, nil
+}
+
+func (u *updater) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", appName+"/"+Version)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, releaseMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > releaseMaxSize {
+		return nil, fmt.Errorf("response exceeds %d bytes", releaseMaxSize)
+	}
+	return b, nil
+}
+
+func (r *release) assetURL(name string) (string, error) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL, nil
+		}
+	}
+	return "", fmt.Errorf("no asset %s in release %s", name, r.TagName)
+}
+
+// archiveName follows the name_template of the archives in .goreleaser.yml.
+func archiveName(goos, goarch string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	}
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	return fmt.Sprintf("%s_%s_%s%s", appName, strings.ToUpper(goos[:1])+goos[1:], arch, ext)
+}
+
+func binaryName(goos string) string {
+	if goos == "windows" {
+		return appName + ".exe"
+	}
+	return appName
+}
+
+// newerVersion reports whether the release tag is a later version than
+// current, comparing the dot-separated numbers of both.
+func newerVersion(current, tag string) (bool, error) {
+	parse := func(s string) ([]int, error) {
+		fields := strings.Split(strings.TrimPrefix(s, "v"), ".")
+		nums := make([]int, len(fields))
+		for i, field := range fields {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version: %q", s)
+			}
+			nums[i] = n
+		}
+		return nums, nil
+	}
+	cur, err := parse(current)
+	if err != nil {
+		return false, err
+	}
+	latest, err := parse(tag)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < max(len(cur), len(latest)); i++ {
+		var a, b int
+		if i < len(cur) {
+			a = cur[i]
+		}
+		if i < len(latest) {
+			b = latest[i]
+		}
+		if a != b {
+			return b > a, nil
+		}
+	}
+	return false, nil
+}
+
+// verifyChecksum checks data against the line for name in a checksums file
+// of sha256sum format.
+func verifyChecksum(checksums []byte, name string, data []byte) error {
+	sc := bufio.NewScanner(bytes.NewReader(checksums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+		return nil
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("no checksum for %s", name)
+}
+
+// extractBinary returns the file named bin at the top of the tar.gz or zip
+// archive.
+func extractBinary(name string, archive []byte, bin string) ([]byte, error) {
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.Name != bin {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(io.LimitReader(rc, releaseMaxSize))
+		}
+		return nil, fmt.Errorf("no %s in %s", bin, name)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no %s in %s", bin, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && hdr.Name == bin {
+			return io.ReadAll(io.LimitReader(tr, releaseMaxSize))
+		}
+	}
+}
+
+// replaceExecutable writes bin next to exe and renames it over exe, so that
+// exe is never left half-written. Windows does not allow replacing a running
+// executable, so the old one is moved aside first.
+func replaceExecutable(exe string, bin []byte) error {
+	exe, err := filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), exe)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tarGz(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, b := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, b := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_newerVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		tag     string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "newer patch",
+			current: "0.0.22",
+			tag:     "v0.0.23",
+			want:    true,
+		},
+		{
+			name:    "newer minor",
+			current: "0.0.22",
+			tag:     "v0.1.0",
+			want:    true,
+		},
+		{
+			name:    "numeric not lexical",
+			current: "0.0.9",
+			tag:     "v0.0.10",
+			want:    true,
+		},
+		{
+			name:    "same",
+			current: "0.0.22",
+			tag:     "v0.0.22",
+			want:    false,
+		},
+		{
+			name:    "older",
+			current: "0.1.0",
+			tag:     "v0.0.99",
+			want:    false,
+		},
+		{
+			name:    "shorter tag",
+			current: "1.0.1",
+			tag:     "v1.0",
+			want:    false,
+		},
+		{
+			name:    "invalid tag",
+			current: "0.0.22",
+			tag:     "nightly",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newerVersion(tt.current, tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newerVersion() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("newerVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_archiveName(t *testing.T) {
+	tests := []struct {
+		goos   string
+		goarch string
+		want   string
+	}{
+		{goos: "linux", goarch: "amd64", want: "tlc3_Linux_x86_64.tar.gz"},
+		{goos: "linux", goarch: "arm64", want: "tlc3_Linux_arm64.tar.gz"},
+		{goos: "darwin", goarch: "arm64", want: "tlc3_Darwin_arm64.tar.gz"},
+		{goos: "windows", goarch: "386", want: "tlc3_Windows_i386.zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := archiveName(tt.goos, tt.goarch); got != tt.want {
+				t.Errorf("archiveName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_verifyChecksum(t *testing.T) {
+	data := []byte("archive")
+	tests := []struct {
+		name      string
+		checksums string
+		wantErr   bool
+	}{
+		{
+			name:      "match",
+			checksums: fmt.Sprintf("%s  tlc3_Darwin_arm64.tar.gz\n%s  tlc3_Linux_x86_64.tar.gz\n", sha256Hex([]byte("other")), sha256Hex(data)),
+			wantErr:   false,
+		},
+		{
+			name:      "binary mode",
+			checksums: fmt.Sprintf("%s *tlc3_Linux_x86_64.tar.gz\n", strings.ToUpper(sha256Hex(data))),
+			wantErr:   false,
+		},
+		{
+			name:      "mismatch",
+			checksums: fmt.Sprintf("%s  tlc3_Linux_x86_64.tar.gz\n", sha256Hex([]byte("tampered"))),
+			wantErr:   true,
+		},
+		{
+			name:      "missing",
+			checksums: fmt.Sprintf("%s  tlc3_Darwin_arm64.tar.gz\n", sha256Hex(data)),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyChecksum([]byte(tt.checksums), "tlc3_Linux_x86_64.tar.gz", data); (err != nil) != tt.wantErr {
+				t.Errorf("verifyChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_extractBinary(t *testing.T) {
+	files := map[string][]byte{
+		"README.md": []byte("readme"),
+		"tlc3":      []byte("binary"),
+		"tlc3.exe":  []byte("binary.exe"),
+	}
+	tests := []struct {
+		name    string
+		archive string
+		data    []byte
+		bin     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "tar.gz",
+			archive: "tlc3_Linux_x86_64.tar.gz",
+			data:    tarGz(t, files),
+			bin:     "tlc3",
+			want:    "binary",
+		},
+		{
+			name:    "zip",
+			archive: "tlc3_Windows_x86_64.zip",
+			data:    zipArchive(t, files),
+			bin:     "tlc3.exe",
+			want:    "binary.exe",
+		},
+		{
+			name:    "no binary",
+			archive: "tlc3_Linux_x86_64.tar.gz",
+			data:    tarGz(t, map[string][]byte{"README.md": []byte("readme")}),
+			bin:     "tlc3",
+			wantErr: true,
+		},
+		{
+			name:    "not an archive",
+			archive: "tlc3_Linux_x86_64.tar.gz",
+			data:    []byte("<html>"),
+			bin:     "tlc3",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractBinary(tt.archive, tt.data, tt.bin)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("extractBinary() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("extractBinary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_updater_selfUpdate(t *testing.T) {
+	name := archiveName("linux", "amd64")
+	archive := tarGz(t, map[string][]byte{"tlc3": []byte("new binary")})
+	tests := []struct {
+		name      string
+		tag       string
+		checksum  string
+		checkOnly bool
+		want      string
+		wantBin   string
+		wantErr   bool
+	}{
+		{
+			name:     "update",
+			tag:      "v99.0.0",
+			checksum: sha256Hex(archive),
+			want:     "tlc3 is updated: " + Version + " -> 99.0.0\n",
+			wantBin:  "new binary",
+		},
+		{
+			name:      "check only",
+			tag:       "v99.0.0",
+			checksum:  sha256Hex(archive),
+			checkOnly: true,
+			want:      "tlc3 can be updated: " + Version + " -> 99.0.0\n",
+			wantBin:   "old binary",
+		},
+		{
+			name:     "up to date",
+			tag:      "v" + Version,
+			checksum: sha256Hex(archive),
+			want:     "tlc3 is up to date: " + Version + "\n",
+			wantBin:  "old binary",
+		},
+		{
+			name:     "checksum mismatch",
+			tag:      "v99.0.0",
+			checksum: sha256Hex([]byte("tampered")),
+			wantBin:  "old binary",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			server := httptest.NewServer(mux)
+			defer server.Close()
+			mux.HandleFunc("/latest", func(w http.ResponseWriter, _ *http.Request) {
+				_ = json.NewEncoder(w).Encode(release{
+					TagName: tt.tag,
+					Assets: []releaseAsset{
+						{Name: name, URL: server.URL + "/" + name},
+						{Name: releaseChecksums, URL: server.URL + "/" + releaseChecksums},
+					},
+				})
+			})
+			mux.HandleFunc("/"+name, func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(archive)
+			})
+			mux.HandleFunc("/"+releaseChecksums, func(w http.ResponseWriter, _ *http.Request) {
+				fmt.Fprintf(w, "%s  %s\n", tt.checksum, name)
+			})
+			exe := filepath.Join(t.TempDir(), "tlc3")
+			if err := os.WriteFile(exe, []byte("old binary"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			u := &updater{
+				client: &http.Client{Timeout: 5 * time.Second},
+				api:    server.URL + "/latest",
+				goos:   "linux",
+				goarch: "amd64",
+			}
+			w := &bytes.Buffer{}
+			err := u.selfUpdate(context.Background(), w, exe, tt.checkOnly)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("selfUpdate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if w.String() != tt.want {
+				t.Errorf("selfUpdate() output = %q, want %q", w.String(), tt.want)
+			}
+			b, err := os.ReadFile(exe)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.wantBin {
+				t.Errorf("binary = %q, want %q", b, tt.wantBin)
+			}
+			entries, err := os.ReadDir(filepath.Dir(exe))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("temporary files left: %d entries", len(entries))
+			}
+		})
+	}
+}
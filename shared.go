@@ -0,0 +1,641 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	defaultSharedTTL = 10 * time.Minute
+
+	sharedCertPrefix = canonicalName + ":cert:"
+	sharedDNSPrefix  = canonicalName + ":dns:"
+	sharedLockPrefix = canonicalName + ":lock:"
+
+	// how long a host stays claimed by an instance that stopped before
+	// releasing it
+	sharedLockTTL      = time.Minute
+	sharedPollInterval = 200 * time.Millisecond
+)
+
+var sharedSchemes = []string{"redis", "rediss", "memcached"}
+
+// sharedStore is a key-value store reachable by every instance of a fleet.
+type sharedStore interface {
+	get(ctx context.Context, key string) ([]byte, bool, error)
+	set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// add stores value only if key is absent, and reports whether it did.
+	add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	del(ctx context.Context, key string) error
+	close() error
+}
+
+// sharedCache lets instances scanning overlapping inventories reuse the
+// results and DNS answers of each other. An instance claims a host before
+// checking it, so that the others wait for its result instead of repeating
+// the handshake. The cache is an optimization only: when the store cannot be
+// reached, hosts are checked as if there were no cache.
+type sharedCache struct {
+	store sharedStore
+	ttl   time.Duration
+	owner string
+	// scope are the values of the flags that change the result of a host
+	// but are not kept in the options, such as the files roots are read from,
+	// so that instances checking with others keep their results apart
+	scope []any
+}
+
+// newSharedCache connects to the store at rawURL, one of
+// redis://[user:password@]host[:port][/db], rediss://... for TLS, and
+// memcached://host[:port]. An empty rawURL disables the cache.
+func newSharedCache(rawURL string, ttl, timeout time.Duration) (*sharedCache, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	if ttl <= 0 {
+		return nil, errors.New("invalid cache ttl: must be greater than 0")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid cache %q: want scheme://host[:port]", rawURL)
+	}
+	var store sharedStore
+	switch u.Scheme {
+	case "redis", "rediss":
+		store, err = newRedisStore(u, timeout)
+	case "memcached":
+		store = newMemcachedStore(u, timeout)
+	default:
+		return nil, fmt.Errorf("invalid cache %q: allowed schemes: %s", rawURL, pipeJoin(sharedSchemes))
+	}
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	return &sharedCache{
+		store: store,
+		ttl:   ttl,
+		owner: fmt.Sprintf("%s/%d", hostname, os.Getpid()),
+	}, nil
+}
+
+// sharedEntry is a result as stored in the cache. The chain is kept in DER
+// since it is not part of the JSON of a result.
+type sharedEntry struct {
+	Info  *certInfo
+	Chain [][]byte
+}
+
+// check returns the result of addr found in the cache, or else the result of
+// fn, which is shared with the other instances. Failures are not shared, so
+// that each instance reports them on its own.
+func (c *sharedCache) check(ctx context.Context, addr string, opts *options, fn func() (*certInfo, error)) (*certInfo, error) {
+	if c == nil {
+		return fn()
+	}
+	addr = ensureDefaultPort(addr)
+	scoped := c.scoped(addr, opts)
+	key := sharedCertPrefix + scoped
+	for {
+		if info, ok := c.load(ctx, key, opts); ok {
+			info.insecure = opts.insecure || opts.targets.lookup(addr).insecure
+			log.Debug("result from shared cache", "host", addr)
+			return info, nil
+		}
+		claimed, err := c.store.add(ctx, sharedLockPrefix+scoped, []byte(c.owner), sharedLockTTL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Warn("shared cache unavailable", "host", addr, "err", err)
+			return fn()
+		}
+		if claimed {
+			break
+		}
+		// another instance is checking the host, and its result will be
+		// there once it releases the claim
+		if err := sleep(ctx, sharedPollInterval); err != nil {
+			return nil, err
+		}
+	}
+	defer func() {
+		if err := c.store.del(context.WithoutCancel(ctx), sharedLockPrefix+scoped); err != nil {
+			log.Warn("cannot release host in shared cache", "host", addr, "err", err)
+		}
+	}()
+	info, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	entry := sharedEntry{Info: info}
+	for _, cert := range info.chain {
+		entry.Chain = append(entry.Chain, cert.Raw)
+	}
+	if b, err := json.Marshal(entry); err == nil {
+		if err := c.store.set(ctx, key, b, c.ttl); err != nil {
+			log.Warn("cannot store result in shared cache", "host", addr, "err", err)
+		}
+	}
+	return info, nil
+}
+
+// scoped returns addr prefixed with a digest of the scope of the cache, of
+// the options of the run as finally adjusted and of those of addr in a
+// structured list, for the results of a host checked with other options not
+// to be taken for each other.
+func (c *sharedCache) scoped(addr string, opts *options) string {
+	t := opts.targets.lookup(addr)
+	cnIdentity := opts.sweep.covers(addr)
+	stores := make([]string, len(opts.trustStores))
+	for i, s := range opts.trustStores {
+		stores[i] = s.name
+	}
+	var hello []any
+	if opts.hello != nil {
+		hello = []any{opts.hello.cipherSuites, opts.hello.curves, opts.hello.maxVersion}
+	}
+	b, err := json.Marshal([]any{
+		c.scope,
+		opts.serverName, opts.starttls, opts.partial, opts.probe, opts.smime, opts.grpc,
+		opts.grpcHealth, opts.grpcSvc, opts.checkSANs, opts.caa != nil, opts.aia != nil,
+		stores, hello, opts.expected,
+		t.serverName, t.starttls, t.tags, t.issuer, t.expected, cnIdentity,
+	})
+	if err != nil {
+		return addr
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]) + ":" + addr
+}
+
+// load reads a result and brings its time fields up to date, since it may
+// have been stored by another instance up to the ttl ago.
+func (c *sharedCache) load(ctx context.Context, key string, opts *options) (*certInfo, bool) {
+	b, ok, err := c.store.get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	var entry sharedEntry
+	if err := json.Unmarshal(b, &entry); err != nil || entry.Info == nil {
+		return nil, false
+	}
+	info := entry.Info
+	for _, der := range entry.Chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, false
+		}
+		info.chain = append(info.chain, cert)
+	}
+	now := time.Now()
+	loc := opts.location
+	info.NotBefore = info.NotBefore.In(loc)
+	info.NotAfter = info.NotAfter.In(loc)
+	info.CurrentTime = now.In(loc).Truncate(time.Second)
+	info.DaysLeft = daysLeft(info.NotAfter, now)
+	if info.OCSPNextUpdate != nil {
+		t := info.OCSPNextUpdate.In(loc)
+		info.OCSPNextUpdate = &t
+	}
+	if len(info.chain) == 0 {
+		return info, true
+	}
+	info.ChainDaysLeft = chainDaysLeft(info.chain, now)
+	info.ExpiredInChain = expiredInChain(info.chain, now)
+	info.Distrust = distrustStatus(info.chain, distrustEvents, now)
+	// a chain that verified when stored fails once a cert of it expires
+	if info.VerificationStatus == verificationVerified {
+		if err := expiredError(info.chain, now); err != nil {
+			info.VerificationStatus = verificationFailed
+			info.VerifyError = err.Error()
+			info.TrustStatus = trustStatus(info.chain[0], err)
+		}
+	}
+	return info, true
+}
+
+// expiredError returns the error x509 verification fails with for the first
+// cert of chain that has expired as of now, if any.
+func expiredError(chain []*x509.Certificate, now time.Time) error {
+	for _, cert := range chain {
+		if now.After(cert.NotAfter) {
+			return x509.CertificateInvalidError{
+				Cert:   cert,
+				Reason: x509.Expired,
+				Detail: fmt.Sprintf("current time %s is after %s", now.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339)),
+			}
+		}
+	}
+	return nil
+}
+
+func (c *sharedCache) loadIPs(ctx context.Context, host string) ([]net.IP, bool) {
+	if c == nil {
+		return nil, false
+	}
+	b, ok, err := c.store.get(ctx, sharedDNSPrefix+host)
+	if err != nil || !ok {
+		return nil, false
+	}
+	var ips []net.IP
+	if err := json.Unmarshal(b, &ips); err != nil {
+		return nil, false
+	}
+	return ips, true
+}
+
+func (c *sharedCache) storeIPs(ctx context.Context, host string, ips []net.IP, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	b, err := json.Marshal(ips)
+	if err != nil {
+		return
+	}
+	if err := c.store.set(ctx, sharedDNSPrefix+host, b, ttl); err != nil {
+		log.Warn("cannot store answer in shared cache", "host", host, "err", err)
+	}
+}
+
+func (c *sharedCache) close() error {
+	if c == nil {
+		return nil
+	}
+	return c.store.close()
+}
+
+// sharedConn is a single connection to a store, dialed on first use and
+// again after a failure. Commands are serialized on it.
+type sharedConn struct {
+	mu      sync.Mutex
+	addr    string
+	tls     *tls.Config
+	timeout time.Duration
+	conn    net.Conn
+	r       *bufio.Reader
+	// hello runs right after dialing, e.g. to authenticate
+	hello func() error
+}
+
+func (s *sharedConn) do(ctx context.Context, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return err
+		}
+	}
+	if s.timeout > 0 {
+		_ = s.conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+	release := bindContext(ctx, s.conn)
+	err := fn()
+	release()
+	var reply replyError
+	if err != nil && !errors.As(err, &reply) {
+		// the connection is out of sync after a broken exchange
+		s.conn.Close()
+		s.conn = nil
+	}
+	return contextErr(ctx, err)
+}
+
+func (s *sharedConn) dial(ctx context.Context) error {
+	d := net.Dialer{Timeout: s.timeout}
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if s.tls != nil {
+		conn = tls.Client(conn, s.tls)
+	}
+	s.conn = conn
+	s.r = bufio.NewReader(conn)
+	if s.hello != nil {
+		if s.timeout > 0 {
+			_ = conn.SetDeadline(time.Now().Add(s.timeout))
+		}
+		release := bindContext(ctx, conn)
+		err := s.hello()
+		release()
+		if err != nil {
+			conn.Close()
+			s.conn = nil
+			return contextErr(ctx, err)
+		}
+	}
+	return nil
+}
+
+func (s *sharedConn) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *sharedConn) readLine() (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// replyError is an error reported by the store, after which the connection
+// is still usable.
+type replyError string
+
+func (e replyError) Error() string {
+	return string(e)
+}
+
+// redisStore speaks the RESP protocol of Redis and compatible servers such
+// as Valkey and KeyDB.
+type redisStore struct {
+	sharedConn
+}
+
+func newRedisStore(u *url.URL, timeout time.Duration) (*redisStore, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	s := &redisStore{sharedConn{addr: host, timeout: timeout}}
+	if u.Scheme == "rediss" {
+		s.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	}
+	var auth []string
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			if name := u.User.Username(); name != "" {
+				auth = []string{"AUTH", name, password}
+			} else {
+				auth = []string{"AUTH", password}
+			}
+		} else {
+			auth = []string{"AUTH", u.User.Username()}
+		}
+	}
+	var db string
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if n, err := strconv.Atoi(path); err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid cache %q: database must be a number", u.Redacted())
+		}
+		db = path
+	}
+	s.hello = func() error {
+		if auth != nil {
+			if _, err := s.command(auth...); err != nil {
+				return err
+			}
+		}
+		if db != "" {
+			if _, err := s.command("SELECT", db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return s, nil
+}
+
+func (s *redisStore) get(ctx context.Context, key string) ([]byte, bool, error) {
+	var v any
+	err := s.do(ctx, func() (err error) {
+		v, err = s.command("GET", key)
+		return err
+	})
+	if err != nil || v == nil {
+		return nil, false, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected reply to GET: %v", v)
+	}
+	return b, true, nil
+}
+
+func (s *redisStore) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.do(ctx, func() error {
+		_, err := s.command("SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+		return err
+	})
+}
+
+func (s *redisStore) add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	var v any
+	err := s.do(ctx, func() (err error) {
+		v, err = s.command("SET", key, string(value), "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+		return err
+	})
+	return err == nil && v != nil, err
+}
+
+func (s *redisStore) del(ctx context.Context, key string) error {
+	return s.do(ctx, func() error {
+		_, err := s.command("DEL", key)
+		return err
+	})
+}
+
+// command sends args as an array of bulk strings and reads the reply.
+func (s *redisStore) command(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(s.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return s.reply()
+}
+
+// reply reads a reply, which is a string, an int64, a []byte, an []any, or
+// nil for a null bulk string or array.
+func (s *redisStore) reply() (any, error) {
+	line, err := s.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, replyError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(s.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		v := make([]any, n)
+		for i := range v {
+			if v[i], err = s.reply(); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("invalid reply: %q", line)
+	}
+}
+
+// memcachedStore speaks the text protocol of memcached.
+type memcachedStore struct {
+	sharedConn
+}
+
+func newMemcachedStore(u *url.URL, timeout time.Duration) *memcachedStore {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "11211")
+	}
+	return &memcachedStore{sharedConn{addr: host, timeout: timeout}}
+}
+
+// memcachedKey fits key into the 250 bytes allowed by memcached.
+func memcachedKey(key string) string {
+	if len(key) <= 250 {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return canonicalName + ":" + hex.EncodeToString(sum[:])
+}
+
+func (s *memcachedStore) get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	var found bool
+	err := s.do(ctx, func() error {
+		if _, err := fmt.Fprintf(s.conn, "get %s\r\n", memcachedKey(key)); err != nil {
+			return err
+		}
+		for {
+			line, err := s.readLine()
+			if err != nil {
+				return err
+			}
+			if line == "END" {
+				return nil
+			}
+			// VALUE <key> <flags> <bytes>
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[0] != "VALUE" {
+				return memcachedError(line)
+			}
+			n, err := strconv.Atoi(fields[3])
+			if err != nil {
+				return fmt.Errorf("invalid reply: %q", line)
+			}
+			b := make([]byte, n+2)
+			if _, err := io.ReadFull(s.r, b); err != nil {
+				return err
+			}
+			value, found = b[:n], true
+		}
+	})
+	return value, found, err
+}
+
+func (s *memcachedStore) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.store(ctx, "set", key, value, ttl)
+	return err
+}
+
+func (s *memcachedStore) add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.store(ctx, "add", key, value, ttl)
+}
+
+func (s *memcachedStore) store(ctx context.Context, verb, key string, value []byte, ttl time.Duration) (bool, error) {
+	// expiration is in whole seconds, where zero would mean never
+	exptime := max(int64((ttl+time.Second-1)/time.Second), 1)
+	var stored bool
+	err := s.do(ctx, func() error {
+		if _, err := fmt.Fprintf(s.conn, "%s %s 0 %d %d\r\n%s\r\n", verb, memcachedKey(key), exptime, len(value), value); err != nil {
+			return err
+		}
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "STORED":
+			stored = true
+		case "NOT_STORED":
+		default:
+			return memcachedError(line)
+		}
+		return nil
+	})
+	return stored, err
+}
+
+func (s *memcachedStore) del(ctx context.Context, key string) error {
+	return s.do(ctx, func() error {
+		if _, err := fmt.Fprintf(s.conn, "delete %s\r\n", memcachedKey(key)); err != nil {
+			return err
+		}
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		if line != "DELETED" && line != "NOT_FOUND" {
+			return memcachedError(line)
+		}
+		return nil
+	})
+}
+
+// memcachedError turns an unexpected line into an error. Only the errors
+// reported by the server leave the connection usable.
+func memcachedError(line string) error {
+	for _, prefix := range []string{"ERROR", "CLIENT_ERROR", "SERVER_ERROR"} {
+		if strings.HasPrefix(line, prefix) {
+			return replyError(line)
+		}
+	}
+	return fmt.Errorf("invalid reply: %q", line)
+}
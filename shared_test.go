@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// memStore is a sharedStore in memory, failing every call when down is set.
+type memStore struct {
+	mu   sync.Mutex
+	m    map[string][]byte
+	down bool
+}
+
+func (s *memStore) get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, false, errors.New("down")
+	}
+	b, ok := s.m[key]
+	return b, ok, nil
+}
+
+func (s *memStore) set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("down")
+	}
+	s.m[key] = value
+	return nil
+}
+
+func (s *memStore) add(_ context.Context, key string, value []byte, _ time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return false, errors.New("down")
+	}
+	if _, ok := s.m[key]; ok {
+		return false, nil
+	}
+	s.m[key] = value
+	return true, nil
+}
+
+func (s *memStore) del(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("down")
+	}
+	delete(s.m, key)
+	return nil
+}
+
+func (s *memStore) close() error {
+	return nil
+}
+
+func Test_sharedCache_check(t *testing.T) {
+	store := &memStore{m: make(map[string][]byte)}
+	a := &sharedCache{store: store, ttl: time.Minute, owner: "a"}
+	b := &sharedCache{store: store, ttl: time.Minute, owner: "b"}
+	ctx := context.Background()
+	opts := &options{location: time.UTC}
+	cert := newDiffCert(t, "example.com", []string{"example.com"}, time.Now().Add(48*time.Hour))
+	var calls atomic.Int32
+	fn := func() (*certInfo, error) {
+		calls.Add(1)
+		return &certInfo{DomainName: "example.com", AccessPort: "443", NotAfter: cert.NotAfter, DaysLeft: 99, chain: []*x509.Certificate{cert}}, nil
+	}
+
+	info, err := a.check(ctx, "example.com", opts, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.DaysLeft != 99 {
+		t.Errorf("check() DaysLeft = %d, want 99", info.DaysLeft)
+	}
+	info, err = b.check(ctx, "example.com:443", opts, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("checked %d times, want 1", n)
+	}
+	// time fields are recomputed on load
+	if info.DaysLeft != 1 {
+		t.Errorf("check() DaysLeft = %d, want 1", info.DaysLeft)
+	}
+	if len(info.chain) != 1 || !info.chain[0].Equal(cert) {
+		t.Error("check() chain not restored")
+	}
+	if _, ok := store.m[sharedLockPrefix+a.scoped("example.com:443", opts)]; ok {
+		t.Error("claim not released")
+	}
+
+	// failures are not shared
+	_, err = a.check(ctx, "fail.example", opts, func() (*certInfo, error) {
+		return nil, errors.New("refused")
+	})
+	if err == nil {
+		t.Fatal("check() error = nil")
+	}
+	if _, ok := store.m[sharedCertPrefix+a.scoped("fail.example:443", opts)]; ok {
+		t.Error("failure stored in cache")
+	}
+
+	// an unavailable store is skipped
+	store.down = true
+	calls.Store(0)
+	if _, err := a.check(ctx, "example.com", opts, fn); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("checked %d times, want 1", n)
+	}
+}
+
+func Test_sharedCache_check_claimed(t *testing.T) {
+	opts := &options{location: time.UTC}
+	a := &sharedCache{ttl: time.Minute, owner: "a"}
+	store := &memStore{m: map[string][]byte{sharedLockPrefix + a.scoped("example.com:443", opts): []byte("b")}}
+	a.store = store
+	go func() {
+		// the other instance stores its result and releases the host
+		time.Sleep(2 * sharedPollInterval)
+		store.set(context.Background(), sharedCertPrefix+a.scoped("example.com:443", opts), []byte(`{"Info":{"DomainName":"example.com","AccessPort":"443"}}`), time.Minute)
+		store.del(context.Background(), sharedLockPrefix+a.scoped("example.com:443", opts))
+	}()
+	info, err := a.check(context.Background(), "example.com", opts, func() (*certInfo, error) {
+		t.Error("host checked while claimed")
+		return &certInfo{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.DomainName != "example.com" {
+		t.Errorf("check() DomainName = %q", info.DomainName)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedPollInterval/2)
+	defer cancel()
+	store.m[sharedLockPrefix+a.scoped("other.example:443", opts)] = []byte("b")
+	if _, err := a.check(ctx, "other.example", opts, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("check() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func Test_sharedCache_check_scope(t *testing.T) {
+	store := &memStore{m: make(map[string][]byte)}
+	ctx := context.Background()
+	cert := newDiffCert(t, "example.com", []string{"example.com"}, time.Now().Add(48*time.Hour))
+	var calls atomic.Int32
+	fn := func() (*certInfo, error) {
+		calls.Add(1)
+		return &certInfo{DomainName: "example.com", AccessPort: "443", chain: []*x509.Certificate{cert}}, nil
+	}
+	// the versions are probed for a policy asserting them, as in app
+	probed := &options{location: time.UTC, policy: policy{{pattern: "*", minTLSVersion: tls.VersionTLS12}}}
+	probed.probe = probed.policy.probes()
+	tests := []struct {
+		name      string
+		scope     []any
+		opts      *options
+		wantCalls int32
+	}{
+		{
+			name:      "first",
+			scope:     []any{"", false},
+			opts:      &options{location: time.UTC},
+			wantCalls: 1,
+		},
+		{
+			name:      "same options",
+			scope:     []any{"", false},
+			opts:      &options{location: time.UTC},
+			wantCalls: 0,
+		},
+		{
+			name:      "insecure",
+			scope:     []any{"", false},
+			opts:      &options{location: time.UTC, insecure: true},
+			wantCalls: 0,
+		},
+		{
+			name:      "other flags",
+			scope:     []any{"www.example.com", true},
+			opts:      &options{location: time.UTC},
+			wantCalls: 1,
+		},
+		{
+			name:      "probe forced by a policy",
+			scope:     []any{"", false},
+			opts:      probed,
+			wantCalls: 1,
+		},
+		{
+			name:      "other target options",
+			scope:     []any{"", false},
+			opts:      &options{location: time.UTC, targets: targets{"example.com:443": {starttls: "smtp"}}},
+			wantCalls: 1,
+		},
+		{
+			name:      "expanded from a range",
+			scope:     []any{"", false},
+			opts:      &options{location: time.UTC, sweep: &sweep{hosts: map[string]bool{"example.com:443": true}}},
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			c := &sharedCache{store: store, ttl: time.Minute, owner: "a", scope: tt.scope}
+			info, err := c.check(ctx, "example.com", tt.opts, fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("checked %d times, want %d", n, tt.wantCalls)
+			}
+			if info.insecure != tt.opts.insecure {
+				t.Errorf("check() insecure = %v, want %v", info.insecure, tt.opts.insecure)
+			}
+		})
+	}
+}
+
+func Test_sharedCache_load_expired(t *testing.T) {
+	store := &memStore{m: make(map[string][]byte)}
+	c := &sharedCache{store: store, ttl: time.Minute, owner: "a"}
+	opts := &options{location: time.UTC}
+	cert := newDiffCert(t, "example.com", []string{"example.com"}, time.Now().Add(-72*time.Hour))
+	stored := 2
+	// the result was stored by another instance while the cert was valid
+	b, err := json.Marshal(sharedEntry{
+		Info: &certInfo{
+			DomainName:         "example.com",
+			AccessPort:         "443",
+			NotAfter:           cert.NotAfter,
+			DaysLeft:           stored,
+			ChainDaysLeft:      &stored,
+			VerificationStatus: verificationVerified,
+			TrustStatus:        trustTrusted,
+		},
+		Chain: [][]byte{cert.Raw},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := sharedCertPrefix + c.scoped("example.com:443", opts)
+	store.m[key] = b
+	info, ok := c.load(context.Background(), key, opts)
+	if !ok {
+		t.Fatal("load() ok = false")
+	}
+	want := daysLeft(cert.NotAfter, time.Now())
+	if info.DaysLeft != want {
+		t.Errorf("load() DaysLeft = %d, want %d", info.DaysLeft, want)
+	}
+	if info.ChainDaysLeft == nil || *info.ChainDaysLeft != want {
+		t.Errorf("load() ChainDaysLeft = %v, want %d", info.ChainDaysLeft, want)
+	}
+	if info.VerificationStatus != verificationFailed || !strings.Contains(info.VerifyError, "expired") {
+		t.Errorf("load() VerificationStatus = %q, VerifyError = %q", info.VerificationStatus, info.VerifyError)
+	}
+	if info.TrustStatus != trustExpiredChain {
+		t.Errorf("load() TrustStatus = %q, want %q", info.TrustStatus, trustExpiredChain)
+	}
+}
+
+func Test_cachingResolver_shared(t *testing.T) {
+	ipCache.delete("example.test")
+	defer ipCache.delete("example.test")
+	store := &memStore{m: make(map[string][]byte)}
+	shared := &sharedCache{store: store, ttl: time.Minute}
+	base := &countingResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}, ttl: 30 * time.Second}
+	r := newCachingResolver(base, time.Minute)
+	r.shared = shared
+	if _, _, err := r.lookupIP(context.Background(), "example.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.m[sharedDNSPrefix+"example.test"]; !ok {
+		t.Fatal("answer not stored in shared cache")
+	}
+
+	// another instance finds the answer without resolving
+	ipCache.delete("example.test")
+	other := &countingResolver{}
+	r = newCachingResolver(other, time.Minute)
+	r.shared = shared
+	ips, _, err := r.lookupIP(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.calls != 0 {
+		t.Errorf("calls = %d, want 0", other.calls)
+	}
+	if diff := cmp.Diff(ips, base.ips); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_newSharedCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		ttl     time.Duration
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "empty", url: "", ttl: time.Minute, wantNil: true},
+		{name: "redis", url: "redis://:secret@localhost:6379/2", ttl: time.Minute},
+		{name: "rediss", url: "rediss://localhost", ttl: time.Minute},
+		{name: "memcached", url: "memcached://localhost", ttl: time.Minute},
+		{name: "unknown scheme", url: "mongodb://localhost", ttl: time.Minute, wantErr: true},
+		{name: "no host", url: "redis://", ttl: time.Minute, wantErr: true},
+		{name: "bad database", url: "redis://localhost/x", ttl: time.Minute, wantErr: true},
+		{name: "zero ttl", url: "redis://localhost", ttl: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newSharedCache(tt.url, tt.ttl, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSharedCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (c == nil) != tt.wantNil {
+				t.Errorf("newSharedCache() = %v", c)
+			}
+		})
+	}
+}
+
+// startFakeServer serves every connection accepted with handler until the
+// test ends. It returns the listening address.
+func startFakeServer(t *testing.T, handler func(net.Conn) error) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = handler(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// startFakeRedis serves GET, SET with NX and PX, DEL, AUTH and SELECT from a
+// map, and records the commands received.
+func startFakeRedis(t *testing.T, password string) (string, func() []string) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		m        = make(map[string]string)
+		commands []string
+	)
+	addr := startFakeServer(t, func(conn net.Conn) error {
+		r := bufio.NewReader(conn)
+		authed := password == ""
+		for {
+			args, err := readRESP(r)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			mu.Lock()
+			commands = append(commands, args[0])
+			var reply string
+			switch {
+			case args[0] == "AUTH":
+				authed = args[len(args)-1] == password
+				reply = "+OK\r\n"
+				if !authed {
+					reply = "-WRONGPASS invalid password\r\n"
+				}
+			case !authed:
+				reply = "-NOAUTH Authentication required\r\n"
+			case args[0] == "SELECT":
+				reply = "+OK\r\n"
+			case args[0] == "GET":
+				if v, ok := m[args[1]]; ok {
+					reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+				} else {
+					reply = "$-1\r\n"
+				}
+			case args[0] == "SET":
+				if _, ok := m[args[1]]; ok && args[3] == "NX" {
+					reply = "$-1\r\n"
+				} else {
+					m[args[1]] = args[2]
+					reply = "+OK\r\n"
+				}
+			case args[0] == "DEL":
+				delete(m, args[1])
+				reply = ":1\r\n"
+			default:
+				reply = "-ERR unknown command\r\n"
+			}
+			mu.Unlock()
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return err
+			}
+		}
+	})
+	return addr, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(commands)
+	}
+}
+
+func readRESP(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func Test_redisStore(t *testing.T) {
+	addr, commands := startFakeRedis(t, "secret")
+	ctx := context.Background()
+
+	u, _ := url.Parse("redis://:wrong@" + addr)
+	s, err := newRedisStore(u, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply replyError
+	if _, _, err := s.get(ctx, "k"); !errors.As(err, &reply) {
+		t.Errorf("get() error = %v, want a reply error", err)
+	}
+	s.close()
+
+	u, _ = url.Parse("redis://:secret@" + addr + "/1")
+	s, err = newRedisStore(u, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	if _, ok, err := s.get(ctx, "k"); err != nil || ok {
+		t.Errorf("get() = %v, %v, want not found", ok, err)
+	}
+	if err := s.set(ctx, "k", []byte("v\r\nv"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	b, ok, err := s.get(ctx, "k")
+	if err != nil || !ok || string(b) != "v\r\nv" {
+		t.Errorf("get() = %q, %v, %v", b, ok, err)
+	}
+	if added, err := s.add(ctx, "k", []byte("w"), time.Minute); err != nil || added {
+		t.Errorf("add() = %v, %v, want not added", added, err)
+	}
+	if err := s.del(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if added, err := s.add(ctx, "k", []byte("w"), time.Minute); err != nil || !added {
+		t.Errorf("add() = %v, %v, want added", added, err)
+	}
+	want := []string{"AUTH", "AUTH", "SELECT", "GET", "SET", "GET", "SET", "DEL", "SET"}
+	if diff := cmp.Diff(commands(), want); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func startFakeMemcached(t *testing.T) string {
+	t.Helper()
+	var (
+		mu sync.Mutex
+		m  = make(map[string]string)
+	)
+	return startFakeServer(t, func(conn net.Conn) error {
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			fields := strings.Fields(line)
+			mu.Lock()
+			var reply string
+			switch fields[0] {
+			case "get":
+				if v, ok := m[fields[1]]; ok {
+					reply = fmt.Sprintf("VALUE %s 0 %d\r\n%s\r\n", fields[1], len(v), v)
+				}
+				reply += "END\r\n"
+			case "set", "add":
+				size, _ := strconv.Atoi(fields[4])
+				b := make([]byte, size+2)
+				if _, err := io.ReadFull(r, b); err != nil {
+					mu.Unlock()
+					return err
+				}
+				if _, ok := m[fields[1]]; ok && fields[0] == "add" {
+					reply = "NOT_STORED\r\n"
+				} else {
+					m[fields[1]] = string(b[:size])
+					reply = "STORED\r\n"
+				}
+			case "delete":
+				reply = "NOT_FOUND\r\n"
+				if _, ok := m[fields[1]]; ok {
+					delete(m, fields[1])
+					reply = "DELETED\r\n"
+				}
+			default:
+				reply = "ERROR\r\n"
+			}
+			mu.Unlock()
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func Test_memcachedStore(t *testing.T) {
+	addr := startFakeMemcached(t)
+	ctx := context.Background()
+	u, _ := url.Parse("memcached://" + addr)
+	s := newMemcachedStore(u, time.Second)
+	defer s.close()
+
+	key := strings.Repeat("k", 300)
+	if _, ok, err := s.get(ctx, key); err != nil || ok {
+		t.Errorf("get() = %v, %v, want not found", ok, err)
+	}
+	if err := s.set(ctx, key, []byte("v\r\nv"), 500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	b, ok, err := s.get(ctx, key)
+	if err != nil || !ok || string(b) != "v\r\nv" {
+		t.Errorf("get() = %q, %v, %v", b, ok, err)
+	}
+	if added, err := s.add(ctx, key, []byte("w"), time.Minute); err != nil || added {
+		t.Errorf("add() = %v, %v, want not added", added, err)
+	}
+	if err := s.del(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.del(ctx, key); err != nil {
+		t.Errorf("del() of a missing key error = %v", err)
+	}
+	if added, err := s.add(ctx, key, []byte("w"), time.Minute); err != nil || !added {
+		t.Errorf("add() = %v, %v, want added", added, err)
+	}
+}
+
+func Test_memcachedKey(t *testing.T) {
+	if got := memcachedKey("short"); got != "short" {
+		t.Errorf("memcachedKey() = %q", got)
+	}
+	long := strings.Repeat("k", 251)
+	if got := memcachedKey(long); len(got) > 250 || got != memcachedKey(long) {
+		t.Errorf("memcachedKey() = %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/x509"
+	"slices"
+)
+
+const smimeValid = "valid"
+
+// smimeStatus reports whether the leaf of chain is also usable for S/MIME:
+// it must allow email protection, digital signatures and name an email
+// address, and its chain must verify for email protection against roots,
+// or the system roots if nil.
+func smimeStatus(chain []*x509.Certificate, roots *x509.CertPool) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	cert := chain[0]
+	if len(cert.ExtKeyUsage) > 0 &&
+		!slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageEmailProtection) &&
+		!slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageAny) {
+		return "invalid: no emailProtection extended key usage"
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return "invalid: key usage excludes digitalSignature"
+	}
+	if len(cert.EmailAddresses) == 0 {
+		return "invalid: no email address in SANs"
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return "invalid: " + err.Error()
+	}
+	return smimeValid
+}
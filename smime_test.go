@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_smimeStatus(t *testing.T) {
+	now := time.Now()
+	newCert := func(tmpl *x509.Certificate, parent *farmIssuer) *farmIssuer {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.NotBefore = now.Add(-time.Hour)
+		tmpl.NotAfter = now.Add(time.Hour)
+		fi, err := newFarmCert(tmpl, parent, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi
+	}
+	ca := newCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "smime test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	leaf := func(eku []x509.ExtKeyUsage, ku x509.KeyUsage, emails []string) []*x509.Certificate {
+		fi := newCert(&x509.Certificate{
+			Subject:        pkix.Name{CommonName: "mail.example.com"},
+			DNSNames:       []string{"mail.example.com"},
+			EmailAddresses: emails,
+			ExtKeyUsage:    eku,
+			KeyUsage:       ku,
+		}, ca)
+		return []*x509.Certificate{fi.cert, ca.cert}
+	}
+	both := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageEmailProtection}
+	emails := []string{"postmaster@example.com"}
+	tests := []struct {
+		name  string
+		chain []*x509.Certificate
+		roots *x509.CertPool
+		want  string
+	}{
+		{
+			name:  "empty",
+			chain: nil,
+			roots: roots,
+			want:  "",
+		},
+		{
+			name:  "valid",
+			chain: leaf(both, x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment, emails),
+			roots: roots,
+			want:  smimeValid,
+		},
+		{
+			name:  "server auth only",
+			chain: leaf([]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, x509.KeyUsageDigitalSignature, emails),
+			roots: roots,
+			want:  "invalid: no emailProtection",
+		},
+		{
+			name:  "no digital signature",
+			chain: leaf(both, x509.KeyUsageKeyEncipherment, emails),
+			roots: roots,
+			want:  "invalid: key usage excludes digitalSignature",
+		},
+		{
+			name:  "no email address",
+			chain: leaf(both, x509.KeyUsageDigitalSignature, nil),
+			roots: roots,
+			want:  "invalid: no email address",
+		},
+		{
+			name:  "untrusted",
+			chain: leaf(both, x509.KeyUsageDigitalSignature, emails),
+			roots: x509.NewCertPool(),
+			want:  "invalid: x509:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := smimeStatus(tt.chain, tt.roots)
+			if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("smimeStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
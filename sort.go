@@ -0,0 +1,46 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+const (
+	sortDomain   = "domain"
+	sortDaysLeft = "daysleft"
+	sortNotAfter = "notafter"
+	sortIssuer   = "issuer"
+)
+
+var sortKeys = []string{sortDomain, sortDaysLeft, sortNotAfter, sortIssuer}
+
+// certOrder returns a comparison of certs by key that falls back to domain
+// name and port, so that the order is the same across runs. reverse turns
+// the whole order around, e.g. the most days left first.
+func certOrder(key string, reverse bool) (func(a, b *certInfo) int, error) {
+	var compare func(a, b *certInfo) int
+	switch key {
+	case sortDomain:
+		compare = func(_, _ *certInfo) int { return 0 }
+	case sortDaysLeft:
+		compare = func(a, b *certInfo) int { return cmp.Compare(a.DaysLeft, b.DaysLeft) }
+	case sortNotAfter:
+		compare = func(a, b *certInfo) int { return a.NotAfter.Compare(b.NotAfter) }
+	case sortIssuer:
+		compare = func(a, b *certInfo) int { return strings.Compare(a.Issuer, b.Issuer) }
+	default:
+		return nil, fmt.Errorf("invalid sort key: allowed values: %s", pipeJoin(sortKeys))
+	}
+	return func(a, b *certInfo) int {
+		c := cmp.Or(
+			compare(a, b),
+			strings.Compare(a.DomainName, b.DomainName),
+			strings.Compare(a.AccessPort, b.AccessPort),
+		)
+		if reverse {
+			return -c
+		}
+		return c
+	}, nil
+}
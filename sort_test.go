@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_certOrder(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := &certInfo{DomainName: "a.example.com", AccessPort: "443", Issuer: "CN=Z", NotAfter: base.AddDate(0, 0, 30), DaysLeft: 30}
+	b := &certInfo{DomainName: "b.example.com", AccessPort: "443", Issuer: "CN=Y", NotAfter: base.AddDate(0, 0, 10), DaysLeft: 10}
+	c := &certInfo{DomainName: "c.example.com", AccessPort: "443", Issuer: "CN=Y", NotAfter: base.AddDate(0, 0, 20), DaysLeft: 20}
+	d := &certInfo{DomainName: "a.example.com", AccessPort: "8443", Issuer: "CN=X", NotAfter: base.AddDate(0, 0, 10), DaysLeft: 10}
+	type args struct {
+		key     string
+		reverse bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []*certInfo
+		wantErr bool
+	}{
+		{
+			name: "domain",
+			args: args{key: "domain"},
+			want: []*certInfo{a, d, b, c},
+		},
+		{
+			name: "domain reverse",
+			args: args{key: "domain", reverse: true},
+			want: []*certInfo{c, b, d, a},
+		},
+		{
+			name: "daysleft",
+			args: args{key: "daysleft"},
+			want: []*certInfo{d, b, c, a},
+		},
+		{
+			name: "daysleft reverse",
+			args: args{key: "daysleft", reverse: true},
+			want: []*certInfo{a, c, b, d},
+		},
+		{
+			name: "notafter",
+			args: args{key: "notafter"},
+			want: []*certInfo{d, b, c, a},
+		},
+		{
+			name: "issuer",
+			args: args{key: "issuer"},
+			want: []*certInfo{d, b, c, a},
+		},
+		{
+			name:    "invalid",
+			args:    args{key: "expiry"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := certOrder(tt.args.key, tt.args.reverse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("certOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := []*certInfo{c, a, b, d}
+			slices.SortFunc(got, order)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("certOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
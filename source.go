@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sourceDomain is the source of the hosts given by --domain. Those of a list
+// have its path as their source.
+const sourceDomain = "domain"
+
+// sources records the input each host is given by, to group the output by
+// source when several are combined.
+type sources struct {
+	names  []string
+	byAddr map[string]string
+}
+
+func newSources() *sources {
+	return &sources{byAddr: make(map[string]string)}
+}
+
+// add records addrs as given by name and returns them without those given
+// by a source added before, so that a host is checked once and attributed to
+// the first source giving it.
+func (s *sources) add(name string, addrs []string) []string {
+	s.names = append(s.names, name)
+	res := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if src, ok := s.byAddr[addr]; ok && src != name {
+			continue
+		}
+		s.byAddr[addr] = name
+		res = append(res, addr)
+	}
+	return res
+}
+
+// lookup returns the source of addr, or "" when not grouping by source.
+func (s *sources) lookup(addr string) string {
+	if s == nil {
+		return ""
+	}
+	return s.byAddr[addr]
+}
+
+// sourceGroup is the section of a source in the output, with its subtotals.
+type sourceGroup struct {
+	Source  string
+	Certs   int
+	Expired int
+	Results any `json:",omitempty"`
+
+	infos []*certInfo
+}
+
+// groupBySource splits infos into a group per source in the order the
+// sources are given, including those without any result.
+func groupBySource(infos []*certInfo, names []string) []*sourceGroup {
+	groups := make([]*sourceGroup, 0, len(names))
+	index := make(map[string]*sourceGroup)
+	for _, name := range names {
+		g := &sourceGroup{Source: name}
+		index[name] = g
+		groups = append(groups, g)
+	}
+	for _, info := range infos {
+		g, ok := index[info.Source]
+		if !ok {
+			g = &sourceGroup{Source: info.Source}
+			index[info.Source] = g
+			groups = append(groups, g)
+		}
+		g.infos = append(g.infos, info)
+		g.Certs++
+		if !info.NotAfter.After(info.CurrentTime) {
+			g.Expired++
+		}
+	}
+	return groups
+}
+
+// toGroupedJSON writes the groups as a JSON array with the results of each
+// group under Results.
+func toGroupedJSON(groups []*sourceGroup, w io.Writer, cols []string) error {
+	for _, g := range groups {
+		v, err := selectFields(g.infos, cols)
+		if err != nil {
+			return err
+		}
+		g.Results = v
+	}
+	return toJSON(groups, w)
+}
+
+// toGroupedText writes each group under a heading line with its subtotals,
+// rendered by render as the output would be without grouping.
+func toGroupedText(groups []*sourceGroup, w io.Writer, render func([]*certInfo, io.Writer) error) error {
+	var b strings.Builder
+	for i, g := range groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s (%d certs, %d expired)\n", g.Source, g.Certs, g.Expired)
+		if len(g.infos) == 0 {
+			continue
+		}
+		if err := render(g.infos, &b); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
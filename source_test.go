@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_sources_add(t *testing.T) {
+	s := newSources()
+	got := s.add(sourceDomain, []string{"a.example.com:443", "b.example.com:443"})
+	if diff := cmp.Diff([]string{"a.example.com:443", "b.example.com:443"}, got); diff != "" {
+		t.Error(diff)
+	}
+	// a host of an earlier source is left to it, but one listed twice in
+	// the same source is kept as it was before grouping
+	got = s.add("list.txt", []string{"b.example.com:443", "c.example.com:443", "c.example.com:443"})
+	if diff := cmp.Diff([]string{"c.example.com:443", "c.example.com:443"}, got); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{sourceDomain, "list.txt"}, s.names); diff != "" {
+		t.Error(diff)
+	}
+	for addr, want := range map[string]string{
+		"a.example.com:443": sourceDomain,
+		"b.example.com:443": sourceDomain,
+		"c.example.com:443": "list.txt",
+		"d.example.com:443": "",
+	} {
+		if got := s.lookup(addr); got != want {
+			t.Errorf("lookup(%q) = %q, want %q", addr, got, want)
+		}
+	}
+	var nilSources *sources
+	if got := nilSources.lookup("a.example.com:443"); got != "" {
+		t.Errorf("lookup() = %q, want empty", got)
+	}
+}
+
+func testSourceInfos() []*certInfo {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []*certInfo{
+		{DomainName: "a.example.com", Source: sourceDomain, NotAfter: now.AddDate(0, 1, 0), CurrentTime: now},
+		{DomainName: "b.example.com", Source: "list.txt", NotAfter: now.AddDate(0, -1, 0), CurrentTime: now},
+		{DomainName: "c.example.com", Source: "list.txt", NotAfter: now.AddDate(1, 0, 0), CurrentTime: now},
+	}
+}
+
+func Test_groupBySource(t *testing.T) {
+	groups := groupBySource(testSourceInfos(), []string{sourceDomain, "list.txt", "empty.txt"})
+	type subtotal struct {
+		source  string
+		certs   int
+		expired int
+	}
+	var got []subtotal
+	for _, g := range groups {
+		if len(g.infos) != g.Certs {
+			t.Errorf("%s: %d infos, want %d", g.Source, len(g.infos), g.Certs)
+		}
+		got = append(got, subtotal{g.Source, g.Certs, g.Expired})
+	}
+	want := []subtotal{
+		{sourceDomain, 1, 0},
+		{"list.txt", 2, 1},
+		{"empty.txt", 0, 0},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(subtotal{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_toGroupedText(t *testing.T) {
+	groups := groupBySource(testSourceInfos(), []string{sourceDomain, "list.txt", "empty.txt"})
+	w := &bytes.Buffer{}
+	err := toGroupedText(groups, w, func(infos []*certInfo, w io.Writer) error {
+		for _, info := range infos {
+			if _, err := io.WriteString(w, info.DomainName+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `domain (1 certs, 0 expired)
+a.example.com
+
+list.txt (2 certs, 1 expired)
+b.example.com
+c.example.com
+
+empty.txt (0 certs, 0 expired)
+`
+	if diff := cmp.Diff(want, w.String()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func Test_toGroupedJSON(t *testing.T) {
+	groups := groupBySource(testSourceInfos(), []string{sourceDomain, "list.txt"})
+	w := &bytes.Buffer{}
+	if err := toGroupedJSON(groups, w, []string{"DomainName"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {
+    "Source": "domain",
+    "Certs": 1,
+    "Expired": 0,
+    "Results": [
+      {
+        "DomainName": "a.example.com"
+      }
+    ]
+  },
+  {
+    "Source": "list.txt",
+    "Certs": 2,
+    "Expired": 1,
+    "Results": [
+      {
+        "DomainName": "b.example.com"
+      },
+      {
+        "DomainName": "c.example.com"
+      }
+    ]
+  }
+]
+`
+	if diff := cmp.Diff(want, w.String()); diff != "" {
+		t.Error(diff)
+	}
+}
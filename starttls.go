@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+func init() {
+	registerPreamble("smtp", preambleFunc(smtpStartTLS))
+	registerPreamble("imap", preambleFunc(imapStartTLS))
+	registerPreamble("pop3", preambleFunc(pop3StartTLS))
+	registerPreamble("ldap", preambleFunc(ldapStartTLS))
+	registerPreamble("mysql", preambleFunc(mysqlStartTLS))
+	registerPreamble("rdp", preambleFunc(rdpStartTLS))
+}
+
+// smtpStartTLS upgrades an SMTP session as described in RFC 3207.
+func smtpStartTLS(conn net.Conn, _ string) error {
+	tp := textproto.NewConn(conn)
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	if err := tp.PrintfLine("EHLO %s", localName()); err != nil {
+		return err
+	}
+	if _, _, err := tp.ReadResponse(250); err != nil {
+		return fmt.Errorf("smtp EHLO: %w", err)
+	}
+	if err := tp.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("smtp STARTTLS: %w", err)
+	}
+	return nil
+}
+
+// imapStartTLS upgrades an IMAP session as described in RFC 2595.
+func imapStartTLS(conn net.Conn, _ string) error {
+	tp := textproto.NewConn(conn)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("imap greeting: %s", line)
+	}
+	const tag = "a001"
+	if err := tp.PrintfLine("%s STARTTLS", tag); err != nil {
+		return err
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return fmt.Errorf("imap STARTTLS: %w", err)
+		}
+		if strings.HasPrefix(line, "* ") {
+			continue
+		}
+		if !strings.HasPrefix(line, tag+" OK") {
+			return fmt.Errorf("imap STARTTLS: %s", line)
+		}
+		return nil
+	}
+}
+
+// pop3StartTLS upgrades a POP3 session as described in RFC 2595.
+func pop3StartTLS(conn net.Conn, _ string) error {
+	tp := textproto.NewConn(conn)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return fmt.Errorf("pop3 greeting: %w", err)
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("pop3 greeting: %s", line)
+	}
+	if err := tp.PrintfLine("STLS"); err != nil {
+		return err
+	}
+	line, err = tp.ReadLine()
+	if err != nil {
+		return fmt.Errorf("pop3 STLS: %w", err)
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("pop3 STLS: %s", line)
+	}
+	return nil
+}
+
+const ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+type ldapMessage struct {
+	ID int
+	Op asn1.RawValue
+}
+
+// ldapStartTLS sends the StartTLS extended operation described in RFC 4511.
+func ldapStartTLS(conn net.Conn, _ string) error {
+	name, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: []byte(ldapStartTLSOID)})
+	if err != nil {
+		return err
+	}
+	req, err := asn1.Marshal(ldapMessage{
+		ID: 1,
+		Op: asn1.RawValue{Class: asn1.ClassApplication, Tag: 23, IsCompound: true, Bytes: name},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	b, err := readBER(conn)
+	if err != nil {
+		return fmt.Errorf("ldap StartTLS: %w", err)
+	}
+	var res ldapMessage
+	if _, err := asn1.Unmarshal(b, &res); err != nil {
+		return fmt.Errorf("ldap StartTLS: %w", err)
+	}
+	if res.ID != 1 || res.Op.Class != asn1.ClassApplication || res.Op.Tag != 24 {
+		return fmt.Errorf("ldap StartTLS: unexpected response")
+	}
+	var code asn1.Enumerated
+	if _, err := asn1.Unmarshal(res.Op.Bytes, &code); err != nil {
+		return fmt.Errorf("ldap StartTLS: %w", err)
+	}
+	if code != 0 {
+		return fmt.Errorf("ldap StartTLS: result code %d", code)
+	}
+	return nil
+}
+
+// readBER reads a single BER element with a definite length.
+func readBER(r io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1])
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 {
+			return nil, fmt.Errorf("unsupported BER length")
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		header = append(header, b...)
+		length = 0
+		for _, v := range b {
+			length = length<<8 | int(v)
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return append(header, body...), nil
+}
+
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+	mysqlMaxPacketSize          = 1<<24 - 1
+	mysqlCharsetUTF8MB4         = 45
+)
+
+// mysqlStartTLS reads the initial handshake of the MySQL client/server
+// protocol and answers with an SSLRequest packet.
+func mysqlStartTLS(conn net.Conn, _ string) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("mysql handshake: %w", err)
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("mysql handshake: %w", err)
+	}
+	if len(payload) > 3 && payload[0] == 0xff {
+		return fmt.Errorf("mysql handshake: %s", payload[3:])
+	}
+	if len(payload) == 0 || payload[0] != 10 {
+		return fmt.Errorf("mysql handshake: unsupported protocol version")
+	}
+	// skip the server version, connection id, auth plugin data and filler
+	i := bytes.IndexByte(payload[1:], 0)
+	if i < 0 || len(payload) < 1+i+1+4+8+1+2 {
+		return fmt.Errorf("mysql handshake: malformed packet")
+	}
+	pos := 1 + i + 1 + 4 + 8 + 1
+	caps := binary.LittleEndian.Uint16(payload[pos:])
+	if caps&mysqlClientSSL == 0 {
+		return fmt.Errorf("mysql handshake: server does not support SSL")
+	}
+	req := make([]byte, 4+32)
+	req[0], req[3] = 32, header[3]+1
+	binary.LittleEndian.PutUint32(req[4:], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(req[8:], mysqlMaxPacketSize)
+	req[12] = mysqlCharsetUTF8MB4
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	return nil
+}
+
+func localName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "localhost"
+	}
+	return name
+}
+
+const (
+	rdpNegReq         = 0x01
+	rdpNegRsp         = 0x02
+	rdpNegFailure     = 0x03
+	rdpProtocolSSL    = 0x00000001
+	rdpProtocolHybrid = 0x00000002
+)
+
+// rdpStartTLS sends an X.224 Connection Request carrying an RDP Negotiation
+// Request for the TLS security layer, as described in MS-RDPBCGR 2.2.1.1, and
+// checks that the server selects a protocol that continues with TLS.
+func rdpStartTLS(conn net.Conn, _ string) error {
+	req := []byte{
+		0x03, 0x00, 0x00, 0x13, // TPKT header
+		0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, // X.224 Connection Request
+		rdpNegReq, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, // RDP Negotiation Request
+	}
+	binary.LittleEndian.PutUint32(req[15:], rdpProtocolSSL|rdpProtocolHybrid)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("rdp connection confirm: %w", err)
+	}
+	if header[0] != 0x03 {
+		return fmt.Errorf("rdp connection confirm: unexpected TPKT version %d", header[0])
+	}
+	size := int(binary.BigEndian.Uint16(header[2:]))
+	if size < len(header)+7 {
+		return fmt.Errorf("rdp connection confirm: malformed packet")
+	}
+	body := make([]byte, size-len(header))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return fmt.Errorf("rdp connection confirm: %w", err)
+	}
+	if body[1]&0xf0 != 0xd0 {
+		return fmt.Errorf("rdp connection confirm: unexpected X.224 code %#x", body[1])
+	}
+	neg := body[7:]
+	if len(neg) < 8 {
+		return fmt.Errorf("rdp connection confirm: server supports standard RDP security only")
+	}
+	value := binary.LittleEndian.Uint32(neg[4:])
+	switch neg[0] {
+	case rdpNegRsp:
+		if value&(rdpProtocolSSL|rdpProtocolHybrid) == 0 {
+			return fmt.Errorf("rdp connection confirm: server selected protocol %#x without TLS", value)
+		}
+		return nil
+	case rdpNegFailure:
+		return fmt.Errorf("rdp connection confirm: negotiation failure code %d", value)
+	default:
+		return fmt.Errorf("rdp connection confirm: unexpected negotiation type %#x", neg[0])
+	}
+}
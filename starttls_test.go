@@ -0,0 +1,562 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveScript plays the server side of a line-based exchange on conn. Lines
+// prefixed with "S: " are sent to the client, and lines prefixed with "C: " are
+// expected from the client, where a trailing "*" matches any suffix.
+func serveScript(conn net.Conn, script []string) error {
+	r := bufio.NewReader(conn)
+	for _, line := range script {
+		switch {
+		case strings.HasPrefix(line, "S: "):
+			if _, err := fmt.Fprintf(conn, "%s\r\n", strings.TrimPrefix(line, "S: ")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "C: "):
+			want := strings.TrimPrefix(line, "C: ")
+			got, err := r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			got = strings.TrimRight(got, "\r\n")
+			if prefix, ok := strings.CutSuffix(want, "*"); ok && strings.HasPrefix(got, prefix) {
+				continue
+			}
+			if got != want {
+				return fmt.Errorf("unexpected client line: got %q, want %q", got, want)
+			}
+		}
+	}
+	return nil
+}
+
+func testPreamble(t *testing.T, p preamble, script []string, wantErr bool) {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+	ch := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		ch <- serveScript(server, script)
+	}()
+	if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	err := p.negotiate(client, host)
+	if (err != nil) != wantErr {
+		t.Errorf("negotiate() error = %v, wantErr %v", err, wantErr)
+	}
+	client.Close()
+	if err := <-ch; err != nil && !wantErr {
+		t.Errorf("server error = %v", err)
+	}
+}
+
+// newTestTLSConfig returns a server config with an in-memory self-signed cert.
+func newTestTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "starttls test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		DNSNames:     []string{host},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+}
+
+// startTestServer accepts a single connection, runs the plaintext exchange
+// with handler and then serves TLS on it. It returns the listening address.
+func startTestServer(t *testing.T, handler func(net.Conn) error) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if err := handler(conn); err != nil {
+			return
+		}
+		tlsConn := tls.Server(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		_, _ = tlsConn.Read(make([]byte, 1))
+	}()
+	return ln.Addr().String()
+}
+
+var smtpScript = []string{
+	"S: 220 mail.example.com ESMTP",
+	"C: EHLO *",
+	"S: 250-mail.example.com",
+	"S: 250-PIPELINING",
+	"S: 250 STARTTLS",
+	"C: STARTTLS",
+	"S: 220 2.0.0 Ready to start TLS",
+}
+
+func Test_smtpStartTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  []string
+		wantErr bool
+	}{
+		{
+			name:    "basic",
+			script:  smtpScript,
+			wantErr: false,
+		},
+		{
+			name: "greeting rejected",
+			script: []string{
+				"S: 554 no service",
+			},
+			wantErr: true,
+		},
+		{
+			name: "starttls not supported",
+			script: []string{
+				"S: 220 mail.example.com ESMTP",
+				"C: EHLO *",
+				"S: 250 mail.example.com",
+				"C: STARTTLS",
+				"S: 502 5.5.1 command not implemented",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testPreamble(t, preambleFunc(smtpStartTLS), tt.script, tt.wantErr)
+		})
+	}
+}
+
+func Test_imapStartTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  []string
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			script: []string{
+				"S: * OK [CAPABILITY IMAP4rev1 STARTTLS] ready",
+				"C: a001 STARTTLS",
+				"S: a001 OK Begin TLS negotiation now",
+			},
+			wantErr: false,
+		},
+		{
+			name: "untagged response",
+			script: []string{
+				"S: * OK ready",
+				"C: a001 STARTTLS",
+				"S: * CAPABILITY IMAP4rev1",
+				"S: a001 OK Begin TLS negotiation now",
+			},
+			wantErr: false,
+		},
+		{
+			name: "greeting rejected",
+			script: []string{
+				"S: * BYE server shutting down",
+			},
+			wantErr: true,
+		},
+		{
+			name: "starttls rejected",
+			script: []string{
+				"S: * OK ready",
+				"C: a001 STARTTLS",
+				"S: a001 BAD command unknown",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testPreamble(t, preambleFunc(imapStartTLS), tt.script, tt.wantErr)
+		})
+	}
+}
+
+func Test_pop3StartTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  []string
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			script: []string{
+				"S: +OK POP3 server ready",
+				"C: STLS",
+				"S: +OK Begin TLS negotiation",
+			},
+			wantErr: false,
+		},
+		{
+			name: "greeting rejected",
+			script: []string{
+				"S: -ERR unavailable",
+			},
+			wantErr: true,
+		},
+		{
+			name: "stls rejected",
+			script: []string{
+				"S: +OK POP3 server ready",
+				"C: STLS",
+				"S: -ERR command not supported",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testPreamble(t, preambleFunc(pop3StartTLS), tt.script, tt.wantErr)
+		})
+	}
+}
+
+func ldapResponse(t *testing.T, id int, tag int, code int) []byte {
+	t.Helper()
+	var body []byte
+	for _, v := range []any{asn1.Enumerated(code), []byte{}, []byte{}} {
+		b, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = append(body, b...)
+	}
+	b, err := asn1.Marshal(ldapMessage{
+		ID: id,
+		Op: asn1.RawValue{Class: asn1.ClassApplication, Tag: tag, IsCompound: true, Bytes: body},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func Test_ldapStartTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     []byte
+		wantErr bool
+	}{
+		{
+			name:    "basic",
+			res:     ldapResponse(t, 1, 24, 0),
+			wantErr: false,
+		},
+		{
+			name:    "protocol error",
+			res:     ldapResponse(t, 1, 24, 2),
+			wantErr: true,
+		},
+		{
+			name:    "unexpected operation",
+			res:     ldapResponse(t, 1, 1, 0),
+			wantErr: true,
+		},
+		{
+			name:    "unexpected message id",
+			res:     ldapResponse(t, 2, 24, 0),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer server.Close()
+				req, err := readBER(server)
+				if err != nil || !bytes.Contains(req, []byte(ldapStartTLSOID)) {
+					return
+				}
+				_, _ = server.Write(tt.res)
+			}()
+			if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			if err := ldapStartTLS(client, host); (err != nil) != tt.wantErr {
+				t.Errorf("ldapStartTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_readBER(t *testing.T) {
+	long, err := asn1.Marshal(bytes.Repeat([]byte("a"), 300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		arg     []byte
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:    "short form",
+			arg:     []byte{0x04, 0x01, 0x61, 0xff},
+			want:    []byte{0x04, 0x01, 0x61},
+			wantErr: false,
+		},
+		{
+			name:    "long form",
+			arg:     long,
+			want:    long,
+			wantErr: false,
+		},
+		{
+			name:    "indefinite length",
+			arg:     []byte{0x30, 0x80, 0x00, 0x00},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			arg:     []byte{0x04, 0x05, 0x61},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readBER(bytes.NewReader(tt.arg))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readBER() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("readBER() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func mysqlPacket(seq byte, payload []byte) []byte {
+	n := len(payload)
+	return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, payload...)
+}
+
+func mysqlGreeting(caps uint16) []byte {
+	payload := []byte{10}
+	payload = append(payload, "8.0.36\x00"...)
+	payload = append(payload, 1, 0, 0, 0)
+	payload = append(payload, "abcdefgh"...)
+	payload = append(payload, 0)
+	payload = binary.LittleEndian.AppendUint16(payload, caps)
+	payload = append(payload, mysqlCharsetUTF8MB4, 2, 0, 0xff, 0xff)
+	return mysqlPacket(0, payload)
+}
+
+func Test_mysqlStartTLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		greeting []byte
+		wantErr  bool
+	}{
+		{
+			name:     "basic",
+			greeting: mysqlGreeting(0xffff),
+			wantErr:  false,
+		},
+		{
+			name:     "ssl not supported",
+			greeting: mysqlGreeting(0xffff &^ mysqlClientSSL),
+			wantErr:  true,
+		},
+		{
+			name:     "error packet",
+			greeting: mysqlPacket(0, append([]byte{0xff, 0x6a, 0x04}, "Host is not allowed to connect"...)),
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported protocol",
+			greeting: mysqlPacket(0, []byte{9, 0}),
+			wantErr:  true,
+		},
+		{
+			name:     "malformed packet",
+			greeting: mysqlPacket(0, []byte{10, 'x'}),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			ch := make(chan []byte, 1)
+			go func() {
+				defer server.Close()
+				defer close(ch)
+				if _, err := server.Write(tt.greeting); err != nil {
+					return
+				}
+				req := make([]byte, 36)
+				if _, err := io.ReadFull(server, req); err != nil {
+					return
+				}
+				ch <- req
+			}()
+			if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			err := mysqlStartTLS(client, host)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mysqlStartTLS() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			req := <-ch
+			if req[0] != 32 || req[3] != 1 {
+				t.Errorf("unexpected packet header: %v", req[:4])
+			}
+			if caps := binary.LittleEndian.Uint32(req[4:]); caps&mysqlClientSSL == 0 {
+				t.Errorf("SSL capability is not set: %x", caps)
+			}
+		})
+	}
+}
+
+func Test_getCertList_starttls(t *testing.T) {
+	serverAddr := startTestServer(t, func(conn net.Conn) error {
+		return serveScript(conn, smtpScript)
+	})
+	connCache.delete(serverAddr)
+	t.Cleanup(func() { connCache.delete(serverAddr) })
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+		starttls: "smtp",
+	}
+	got, err := getCertList(context.Background(), []string{serverAddr}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].CommonName != "starttls test" {
+		t.Errorf("CommonName = %v, want %v", got[0].CommonName, "starttls test")
+	}
+}
+
+func rdpConfirm(neg ...byte) []byte {
+	x224 := append([]byte{byte(6 + len(neg)), 0xd0, 0x00, 0x00, 0x12, 0x34, 0x00}, neg...)
+	return append([]byte{0x03, 0x00, 0x00, byte(4 + len(x224))}, x224...)
+}
+
+func Test_rdpStartTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm []byte
+		wantErr bool
+	}{
+		{
+			name:    "ssl selected",
+			confirm: rdpConfirm(rdpNegRsp, 0x00, 0x08, 0x00, 0x01, 0x00, 0x00, 0x00),
+			wantErr: false,
+		},
+		{
+			name:    "hybrid selected",
+			confirm: rdpConfirm(rdpNegRsp, 0x1f, 0x08, 0x00, 0x02, 0x00, 0x00, 0x00),
+			wantErr: false,
+		},
+		{
+			name:    "standard rdp security selected",
+			confirm: rdpConfirm(rdpNegRsp, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00),
+			wantErr: true,
+		},
+		{
+			name:    "negotiation failure",
+			confirm: rdpConfirm(rdpNegFailure, 0x00, 0x08, 0x00, 0x02, 0x00, 0x00, 0x00),
+			wantErr: true,
+		},
+		{
+			name:    "no negotiation response",
+			confirm: rdpConfirm(),
+			wantErr: true,
+		},
+		{
+			name:    "not tpkt",
+			confirm: []byte("HTTP/1.1 400 Bad Request\r\n\r\n"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			ch := make(chan []byte, 1)
+			go func() {
+				defer server.Close()
+				defer close(ch)
+				req := make([]byte, 19)
+				if _, err := io.ReadFull(server, req); err != nil {
+					return
+				}
+				ch <- req
+				_, _ = server.Write(tt.confirm)
+			}()
+			if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			err := rdpStartTLS(client, host)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rdpStartTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			req := <-ch
+			if len(req) != 19 || req[0] != 0x03 || req[5] != 0xe0 || req[11] != rdpNegReq {
+				t.Errorf("unexpected connection request: %x", req)
+			}
+			if protocols := binary.LittleEndian.Uint32(req[15:]); protocols&rdpProtocolSSL == 0 {
+				t.Errorf("SSL protocol is not requested: %x", protocols)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// streamer writes each cert as a JSON line as soon as its check completes,
+// so that consumers can start before the whole list is checked and nothing
+// is held for the output. A nil streamer writes nothing.
+type streamer struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	redactor *redactor
+	computed computedFields
+	columns  []string
+}
+
+func newStreamer(w io.Writer, r *redactor, f computedFields, cols []string) *streamer {
+	return &streamer{
+		enc:      json.NewEncoder(w),
+		redactor: r,
+		computed: f,
+		columns:  cols,
+	}
+}
+
+func (s *streamer) write(ctx context.Context, info *certInfo) error {
+	if s == nil {
+		return nil
+	}
+	infos, err := s.computed.apply(ctx, []*certInfo{info})
+	if err != nil {
+		return err
+	}
+	info = s.redactor.apply(infos)[0]
+	v, err := selectField(info, s.columns)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(v)
+}
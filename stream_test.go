@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func Test_streamer(t *testing.T) {
+	red, err := newRedactor([]string{"ips"}, redactOmit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	s := newStreamer(&buf, red, nil, nil)
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.write(context.Background(), input[0]); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	n := 0
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var info certInfo
+		if err := json.Unmarshal(sc.Bytes(), &info); err != nil {
+			t.Fatalf("line %d: %v", n+1, err)
+		}
+		if len(info.IPAddresses) != 0 {
+			t.Errorf("line %d: IPAddresses not redacted", n+1)
+		}
+		n++
+	}
+	if n != 50 {
+		t.Errorf("lines = %d, want 50", n)
+	}
+	var nilStreamer *streamer
+	if err := nilStreamer.write(context.Background(), input[0]); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// sourceCIDR is the source of the hosts swept by --cidr.
+	sourceCIDR = "cidr"
+
+	defaultSweepPort           = "443"
+	defaultSweepConnectTimeout = time.Second
+
+	maxCIDRBits = 16
+)
+
+// sweep holds the hosts of the CIDR ranges swept for TLS endpoints, those of
+// --cidr and those given as targets. Unlike the hosts given by name, they do
+// not fail the check: a host that does not accept the connection in time is
+// skipped, and one that accepts it but does not speak TLS or fails otherwise
+// is only logged. A nil sweep covers no host.
+type sweep struct {
+	connectTimeout time.Duration
+	hosts          map[string]bool
+
+	mu     sync.Mutex
+	closed int
+	noTLS  int
+	failed int
+}
+
+// newSweep returns the sweep of ranges on port, to which the ranges among
+// the targets may be added by expand.
+func newSweep(ranges []string, port string, connectTimeout time.Duration) (*sweep, []string, error) {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, nil, fmt.Errorf("invalid sweep port %q", port)
+	}
+	if connectTimeout <= 0 {
+		return nil, nil, errors.New("invalid connect timeout: must be greater than 0")
+	}
+	s := &sweep{connectTimeout: connectTimeout, hosts: make(map[string]bool)}
+	targets := make([]string, len(ranges))
+	for i, r := range ranges {
+		if !strings.Contains(r, "/") {
+			return nil, nil, fmt.Errorf("invalid CIDR range %q", r)
+		}
+		targets[i] = net.JoinHostPort(r, port)
+	}
+	addrs, err := s.expand(targets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, addrs, nil
+}
+
+// expand expands the targets written as CIDR ranges such as
+// "10.0.5.0/24:443" into an address per host, on 443 unless the range has a
+// port, which are swept as the ranges of --cidr are. Other targets are kept
+// as they are.
+func (s *sweep) expand(addrs []string) ([]string, error) {
+	var res []string
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			res = append(res, addr)
+			continue
+		}
+		cidr, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			cidr, port = addr, "443"
+		}
+		ips, err := hostsInCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			host := net.JoinHostPort(ip.String(), port)
+			if !s.hosts[host] {
+				s.hosts[host] = true
+				res = append(res, host)
+			}
+		}
+	}
+	return res, nil
+}
+
+func hostsInCIDR(cidr string) ([]net.IP, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > maxCIDRBits {
+		return nil, fmt.Errorf("CIDR range too large: %s: allowed up to /%d", cidr, bits-maxCIDRBits)
+	}
+	var ips []net.IP
+	for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); ip = nextIP(ip) {
+		ips = append(ips, ip)
+	}
+	// exclude the network and broadcast addresses of IPv4 subnets
+	if ipnet.IP.To4() != nil && bits-ones > 1 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// covers reports whether addr is a swept host.
+func (s *sweep) covers(addr string) bool {
+	return s != nil && s.hosts[addr]
+}
+
+// skip reports whether the failure of addr is left out of the check because
+// addr is a swept host, and counts it by kind.
+func (s *sweep) skip(addr string, err error) bool {
+	if !s.covers(addr) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case isClosed(err):
+		s.closed++
+		log.Debug("no answer", "host", addr)
+	case isNoTLS(err):
+		s.noTLS++
+		log.Info("no TLS", "host", addr)
+	default:
+		s.failed++
+		log.Warn("cannot check TLS endpoint", "host", addr, "err", err)
+	}
+	return true
+}
+
+// report logs how many of the swept hosts fell into each kind once the check
+// is complete.
+func (s *sweep) report() {
+	if s == nil || len(s.hosts) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := len(s.hosts) - s.closed - s.noTLS - s.failed
+	log.Info("swept CIDR ranges", "hosts", len(s.hosts), "tls", found, "notls", s.noTLS, "closed", s.closed, "errors", s.failed)
+}
+
+// isClosed reports whether err is the failure to connect at all, as for an
+// unused address or a closed or filtered port.
+func isClosed(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isNoTLS reports whether err is the failure of a handshake with a host that
+// accepted the connection but answered with another protocol or hung up.
+func isNoTLS(err error) bool {
+	var recordHeader tls.RecordHeaderError
+	return errors.As(err, &recordHeader) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
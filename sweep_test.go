@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newSweep(t *testing.T) {
+	tests := []struct {
+		name      string
+		ranges    []string
+		port      string
+		timeout   time.Duration
+		wantAddrs []string
+		wantErr   bool
+	}{
+		{
+			name:      "ranges",
+			ranges:    []string{"192.0.2.0/30", "192.0.2.2/31"},
+			port:      "8443",
+			timeout:   time.Second,
+			wantAddrs: []string{"192.0.2.1:8443", "192.0.2.2:8443", "192.0.2.3:8443"},
+		},
+		{
+			name:      "no range",
+			ranges:    nil,
+			port:      "443",
+			timeout:   time.Second,
+			wantAddrs: nil,
+		},
+		{
+			name:    "not a range",
+			ranges:  []string{"192.0.2.1"},
+			port:    "443",
+			timeout: time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "too large",
+			ranges:  []string{"10.0.0.0/8"},
+			port:    "443",
+			timeout: time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			ranges:  []string{"192.0.2.0/30"},
+			port:    "https",
+			timeout: time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "zero timeout",
+			ranges:  []string{"192.0.2.0/30"},
+			port:    "443",
+			timeout: 0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, addrs, err := newSweep(tt.ranges, tt.port, tt.timeout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSweep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.wantAddrs, addrs); diff != "" {
+				t.Error(diff)
+			}
+			for _, addr := range addrs {
+				if !s.covers(addr) {
+					t.Errorf("covers(%q) = false", addr)
+				}
+			}
+		})
+	}
+}
+
+func Test_sweep_expand(t *testing.T) {
+	tests := []struct {
+		name      string
+		addrs     []string
+		want      []string
+		wantSwept []string
+		wantErr   bool
+	}{
+		{
+			name:      "basic",
+			addrs:     []string{addr},
+			want:      []string{addr},
+			wantSwept: nil,
+		},
+		{
+			name:      "ipv4 range",
+			addrs:     []string{"10.0.5.0/30:8443", addr},
+			want:      []string{"10.0.5.1:8443", "10.0.5.2:8443", addr},
+			wantSwept: []string{"10.0.5.1:8443", "10.0.5.2:8443"},
+		},
+		{
+			name:      "ipv4 single host",
+			addrs:     []string{"10.0.5.7/32:8443"},
+			want:      []string{"10.0.5.7:8443"},
+			wantSwept: []string{"10.0.5.7:8443"},
+		},
+		{
+			name:      "default port",
+			addrs:     []string{"10.0.5.0/31"},
+			want:      []string{"10.0.5.0:443", "10.0.5.1:443"},
+			wantSwept: []string{"10.0.5.0:443", "10.0.5.1:443"},
+		},
+		{
+			name:      "ipv6 range",
+			addrs:     []string{"[2001:db8::/127]:8443"},
+			want:      []string{"[2001:db8::]:8443", "[2001:db8::1]:8443"},
+			wantSwept: []string{"[2001:db8::]:8443", "[2001:db8::1]:8443"},
+		},
+		{
+			name:      "overlapping ranges",
+			addrs:     []string{"10.0.5.0/30", "10.0.5.2/31"},
+			want:      []string{"10.0.5.1:443", "10.0.5.2:443", "10.0.5.3:443"},
+			wantSwept: []string{"10.0.5.1:443", "10.0.5.2:443", "10.0.5.3:443"},
+		},
+		{
+			name:    "invalid range",
+			addrs:   []string{"10.0.5.0/33:8443"},
+			wantErr: true,
+		},
+		{
+			name:    "too large range",
+			addrs:   []string{"10.0.0.0/8:8443"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, err := newSweep(nil, defaultSweepPort, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.expand(tt.addrs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+			var swept []string
+			for _, addr := range got {
+				if s.covers(addr) {
+					swept = append(swept, addr)
+				}
+			}
+			if diff := cmp.Diff(tt.wantSwept, swept); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_sweep_kinds(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name       string
+		err        error
+		wantClosed bool
+		wantNoTLS  bool
+	}{
+		{name: "refused", err: fmt.Errorf("cannot connect: %w", dial), wantClosed: true},
+		{name: "connect timeout", err: &net.OpError{Op: "dial", Err: context.DeadlineExceeded}, wantClosed: true},
+		{name: "other protocol", err: fmt.Errorf("cannot connect: %w", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), wantNoTLS: true},
+		{name: "hung up", err: &throttledError{attempts: 1, err: io.EOF}, wantNoTLS: true},
+		{name: "reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, wantNoTLS: true},
+		{name: "handshake failure", err: errors.New("x509: certificate signed by unknown authority")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isClosed(tt.err); got != tt.wantClosed {
+				t.Errorf("isClosed() = %v, want %v", got, tt.wantClosed)
+			}
+			if got := isNoTLS(tt.err); got != tt.wantNoTLS {
+				t.Errorf("isNoTLS() = %v, want %v", got, tt.wantNoTLS)
+			}
+		})
+	}
+}
+
+func Test_getCertList_sweep(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	// a host answering with another protocol
+	plain, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", port))
+	if err != nil {
+		t.Skip("cannot listen on 127.0.0.2:", err)
+	}
+	t.Cleanup(func() { plain.Close() })
+	config := newTestTLSConfig(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	go func() {
+		for {
+			conn, err := plain.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = io.WriteString(conn, "SSH-2.0-OpenSSH_9.6\r\n")
+			conn.Close()
+		}
+	}()
+	tests := []struct {
+		name    string
+		ranges  []string
+		targets []string
+	}{
+		{
+			name:   "cidr",
+			ranges: []string{"127.0.0.0/29"},
+		},
+		{
+			name:    "target",
+			targets: []string{"127.0.0.0/29:" + port},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, addrs, err := newSweep(tt.ranges, port, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			targets, err := s.expand(tt.targets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addrs = append(addrs, targets...)
+			connCache.purge()
+			opts := &options{
+				timeout:     5 * time.Second,
+				insecure:    true,
+				location:    time.UTC,
+				sweep:       s,
+				concurrency: len(addrs),
+			}
+			infos, err := getCertList(context.Background(), addrs, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(infos) != 1 || infos[0].DomainName != "127.0.0.1" {
+				t.Fatalf("getCertList() = %v, want the cert of 127.0.0.1", infos)
+			}
+			if s.noTLS != 1 || s.closed != len(addrs)-2 || s.failed != 0 {
+				t.Errorf("notls = %d, closed = %d, errors = %d", s.noTLS, s.closed, s.failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// targetColumns maps the accepted header names of a structured list to the
+// option they set.
+var targetColumns = map[string]string{
+	"host":        "host",
+	"hostname":    "host",
+	"domain":      "host",
+	"port":        "port",
+	"servername":  "servername",
+	"sni":         "servername",
+	"starttls":    "starttls",
+	"tags":        "tags",
+	"tag":         "tags",
+	"via":         "via",
+	"proxy":       "via",
+	"egress":      "via",
+	"timeout":     "timeout",
+	"insecure":    "insecure",
+	"issuer":      "issuer",
+	"expect":      "expect",
+	"expected":    "expect",
+	"expect-cert": "expect",
+}
+
+// target holds the options given to a host in a structured list, which take
+// precedence over the flags.
+type target struct {
+	serverName string
+	starttls   string
+	tags       []string
+	// routes replace those of the PAC script, if any
+	routes []route
+	// timeout replaces the global one when set
+	timeout time.Duration
+	// insecure skips verification for this host alone
+	insecure bool
+	// issuer is a part of the issuer DN the cert must have
+	issuer string
+	// expectCert is the path to the cert the host is expected to serve, and
+	// expected its fingerprint once loaded
+	expectCert string
+	expected   string
+}
+
+// targets are the options of the hosts in a structured list, keyed by
+// host:port, or by host alone for a host given without a port, which then
+// gets the port of a preset as usual. A row with a server name is keyed, and
+// listed, as servername@host:port, so that a host such as a load balancer
+// can be checked under several names. A plain list has none.
+type targets map[string]target
+
+// lookup returns the options of addr, if any.
+func (ts targets) lookup(addr string) target {
+	addr = ensureDefaultPort(addr)
+	if t, ok := ts[addr]; ok {
+		return t
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	return ts[host]
+}
+
+// targetAddr returns the address of a host listed as servername@host:port.
+func targetAddr(addr string) string {
+	if _, after, ok := strings.Cut(addr, "@"); ok {
+		return after
+	}
+	return addr
+}
+
+// listComma returns the separator of a structured list, named *.csv or *.tsv,
+// or zero for a plain list of addresses.
+func listComma(path string) rune {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ','
+	case ".tsv":
+		return '\t'
+	default:
+		return 0
+	}
+}
+
+// parseTargets reads a structured list with a header line. Only the host
+// column is required; tags are separated by spaces or semicolons, and the
+// egress paths of via by semicolons. The timeout is a duration such as 10s.
+func parseTargets(r io.Reader, comma rune) ([]string, targets, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		option, ok := targetColumns[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid column %q: allowed values: host|port|servername|starttls|tags|via|timeout|insecure|issuer|expect", name)
+		}
+		index[option] = i
+	}
+	if _, ok := index["host"]; !ok {
+		return nil, nil, errors.New("no host column")
+	}
+	get := func(record []string, option string) string {
+		if i, ok := index[option]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var addrs []string
+	ts := make(targets)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		addr := get(record, "host")
+		if addr == "" {
+			return nil, nil, fmt.Errorf("line %d: no host", line)
+		}
+		if port := get(record, "port"); port != "" {
+			addr = net.JoinHostPort(addr, port)
+		}
+		host, _, err := ensureHostPort(ensureDefaultPort(addr))
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: invalid host %q: %w", line, addr, err)
+		}
+		key := host
+		if strings.Contains(addr, ":") {
+			key = addr
+		}
+		t := target{
+			serverName: get(record, "servername"),
+			starttls:   get(record, "starttls"),
+			tags: strings.FieldsFunc(get(record, "tags"), func(r rune) bool {
+				return r == ';' || r == ' '
+			}),
+		}
+		if t.serverName != "" {
+			key = t.serverName + "@" + key
+			addr = t.serverName + "@" + addr
+		}
+		if _, ok := ts[key]; ok {
+			return nil, nil, fmt.Errorf("line %d: duplicate host %q", line, key)
+		}
+		if t.starttls != "" {
+			if _, err := lookupPreamble(t.starttls); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if via := get(record, "via"); via != "" {
+			t.routes, err = parseVia(via)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if timeout := get(record, "timeout"); timeout != "" {
+			t.timeout, err = time.ParseDuration(timeout)
+			if err != nil || t.timeout <= 0 {
+				return nil, nil, fmt.Errorf("line %d: invalid timeout %q", line, timeout)
+			}
+		}
+		if insecure := get(record, "insecure"); insecure != "" {
+			t.insecure, err = strconv.ParseBool(insecure)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: invalid insecure %q", line, insecure)
+			}
+		}
+		t.issuer = get(record, "issuer")
+		t.expectCert = get(record, "expect")
+		ts[key] = t
+		addrs = append(addrs, addr)
+	}
+	return addrs, ts, nil
+}
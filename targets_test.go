@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func Test_parseTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		comma       rune
+		wantAddrs   []string
+		wantTargets targets
+		wantErr     bool
+	}{
+		{
+			name:      "csv",
+			input:     "Host,Port,SNI,StartTLS,Tags\nexample.com,8443,www.example.com,,prod;web\n# comment\nmail.example.com,,,smtp,mail\n",
+			comma:     ',',
+			wantAddrs: []string{"www.example.com@example.com:8443", "mail.example.com"},
+			wantTargets: targets{
+				"www.example.com@example.com:8443": {serverName: "www.example.com", tags: []string{"prod", "web"}},
+				"mail.example.com":                 {starttls: "smtp", tags: []string{"mail"}},
+			},
+		},
+		{
+			name:      "names of a load balancer",
+			input:     "host,servername\nlb.example.com,www.example.com\nlb.example.com,api.example.com\nlb.example.com,\n",
+			comma:     ',',
+			wantAddrs: []string{"www.example.com@lb.example.com", "api.example.com@lb.example.com", "lb.example.com"},
+			wantTargets: targets{
+				"www.example.com@lb.example.com": {serverName: "www.example.com"},
+				"api.example.com@lb.example.com": {serverName: "api.example.com"},
+				"lb.example.com":                 {},
+			},
+		},
+		{
+			name:      "tsv with port in host",
+			input:     "host\ttags\n[2001:db8::1]:443\ta b\n",
+			comma:     '\t',
+			wantAddrs: []string{"[2001:db8::1]:443"},
+			wantTargets: targets{
+				"[2001:db8::1]:443": {tags: []string{"a", "b"}},
+			},
+		},
+		{
+			name:      "via",
+			input:     "host,via\nexample.com,socks5://proxy:1080;direct\n",
+			comma:     ',',
+			wantAddrs: []string{"example.com"},
+			wantTargets: targets{
+				"example.com": {routes: []route{{kind: routeSOCKS, addr: "proxy:1080"}, {kind: routeDirect}}},
+			},
+		},
+		{
+			name:      "overrides",
+			input:     "host,port,timeout,insecure,issuer\nintranet.corp,8443,30s,true,CN=Corp CA\nexample.com,,,false,\n",
+			comma:     ',',
+			wantAddrs: []string{"intranet.corp:8443", "example.com"},
+			wantTargets: targets{
+				"intranet.corp:8443": {timeout: 30 * time.Second, insecure: true, issuer: "CN=Corp CA"},
+				"example.com":        {},
+			},
+		},
+		{
+			name:      "expect",
+			input:     "host,expect-cert\nwww1.example.com,certs/www.pem\nwww2.example.com,certs/www.pem\n",
+			comma:     ',',
+			wantAddrs: []string{"www1.example.com", "www2.example.com"},
+			wantTargets: targets{
+				"www1.example.com": {expectCert: "certs/www.pem"},
+				"www2.example.com": {expectCert: "certs/www.pem"},
+			},
+		},
+		{
+			name:    "invalid timeout",
+			input:   "host,timeout\nexample.com,30\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "negative timeout",
+			input:   "host,timeout\nexample.com,-1s\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "invalid insecure",
+			input:   "host,insecure\nexample.com,maybe\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "invalid via",
+			input:   "host,via\nexample.com,ftp://proxy\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "no host column",
+			input:   "port\n443\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "unknown column",
+			input:   "host,owner\nexample.com,team\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "empty host",
+			input:   "host,port\n,443\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			input:   "host,port\nexample.com,http2\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "invalid starttls",
+			input:   "host,starttls\nexample.com,gopher\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			input:   "host,port\nexample.com,443\nexample.com,443\n",
+			comma:   ',',
+			wantErr: true,
+		},
+		{
+			name:    "duplicate name",
+			input:   "host,servername\nlb.example.com,www.example.com\nlb.example.com,www.example.com\n",
+			comma:   ',',
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, ts, err := parseTargets(strings.NewReader(tt.input), tt.comma)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(addrs, tt.wantAddrs); diff != "" {
+				t.Error(diff)
+			}
+			if diff := cmp.Diff(ts, tt.wantTargets, cmp.AllowUnexported(target{}, route{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_targets_lookup(t *testing.T) {
+	ts := targets{
+		"example.com:8443": {serverName: "a.example.com"},
+		"example.com":      {serverName: "b.example.com"},
+	}
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "example.com:8443", want: "a.example.com"},
+		{addr: "example.com:3389", want: "b.example.com"},
+		{addr: "example.com", want: "b.example.com"},
+		{addr: "other.example.com", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := ts.lookup(tt.addr).serverName; got != tt.want {
+				t.Errorf("lookup() serverName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	var none targets
+	if got := none.lookup("example.com"); got.serverName != "" {
+		t.Errorf("lookup() = %+v", got)
+	}
+}
+
+func Test_newConnector_target(t *testing.T) {
+	opts := &options{
+		serverName: "flag.example.com",
+		targets: targets{
+			"example.com:25": {serverName: "mx.example.com", starttls: "smtp", tags: []string{"mail"}},
+		},
+	}
+	conn, err := newConnector("example.com:25", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.tlsConfig.ServerName != "mx.example.com" {
+		t.Errorf("ServerName = %q, want %q", conn.tlsConfig.ServerName, "mx.example.com")
+	}
+	if conn.preamble == nil {
+		t.Error("preamble = nil, want smtp")
+	}
+	if diff := cmp.Diff(conn.tags, []string{"mail"}); diff != "" {
+		t.Error(diff)
+	}
+	conn, err = newConnector("other.example.com", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.tlsConfig.ServerName != "flag.example.com" {
+		t.Errorf("ServerName = %q, want %q", conn.tlsConfig.ServerName, "flag.example.com")
+	}
+}
+
+func Test_newConnector_targetNames(t *testing.T) {
+	_, ts, err := parseTargets(strings.NewReader("host,servername\nlb.example.com,www.example.com\nlb.example.com,api.example.com\n"), ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &options{targets: ts}
+	www, err := newConnector("www.example.com@lb.example.com", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := newConnector("api.example.com@lb.example.com", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		conn *connector
+		want string
+	}{
+		{conn: www, want: "www.example.com"},
+		{conn: api, want: "api.example.com"},
+	} {
+		if tt.conn.addr != "lb.example.com:443" || tt.conn.tlsConfig.ServerName != tt.want {
+			t.Errorf("addr, ServerName = %q, %q, want %q, %q", tt.conn.addr, tt.conn.tlsConfig.ServerName, "lb.example.com:443", tt.want)
+		}
+	}
+	// a conn made under one name is not reused for the other
+	if www.connKey() == api.connKey() {
+		t.Errorf("connKey() = %q for both names", www.connKey())
+	}
+}
+
+func Test_newConnector_targetOverrides(t *testing.T) {
+	opts := &options{
+		timeout: 5 * time.Second,
+		targets: targets{
+			"intranet.corp:8443": {timeout: 30 * time.Second, insecure: true},
+		},
+	}
+	conn, err := newConnector("intranet.corp:8443", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.timeout != 30*time.Second {
+		t.Errorf("timeout = %v, want %v", conn.timeout, 30*time.Second)
+	}
+	if !conn.insecure {
+		t.Error("insecure = false, want true")
+	}
+	conn, err = newConnector("example.com", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.timeout != 5*time.Second {
+		t.Errorf("timeout = %v, want %v", conn.timeout, 5*time.Second)
+	}
+	if conn.insecure {
+		t.Error("insecure = true, want false")
+	}
+}
+
+func Test_getCertList_expectedIssuer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	config := newTestTLSConfig(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tlsConn := tls.Server(conn, config)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+				_, _ = tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	addr := ln.Addr().String()
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := leaf.Issuer.String()
+	tests := []struct {
+		name    string
+		issuer  string
+		wantErr bool
+	}{
+		{name: "match", issuer: issuer},
+		{name: "mismatch", issuer: "O=Other CA", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				location: time.UTC,
+				targets: targets{
+					addr: {insecure: true, issuer: tt.issuer},
+				},
+			}
+			_, err := getCertList(context.Background(), []string{addr}, opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getCertList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
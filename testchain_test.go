@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// issueTestCert issues tmpl for pub from parent with parentKey, or self-signs
+// it with parentKey when parent is nil.
+func issueTestCert(t *testing.T, tmpl, parent *x509.Certificate, pub any, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	if parent == nil {
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// testCATemplate returns a CA named cn, valid from an hour ago until
+// notAfter.
+func testCATemplate(serial int64, cn string, notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+// testLeafTemplate returns a server cert for 127.0.0.1, valid from an hour
+// ago for a day.
+func testLeafTemplate(serial int64) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+}
+
+// serveTestTLS serves chain with key on 127.0.0.1 until the test ends, and
+// returns the address it listens on.
+func serveTestTLS(t *testing.T, key *ecdsa.PrivateKey, chain ...*x509.Certificate) string {
+	t.Helper()
+	cert := tls.Certificate{PrivateKey: key}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := conn.(*tls.Conn).Handshake(); err != nil {
+					return
+				}
+				_, _ = conn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+type testChain struct {
+	root, intermediate, leaf *x509.Certificate
+	leafKey                  *ecdsa.PrivateKey
+	roots                    *x509.CertPool
+	fetches                  *atomic.Int32
+}
+
+// newTestChain issues a leaf for 127.0.0.1 from an intermediate published at
+// the CA Issuers URL of the leaf, which is served by a test server.
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+	fetches := &atomic.Int32{}
+	var intermediateDER []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path != "/intermediate.crt" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(intermediateDER)
+	}))
+	t.Cleanup(srv.Close)
+	rootKey, interKey, leafKey := newTestKey(t), newTestKey(t), newTestKey(t)
+	notAfter := time.Now().Add(24 * time.Hour)
+	root := issueTestCert(t, testCATemplate(1, "aia test root", notAfter), nil, &rootKey.PublicKey, rootKey)
+	intermediate := issueTestCert(t, testCATemplate(2, "aia test intermediate", notAfter), root, &interKey.PublicKey, rootKey)
+	intermediateDER = intermediate.Raw
+	tmpl := testLeafTemplate(3)
+	tmpl.IssuingCertificateURL = []string{srv.URL + "/intermediate.crt"}
+	leaf := issueTestCert(t, tmpl, intermediate, &leafKey.PublicKey, interKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &testChain{root: root, intermediate: intermediate, leaf: leaf, leafKey: leafKey, roots: roots, fetches: fetches}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// openSSLTime is the layout of dates in the output of openssl x509 -text.
+const openSSLTime = "Jan _2 15:04:05 2006 MST"
+
+var (
+	signatureAlgorithmNames = map[x509.SignatureAlgorithm]string{
+		x509.MD5WithRSA:       "md5WithRSAEncryption",
+		x509.SHA1WithRSA:      "sha1WithRSAEncryption",
+		x509.SHA256WithRSA:    "sha256WithRSAEncryption",
+		x509.SHA384WithRSA:    "sha384WithRSAEncryption",
+		x509.SHA512WithRSA:    "sha512WithRSAEncryption",
+		x509.SHA256WithRSAPSS: "rsassaPss",
+		x509.SHA384WithRSAPSS: "rsassaPss",
+		x509.SHA512WithRSAPSS: "rsassaPss",
+		x509.ECDSAWithSHA1:    "ecdsa-with-SHA1",
+		x509.ECDSAWithSHA256:  "ecdsa-with-SHA256",
+		x509.ECDSAWithSHA384:  "ecdsa-with-SHA384",
+		x509.ECDSAWithSHA512:  "ecdsa-with-SHA512",
+		x509.PureEd25519:      "ED25519",
+	}
+	attributeNames = map[string]string{
+		"2.5.4.3":                  "CN",
+		"2.5.4.5":                  "serialNumber",
+		"2.5.4.6":                  "C",
+		"2.5.4.7":                  "L",
+		"2.5.4.8":                  "ST",
+		"2.5.4.9":                  "street",
+		"2.5.4.10":                 "O",
+		"2.5.4.11":                 "OU",
+		"2.5.4.15":                 "businessCategory",
+		"2.5.4.17":                 "postalCode",
+		"1.2.840.113549.1.9.1":     "emailAddress",
+		"1.3.6.1.4.1.311.60.2.1.3": "jurisdictionC",
+	}
+	keyUsageNames = []string{
+		"Digital Signature",
+		"Non Repudiation",
+		"Key Encipherment",
+		"Data Encipherment",
+		"Key Agreement",
+		"Certificate Sign",
+		"CRL Sign",
+		"Encipher Only",
+		"Decipher Only",
+	}
+	extKeyUsageNames = map[x509.ExtKeyUsage]string{
+		x509.ExtKeyUsageAny:             "Any Extended Key Usage",
+		x509.ExtKeyUsageServerAuth:      "TLS Web Server Authentication",
+		x509.ExtKeyUsageClientAuth:      "TLS Web Client Authentication",
+		x509.ExtKeyUsageCodeSigning:     "Code Signing",
+		x509.ExtKeyUsageEmailProtection: "E-mail Protection",
+		x509.ExtKeyUsageTimeStamping:    "Time Stamping",
+		x509.ExtKeyUsageOCSPSigning:     "OCSP Signing",
+	}
+	extensionNames = map[string]string{
+		"2.5.29.14":               "X509v3 Subject Key Identifier",
+		"2.5.29.15":               "X509v3 Key Usage",
+		"2.5.29.17":               "X509v3 Subject Alternative Name",
+		"2.5.29.19":               "X509v3 Basic Constraints",
+		"2.5.29.30":               "X509v3 Name Constraints",
+		"2.5.29.31":               "X509v3 CRL Distribution Points",
+		"2.5.29.32":               "X509v3 Certificate Policies",
+		"2.5.29.35":               "X509v3 Authority Key Identifier",
+		"2.5.29.37":               "X509v3 Extended Key Usage",
+		"1.3.6.1.5.5.7.1.1":       "Authority Information Access",
+		"1.3.6.1.5.5.7.1.24":      "TLS Feature",
+		"1.3.6.1.4.1.11129.2.4.2": "CT Precertificate SCTs",
+		"1.3.6.1.4.1.11129.2.4.3": "CT Precertificate Poison",
+	}
+)
+
+// toText writes the leaf cert of each host decoded in the layout of
+// openssl x509 -text -fingerprint, for a close look at a cert beyond the
+// columns of the other formats.
+func toText(infos []*certInfo, w io.Writer) error {
+	var sb strings.Builder
+	for i, info := range infos {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "# %s\n", net.JoinHostPort(info.DomainName, info.AccessPort))
+		if len(info.chain) == 0 {
+			sb.WriteString("(no cert to decode)\n")
+			continue
+		}
+		writeCertText(&sb, info.chain[0])
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeCertText(sb *strings.Builder, cert *x509.Certificate) {
+	line := func(indent int, format string, args ...any) {
+		sb.WriteString(strings.Repeat(" ", indent))
+		fmt.Fprintf(sb, format, args...)
+		sb.WriteString("\n")
+	}
+	line(0, "Certificate:")
+	line(4, "Data:")
+	line(8, "Version: %d (0x%x)", cert.Version, cert.Version-1)
+	if cert.SerialNumber.IsInt64() && cert.SerialNumber.Sign() >= 0 {
+		line(8, "Serial Number: %d (0x%x)", cert.SerialNumber, cert.SerialNumber)
+	} else {
+		line(8, "Serial Number:")
+		line(12, "%s", hexBytes(serialBytes(cert), false))
+	}
+	line(8, "Signature Algorithm: %s", signatureAlgorithmName(cert.SignatureAlgorithm))
+	line(8, "Issuer: %s", distinguishedName(cert.RawIssuer))
+	line(8, "Validity")
+	line(12, "Not Before: %s", cert.NotBefore.UTC().Format(openSSLTime))
+	line(12, "Not After : %s", cert.NotAfter.UTC().Format(openSSLTime))
+	line(8, "Subject: %s", distinguishedName(cert.RawSubject))
+	line(8, "Subject Public Key Info:")
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		line(12, "Public Key Algorithm: rsaEncryption")
+		line(16, "Public-Key: (%d bit)", k.N.BitLen())
+		line(16, "Exponent: %d (0x%x)", k.E, k.E)
+	case *ecdsa.PublicKey:
+		line(12, "Public Key Algorithm: id-ecPublicKey")
+		line(16, "Public-Key: (%d bit)", k.Curve.Params().BitSize)
+		line(16, "NIST CURVE: %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		line(12, "Public Key Algorithm: ED25519")
+		line(16, "ED25519 Public-Key:")
+	default:
+		line(12, "Public Key Algorithm: %s", cert.PublicKeyAlgorithm)
+	}
+	if len(cert.Extensions) > 0 {
+		line(8, "X509v3 extensions:")
+	}
+	for _, ext := range cert.Extensions {
+		oid := ext.Id.String()
+		name, ok := extensionNames[oid]
+		if !ok {
+			name = oid
+		}
+		if ext.Critical {
+			line(12, "%s: critical", name)
+		} else {
+			line(12, "%s:", name)
+		}
+		for _, v := range extensionValues(cert, oid, ext.Value) {
+			line(16, "%s", v)
+		}
+	}
+	line(4, "Signature Algorithm: %s", signatureAlgorithmName(cert.SignatureAlgorithm))
+	line(4, "Signature Value:")
+	// openssl ends every line but the last with a colon
+	for sig := cert.Signature; len(sig) > 0; {
+		n := min(len(sig), 18)
+		s := hexBytes(sig[:n], false)
+		if n < len(sig) {
+			s += ":"
+		}
+		line(8, "%s", s)
+		sig = sig[n:]
+	}
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw) // #nosec G401
+	line(0, "SHA256 Fingerprint=%s", fingerprint(sha256Sum[:]))
+	line(0, "SHA1 Fingerprint=%s", fingerprint(sha1Sum[:]))
+}
+
+// extensionValues renders the value of an extension as the lines below its
+// name. Extensions that are not decoded are shown in hex.
+func extensionValues(cert *x509.Certificate, oid string, raw []byte) []string {
+	switch oid {
+	case "2.5.29.14":
+		return []string{hexBytes(cert.SubjectKeyId, true)}
+	case "2.5.29.35":
+		return []string{hexBytes(cert.AuthorityKeyId, true)}
+	case "2.5.29.15":
+		var names []string
+		for i, name := range keyUsageNames {
+			if cert.KeyUsage&(1<<i) != 0 {
+				names = append(names, name)
+			}
+		}
+		return []string{strings.Join(names, ", ")}
+	case "2.5.29.37":
+		var names []string
+		for _, u := range cert.ExtKeyUsage {
+			if name, ok := extKeyUsageNames[u]; ok {
+				names = append(names, name)
+			}
+		}
+		for _, id := range cert.UnknownExtKeyUsage {
+			names = append(names, id.String())
+		}
+		return []string{strings.Join(names, ", ")}
+	case "2.5.29.19":
+		if !cert.IsCA {
+			return []string{"CA:FALSE"}
+		}
+		if cert.MaxPathLen > 0 || cert.MaxPathLenZero {
+			return []string{fmt.Sprintf("CA:TRUE, pathlen:%d", cert.MaxPathLen)}
+		}
+		return []string{"CA:TRUE"}
+	case "2.5.29.17":
+		var names []string
+		for _, name := range cert.DNSNames {
+			names = append(names, "DNS:"+name)
+		}
+		for _, ip := range cert.IPAddresses {
+			names = append(names, "IP Address:"+ip.String())
+		}
+		for _, email := range cert.EmailAddresses {
+			names = append(names, "email:"+email)
+		}
+		for _, uri := range cert.URIs {
+			names = append(names, "URI:"+uri.String())
+		}
+		return []string{strings.Join(names, ", ")}
+	case "1.3.6.1.5.5.7.1.1":
+		var lines []string
+		for _, u := range cert.OCSPServer {
+			lines = append(lines, "OCSP - URI:"+u)
+		}
+		for _, u := range cert.IssuingCertificateURL {
+			lines = append(lines, "CA Issuers - URI:"+u)
+		}
+		return lines
+	case "2.5.29.31":
+		lines := []string{"Full Name:"}
+		for _, u := range cert.CRLDistributionPoints {
+			lines = append(lines, "  URI:"+u)
+		}
+		return lines
+	case "2.5.29.32":
+		var lines []string
+		for _, id := range cert.PolicyIdentifiers {
+			lines = append(lines, "Policy: "+id.String())
+		}
+		return lines
+	case "2.5.29.30":
+		var lines []string
+		if len(cert.PermittedDNSDomains) > 0 {
+			lines = append(lines, "Permitted:")
+			for _, d := range cert.PermittedDNSDomains {
+				lines = append(lines, "  DNS:"+d)
+			}
+		}
+		if len(cert.ExcludedDNSDomains) > 0 {
+			lines = append(lines, "Excluded:")
+			for _, d := range cert.ExcludedDNSDomains {
+				lines = append(lines, "  DNS:"+d)
+			}
+		}
+		return lines
+	case "1.3.6.1.4.1.11129.2.4.2":
+		return []string{fmt.Sprintf("Signed Certificate Timestamp list (%d bytes, not decoded)", len(raw))}
+	case "1.3.6.1.4.1.11129.2.4.3":
+		return []string{"NULL"}
+	}
+	return []string{hexBytes(raw, false)}
+}
+
+// distinguishedName renders a raw name as openssl does, keeping the order of
+// the attributes in the cert, e.g. "C = US, O = Example, CN = example.com".
+func distinguishedName(raw []byte) string {
+	var seq pkix.RDNSequence
+	if _, err := asn1.Unmarshal(raw, &seq); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, rdn := range seq {
+		for _, atv := range rdn {
+			name, ok := attributeNames[atv.Type.String()]
+			if !ok {
+				name = atv.Type.String()
+			}
+			parts = append(parts, fmt.Sprintf("%s = %v", name, atv.Value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func signatureAlgorithmName(alg x509.SignatureAlgorithm) string {
+	if name, ok := signatureAlgorithmNames[alg]; ok {
+		return name
+	}
+	return alg.String()
+}
+
+// serialBytes returns the serial number with a leading zero byte when the high
+// bit is set, as encoded in the cert.
+func serialBytes(cert *x509.Certificate) []byte {
+	b := cert.SerialNumber.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+func hexBytes(b []byte, upper bool) string {
+	s := strings.ReplaceAll(fmt.Sprintf("% x", b), " ", ":")
+	if upper {
+		return strings.ToUpper(s)
+	}
+	return s
+}
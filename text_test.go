@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_toText(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := new(big.Int).SetString("8f3a0000000000000000000000000001", 16)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Country: []string{"JP"}, Organization: []string{"Example"}, CommonName: "example.com"},
+		NotBefore:             time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		NotAfter:              time.Date(2025, 4, 2, 3, 4, 5, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"example.com", "www.example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("192.0.2.1")},
+		OCSPServer:            []string{"http://ocsp.example.com"},
+		IssuingCertificateURL: []string{"http://ca.example.com/ca.crt"},
+		CRLDistributionPoints: []string{"http://crl.example.com/ca.crl"},
+		PolicyIdentifiers:     []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := []*certInfo{
+		{DomainName: "example.com", AccessPort: "443", chain: []*x509.Certificate{cert}},
+		{DomainName: "restored.example.com", AccessPort: "443"},
+	}
+	w := &bytes.Buffer{}
+	if err := toText(infos, w); err != nil {
+		t.Fatal(err)
+	}
+	got := w.String()
+	for _, want := range []string{
+		"# example.com:443\nCertificate:\n    Data:\n        Version: 3 (0x2)\n",
+		"        Serial Number:\n            00:8f:3a:00:00:00:00:00:00:00:00:00:00:00:00:00:01\n",
+		"        Signature Algorithm: ecdsa-with-SHA256\n",
+		"        Issuer: C = JP, O = Example, CN = example.com\n",
+		"            Not Before: Jan  2 03:04:05 2025 UTC\n",
+		"            Not After : Apr  2 03:04:05 2025 UTC\n",
+		"            Public Key Algorithm: id-ecPublicKey\n                Public-Key: (256 bit)\n                NIST CURVE: P-256\n",
+		"            X509v3 Key Usage: critical\n                Digital Signature, Key Encipherment\n",
+		"            X509v3 Extended Key Usage:\n                TLS Web Server Authentication, TLS Web Client Authentication\n",
+		"            X509v3 Basic Constraints: critical\n                CA:FALSE\n",
+		"            X509v3 Subject Alternative Name:\n                DNS:example.com, DNS:www.example.com, IP Address:192.0.2.1\n",
+		"            Authority Information Access:\n                OCSP - URI:http://ocsp.example.com\n                CA Issuers - URI:http://ca.example.com/ca.crt\n",
+		"            X509v3 CRL Distribution Points:\n                Full Name:\n                  URI:http://crl.example.com/ca.crl\n",
+		"            X509v3 Certificate Policies:\n                Policy: 2.23.140.1.2.1\n",
+		"    Signature Algorithm: ecdsa-with-SHA256\n    Signature Value:\n",
+		"SHA256 Fingerprint=",
+		"\n\n# restored.example.com:443\n(no cert to decode)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("toText() does not contain %q\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func Test_writeCertText_serial(t *testing.T) {
+	tests := []struct {
+		serial int64
+		want   string
+	}{
+		{serial: 4660, want: "Serial Number: 4660 (0x1234)\n"},
+		{serial: 1, want: "Serial Number: 1 (0x1)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			var sb strings.Builder
+			writeCertText(&sb, &x509.Certificate{Version: 3, SerialNumber: big.NewInt(tt.serial)})
+			if !strings.Contains(sb.String(), tt.want) {
+				t.Errorf("writeCertText() = %q, want %q", sb.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+const (
+	exitWarning  = 1
+	exitCritical = 2
+)
+
+// days below which DaysLeft is highlighted in workbooks and colored tables
+// when the warning or critical days are not given
+const (
+	highlightWarnDays = 30
+	highlightCritDays = 7
+)
+
+// thresholdError reports certs expiring within a threshold. It carries the
+// exit code for monitoring: 1 for warning and 2 for critical.
+type thresholdError struct {
+	code  int
+	count int
+	days  int
+}
+
+func (e *thresholdError) Error() string {
+	level := "warning"
+	if e.code == exitCritical {
+		level = "critical"
+	}
+	return fmt.Sprintf("%s: %d cert(s) expire within %d days", level, e.count, e.days)
+}
+
+func (e *thresholdError) ExitCode() int {
+	return e.code
+}
+
+// checkThresholds returns a thresholdError if any cert has fewer days left
+// than crit or warn. A threshold below zero is disabled.
+func checkThresholds(infos []*certInfo, warn, crit int) error {
+	for _, t := range []struct{ code, days int }{{exitCritical, crit}, {exitWarning, warn}} {
+		if t.days < 0 {
+			continue
+		}
+		count := 0
+		for _, info := range infos {
+			// a host that cannot be checked has no days left to count
+			if info.Error == nil && info.DaysLeft < t.days {
+				count++
+			}
+		}
+		if count > 0 {
+			return &thresholdError{code: t.code, count: count, days: t.days}
+		}
+	}
+	return nil
+}
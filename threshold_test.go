@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_checkThresholds(t *testing.T) {
+	infos := []*certInfo{
+		{DomainName: "a.example", DaysLeft: 40},
+		{DomainName: "b.example", DaysLeft: 20},
+		{DomainName: "c.example", DaysLeft: 5},
+		{DomainName: "d.example", Error: &checkError{Category: categoryRefused}},
+	}
+	tests := []struct {
+		name      string
+		warn      int
+		crit      int
+		wantCode  int
+		wantCount int
+	}{
+		{
+			name:     "disabled",
+			warn:     -1,
+			crit:     -1,
+			wantCode: 0,
+		},
+		{
+			name:     "all above",
+			warn:     5,
+			crit:     1,
+			wantCode: 0,
+		},
+		{
+			name:      "warning",
+			warn:      30,
+			crit:      5,
+			wantCode:  exitWarning,
+			wantCount: 2,
+		},
+		{
+			name:      "critical",
+			warn:      30,
+			crit:      7,
+			wantCode:  exitCritical,
+			wantCount: 1,
+		},
+		{
+			name:      "critical only",
+			warn:      -1,
+			crit:      30,
+			wantCode:  exitCritical,
+			wantCount: 2,
+		},
+		{
+			name:      "warning only",
+			warn:      60,
+			crit:      -1,
+			wantCode:  exitWarning,
+			wantCount: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkThresholds(infos, tt.warn, tt.crit)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("checkThresholds() error = %v, want nil", err)
+				}
+				return
+			}
+			var te *thresholdError
+			if !errors.As(err, &te) {
+				t.Fatalf("checkThresholds() error = %v, want thresholdError", err)
+			}
+			if te.ExitCode() != tt.wantCode || te.count != tt.wantCount {
+				t.Errorf("checkThresholds() = code %d count %d, want code %d count %d", te.ExitCode(), te.count, tt.wantCode, tt.wantCount)
+			}
+		})
+	}
+}
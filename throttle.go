@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	defaultThrottleRetries = 0
+	defaultThrottleBackoff = 2 * time.Second
+
+	// smtpServiceNotAvailable is the reply of mail servers that turn away a
+	// client over their connection limit.
+	smtpServiceNotAvailable = 421
+)
+
+// throttle retries handshakes that a host explicitly refused as throttled, waiting backoff before the first retry and twice as long
+// before each next one. A nil throttle retries nothing.
+type throttle struct {
+	retries int
+	backoff time.Duration
+}
+
+func newThrottle(retries int, backoff time.Duration) (*throttle, error) {
+	if retries < 0 {
+		return nil, errors.New("invalid throttle retries: must not be negative")
+	}
+	if backoff <= 0 {
+		return nil, errors.New("invalid throttle backoff: must be greater than 0")
+	}
+	if retries == 0 {
+		return nil, nil
+	}
+	return &throttle{retries: retries, backoff: backoff}, nil
+}
+
+// throttledError is the failure of a host that kept refusing the handshake
+// like a throttling endpoint rather than being down.
+type throttledError struct {
+	attempts int
+	err      error
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("throttled after %d attempt(s): %v", e.attempts, e.err)
+}
+
+func (e *throttledError) Unwrap() error {
+	return e.err
+}
+
+// do runs fn until it succeeds, fails other than by throttling, or runs out
+// of retries. It reports whether any attempt was throttled.
+func (t *throttle) do(ctx context.Context, addr string, fn func() error) (bool, error) {
+	err := fn()
+	if !isThrottled(err) {
+		return false, err
+	}
+	if t == nil {
+		return true, &throttledError{attempts: 1, err: err}
+	}
+	backoff := t.backoff
+	for i := 1; i <= t.retries; i++ {
+		log.Warn("handshake refused, likely throttled", "host", addr, "retry", backoff, "err", err)
+		if err := sleep(ctx, backoff); err != nil {
+			return true, err
+		}
+		backoff *= 2
+		err = fn()
+		if !isThrottled(err) {
+			return true, err
+		}
+	}
+	return true, &throttledError{attempts: t.retries + 1, err: err}
+}
+
+// isThrottled reports whether err carries an explicit throttling signal: a
+// proxy answering 429, a mail server replying 421, or a server saying it has
+// too many connections. A reset or a close before the handshake completes is
+// not one, as hosts that are down or misconfigured fail the same way.
+func isThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	var tunnelErr *tunnelError
+	if errors.As(err, &tunnelErr) && tunnelErr.code == http.StatusTooManyRequests {
+		return true
+	}
+	var replyErr *textproto.Error
+	if errors.As(err, &replyErr) && replyErr.Code == smtpServiceNotAvailable {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "too many connections")
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_isThrottled(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "proxy too many requests", err: fmt.Errorf("via proxy: %w", &tunnelError{code: 429, status: "429 Too Many Requests"}), want: true},
+		{name: "proxy forbidden", err: &tunnelError{code: 403, status: "403 Forbidden"}, want: false},
+		{name: "smtp service not available", err: fmt.Errorf("smtp greeting: %w", &textproto.Error{Code: 421, Msg: "4.7.0 Try again later"}), want: true},
+		{name: "smtp rejected", err: &textproto.Error{Code: 554, Msg: "5.7.1 Rejected"}, want: false},
+		{name: "too many connections", err: errors.New("mysql handshake: Too many connections"), want: true},
+		{name: "reset", err: fmt.Errorf("cannot connect: %w", &net.OpError{Op: "read", Err: syscall.ECONNRESET}), want: false},
+		{name: "eof", err: fmt.Errorf("cannot connect: %w", io.EOF), want: false},
+		{name: "internal error alert", err: &net.OpError{Op: "remote error", Err: tls.AlertError(80)}, want: false},
+		{name: "refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: false},
+		{name: "timeout", err: context.DeadlineExceeded, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isThrottled(tt.err); got != tt.want {
+				t.Errorf("isThrottled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_throttle_do(t *testing.T) {
+	reset := &textproto.Error{Code: 421, Msg: "4.7.0 Too many connections"}
+	refused := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name          string
+		throttle      *throttle
+		errs          []error
+		wantThrottled bool
+		wantCalls     int
+		wantAttempts  int
+		wantErr       error
+	}{
+		{
+			name:      "ok",
+			throttle:  &throttle{retries: 2, backoff: time.Millisecond},
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "outage",
+			throttle:  &throttle{retries: 2, backoff: time.Millisecond},
+			errs:      []error{refused},
+			wantCalls: 1,
+			wantErr:   refused,
+		},
+		{
+			name:          "recovered",
+			throttle:      &throttle{retries: 2, backoff: time.Millisecond},
+			errs:          []error{reset, reset, nil},
+			wantThrottled: true,
+			wantCalls:     3,
+		},
+		{
+			name:          "down after throttling",
+			throttle:      &throttle{retries: 2, backoff: time.Millisecond},
+			errs:          []error{reset, refused},
+			wantThrottled: true,
+			wantCalls:     2,
+			wantErr:       refused,
+		},
+		{
+			name:          "still throttled",
+			throttle:      &throttle{retries: 2, backoff: time.Millisecond},
+			errs:          []error{reset, reset, reset},
+			wantThrottled: true,
+			wantCalls:     3,
+			wantAttempts:  3,
+			wantErr:       reset,
+		},
+		{
+			name:          "no retries",
+			throttle:      nil,
+			errs:          []error{reset},
+			wantThrottled: true,
+			wantCalls:     1,
+			wantAttempts:  1,
+			wantErr:       reset,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			throttled, err := tt.throttle.do(context.Background(), "example.com:443", func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if throttled != tt.wantThrottled {
+				t.Errorf("do() throttled = %v, want %v", throttled, tt.wantThrottled)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("do() error = %v, want %v", err, tt.wantErr)
+			}
+			var te *throttledError
+			if errors.As(err, &te) != (tt.wantAttempts > 0) || te != nil && te.attempts != tt.wantAttempts {
+				t.Errorf("do() error = %v, want %d attempts", err, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func Test_throttle_do_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	th := &throttle{retries: 2, backoff: time.Hour}
+	_, err := th.do(ctx, "example.com:443", func() error {
+		return &tunnelError{code: 429, status: "429 Too Many Requests"}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("do() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_newThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		backoff time.Duration
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "default", retries: defaultThrottleRetries, backoff: defaultThrottleBackoff, wantNil: true},
+		{name: "enabled", retries: 2, backoff: time.Second},
+		{name: "negative retries", retries: -1, backoff: time.Second, wantErr: true},
+		{name: "zero backoff", retries: 1, backoff: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newThrottle(tt.retries, tt.backoff)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newThrottle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got == nil) != tt.wantNil {
+				t.Errorf("newThrottle() = %v", got)
+			}
+		})
+	}
+}
+
+func Test_getCert_throttled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	config := newTestTLSConfig(t)
+	go func() {
+		// the first connection is turned away as a mail server over its
+		// connection limit does
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				_ = serveScript(conn, []string{"S: 421 4.7.0 Too many connections"})
+				conn.Close()
+				continue
+			}
+			if err := serveScript(conn, smtpScript); err != nil {
+				conn.Close()
+				continue
+			}
+			tlsConn := tls.Server(conn, config)
+			_ = tlsConn.Handshake()
+			_, _ = tlsConn.Read(make([]byte, 1))
+			conn.Close()
+		}
+	}()
+	addr := ln.Addr().String()
+	connCache.delete(addr)
+	defer connCache.delete(addr)
+	opts := &options{
+		timeout:  5 * time.Second,
+		insecure: true,
+		location: time.UTC,
+		starttls: "smtp",
+		throttle: &throttle{retries: 1, backoff: time.Millisecond},
+	}
+	info, err := getCert(context.Background(), addr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Throttled {
+		t.Error("getCert() Throttled = false, want true")
+	}
+}
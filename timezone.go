@@ -0,0 +1,81 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xrash/smetrics"
+)
+
+// maxZoneSuggestions is how many close zone names are suggested at most.
+const maxZoneSuggestions = 3
+
+// loadLocation loads the time zone of name, suggesting the closest valid
+// names when it is unknown, e.g. when misspelled or given without its area.
+func loadLocation(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err == nil {
+		return loc, nil
+	}
+	msg := fmt.Sprintf("cannot load timezone %q", name)
+	if s := suggestZones(name, timeZoneNames()); len(s) > 0 {
+		msg += fmt.Sprintf(": did you mean %s?", strings.Join(s, ", "))
+	}
+	return nil, fmt.Errorf("%s (run \"%s timezones\" to list the valid names)", msg, appName)
+}
+
+// suggestZones returns the names in zones closest to name, best first. A name
+// is compared both as a whole and by its city alone, regardless of case and
+// with spaces taken as underscores, so that "tokyo" finds Asia/Tokyo.
+func suggestZones(name string, zones []string) []string {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+	if name == "" {
+		return nil
+	}
+	type match struct {
+		zone  string
+		score float64
+	}
+	var matches []match
+	for _, zone := range zones {
+		z := strings.ToLower(zone)
+		score := max(
+			smetrics.JaroWinkler(name, z, 0.7, 4),
+			smetrics.JaroWinkler(name, path.Base(z), 0.7, 4),
+		)
+		if score >= 0.85 {
+			matches = append(matches, match{zone: zone, score: score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int {
+		return cmp.Compare(b.score, a.score)
+	})
+	var s []string
+	for _, m := range matches[:min(len(matches), maxZoneSuggestions)] {
+		s = append(s, m.zone)
+	}
+	return s
+}
+
+// filterZones returns the names in zones that contain any of patterns,
+// regardless of case, or all of them without a pattern.
+func filterZones(zones []string, patterns []string) []string {
+	if len(patterns) == 0 {
+		return zones
+	}
+	var s []string
+	for _, zone := range zones {
+		z := strings.ToLower(zone)
+		for _, p := range patterns {
+			if strings.Contains(z, strings.ToLower(p)) {
+				s = append(s, zone)
+				break
+			}
+		}
+	}
+	return s
+}
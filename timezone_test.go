@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var testZones = []string{"America/New_York", "Asia/Omsk", "Asia/Tokyo", "Asia/Tomsk", "Europe/London", "Local", "UTC"}
+
+func Test_suggestZones(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "typo", input: "Asia/Tokio", want: []string{"Asia/Tokyo", "Asia/Tomsk", "Asia/Omsk"}},
+		{name: "city only", input: "tokyo", want: []string{"Asia/Tokyo"}},
+		{name: "space", input: "New York", want: []string{"America/New_York"}},
+		{name: "lower case", input: "europe/london", want: []string{"Europe/London"}},
+		{name: "abbreviation", input: "JST", want: nil},
+		{name: "empty", input: " ", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, suggestZones(tt.input, testZones)); diff != "" {
+				t.Errorf("suggestZones() (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_filterZones(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{name: "all", patterns: nil, want: testZones},
+		{name: "area", patterns: []string{"asia/"}, want: []string{"Asia/Omsk", "Asia/Tokyo", "Asia/Tomsk"}},
+		{name: "any", patterns: []string{"york", "LONDON"}, want: []string{"America/New_York", "Europe/London"}},
+		{name: "none", patterns: []string{"mars"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, filterZones(testZones, tt.patterns)); diff != "" {
+				t.Errorf("filterZones() (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_loadLocation(t *testing.T) {
+	loc, err := loadLocation("UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.String() != "UTC" {
+		t.Errorf("loadLocation() = %v, want UTC", loc)
+	}
+	_, err = loadLocation("Nowhere/Zone")
+	if err == nil || !strings.Contains(err.Error(), appName+" timezones") {
+		t.Errorf("loadLocation() error = %v, want a pointer to the timezones command", err)
+	}
+}
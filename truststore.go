@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// trustStoreFiles and trustStoreDirs are where crypto/x509 looks for the
+// system roots on Linux, in the same order.
+var (
+	trustStoreFiles = []string{
+		"/etc/ssl/certs/ca-certificates.crt",
+		"/etc/pki/tls/certs/ca-bundle.crt",
+		"/etc/ssl/ca-bundle.pem",
+		"/etc/pki/tls/cacert.pem",
+		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		"/etc/ssl/cert.pem",
+	}
+	trustStoreDirs = []string{
+		"/etc/ssl/certs",
+		"/etc/pki/tls/certs",
+	}
+)
+
+// trustStore is the bundle of roots that certs are verified against, dated by
+// when it was last written, which on most systems is when the CA certificates
+// package was last updated.
+type trustStore struct {
+	Path     string
+	Modified time.Time
+}
+
+// findTrustStore locates the roots crypto/x509 loads, honoring SSL_CERT_FILE
+// and SSL_CERT_DIR. Where the OS verifies certs itself, as on macOS and
+// Windows, there is no file to date and an error is returned.
+func findTrustStore() (*trustStore, error) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("trust store is managed by %s", runtime.GOOS)
+	}
+	files, dirs := trustStoreFiles, trustStoreDirs
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		files = []string{f}
+	}
+	if d := os.Getenv("SSL_CERT_DIR"); d != "" {
+		dirs = filepath.SplitList(d)
+	}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err == nil && info.Mode().IsRegular() {
+			return &trustStore{Path: f, Modified: info.ModTime()}, nil
+		}
+	}
+	for _, d := range dirs {
+		modified, err := newestFile(d)
+		if err == nil {
+			return &trustStore{Path: d, Modified: modified}, nil
+		}
+	}
+	return nil, errors.New("no trust store found")
+}
+
+// newestFile returns the latest modification time of the certs in dir.
+func newestFile(dir string) (time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest time.Time
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, e.Name()))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	if newest.IsZero() {
+		return time.Time{}, fmt.Errorf("no certs in %s", dir)
+	}
+	return newest, nil
+}
+
+// age returns the whole days since the trust store was written.
+func (ts *trustStore) age(now time.Time) int {
+	return int(now.Sub(ts.Modified).Hours() / 24)
+}
+
+// checkTrustStore reports the trust store in use and warns when it was written
+// more than maxAge days ago, since roots missing from an outdated store fail
+// verification in ways that look like a problem of the server. A negative
+// maxAge only logs the store at debug level.
+func checkTrustStore(now time.Time, maxAge int) {
+	ts, err := findTrustStore()
+	if err != nil {
+		log.Debug("cannot date the trust store", "reason", err)
+		return
+	}
+	age := ts.age(now)
+	report := log.Debug
+	if maxAge >= 0 {
+		report = log.Info
+	}
+	report("trust store", "path", ts.Path, "modified", ts.Modified.Format(time.RFC3339), "days", age)
+	if maxAge >= 0 && age > maxAge {
+		log.Warn("trust store is outdated, certs from newer roots may fail verification", "path", ts.Path, "days", age, "max", maxAge)
+	}
+}
+
+// loadRoots returns base, or the system roots when nil, with the CA certs of
+// file and of the files in dir added, so that certs issued by an internal CA
+// are verified without resorting to insecure. It returns base as is when
+// neither is given, which is nil to use the system roots alone. Files in dir
+// without a cert are skipped.
+func loadRoots(base *x509.CertPool, file, dir string) (*x509.CertPool, error) {
+	if file == "" && dir == "" {
+		return base, nil
+	}
+	roots := base
+	if roots == nil {
+		var err error
+		roots, err = x509.SystemCertPool()
+		if err != nil {
+			log.Debug("cannot load the system roots", "reason", err)
+			roots = x509.NewCertPool()
+		}
+	}
+	var n int
+	if file != "" {
+		certs, err := readCAFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range certs {
+			roots.AddCert(cert)
+		}
+		n += len(certs)
+	}
+	if dir != "" {
+		certs, err := readCAPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range certs {
+			roots.AddCert(cert)
+		}
+		n += len(certs)
+	}
+	log.Info("verifying with additional roots", "certs", n)
+	return roots, nil
+}
+
+// readCAFile returns the CA certs of a PEM bundle.
+func readCAFile(file string) ([]*x509.Certificate, error) {
+	b, err := os.ReadFile(file) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA file: %w", err)
+	}
+	certs, err := parsePEMChain(b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA file %s: %w", file, err)
+	}
+	return certs, nil
+}
+
+// readCAPath returns the CA certs of the PEM files in dir, skipping those
+// without a cert.
+func readCAPath(dir string) ([]*x509.Certificate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA path: %w", err)
+	}
+	var res []*x509.Certificate
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		b, err := os.ReadFile(path) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA path: %w", err)
+		}
+		certs, err := parsePEMChain(b)
+		if err != nil {
+			log.Debug("skipping file without CA cert", "path", path, "reason", err)
+			continue
+		}
+		res = append(res, certs...)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("cannot read CA path: no certs in %s", dir)
+	}
+	return res, nil
+}
+
+// systemStore is the name of the trust store of the system roots.
+const systemStore = "system"
+
+// namedRoots are roots that chains are verified against under a name, such as
+// those of a client trusting other roots than the system.
+type namedRoots struct {
+	name string
+	// roots are the certs of the store, nil for the system roots
+	roots *x509.CertPool
+}
+
+// loadTrustStores returns the stores of specs, each given as name=path to a
+// PEM bundle or to a directory of PEM files, or as system for the system
+// roots. Unlike --ca-file, a store holds its own certs alone, so that a chain
+// is verified as by a client that trusts none of the system roots.
+func loadTrustStores(specs []string) ([]namedRoots, error) {
+	var stores []namedRoots
+	for _, spec := range specs {
+		name, path, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if slices.ContainsFunc(stores, func(s namedRoots) bool { return s.name == name }) {
+			return nil, fmt.Errorf("invalid trust store %q: duplicate name", spec)
+		}
+		if !ok {
+			if name != systemStore {
+				return nil, fmt.Errorf("invalid trust store %q: want name=path or %s", spec, systemStore)
+			}
+			stores = append(stores, namedRoots{name: name})
+			continue
+		}
+		if name == "" || path == "" {
+			return nil, fmt.Errorf("invalid trust store %q: want name=path or %s", spec, systemStore)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read trust store %s: %w", name, err)
+		}
+		read := readCAFile
+		if info.IsDir() {
+			read = readCAPath
+		}
+		certs, err := read(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read trust store %s: %w", name, err)
+		}
+		roots := x509.NewCertPool()
+		for _, cert := range certs {
+			roots.AddCert(cert)
+		}
+		log.Debug("trust store", "name", name, "path", path, "certs", len(certs))
+		stores = append(stores, namedRoots{name: name, roots: roots})
+	}
+	return stores, nil
+}
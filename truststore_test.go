@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_findTrustStore(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the OS verifies certs itself")
+	}
+	dir := t.TempDir()
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	bundle := filepath.Join(dir, "bundle.pem")
+	if err := os.WriteFile(bundle, []byte("roots"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(bundle, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	certDir := filepath.Join(dir, "certs")
+	if err := os.Mkdir(certDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"a.pem", "b.pem"} {
+		path := filepath.Join(certDir, name)
+		if err := os.WriteFile(path, []byte("root"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := modified.AddDate(0, 0, i)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		file    string
+		dir     string
+		want    *trustStore
+		wantErr bool
+	}{
+		{
+			name: "file",
+			file: bundle,
+			dir:  certDir,
+			want: &trustStore{Path: bundle, Modified: modified},
+		},
+		{
+			name: "newest file in dir",
+			file: filepath.Join(dir, "missing.pem"),
+			dir:  certDir,
+			want: &trustStore{Path: certDir, Modified: modified.AddDate(0, 0, 1)},
+		},
+		{
+			name:    "none",
+			file:    filepath.Join(dir, "missing.pem"),
+			dir:     filepath.Join(dir, "missing"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SSL_CERT_FILE", tt.file)
+			t.Setenv("SSL_CERT_DIR", tt.dir)
+			got, err := findTrustStore()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("findTrustStore() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Path != tt.want.Path || !got.Modified.Equal(tt.want.Modified) {
+				t.Errorf("findTrustStore() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkTrustStore(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the OS verifies certs itself")
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(bundle, []byte("roots"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().AddDate(0, 0, -400)
+	if err := os.Chtimes(bundle, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSL_CERT_FILE", bundle)
+	tests := []struct {
+		name     string
+		maxAge   int
+		wantWarn bool
+	}{
+		{
+			name:     "outdated",
+			maxAge:   365,
+			wantWarn: true,
+		},
+		{
+			name:     "fresh enough",
+			maxAge:   500,
+			wantWarn: false,
+		},
+		{
+			name:     "disabled",
+			maxAge:   -1,
+			wantWarn: false,
+		},
+	}
+	defer log.SetDefault(log.Default())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log.SetDefault(log.New(&buf))
+			checkTrustStore(time.Now(), tt.maxAge)
+			if got := strings.Contains(buf.String(), "outdated"); got != tt.wantWarn {
+				t.Errorf("warned = %v, want %v: %s", got, tt.wantWarn, buf.String())
+			}
+		})
+	}
+}
+
+func Test_loadRoots(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := os.ReadFile(filepath.Join("testdata", "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certDir := filepath.Join(dir, "certs")
+	emptyDir := filepath.Join(dir, "empty")
+	for _, d := range []string{certDir, emptyDir} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, b := range map[string][]byte{
+		filepath.Join(certDir, "internal.pem"): ca,
+		filepath.Join(certDir, "README"):       []byte("not a cert"),
+		filepath.Join(emptyDir, "README"):      []byte("not a cert"),
+	} {
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		file    string
+		dir     string
+		wantNil bool
+		wantErr bool
+	}{
+		{
+			name:    "none",
+			wantNil: true,
+			wantErr: false,
+		},
+		{
+			name:    "file",
+			file:    filepath.Join("testdata", "cert.pem"),
+			wantErr: false,
+		},
+		{
+			name:    "dir",
+			dir:     certDir,
+			wantErr: false,
+		},
+		{
+			name:    "file without cert",
+			file:    filepath.Join("testdata", "1.txt"),
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			file:    filepath.Join("testdata", "missing.pem"),
+			wantErr: true,
+		},
+		{
+			name:    "dir without cert",
+			dir:     emptyDir,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadRoots(nil, tt.file, tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadRoots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got == nil) != tt.wantNil {
+				t.Errorf("loadRoots() = %v, wantNil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func Test_loadTrustStores(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := os.ReadFile(filepath.Join("testdata", "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "internal.pem"), ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join("testdata", "cert.pem")
+	tests := []struct {
+		name    string
+		specs   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "none",
+			specs: nil,
+			want:  nil,
+		},
+		{
+			name:  "system, bundle and dir",
+			specs: []string{"system", "mozilla=" + bundle, "internal=" + dir},
+			want:  []string{"system", "mozilla", "internal"},
+		},
+		{
+			name:    "no path",
+			specs:   []string{"mozilla"},
+			wantErr: true,
+		},
+		{
+			name:    "empty path",
+			specs:   []string{"mozilla="},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate name",
+			specs:   []string{"mozilla=" + bundle, "mozilla=" + dir},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			specs:   []string{"mozilla=" + filepath.Join("testdata", "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "file without cert",
+			specs:   []string{"mozilla=" + filepath.Join("testdata", "1.txt")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadTrustStores(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadTrustStores() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, s := range got {
+				names = append(names, s.name)
+				if (s.roots == nil) != (s.name == systemStore) {
+					t.Errorf("loadTrustStores() roots of %s = %v", s.name, s.roots)
+				}
+			}
+			if diff := cmp.Diff(tt.want, names); diff != "" {
+				t.Errorf("loadTrustStores() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_getCertList_roots(t *testing.T) {
+	dir := t.TempDir()
+	caKey, key := newTestKey(t), newTestKey(t)
+	ca := issueTestCert(t, testCATemplate(1, "internal test CA", time.Now().Add(24*time.Hour)), nil, &caKey.PublicKey, caKey)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTestTLS(t, key, issueTestCert(t, testLeafTemplate(2), ca, &key.PublicKey, caKey))
+	roots, err := loadRoots(nil, caFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		roots      *x509.CertPool
+		wantStatus string
+	}{
+		{
+			name:       "internal CA",
+			roots:      roots,
+			wantStatus: verificationVerified,
+		},
+		{
+			name:       "system roots",
+			roots:      nil,
+			wantStatus: verificationFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				location: time.UTC,
+				roots:    tt.roots,
+			}
+			infos, err := getCertList(context.Background(), []string{addr}, opts)
+			if err != nil {
+				t.Fatalf("getCertList() error = %v", err)
+			}
+			if got := infos[0].VerificationStatus; got != tt.wantStatus {
+				t.Errorf("VerificationStatus = %s, want %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
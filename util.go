@@ -1,7 +1,37 @@
 package main
 
-import "strings"
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
 
 func pipeJoin(s []string) string {
 	return strings.Join(s, "|")
 }
+
+// bindContext bounds the I/O on conn by the deadline of ctx and interrupts it
+// as soon as ctx is canceled, for protocols spoken on a raw connection that
+// cannot take a context. The returned func releases conn from ctx.
+func bindContext(ctx context.Context, conn net.Conn) (release func()) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	return func() {
+		stop()
+		_ = conn.SetDeadline(time.Time{})
+	}
+}
+
+// contextErr returns the error of ctx in place of err once ctx is done, since
+// an I/O interrupted by bindContext reports a timeout instead.
+func contextErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// statuses of the verification of the chain a host serves, which tlc3 runs
+// itself so that the result is reported even with --insecure.
+const (
+	verificationVerified = "verified"
+	verificationFailed   = "failed"
+)
+
+// trust statuses of a chain, telling an internal self-signed endpoint from
+// one whose issuer is not trusted.
+const (
+	trustTrusted      = "trusted"
+	trustSelfSigned   = "self-signed"
+	trustUnknownCA    = "unknown-ca"
+	trustExpiredChain = "expired-chain"
+	trustUntrusted    = "untrusted"
+)
+
+// verification is the result of verifying a chain against the roots and its
+// leaf against the name the host was checked by.
+type verification struct {
+	status        string
+	err           string
+	hostnameMatch bool
+	trustStatus   string
+}
+
+// verifyChain verifies chain as of now against roots, or the system roots
+// when nil, and its leaf against name. The first error, of the chain or of
+// the name, fails the verification.
+func verifyChain(chain []*x509.Certificate, roots *x509.CertPool, name string, now time.Time) verification {
+	leaf := chain[0]
+	_, chainErr := leaf.Verify(verifyOptions(chain, roots, now))
+	nameErr := leaf.VerifyHostname(name)
+	v := verification{
+		status:        verificationVerified,
+		hostnameMatch: nameErr == nil,
+		trustStatus:   trustStatus(leaf, chainErr),
+	}
+	if err := cmp.Or(chainErr, nameErr); err != nil {
+		v.status = verificationFailed
+		v.err = err.Error()
+	}
+	return v
+}
+
+// verifyOptions returns the options to verify the leaf of chain as of now
+// against roots, or the system roots when nil, with the rest of chain as
+// intermediates.
+func verifyOptions(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) x509.VerifyOptions {
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   now,
+	}
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	return opts
+}
+
+// storeTrust returns the trust status of chain as of now against each of
+// stores, as name: status, leaving the name the host was checked by aside as
+// it does not depend on the store.
+func storeTrust(chain []*x509.Certificate, stores []namedRoots, now time.Time) []string {
+	var res []string
+	for _, s := range stores {
+		_, err := chain[0].Verify(verifyOptions(chain, s.roots, now))
+		res = append(res, s.name+": "+trustStatus(chain[0], err))
+	}
+	return res
+}
+
+// trustStatus tells why chainErr, if any, leaves leaf untrusted. A
+// self-signed leaf is labeled as such even when it has expired as well.
+func trustStatus(leaf *x509.Certificate, chainErr error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		invalid          x509.CertificateInvalidError
+	)
+	switch {
+	case chainErr == nil:
+		return trustTrusted
+	case isSelfSigned(leaf):
+		return trustSelfSigned
+	case errors.As(chainErr, &invalid) && invalid.Reason == x509.Expired:
+		return trustExpiredChain
+	case errors.As(chainErr, &unknownAuthority):
+		return trustUnknownCA
+	default:
+		return trustUntrusted
+	}
+}
+
+// isSelfSigned reports whether cert is issued by and signed with its own key.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// unverified reports whether the chain of info failed a verification that
+// was expected to pass, which is not when checked with --insecure.
+func unverified(info *certInfo) bool {
+	return info.VerificationStatus == verificationFailed && !info.insecure
+}
+
+// unverifiedCount returns the number of infos whose chain does not verify.
+func unverifiedCount(infos []*certInfo) int {
+	count := 0
+	for _, info := range infos {
+		if unverified(info) {
+			count++
+		}
+	}
+	return count
+}
+
+// verificationError fails a check with hosts whose chain does not verify,
+// which are reported along with the others. It exits as critical, as for a
+// Nagios plugin.
+type verificationError struct {
+	count int
+}
+
+func (e *verificationError) Error() string {
+	return fmt.Sprintf("critical: %d host(s) fail verification", e.count)
+}
+
+func (e *verificationError) ExitCode() int {
+	return exitCritical
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_verifyChain(t *testing.T) {
+	now := time.Now()
+	caKey, key := newTestKey(t), newTestKey(t)
+	ca := issueTestCert(t, testCATemplate(1, "verify test CA", now.Add(48*time.Hour)), nil, &caKey.PublicKey, caKey)
+	leaf := issueTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"www.example.com"},
+	}, ca, &key.PublicKey, caKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	if !isSelfSigned(ca) || isSelfSigned(leaf) {
+		t.Errorf("isSelfSigned() = %v for the CA, %v for the leaf", isSelfSigned(ca), isSelfSigned(leaf))
+	}
+	tests := []struct {
+		name          string
+		chain         []*x509.Certificate
+		roots         *x509.CertPool
+		host          string
+		now           time.Time
+		wantStatus    string
+		wantErr       string
+		wantHostMatch bool
+		wantTrust     string
+	}{
+		{
+			name:          "verified",
+			chain:         []*x509.Certificate{leaf, ca},
+			roots:         roots,
+			host:          "www.example.com",
+			now:           now,
+			wantStatus:    verificationVerified,
+			wantHostMatch: true,
+			wantTrust:     trustTrusted,
+		},
+		{
+			name:          "other name",
+			chain:         []*x509.Certificate{leaf, ca},
+			roots:         roots,
+			host:          "example.com",
+			now:           now,
+			wantStatus:    verificationFailed,
+			wantErr:       "not example.com",
+			wantHostMatch: false,
+			wantTrust:     trustTrusted,
+		},
+		{
+			name:          "unknown authority",
+			chain:         []*x509.Certificate{leaf, ca},
+			roots:         x509.NewCertPool(),
+			host:          "www.example.com",
+			now:           now,
+			wantStatus:    verificationFailed,
+			wantErr:       "unknown authority",
+			wantHostMatch: true,
+			wantTrust:     trustUnknownCA,
+		},
+		{
+			name:          "expired",
+			chain:         []*x509.Certificate{leaf, ca},
+			roots:         roots,
+			host:          "www.example.com",
+			now:           now.Add(36 * time.Hour),
+			wantStatus:    verificationFailed,
+			wantErr:       "expired",
+			wantHostMatch: true,
+			wantTrust:     trustExpiredChain,
+		},
+		{
+			name:          "self-signed",
+			chain:         []*x509.Certificate{ca},
+			roots:         x509.NewCertPool(),
+			host:          "verify test CA",
+			now:           now,
+			wantStatus:    verificationFailed,
+			wantErr:       "unknown authority",
+			wantHostMatch: false,
+			wantTrust:     trustSelfSigned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := verifyChain(tt.chain, tt.roots, tt.host, tt.now)
+			if got.status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.status, tt.wantStatus)
+			}
+			if (got.err == "") != (tt.wantErr == "") || !strings.Contains(got.err, tt.wantErr) {
+				t.Errorf("err = %q, want %q", got.err, tt.wantErr)
+			}
+			if got.hostnameMatch != tt.wantHostMatch {
+				t.Errorf("hostnameMatch = %v, want %v", got.hostnameMatch, tt.wantHostMatch)
+			}
+			if got.trustStatus != tt.wantTrust {
+				t.Errorf("trustStatus = %s, want %s", got.trustStatus, tt.wantTrust)
+			}
+		})
+	}
+}
+
+func Test_storeTrust(t *testing.T) {
+	tc := newTestChain(t)
+	tests := []struct {
+		name   string
+		chain  []*x509.Certificate
+		stores []namedRoots
+		now    time.Time
+		want   []string
+	}{
+		{
+			name:   "none",
+			chain:  []*x509.Certificate{tc.leaf, tc.intermediate},
+			stores: nil,
+			now:    time.Now(),
+			want:   nil,
+		},
+		{
+			name:  "per store",
+			chain: []*x509.Certificate{tc.leaf, tc.intermediate},
+			stores: []namedRoots{
+				{name: "internal", roots: tc.roots},
+				{name: "android", roots: x509.NewCertPool()},
+			},
+			now:  time.Now(),
+			want: []string{"internal: trusted", "android: unknown-ca"},
+		},
+		{
+			name:   "expired",
+			chain:  []*x509.Certificate{tc.leaf, tc.intermediate},
+			stores: []namedRoots{{name: "internal", roots: tc.roots}},
+			now:    tc.root.NotAfter.Add(time.Hour),
+			want:   []string{"internal: expired-chain"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := storeTrust(tt.chain, tt.stores, tt.now)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("storeTrust() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_getCertList_unverified(t *testing.T) {
+	key := newTestKey(t)
+	tmpl := testLeafTemplate(1)
+	tmpl.BasicConstraintsValid = true
+	tmpl.IsCA = true
+	addr := serveTestTLS(t, key, issueTestCert(t, tmpl, nil, &key.PublicKey, key))
+	tests := []struct {
+		name           string
+		insecure       bool
+		wantUnverified bool
+	}{
+		{
+			name:           "verified",
+			insecure:       false,
+			wantUnverified: true,
+		},
+		{
+			name:           "insecure",
+			insecure:       true,
+			wantUnverified: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connCache.purge()
+			opts := &options{
+				timeout:  5 * time.Second,
+				insecure: tt.insecure,
+				location: time.UTC,
+			}
+			infos, err := getCertList(context.Background(), []string{addr}, opts)
+			if err != nil {
+				t.Fatalf("getCertList() error = %v, want nil", err)
+			}
+			info := infos[0]
+			if info.VerificationStatus != verificationFailed {
+				t.Errorf("VerificationStatus = %s, want %s", info.VerificationStatus, verificationFailed)
+			}
+			if info.TrustStatus != trustSelfSigned || !info.IsSelfSigned {
+				t.Errorf("TrustStatus = %s, IsSelfSigned = %v, want %s, true", info.TrustStatus, info.IsSelfSigned, trustSelfSigned)
+			}
+			if info.HostnameMatch == nil || !*info.HostnameMatch {
+				t.Errorf("HostnameMatch = %v, want true", info.HostnameMatch)
+			}
+			if got := unverifiedCount(infos) > 0; got != tt.wantUnverified {
+				t.Errorf("unverified = %v, want %v", got, tt.wantUnverified)
+			}
+		})
+	}
+}
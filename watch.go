@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// watch runs check every interval until ctx is done and writes each result.
+// A failed check is logged and retried at the next interval rather than
+// ending the process. With changesOnly set, only the certs that differ from
+// the previous check are written, and nothing when none changed.
+func watch(ctx context.Context, interval time.Duration, changesOnly bool, check func(context.Context) ([]*certInfo, error), write func([]*certInfo) error) error {
+	var prev []*certInfo
+	for {
+		// every cycle needs fresh handshakes to notice a renewed cert
+		connCache.purge()
+		infos, err := check(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			log.Error("check failed", "err", err, "retry", interval)
+		default:
+			res := infos
+			if changesOnly && prev != nil {
+				res = changedCerts(prev, infos)
+			}
+			prev = infos
+			if len(res) > 0 {
+				if err := write(res); err != nil {
+					return err
+				}
+			}
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return nil
+		}
+	}
+}
+
+// changedCerts returns the entries of cur whose host was not in prev or now
+// serves a different cert.
+func changedCerts(prev, cur []*certInfo) []*certInfo {
+	seen := make(map[string]string, len(prev))
+	for _, info := range prev {
+		seen[net.JoinHostPort(info.DomainName, info.AccessPort)] = info.FingerprintSHA256
+	}
+	var res []*certInfo
+	for _, info := range cur {
+		fp, ok := seen[net.JoinHostPort(info.DomainName, info.AccessPort)]
+		if !ok || fp != info.FingerprintSHA256 {
+			res = append(res, info)
+		}
+	}
+	return res
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_changedCerts(t *testing.T) {
+	a := &certInfo{DomainName: "a.example.com", AccessPort: "443", FingerprintSHA256: "AA"}
+	b := &certInfo{DomainName: "b.example.com", AccessPort: "443", FingerprintSHA256: "BB"}
+	renewed := &certInfo{DomainName: "b.example.com", AccessPort: "443", FingerprintSHA256: "CC"}
+	otherPort := &certInfo{DomainName: "a.example.com", AccessPort: "8443", FingerprintSHA256: "AA"}
+	tests := []struct {
+		name string
+		prev []*certInfo
+		cur  []*certInfo
+		want []*certInfo
+	}{
+		{
+			name: "unchanged",
+			prev: []*certInfo{a, b},
+			cur:  []*certInfo{a, b},
+			want: nil,
+		},
+		{
+			name: "renewed",
+			prev: []*certInfo{a, b},
+			cur:  []*certInfo{a, renewed},
+			want: []*certInfo{renewed},
+		},
+		{
+			name: "new host",
+			prev: []*certInfo{a},
+			cur:  []*certInfo{a, otherPort},
+			want: []*certInfo{otherPort},
+		},
+		{
+			name: "removed host",
+			prev: []*certInfo{a, b},
+			cur:  []*certInfo{a},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedCerts(tt.prev, tt.cur)
+			if diff := cmp.Diff(got, tt.want, cmp.AllowUnexported(certInfo{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_watch(t *testing.T) {
+	cycles := [][]*certInfo{
+		{{DomainName: "a.example.com", AccessPort: "443", FingerprintSHA256: "AA"}},
+		{{DomainName: "a.example.com", AccessPort: "443", FingerprintSHA256: "AA"}},
+		nil,
+		{{DomainName: "a.example.com", AccessPort: "443", FingerprintSHA256: "BB"}},
+	}
+	tests := []struct {
+		name        string
+		changesOnly bool
+		want        []string
+	}{
+		{
+			name:        "every cycle",
+			changesOnly: false,
+			want:        []string{"AA", "AA", "BB"},
+		},
+		{
+			name:        "changes only",
+			changesOnly: true,
+			want:        []string{"AA", "BB"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			n := 0
+			check := func(context.Context) ([]*certInfo, error) {
+				defer func() { n++ }()
+				if n == len(cycles) {
+					cancel()
+					return nil, context.Canceled
+				}
+				if cycles[n] == nil {
+					return nil, errors.New("connection refused")
+				}
+				return cycles[n], nil
+			}
+			var got []string
+			write := func(infos []*certInfo) error {
+				for _, info := range infos {
+					got = append(got, info.FingerprintSHA256)
+				}
+				return nil
+			}
+			if err := watch(ctx, time.Millisecond, tt.changesOnly, check, write); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tt.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	t.Run("write error", func(t *testing.T) {
+		check := func(context.Context) ([]*certInfo, error) {
+			return cycles[0], nil
+		}
+		write := func([]*certInfo) error {
+			return errors.New("broken pipe")
+		}
+		if err := watch(context.Background(), time.Millisecond, false, check, write); err == nil {
+			t.Error("watch() error = nil, want write error")
+		}
+	})
+}
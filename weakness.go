@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// minimum key sizes below which a key is weak, as in the CA/Browser Forum
+// Baseline Requirements.
+const (
+	minRSAKeySize   = 2048
+	minECDSAKeySize = 256
+)
+
+// weakSignatures are the signature algorithms on broken hashes.
+var weakSignatures = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// weaknesses describes the weak cryptography of cert, a key too small or a
+// signature on a broken hash, or returns nil if it has none.
+func weaknesses(cert *x509.Certificate) []string {
+	var res []string
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if n := k.N.BitLen(); n < minRSAKeySize {
+			res = append(res, fmt.Sprintf("RSA key of %d bits", n))
+		}
+	case *ecdsa.PublicKey:
+		if p := k.Curve.Params(); p.BitSize < minECDSAKeySize {
+			res = append(res, fmt.Sprintf("ECDSA key on %s", p.Name))
+		}
+	}
+	if weakSignatures[cert.SignatureAlgorithm] {
+		res = append(res, fmt.Sprintf("%s signature", cert.SignatureAlgorithm))
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_weaknesses(t *testing.T) {
+	rsaKey := func(bits int) *rsa.PublicKey {
+		return &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), uint(bits-1)), E: 65537}
+	}
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want []string
+	}{
+		{
+			name: "rsa 2048",
+			cert: &x509.Certificate{PublicKey: rsaKey(2048), SignatureAlgorithm: x509.SHA256WithRSA},
+			want: nil,
+		},
+		{
+			name: "ecdsa p256",
+			cert: &x509.Certificate{PublicKey: &ecdsa.PublicKey{Curve: elliptic.P256()}, SignatureAlgorithm: x509.ECDSAWithSHA256},
+			want: nil,
+		},
+		{
+			name: "ed25519",
+			cert: &x509.Certificate{PublicKey: ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)), SignatureAlgorithm: x509.PureEd25519},
+			want: nil,
+		},
+		{
+			name: "rsa 1024 with sha1",
+			cert: &x509.Certificate{PublicKey: rsaKey(1024), SignatureAlgorithm: x509.SHA1WithRSA},
+			want: []string{"RSA key of 1024 bits", "SHA1-RSA signature"},
+		},
+		{
+			name: "ecdsa p224",
+			cert: &x509.Certificate{PublicKey: &ecdsa.PublicKey{Curve: elliptic.P224()}, SignatureAlgorithm: x509.ECDSAWithSHA256},
+			want: []string{"ECDSA key on P-224"},
+		},
+		{
+			name: "md5",
+			cert: &x509.Certificate{PublicKey: rsaKey(4096), SignatureAlgorithm: x509.MD5WithRSA},
+			want: []string{"MD5-RSA signature"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, weaknesses(tt.cert)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var xlsxParts = map[string]string{
+	"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`,
+	"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`,
+	"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="tlc3" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`,
+	// style 1 is the bold header and style 2 the dates; dxf 0 is the
+	// critical and dxf 1 the warning fill of the conditional formatting
+	"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs><dxfs count="2"><dxf><font><color rgb="FF9C0006"/></font><fill><patternFill><bgColor rgb="FFFFC7CE"/></patternFill></fill></dxf><dxf><font><color rgb="FF9C5700"/></font><fill><patternFill><bgColor rgb="FFFFEB9C"/></patternFill></fill></dxf></dxfs></styleSheet>`,
+}
+
+// toXLSX writes a workbook with the columns of the table formats on a single
+// sheet. DaysLeft is highlighted red below the critical days and yellow below
+// the warning days, which default to 7 and 30 when not given.
+func toXLSX(infos []*certInfo, w io.Writer, omit bool, cols []string, warn, crit int) error {
+	if warn < 0 {
+		warn = highlightWarnDays
+	}
+	if crit < 0 {
+		crit = highlightCritDays
+	}
+	zw := zip.NewWriter(w)
+	names := make([]string, 0, len(xlsxParts))
+	for name := range xlsxParts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xlsxParts[name]); err != nil {
+			return err
+		}
+	}
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	// without a locale, numbers and dates are kept as values
+	input := selectColumns(toInput(infos, omit, nil), cols)
+	if _, err := io.WriteString(f, xlsxSheet(input.Header, input.Data, warn, crit)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func xlsxSheet(header []string, data [][]any, warn, crit int) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// the header row stays in view while scrolling
+	sb.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	sb.WriteString(`<sheetData>`)
+	row := make([]any, len(header))
+	for i, h := range header {
+		row[i] = h
+	}
+	writeXLSXRow(&sb, 1, row, 1)
+	for i, r := range data {
+		writeXLSXRow(&sb, i+2, r, 0)
+	}
+	sb.WriteString(`</sheetData>`)
+	last := xlsxColumn(len(header)-1) + strconv.Itoa(len(data)+1)
+	fmt.Fprintf(&sb, `<autoFilter ref="A1:%s"/>`, last)
+	if i := slices.Index(header, "DaysLeft"); i >= 0 && len(data) > 0 {
+		col := xlsxColumn(i)
+		ref := fmt.Sprintf("%s2:%s%d", col, col, len(data)+1)
+		fmt.Fprintf(&sb, `<conditionalFormatting sqref="%s">`, ref)
+		fmt.Fprintf(&sb, `<cfRule type="cellIs" dxfId="0" priority="1" operator="lessThan"><formula>%d</formula></cfRule>`, crit)
+		fmt.Fprintf(&sb, `<cfRule type="cellIs" dxfId="1" priority="2" operator="lessThan"><formula>%d</formula></cfRule>`, warn)
+		sb.WriteString(`</conditionalFormatting>`)
+	}
+	sb.WriteString(`</worksheet>`)
+	return sb.String()
+}
+
+// writeXLSXRow writes the cells of a row. Numbers and dates are written as
+// such so that they can be sorted and filtered in Excel; everything else is an
+// inline string.
+func writeXLSXRow(sb *strings.Builder, n int, values []any, style int) {
+	fmt.Fprintf(sb, `<row r="%d">`, n)
+	for i, v := range values {
+		ref := xlsxColumn(i) + strconv.Itoa(n)
+		switch v := v.(type) {
+		case int:
+			fmt.Fprintf(sb, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case time.Time:
+			fmt.Fprintf(sb, `<c r="%s" s="2"><v>%s</v></c>`, ref, strconv.FormatFloat(excelSerial(v), 'f', -1, 64))
+		case *time.Time:
+			if v == nil {
+				fmt.Fprintf(sb, `<c r="%s"/>`, ref)
+				continue
+			}
+			fmt.Fprintf(sb, `<c r="%s" s="2"><v>%s</v></c>`, ref, strconv.FormatFloat(excelSerial(*v), 'f', -1, 64))
+		default:
+			fmt.Fprintf(sb, `<c r="%s" t="inlineStr"`, ref)
+			if style > 0 {
+				fmt.Fprintf(sb, ` s="%d"`, style)
+			}
+			sb.WriteString(`><is><t xml:space="preserve">`)
+			_ = xml.EscapeText(sb, []byte(xlsxText(v)))
+			sb.WriteString(`</t></is></c>`)
+		}
+	}
+	sb.WriteString(`</row>`)
+}
+
+func xlsxText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, "\n")
+	case []net.IP:
+		s := make([]string, len(v))
+		for i, ip := range v {
+			s[i] = ip.String()
+		}
+		return strings.Join(s, "\n")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// excelSerial converts t to the serial date of Excel, the days since
+// 1899-12-30, keeping the wall clock of its location.
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
+
+// xlsxColumn returns the letters of the i-th column counted from 0.
+func xlsxColumn(i int) string {
+	var s string
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = string(rune('A'+(i-1)%26)) + s
+	}
+	return s
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_toXLSX(t *testing.T) {
+	notAfter := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	infos := []*certInfo{
+		{
+			DomainName:  "example.com",
+			AccessPort:  "443",
+			IPAddresses: []net.IP{net.ParseIP("192.0.2.1")},
+			Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+			CommonName:  "example.com",
+			SANs:        []string{"example.com", "www.example.com"},
+			NotAfter:    notAfter,
+			DaysLeft:    5,
+		},
+	}
+	tests := []struct {
+		name  string
+		omit  bool
+		warn  int
+		crit  int
+		want  []string
+		wantN []string
+	}{
+		{
+			name: "default thresholds",
+			warn: -1,
+			crit: -1,
+			want: []string{
+				`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">DomainName</t></is></c>`,
+				`<c r="A2" t="inlineStr"><is><t xml:space="preserve">example.com</t></is></c>`,
+				`<t xml:space="preserve">CN=R3,O=Let&#39;s Encrypt,C=US</t>`,
+				`<t xml:space="preserve">example.com&#xA;www.example.com</t>`,
+				`<c r="H2" s="2"><v>45717.5</v></c>`,
+				`<c r="J2"><v>5</v></c>`,
+				`<conditionalFormatting sqref="J2:J2">`,
+				`<formula>7</formula>`,
+				`<formula>30</formula>`,
+				`<autoFilter ref="A1:S2"/>`,
+			},
+		},
+		{
+			name: "given thresholds",
+			warn: 60,
+			crit: 14,
+			want: []string{
+				`<formula>14</formula>`,
+				`<formula>60</formula>`,
+			},
+		},
+		{
+			name:  "no time info",
+			omit:  true,
+			warn:  -1,
+			crit:  -1,
+			wantN: []string{"DaysLeft", "conditionalFormatting"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			if err := toXLSX(infos, w, tt.omit, nil, tt.warn, tt.crit); err != nil {
+				t.Fatal(err)
+			}
+			zr, err := zip.NewReader(bytes.NewReader(w.Bytes()), int64(w.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts := make(map[string]string)
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				parts[f.Name] = string(b)
+			}
+			for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+				if _, ok := parts[name]; !ok {
+					t.Errorf("part %s is missing", name)
+				}
+			}
+			sheet := parts["xl/worksheets/sheet1.xml"]
+			for _, want := range tt.want {
+				if !strings.Contains(sheet, want) {
+					t.Errorf("sheet does not contain %s\ngot: %s", want, sheet)
+				}
+			}
+			for _, want := range tt.wantN {
+				if strings.Contains(sheet, want) {
+					t.Errorf("sheet contains %s", want)
+				}
+			}
+		})
+	}
+}
+
+func Test_xlsxColumn(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{i: 0, want: "A"},
+		{i: 25, want: "Z"},
+		{i: 26, want: "AA"},
+		{i: 51, want: "AZ"},
+		{i: 52, want: "BA"},
+		{i: 701, want: "ZZ"},
+		{i: 702, want: "AAA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := xlsxColumn(tt.i); got != tt.want {
+				t.Errorf("xlsxColumn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_excelSerial(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	tests := []struct {
+		name string
+		t    time.Time
+		want float64
+	}{
+		{name: "after the leap day of 1900", t: time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC), want: 61},
+		{name: "noon", t: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), want: 45717.5},
+		{name: "wall clock", t: time.Date(2025, 3, 1, 12, 0, 0, 0, tokyo), want: 45717.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := excelSerial(tt.t); got != tt.want {
+				t.Errorf("excelSerial() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}